@@ -1,38 +1,136 @@
 package app
 
 import (
-	"database/sql"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/etag"
 	"github.com/gofiber/fiber/v2/middleware/logger"
+	"log"
+	"pvzService/internal/cache"
+	"pvzService/internal/clock"
 	"pvzService/internal/config"
+	"pvzService/internal/db"
 	"pvzService/internal/handlers"
+	"pvzService/internal/lifecycle"
 	"pvzService/internal/middleware"
+	"pvzService/internal/notify"
+	"pvzService/internal/oidc"
+	"pvzService/internal/openapi"
+	"pvzService/internal/permissions"
 	"pvzService/internal/processors"
 	"pvzService/internal/prometheus"
+	"pvzService/internal/ratelimit"
 	"pvzService/internal/repository"
+	"pvzService/internal/validation"
+	"time"
 )
 
-func MakeApp(database *sql.DB, cfg config.Config) *fiber.App {
+// MakeApp builds the HTTP API. Listing may read from a replica through reads, nil reads
+// everything from database. Password reset emails are sent by the worker of resetMails,
+// nil sends them before the response.
+func MakeApp(database repository.DB, reads *repository.ReadRouter, resetMails *lifecycle.Queue, cfg config.Config) *fiber.App {
+	if reads == nil {
+		reads = repository.NewReadRouter(database, nil, 0)
+	}
+	sendResetMail := func(send func()) { send() }
+	if resetMails != nil {
+		sendResetMail = resetMails.Submit
+	}
+
+	// A config without password settings keeps the default policy
+	if cfg.Auth.Password.MinLength > 0 {
+		validation.SetPasswordPolicy(validation.PasswordPolicy{
+			MinLength:     cfg.Auth.Password.MinLength,
+			RequireLetter: cfg.Auth.Password.RequireLetter,
+			RequireDigit:  cfg.Auth.Password.RequireDigit,
+			RequireUpper:  cfg.Auth.Password.RequireUpper,
+			RequireSymbol: cfg.Auth.Password.RequireSymbol,
+		})
+	}
+
 	// Initialize repositories
 	authRepo := repository.NewAuthRepository(database)
-	pvzRepo := repository.NewPVZRepository(database)
+	pvzRepo := repository.NewPVZRepository(database, reads)
 	receptionRepo := repository.NewReceptionRepository(database)
 	productRepo := repository.NewProductRepository(database)
+	idempotencyRepo := repository.NewIdempotencyRepository(database)
+	loginAttemptRepo := repository.NewLoginAttemptRepository(database)
+	userRepo := repository.NewUserRepository(database)
+	passwordResetRepo := repository.NewPasswordResetRepository(database)
+	identityRepo := repository.NewIdentityRepository(database)
+	permissionRepo := repository.NewPermissionRepository(database)
+	apiKeyRepo := repository.NewAPIKeyRepository(database)
+	healthRepo := repository.NewHealthRepository(database)
+
+	// Separate request budgets for authentication and for changes
+	rateLimits := newRateLimitStore(cfg, database)
+	authLimit := middleware.RateLimit(rateLimits, ratelimit.Limit{Name: "auth", Requests: cfg.HTTP.RateLimit.AuthRequests, Window: cfg.HTTP.RateLimit.AuthWindow})
+	writeLimit := middleware.RateLimit(rateLimits, ratelimit.Limit{Name: "write", Requests: cfg.HTTP.RateLimit.WriteRequests, Window: cfg.HTTP.RateLimit.WriteWindow})
+
+	// Role permissions are read from the database and cached
+	perms := permissions.NewCache(permissionRepo, cfg.Auth.PermissionsCacheTTL)
 
-	// Initialize processors
-	authProcessor := processors.NewAuthProcessor(authRepo)
-	pvzProcessor := processors.NewPVZProcessor(pvzRepo)
-	receptionProcessor := processors.NewReceptionProcessor(receptionRepo)
-	productProcessor := processors.NewProductProcessor(productRepo, receptionRepo)
+	// Initialize processors, they all take the time from one clock
+	systemClock := clock.System{}
+	authProcessor := processors.NewAuthProcessor(authRepo, loginAttemptRepo, perms, processors.LoginProtection{
+		MaxAccountAttempts: cfg.Auth.Login.MaxAttempts,
+		MaxIPAttempts:      cfg.Auth.Login.IPMaxAttempts,
+		Window:             cfg.Auth.Login.AttemptWindow,
+		LockoutDuration:    cfg.Auth.Login.LockoutDuration,
+	}, systemClock)
+	pvzProcessor := processors.NewPVZProcessor(pvzRepo, systemClock)
+	receptionProcessor := processors.NewReceptionProcessor(receptionRepo, systemClock)
+	productProcessor := processors.NewProductProcessor(productRepo, receptionRepo, cfg.Capacity.Overflow == "warn", systemClock)
+	userProcessor := processors.NewUserProcessor(userRepo)
+	resetTTL := cfg.Auth.PasswordReset.TTL
+	if resetTTL <= 0 {
+		resetTTL = time.Hour
+	}
+	apiKeyProcessor := processors.NewAPIKeyProcessor(apiKeyRepo, perms, systemClock)
+	healthProcessor := processors.NewHealthProcessor(healthRepo, db.SchemaVersion)
+	passwordProcessor := processors.NewPasswordProcessor(authRepo, passwordResetRepo, newNotifier(cfg), resetTTL, systemClock, sendResetMail)
 
 	// Initialize handlers
-	authHandlers := handlers.NewAuthHandlers(authProcessor, cfg.JWTSecret)
+	authHandlers := handlers.NewAuthHandlers(authProcessor, cfg.Auth.JWTSecret)
 	pvzHandlers := handlers.NewPVZHandlers(pvzProcessor)
 	receptionHandlers := handlers.NewReceptionHandlers(receptionProcessor)
 	productHandlers := handlers.NewProductHandlers(productProcessor)
+	// Optional cache of PVZ reads between handlers and processors, writes to a PVZ drop
+	// its cached responses
+	if cfg.Cache.Enabled {
+		// A replica used for reads may lag behind by up to its threshold plus one check
+		// interval before reads leave it
+		var staleReads time.Duration
+		if reads != nil && reads.HasReplica() {
+			staleReads = cfg.DB.ReplicaMaxLag + cfg.DB.ReplicaCheckInterval
+		}
+		pvzCache := processors.NewPVZCache(cache.NewMemoryStore(cfg.Cache.MaxEntries, systemClock), cfg.Cache.TTL, staleReads, systemClock)
+		pvzHandlers = handlers.NewPVZHandlers(processors.NewCachedPVZProcessor(pvzProcessor, pvzCache))
+		receptionHandlers = handlers.NewReceptionHandlers(processors.NewInvalidatingReceptionProcessor(receptionProcessor, pvzCache))
+		productHandlers = handlers.NewProductHandlers(processors.NewInvalidatingProductProcessor(productProcessor, pvzCache))
+	}
+	userHandlers := handlers.NewUserHandlers(userProcessor)
+	passwordHandlers := handlers.NewPasswordHandlers(passwordProcessor, cfg.Auth.JWTSecret)
+	apiKeyHandlers := handlers.NewAPIKeyHandlers(apiKeyProcessor)
+	healthHandlers := handlers.NewHealthHandlers(healthProcessor)
+	oidcClient := oidc.NewClient(oidc.Config{
+		IssuerURL:    cfg.Auth.OIDC.IssuerURL,
+		ClientID:     cfg.Auth.OIDC.ClientID,
+		ClientSecret: cfg.Auth.OIDC.ClientSecret,
+		RedirectURL:  cfg.Auth.OIDC.RedirectURL,
+		GroupsClaim:  cfg.Auth.OIDC.GroupsClaim,
+	})
+	oidcRoles := oidc.RoleMapper{EmployeeGroups: cfg.Auth.OIDC.EmployeeGroups, ModeratorGroups: cfg.Auth.OIDC.ModeratorGroups}
+	oidcProcessor := processors.NewOIDCProcessor(oidcClient, oidcRoles, authRepo, identityRepo, userRepo)
+	oidcHandlers := handlers.NewOIDCHandlers(oidcProcessor, cfg.Auth.JWTSecret)
 
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		ErrorHandler: handlers.ErrorHandler,
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
+		BodyLimit:    cfg.HTTP.BodyLimit,
+	})
 
 	app.Use(cors.New())
 	app.Use(logger.New(logger.Config{
@@ -41,27 +139,103 @@ func MakeApp(database *sql.DB, cfg config.Config) *fiber.App {
 	}))
 	app.Use(prometheus.PrometheusMiddleware())
 
-	// Health check
-	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.SendString("OK")
-	})
+	// Health checks: liveness doesn't depend on the database, readiness does.
+	// /health is kept for existing probes
+	app.Get("/livez", healthHandlers.LivenessHandler())
+	app.Get("/health", healthHandlers.LivenessHandler())
+	app.Get("/readyz", healthHandlers.ReadinessHandler())
+
+	// API documentation
+	app.Get("/openapi.json", openapi.SpecHandler())
+	app.Get("/docs", openapi.SwaggerUIHandler())
 
-	// Public Routes
-	app.Post("/dummyLogin", authHandlers.DummyLoginHandler())
-	app.Post("/register", authHandlers.RegisterHandler())
-	app.Post("/login", authHandlers.LoginHandler())
+	// Public Routes, authentication endpoints are limited per client IP
+	// Dummy login hands out tokens without credentials and is not served in prod
+	if cfg.DummyLoginEnabled() {
+		app.Post("/dummyLogin", authLimit, authHandlers.DummyLoginHandler())
+	}
+	// Anyone can register an employee, other roles require a token with the user:manage permission
+	app.Post("/register", authLimit, middleware.OptionalAuthMiddleware(cfg.Auth.JWTSecret, userRepo), authHandlers.RegisterHandler())
+	app.Post("/login", authLimit, authHandlers.LoginHandler())
+	app.Post("/password/reset-request", authLimit, passwordHandlers.RequestPasswordResetHandler())
+	app.Post("/password/reset", authLimit, passwordHandlers.ResetPasswordHandler())
+	// Login through the external identity provider, the provider is discovered on first use
+	if cfg.Auth.OIDC.IssuerURL != "" {
+		app.Get("/auth/oidc/login", authLimit, oidcHandlers.LoginHandler())
+		app.Get("/auth/oidc/callback", authLimit, oidcHandlers.CallbackHandler())
+	}
 
-	// Protected Routes
+	// Protected Routes accept a user token or an API key in the X-API-Key header
 	api := app.Group("/")
-	api.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+	api.Use(middleware.APIKeyMiddleware(apiKeyProcessor))
+	api.Use(middleware.AuthMiddleware(cfg.Auth.JWTSecret, userRepo))
 
-	// Routes configuration with role checks
-	api.Post("/pvz", middleware.CheckRole("moderator"), pvzHandlers.CreatePVZHandler())
-	api.Get("/pvz", middleware.CheckRole("employee", "moderator"), pvzHandlers.GetPVZListHandler())
-	api.Post("/receptions", middleware.CheckRole("employee"), receptionHandlers.CreateReceptionHandler())
-	api.Post("/products", middleware.CheckRole("employee"), productHandlers.AddProductHandler())
-	api.Post("/pvz/:pvzId/close_last_reception", middleware.CheckRole("employee"), receptionHandlers.CloseLastReceptionHandler())
-	api.Post("/pvz/:pvzId/delete_last_product", middleware.CheckRole("employee"), productHandlers.DeleteLastProductHandler())
+	// Creating endpoints accept an Idempotency-Key header
+	idempotency := middleware.Idempotency(idempotencyRepo, cfg.HTTP.IdempotencyTTL, cfg.HTTP.IdempotencyLease)
+
+	// Routes configuration with permission checks, city managers only see the PVZ of their city.
+	// Changes are limited per user or API key
+	api.Post("/pvz", writeLimit, middleware.RequirePermission(perms, permissions.PVZCreate), idempotency, pvzHandlers.CreatePVZHandler())
+	// Registered before /pvz/:pvzId, which would take "nearby" and "utilization" for an id
+	api.Get("/pvz/nearby", middleware.RequirePermission(perms, permissions.PVZRead), pvzHandlers.GetNearbyPVZHandler())
+	api.Get("/pvz/utilization", middleware.RequirePermission(perms, permissions.PVZRead), pvzHandlers.GetPVZUtilizationHandler())
+	// Reads of PVZ answer 304 to an If-None-Match with the ETag of an unchanged response
+	api.Get("/pvz", middleware.RequirePermission(perms, permissions.PVZRead), etag.New(), pvzHandlers.GetPVZListHandler())
+	api.Get("/pvz/:pvzId", middleware.RequirePermission(perms, permissions.PVZRead), etag.New(), pvzHandlers.GetPVZHandler())
+	api.Patch("/pvz/:pvzId", writeLimit, middleware.RequirePermission(perms, permissions.PVZUpdate), pvzHandlers.UpdatePVZHandler())
+	// Decommissioned PVZ are archived with their history and can be restored
+	api.Delete("/pvz/:pvzId", writeLimit, middleware.RequirePermission(perms, permissions.PVZArchive), pvzHandlers.ArchivePVZHandler())
+	api.Post("/pvz/:pvzId/restore", writeLimit, middleware.RequirePermission(perms, permissions.PVZArchive), pvzHandlers.RestorePVZHandler())
+	api.Post("/receptions", writeLimit, middleware.RequirePermission(perms, permissions.ReceptionCreate), idempotency, receptionHandlers.CreateReceptionHandler())
+	api.Post("/products", writeLimit, middleware.RequirePermission(perms, permissions.ProductCreate), idempotency, productHandlers.AddProductHandler())
+	api.Post("/pvz/:pvzId/close_last_reception", writeLimit, middleware.RequirePermission(perms, permissions.ReceptionClose), receptionHandlers.CloseLastReceptionHandler())
+	api.Post("/pvz/:pvzId/delete_last_product", writeLimit, middleware.RequirePermission(perms, permissions.ProductDelete), productHandlers.DeleteLastProductHandler())
+	// Issuing a product to its recipient frees its room in the PVZ
+	api.Post("/pvz/:pvzId/products/:productId/issue", writeLimit, middleware.RequirePermission(perms, permissions.ProductIssue), productHandlers.IssueProductHandler())
+
+	// User administration
+	api.Get("/users", middleware.RequirePermission(perms, permissions.UserRead), userHandlers.ListUsersHandler())
+	api.Patch("/users/:userId", writeLimit, middleware.RequirePermission(perms, permissions.UserManage), userHandlers.UpdateUserHandler())
+	api.Delete("/users/:userId", writeLimit, middleware.RequirePermission(perms, permissions.UserManage), userHandlers.DeleteUserHandler())
+
+	// API keys are managed by users only, so a key can't mint or revoke keys
+	userToken := middleware.RequireUserToken()
+	api.Post("/api-keys", writeLimit, userToken, middleware.RequirePermission(perms, permissions.APIKeyManage), idempotency, apiKeyHandlers.CreateAPIKeyHandler())
+	api.Get("/api-keys", userToken, middleware.RequirePermission(perms, permissions.APIKeyManage), apiKeyHandlers.ListAPIKeysHandler())
+	api.Delete("/api-keys/:keyId", writeLimit, userToken, middleware.RequirePermission(perms, permissions.APIKeyManage), apiKeyHandlers.RevokeAPIKeyHandler())
+
+	// Own account
+	api.Post("/me/password", writeLimit, userToken, passwordHandlers.ChangePasswordHandler())
 
 	return app
 }
+
+// newRateLimitStore picks where rate limit counters are kept. In-memory counters are
+// per instance, several instances behind a balancer need the shared Postgres store.
+func newRateLimitStore(cfg config.Config, database repository.DB) ratelimit.Store {
+	switch cfg.HTTP.RateLimit.Backend {
+	case "postgres":
+		return repository.NewRateLimitRepository(database)
+	case "memory", "":
+		return ratelimit.NewMemoryStore()
+	default:
+		log.Printf("Unknown RATE_LIMIT_BACKEND=%q, rate limits are kept in memory", cfg.HTTP.RateLimit.Backend)
+		return ratelimit.NewMemoryStore()
+	}
+}
+
+// newNotifier picks the delivery of password reset tokens. The log notifier prints
+// tokens and is meant for local development only.
+func newNotifier(cfg config.Config) notify.Notifier {
+	switch cfg.Notifier.Type {
+	case "smtp":
+		return notify.NewSMTPNotifier(cfg.Notifier.SMTP.Host, cfg.Notifier.SMTP.Port, cfg.Notifier.SMTP.Username, cfg.Notifier.SMTP.Password, cfg.Notifier.SMTP.From, cfg.Auth.PasswordReset.URL)
+	case "file":
+		return notify.NewFileNotifier(cfg.Notifier.File, cfg.Auth.PasswordReset.URL)
+	case "log", "":
+		return notify.NewLogNotifier(cfg.Auth.PasswordReset.URL)
+	default:
+		log.Printf("Unknown NOTIFIER=%q, password reset tokens are written to the log", cfg.Notifier.Type)
+		return notify.NewLogNotifier(cfg.Auth.PasswordReset.URL)
+	}
+}
@@ -1,34 +1,57 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"pvzService/cmd/app"
+	"pvzService/internal/clock"
 	"pvzService/internal/config"
 	"pvzService/internal/db"
 	grpcserver "pvzService/internal/grpc"
+	"pvzService/internal/lifecycle"
+	"pvzService/internal/models"
+	"pvzService/internal/processors"
+	"pvzService/internal/prometheus"
+	"pvzService/internal/repository"
+	"syscall"
 )
 
-func startMetricsServer() {
-	http.Handle("/metrics", promhttp.Handler())
-	go func() {
-		log.Println("Starting metrics server on :9000")
-		if err := http.ListenAndServe(":9000", nil); err != nil {
-			log.Printf("Metrics server error: %v", err)
-		}
-	}()
+// resetMailQueueSize limits the password reset emails waiting to be sent, more are
+// dropped until the queue drains.
+const resetMailQueueSize = 100
+
+// closePool adapts the pool to lifecycle closers, closing a pool can't fail.
+func closePool(pool *pgxpool.Pool) func() error {
+	return func() error {
+		pool.Close()
+		return nil
+	}
 }
 
-func startGRPCServerAsync(db *sql.DB, port string) {
-	go func() {
-		if err := grpcserver.StartGRPCServer(db, port); err != nil {
-			log.Fatalf("Failed to start gRPC server: %v", err)
-		}
-	}()
+func newMetricsServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return &http.Server{Addr: addr, Handler: mux}
+}
+
+// runConfigPrint prints the configuration with secrets masked and exits non-zero when
+// the service would refuse to start with it.
+func runConfigPrint(cfg config.Config) {
+	if err := config.Print(os.Stdout, cfg); err != nil {
+		log.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
 }
 
 func main() {
@@ -37,20 +60,140 @@ func main() {
 		log.Println("Error loading .env file")
 	}
 
-	cfg := config.LoadConfig()
+	// "config print" shows the effective configuration instead of starting the service
+	args := os.Args[1:]
+	printConfig := len(args) >= 2 && args[0] == "config" && args[1] == "print"
+	if printConfig {
+		args = args[2:]
+	}
 
-	database, err := db.InitializeDB(cfg.DBDSN)
+	cfg, err := config.Load(args)
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	if printConfig {
+		runConfigPrint(cfg)
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	var grpcTLS *tls.Config
+	if cfg.GRPC.TLSCertFile != "" {
+		grpcTLS, err = grpcserver.ServerTLSConfig(cfg.GRPC.TLSCertFile, cfg.GRPC.TLSKeyFile, cfg.GRPC.TLSClientCAFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	pool := db.Pool{
+		MaxConns:        cfg.DB.MaxOpenConns,
+		MinConns:        cfg.DB.MaxIdleConns,
+		MaxConnLifetime: cfg.DB.ConnMaxLifetime,
+		MaxConnIdleTime: cfg.DB.ConnMaxIdleTime,
+	}
+	database, err := db.InitializeDB(cfg.DB.DSN(), pool, db.Backoff{
+		Attempts: cfg.DB.ConnectAttempts,
+		Initial:  cfg.DB.ConnectBackoff,
+		Max:      cfg.DB.ConnectMaxBackoff,
+	})
 	if err != nil {
 		log.Fatal("Failed to initialize DB:", err)
 	}
-	defer database.Close()
+	prometheus.RegisterDBStats(database, "pvz")
 
-	startGRPCServerAsync(database, "3000")
+	// The replica isn't required to start, reads use the primary until it is reachable
+	var replica *pgxpool.Pool
+	reads := repository.NewReadRouter(database, nil, cfg.DB.ReplicaMaxLag)
+	if cfg.DB.ReplicaDSN != "" {
+		replica, err = db.Open(cfg.DB.ReplicaDSN, pool)
+		if err != nil {
+			log.Fatal("Failed to initialize read replica:", err)
+		}
+		prometheus.RegisterDBStats(replica, "pvz_replica")
+		reads = repository.NewReadRouter(database, replica, cfg.DB.ReplicaMaxLag)
+	}
+
+	// Servers are stopped in reverse order, so the HTTP API stops accepting requests
+	// first and the database is closed after everything else
+	manager := lifecycle.NewManager(cfg.ShutdownTimeout)
+	manager.AddCloser("database", closePool(database))
+	if replica != nil {
+		manager.AddCloser("read replica", closePool(replica))
+	}
+
+	metricsServer := newMetricsServer(":" + cfg.Metrics.Port)
+	manager.AddServer("metrics server", func() error {
+		log.Printf("Starting metrics server on :%s", cfg.Metrics.Port)
+		if err := metricsServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}, metricsServer.Shutdown)
+
+	var pvzProcessor processors.PVZProcessor = processors.NewPVZProcessor(repository.NewPVZRepository(database, reads), clock.System{})
+	grpcServer := grpcserver.NewServer(pvzProcessor, cfg.GRPC.Port, grpcTLS)
+	manager.AddServer("gRPC server", grpcServer.Serve, grpcServer.Shutdown)
+
+	// grpc.health.v1 follows the same checks as /readyz
+	healthProcessor := processors.NewHealthProcessor(repository.NewHealthRepository(database), db.SchemaVersion)
+	updateGRPCHealth := func() {
+		grpcServer.SetServing(healthProcessor.Readiness(context.Background()).Status == models.HealthStatusOK)
+	}
+	manager.AddWorker("gRPC health", func(ctx context.Context) {
+		updateGRPCHealth()
+		lifecycle.Periodic(cfg.GRPC.HealthCheckInterval, updateGRPCHealth)(ctx)
+	})
+
+	// Password reset emails are sent after the response, queued ones are still sent
+	// on shutdown
+	resetMails := lifecycle.NewQueue("password reset", resetMailQueueSize)
+	manager.AddWorker("password reset mail", resetMails.Run)
 
-	application := app.MakeApp(database, cfg)
+	application := app.MakeApp(database, reads, resetMails, cfg)
+	manager.AddServer("HTTP server", func() error {
+		addr := fmt.Sprintf("0.0.0.0:%s", cfg.HTTP.Port)
+		if cfg.HTTP.TLSCertFile != "" {
+			log.Printf("Server listening on port %s with TLS", cfg.HTTP.Port)
+			return application.ListenTLS(addr, cfg.HTTP.TLSCertFile, cfg.HTTP.TLSKeyFile)
+		}
+		log.Printf("Server listening on port %s", cfg.HTTP.Port)
+		return application.Listen(addr)
+	}, application.ShutdownWithContext)
 
-	startMetricsServer()
+	if reads.HasReplica() {
+		manager.AddWorker("replica lag check", func(ctx context.Context) {
+			reads.CheckReplica()
+			lifecycle.Periodic(cfg.DB.ReplicaCheckInterval, reads.CheckReplica)(ctx)
+		})
+	}
 
-	log.Printf("Server listening on port %s", cfg.Port)
-	log.Fatal(application.Listen(fmt.Sprintf("0.0.0.0:%s", cfg.Port)))
+	idempotencyRepo := repository.NewIdempotencyRepository(database)
+	manager.AddWorker("idempotency cleanup", lifecycle.Periodic(cfg.Workers.IdempotencyCleanupInterval, func() {
+		if _, err := idempotencyRepo.DeleteExpired(); err != nil {
+			log.Printf("Failed to delete expired idempotency keys: %v", err)
+		}
+	}))
+
+	// Utilization of PVZ with a capacity is exported from the database, not counted
+	// per instance
+	refreshCapacityMetrics := func() {
+		if err := pvzProcessor.RefreshCapacityMetrics(); err != nil {
+			log.Printf("Failed to refresh PVZ capacity metrics: %v", err)
+		}
+	}
+	manager.AddWorker("capacity metrics", func(ctx context.Context) {
+		refreshCapacityMetrics()
+		lifecycle.Periodic(cfg.Workers.CapacityMetricsInterval, refreshCapacityMetrics)(ctx)
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := manager.Run(ctx); err != nil {
+		log.Printf("Server stopped with an error: %v", err)
+		os.Exit(1)
+	}
+	log.Println("Server stopped")
 }
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+
+	"pvzService/internal/oidc/mockidp"
+)
+
+// Local OpenID Connect provider for trying the OIDC login without a real IdP.
+// Users are given as login=group1|group2 pairs, the login is also the subject and
+// the email is login@example.com.
+func main() {
+	addr := flag.String("addr", ":9096", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9096", "issuer URL as seen by the service and the browser")
+	clientID := flag.String("client-id", "pvz-service", "OAuth client id")
+	clientSecret := flag.String("client-secret", "pvz-secret", "OAuth client secret")
+	users := flag.String("users", "moderator=pvz-moderators,employee=pvz-staff", "comma-separated login=group1|group2 pairs")
+	flag.Parse()
+
+	server, err := mockidp.New(*issuer, *clientID, *clientSecret)
+	if err != nil {
+		log.Fatalf("Failed to create mock IdP: %v", err)
+	}
+	for _, pair := range strings.Split(*users, ",") {
+		login, groups, _ := strings.Cut(strings.TrimSpace(pair), "=")
+		if login == "" {
+			continue
+		}
+		server.AddUser(login, mockidp.User{
+			Subject:       login,
+			Email:         login + "@example.com",
+			EmailVerified: true,
+			Groups:        strings.Split(groups, "|"),
+		})
+	}
+
+	log.Printf("Mock IdP listening on %s with issuer %s", *addr, *issuer)
+	log.Fatal(http.ListenAndServe(*addr, server))
+}
@@ -0,0 +1,184 @@
+package apperrors
+
+import (
+	"errors"
+	"net/http"
+
+	"pvzService/internal/models"
+)
+
+type Code string
+
+const (
+	CodeInvalidRequest         Code = "INVALID_REQUEST"
+	CodeValidationFailed       Code = "VALIDATION_FAILED"
+	CodeInvalidRole            Code = "INVALID_ROLE"
+	CodeInvalidCity            Code = "INVALID_CITY"
+	CodeInvalidProductType     Code = "INVALID_PRODUCT_TYPE"
+	CodeEmailAlreadyExists     Code = "EMAIL_ALREADY_EXISTS"
+	CodeInvalidCredentials     Code = "INVALID_CREDENTIALS"
+	CodeTooManyLoginAttempts   Code = "TOO_MANY_LOGIN_ATTEMPTS"
+	CodeRateLimited            Code = "RATE_LIMITED"
+	CodeInvalidCurrentPassword Code = "INVALID_CURRENT_PASSWORD"
+	CodeInvalidResetToken      Code = "INVALID_RESET_TOKEN"
+	CodeOIDCLoginFailed        Code = "OIDC_LOGIN_FAILED"
+	CodeOIDCRoleNotMapped      Code = "OIDC_ROLE_NOT_MAPPED"
+	CodeOIDCUnavailable        Code = "OIDC_PROVIDER_UNAVAILABLE"
+	CodeUnauthorized           Code = "UNAUTHORIZED"
+	CodeTokenExpired           Code = "TOKEN_EXPIRED"
+	CodeForbidden              Code = "FORBIDDEN"
+	CodeNotFound               Code = "NOT_FOUND"
+	CodePVZNotFound            Code = "PVZ_NOT_FOUND"
+	CodePVZArchived            Code = "PVZ_ARCHIVED"
+	CodePVZHasOpenReception    Code = "PVZ_HAS_OPEN_RECEPTION"
+	CodePVZCapacityExceeded    Code = "PVZ_CAPACITY_EXCEEDED"
+	CodeUserNotFound           Code = "USER_NOT_FOUND"
+	CodeAPIKeyNotFound         Code = "API_KEY_NOT_FOUND"
+	CodeCannotModifySelf       Code = "CANNOT_MODIFY_SELF"
+	CodeReceptionAlreadyOpen   Code = "RECEPTION_ALREADY_OPEN"
+	CodeNoOpenReception        Code = "NO_OPEN_RECEPTION"
+	CodeNoProductsToDelete     Code = "NO_PRODUCTS_TO_DELETE"
+	CodeProductNotFound        Code = "PRODUCT_NOT_FOUND"
+	CodeProductAlreadyIssued   Code = "PRODUCT_ALREADY_ISSUED"
+	CodeReceptionNotClosed     Code = "RECEPTION_NOT_CLOSED"
+	CodeIdempotencyKeyReused   Code = "IDEMPOTENCY_KEY_REUSED"
+	CodeIdempotencyInProgress  Code = "IDEMPOTENCY_KEY_IN_PROGRESS"
+	CodeIdempotencyKeyExpired  Code = "IDEMPOTENCY_KEY_EXPIRED"
+	CodeInternal               Code = "INTERNAL_ERROR"
+)
+
+// Error is a domain error with a machine-readable code. Message and Details are safe
+// to show to clients, the wrapped cause is only meant for logs.
+type Error struct {
+	Code    Code
+	Message string
+	Details []models.FieldError
+	Err     error
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports errors with the same code as equal, so errors.Is matches a sentinel
+// even after it was wrapped with a cause.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap returns a copy of the sentinel carrying the underlying cause.
+func Wrap(sentinel *Error, cause error) *Error {
+	return &Error{Code: sentinel.Code, Message: sentinel.Message, Err: cause}
+}
+
+// Validation reports field-level problems with a request.
+func Validation(details []models.FieldError) *Error {
+	return &Error{Code: CodeValidationFailed, Message: "request validation failed", Details: details}
+}
+
+// Internal hides the cause behind a generic message for the client.
+func Internal(message string, cause error) *Error {
+	return &Error{Code: CodeInternal, Message: message, Err: cause}
+}
+
+// From converts any error to *Error, treating unknown errors as internal ones.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Internal("internal server error", err)
+}
+
+var (
+	ErrInvalidRequestBody = New(CodeInvalidRequest, "Invalid request body format")
+
+	ErrInvalidRole            = New(CodeInvalidRole, "invalid role")
+	ErrInvalidCity            = New(CodeInvalidCity, "invalid city")
+	ErrCityAssignment         = New(CodeInvalidCity, "a city must be assigned to city managers and only to them")
+	ErrInvalidProductType     = New(CodeInvalidProductType, "invalid product type")
+	ErrEmailAlreadyExists     = New(CodeEmailAlreadyExists, "email already exists")
+	ErrInvalidCredentials     = New(CodeInvalidCredentials, "invalid email or password")
+	ErrTooManyLoginAttempts   = New(CodeTooManyLoginAttempts, "too many failed login attempts, try again later")
+	ErrRateLimited            = New(CodeRateLimited, "too many requests, try again later")
+	ErrMissingAuthHeader      = New(CodeUnauthorized, "Missing authorization header")
+	ErrInvalidToken           = New(CodeUnauthorized, "Invalid token")
+	ErrUserDeactivated        = New(CodeUnauthorized, "User is deactivated")
+	ErrTokenRevoked           = New(CodeUnauthorized, "Token was revoked after a password change, log in again")
+	ErrTokenExpired           = New(CodeTokenExpired, "Invalid token expiration")
+	ErrInvalidRoleInToken     = New(CodeForbidden, "Invalid role in token")
+	ErrInsufficientPermission = New(CodeForbidden, "Insufficient permissions")
+	ErrPrivilegedRegistration = New(CodeForbidden, "registering this role requires the user:manage permission")
+	ErrAdminOnly              = New(CodeForbidden, "only an admin can grant the admin role or change an admin account")
+	ErrPVZNotFound            = New(CodePVZNotFound, "PVZ not found")
+	ErrPVZArchived            = New(CodePVZArchived, "PVZ is archived, restore it to open receptions")
+	ErrPVZHasOpenReception    = New(CodePVZHasOpenReception, "PVZ has an open reception, close it before archiving")
+	ErrPVZCapacityExceeded    = New(CodePVZCapacityExceeded, "PVZ has no room for the products, its capacity is exceeded")
+	ErrNothingToUpdatePVZ     = New(CodeInvalidRequest, "request must change name, address, coordinates, working hours or capacity")
+	ErrReceptionAlreadyOpen   = New(CodeReceptionAlreadyOpen, "open reception already exists for this PVZ")
+	ErrNoOpenReception        = New(CodeNoOpenReception, "no open reception found for this PVZ")
+	ErrNoProductsToDelete     = New(CodeNoProductsToDelete, "no products to delete in this reception")
+	ErrProductNotFound        = New(CodeProductNotFound, "product not found in this PVZ")
+	ErrProductAlreadyIssued   = New(CodeProductAlreadyIssued, "product is already issued")
+	ErrReceptionNotClosed     = New(CodeReceptionNotClosed, "reception of the product is still open, close it before issuing")
+
+	ErrUserNotFound     = New(CodeUserNotFound, "user not found")
+	ErrCannotModifySelf = New(CodeCannotModifySelf, "users cannot change their own role, deactivate or delete themselves")
+	ErrNothingToUpdate  = New(CodeInvalidRequest, "request must change role, city or active")
+
+	ErrInvalidCurrentPassword = New(CodeInvalidCurrentPassword, "current password is incorrect")
+	ErrInvalidResetToken      = New(CodeInvalidResetToken, "password reset token is invalid or expired")
+
+	ErrOIDCLoginFailed   = New(CodeOIDCLoginFailed, "login with the identity provider failed")
+	ErrOIDCEmailConflict = New(CodeOIDCLoginFailed, "an account with this email exists and the identity provider did not verify the email")
+	ErrOIDCRoleNotMapped = New(CodeOIDCRoleNotMapped, "none of the identity provider groups grants access to the service")
+	ErrOIDCUnavailable   = New(CodeOIDCUnavailable, "identity provider is unavailable, try again later")
+
+	ErrInvalidAPIKey           = New(CodeUnauthorized, "Invalid, expired or revoked API key")
+	ErrUserTokenRequired       = New(CodeForbidden, "this endpoint requires a user token, API keys are not accepted")
+	ErrAPIKeyPermissionNotHeld = New(CodeForbidden, "an API key can only be granted permissions its creator has")
+	ErrAPIKeyExpiryInPast      = New(CodeInvalidRequest, "expiresAt must be in the future")
+	ErrAPIKeyNotFound          = New(CodeAPIKeyNotFound, "API key not found")
+
+	ErrIdempotencyKeyTooLong = New(CodeInvalidRequest, "Idempotency-Key is too long")
+	ErrIdempotencyKeyReused  = New(CodeIdempotencyKeyReused, "Idempotency-Key was already used with a different request")
+	ErrIdempotencyInProgress = New(CodeIdempotencyInProgress, "request with this Idempotency-Key is still being processed")
+	ErrIdempotencyKeyExpired = New(CodeIdempotencyKeyExpired, "Idempotency-Key has just expired, retry the request")
+)
+
+// HTTPStatus maps an error code to the HTTP status returned to REST clients.
+// Business rule violations stay 400 as documented in the API contract.
+func HTTPStatus(code Code) int {
+	switch code {
+	case CodeInvalidRequest, CodeValidationFailed, CodeInvalidRole, CodeInvalidCity, CodeInvalidProductType,
+		CodeEmailAlreadyExists, CodeReceptionAlreadyOpen, CodeNoOpenReception, CodeNoProductsToDelete, CodeCannotModifySelf,
+		CodeInvalidCurrentPassword, CodeInvalidResetToken, CodePVZArchived, CodePVZHasOpenReception,
+		CodePVZCapacityExceeded, CodeProductAlreadyIssued, CodeReceptionNotClosed:
+		return http.StatusBadRequest
+	case CodeInvalidCredentials, CodeUnauthorized, CodeTokenExpired, CodeOIDCLoginFailed:
+		return http.StatusUnauthorized
+	case CodeForbidden, CodeOIDCRoleNotMapped:
+		return http.StatusForbidden
+	case CodeNotFound, CodePVZNotFound, CodeProductNotFound, CodeUserNotFound, CodeAPIKeyNotFound:
+		return http.StatusNotFound
+	case CodeIdempotencyInProgress, CodeIdempotencyKeyExpired:
+		return http.StatusConflict
+	case CodeIdempotencyKeyReused:
+		return http.StatusUnprocessableEntity
+	case CodeTooManyLoginAttempts, CodeRateLimited:
+		return http.StatusTooManyRequests
+	case CodeOIDCUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"pvzService/internal/clock"
+)
+
+// Store keeps encoded values by key for a TTL. Tags name what a value was built from,
+// Invalidate drops every value with one of the tags. A store shared between instances
+// (for example Redis) also shares invalidations, the memory store only sees the writes
+// of its own instance.
+type Store interface {
+	Get(key string) ([]byte, bool, error)
+	Set(key string, value []byte, tags []string, ttl time.Duration) error
+	Invalidate(tags ...string) error
+}
+
+type entry struct {
+	key       string
+	value     []byte
+	tags      []string
+	expiresAt time.Time
+}
+
+// MemoryStore is an LRU of at most maxEntries values in the memory of one instance.
+type MemoryStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	clock      clock.Clock
+	order      *list.List
+	entries    map[string]*list.Element
+	tagged     map[string]map[string]struct{}
+}
+
+func NewMemoryStore(maxEntries int, clock clock.Clock) *MemoryStore {
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		clock:      clock,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+		tagged:     make(map[string]map[string]struct{}),
+	}
+}
+
+func (s *MemoryStore) Get(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*entry)
+	if !s.clock.Now().Before(e.expiresAt) {
+		s.remove(el)
+		return nil, false, nil
+	}
+	s.order.MoveToFront(el)
+	return e.value, true, nil
+}
+
+func (s *MemoryStore) Set(key string, value []byte, tags []string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+	e := &entry{key: key, value: value, tags: tags, expiresAt: s.clock.Now().Add(ttl)}
+	s.entries[key] = s.order.PushFront(e)
+	for _, tag := range tags {
+		if s.tagged[tag] == nil {
+			s.tagged[tag] = make(map[string]struct{})
+		}
+		s.tagged[tag][key] = struct{}{}
+	}
+
+	for s.maxEntries > 0 && s.order.Len() > s.maxEntries {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+func (s *MemoryStore) Invalidate(tags ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range s.tagged[tag] {
+			s.remove(s.entries[key])
+		}
+	}
+	return nil
+}
+
+// Len returns the number of values kept, expired ones included until they are read
+// or evicted.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *MemoryStore) remove(el *list.Element) {
+	e := s.order.Remove(el).(*entry)
+	delete(s.entries, e.key)
+	for _, tag := range e.tags {
+		delete(s.tagged[tag], e.key)
+		if len(s.tagged[tag]) == 0 {
+			delete(s.tagged, tag)
+		}
+	}
+}
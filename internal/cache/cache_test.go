@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"pvzService/internal/clock"
+)
+
+func TestMemoryStore_GetSet(t *testing.T) {
+	store := NewMemoryStore(10, clock.NewFake(time.Now()))
+
+	_, ok, err := store.Get("pvz:1")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, store.Set("pvz:1", []byte("a"), nil, time.Minute))
+	value, ok, err := store.Get("pvz:1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("a"), value)
+
+	assert.NoError(t, store.Set("pvz:1", []byte("b"), nil, time.Minute))
+	value, _, _ = store.Get("pvz:1")
+	assert.Equal(t, []byte("b"), value)
+	assert.Equal(t, 1, store.Len())
+}
+
+func TestMemoryStore_Expires(t *testing.T) {
+	c := clock.NewFake(time.Now())
+	store := NewMemoryStore(10, c)
+
+	store.Set("pvz:1", []byte("a"), nil, time.Minute)
+	c.Advance(time.Minute)
+
+	_, ok, _ := store.Get("pvz:1")
+	assert.False(t, ok)
+	assert.Equal(t, 0, store.Len())
+}
+
+func TestMemoryStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewMemoryStore(2, clock.NewFake(time.Now()))
+
+	store.Set("a", []byte("a"), nil, time.Minute)
+	store.Set("b", []byte("b"), nil, time.Minute)
+	store.Get("a")
+	store.Set("c", []byte("c"), nil, time.Minute)
+
+	_, ok, _ := store.Get("b")
+	assert.False(t, ok)
+	_, ok, _ = store.Get("a")
+	assert.True(t, ok)
+	_, ok, _ = store.Get("c")
+	assert.True(t, ok)
+}
+
+func TestMemoryStore_InvalidatesByTag(t *testing.T) {
+	store := NewMemoryStore(10, clock.NewFake(time.Now()))
+
+	store.Set("list:1", []byte("a"), []string{"list", "pvz:1", "pvz:2"}, time.Minute)
+	store.Set("list:2", []byte("b"), []string{"list", "pvz:3"}, time.Minute)
+	store.Set("pvz:1", []byte("c"), []string{"pvz:1"}, time.Minute)
+
+	assert.NoError(t, store.Invalidate("pvz:1"))
+	_, ok, _ := store.Get("list:1")
+	assert.False(t, ok)
+	_, ok, _ = store.Get("pvz:1")
+	assert.False(t, ok)
+	_, ok, _ = store.Get("list:2")
+	assert.True(t, ok)
+
+	assert.NoError(t, store.Invalidate("list", "unknown"))
+	assert.Equal(t, 0, store.Len())
+}
@@ -0,0 +1,72 @@
+package clock
+
+import (
+	"sync"
+	"time"
+	// Time zones of PVZ don't depend on the tzdata of the host
+	_ "time/tzdata"
+)
+
+// Clock is the source of the current time. Processors take it instead of calling
+// time.Now, so the service has one clock and tests can fix the time.
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the clock of the host, times are in UTC.
+type System struct{}
+
+func (System) Now() time.Time {
+	return time.Now().UTC()
+}
+
+// Fake is a clock for tests that only moves when told to.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Advance moves the clock forward by d.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// Set moves the clock to now.
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+}
+
+var (
+	locationsMu sync.Mutex
+	locations   = map[string]*time.Location{}
+)
+
+// Location returns the time zone with the IANA name, UTC when the name is unknown.
+// Time zones are loaded once.
+func Location(name string) *time.Location {
+	locationsMu.Lock()
+	defer locationsMu.Unlock()
+	if loc, ok := locations[name]; ok {
+		return loc
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc = time.UTC
+	}
+	locations[name] = loc
+	return loc
+}
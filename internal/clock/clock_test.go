@@ -0,0 +1,30 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystem_ReturnsUTC(t *testing.T) {
+	assert.Equal(t, time.UTC, System{}.Now().Location())
+}
+
+func TestFake(t *testing.T) {
+	start := time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	assert.Equal(t, start, c.Now())
+
+	c.Advance(time.Hour)
+	assert.Equal(t, start.Add(time.Hour), c.Now())
+
+	c.Set(start)
+	assert.Equal(t, start, c.Now())
+}
+
+func TestLocation(t *testing.T) {
+	assert.Equal(t, "Europe/Moscow", Location("Europe/Moscow").String())
+	assert.Same(t, Location("Europe/Moscow"), Location("Europe/Moscow"))
+	assert.Equal(t, time.UTC, Location("Nowhere/Unknown"))
+}
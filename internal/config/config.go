@@ -2,33 +2,291 @@ package config
 
 import (
 	"fmt"
-	"os"
+	"time"
 )
 
+// Environment modes, see Config.Env
+const (
+	EnvDev  = "dev"
+	EnvTest = "test"
+	EnvProd = "prod"
+)
+
+// Defaults that are only acceptable outside of prod
+const (
+	defaultJWTSecret  = "secret"
+	defaultDBPassword = "postgres"
+)
+
+// Config is the effective configuration. Every setting has a key in the YAML file
+// (the yaml tags joined with dots, also used as the command-line flag) and an
+// environment variable (the env tag). Settings tagged secret are masked when printed.
 type Config struct {
-	DBDSN     string
-	JWTSecret string
-	Port      string
+	// Environment mode: dev, test or prod. An empty mode is treated as dev
+	Env string `yaml:"env" env:"APP_ENV"`
+
+	// How long in-flight requests are waited for on shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT"`
+
+	HTTP     HTTPConfig     `yaml:"http"`
+	GRPC     GRPCConfig     `yaml:"grpc"`
+	DB       DBConfig       `yaml:"db"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Notifier NotifierConfig `yaml:"notifier"`
+	Cache    CacheConfig    `yaml:"cache"`
+	Capacity CapacityConfig `yaml:"capacity"`
+	Metrics  MetricsConfig  `yaml:"metrics"`
+	Workers  WorkersConfig  `yaml:"workers"`
+}
+
+type HTTPConfig struct {
+	Port string `yaml:"port" env:"SERVER_PORT"`
+
+	// Server limits, zero keeps the Fiber defaults
+	ReadTimeout  time.Duration `yaml:"readTimeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout time.Duration `yaml:"writeTimeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout  time.Duration `yaml:"idleTimeout" env:"HTTP_IDLE_TIMEOUT"`
+	BodyLimit    int           `yaml:"bodyLimit" env:"HTTP_BODY_LIMIT"`
+
+	// TLS is enabled when the certificate and key are set
+	TLSCertFile string `yaml:"tlsCertFile" env:"HTTP_TLS_CERT_FILE"`
+	TLSKeyFile  string `yaml:"tlsKeyFile" env:"HTTP_TLS_KEY_FILE"`
+
+	IdempotencyTTL time.Duration `yaml:"idempotencyTTL" env:"IDEMPOTENCY_TTL"`
+	// How long a request holds its idempotency key, a retry takes over the key of a
+	// request that didn't finish by then
+	IdempotencyLease time.Duration   `yaml:"idempotencyLease" env:"IDEMPOTENCY_LEASE"`
+	RateLimit        RateLimitConfig `yaml:"rateLimit"`
+}
+
+// RateLimitConfig holds request budgets per user or client IP, zero requests disables
+// a budget. Counters live in memory of each instance or in Postgres to be shared:
+// memory or postgres.
+type RateLimitConfig struct {
+	Backend       string        `yaml:"backend" env:"RATE_LIMIT_BACKEND"`
+	AuthRequests  int           `yaml:"authRequests" env:"RATE_LIMIT_AUTH_REQUESTS"`
+	AuthWindow    time.Duration `yaml:"authWindow" env:"RATE_LIMIT_AUTH_WINDOW"`
+	WriteRequests int           `yaml:"writeRequests" env:"RATE_LIMIT_WRITE_REQUESTS"`
+	WriteWindow   time.Duration `yaml:"writeWindow" env:"RATE_LIMIT_WRITE_WINDOW"`
+}
+
+// GRPCConfig enables TLS when the certificate and key are set. With a client CA the
+// server requires client certificates signed by it (mTLS).
+type GRPCConfig struct {
+	Port            string `yaml:"port" env:"GRPC_PORT"`
+	TLSCertFile     string `yaml:"tlsCertFile" env:"GRPC_TLS_CERT_FILE"`
+	TLSKeyFile      string `yaml:"tlsKeyFile" env:"GRPC_TLS_KEY_FILE"`
+	TLSClientCAFile string `yaml:"tlsClientCAFile" env:"GRPC_TLS_CLIENT_CA_FILE"`
+
+	// How often the status of the grpc.health.v1 service is updated from the readiness checks
+	HealthCheckInterval time.Duration `yaml:"healthCheckInterval" env:"GRPC_HEALTH_CHECK_INTERVAL"`
+}
+
+type DBConfig struct {
+	Host     string `yaml:"host" env:"DATABASE_HOST"`
+	Port     string `yaml:"port" env:"DATABASE_PORT"`
+	User     string `yaml:"user" env:"DATABASE_USER"`
+	Password string `yaml:"password" env:"DATABASE_PASSWORD" secret:"true"`
+	Name     string `yaml:"name" env:"DATABASE_NAME"`
+	SSLMode  string `yaml:"sslMode" env:"DATABASE_SSLMODE"`
+
+	// Connection pool: zero max open connections keeps the pgxpool default size, idle
+	// connections are the minimum the pool keeps open
+	MaxOpenConns    int           `yaml:"maxOpenConns" env:"DATABASE_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"maxIdleConns" env:"DATABASE_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"connMaxLifetime" env:"DATABASE_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `yaml:"connMaxIdleTime" env:"DATABASE_CONN_MAX_IDLE_TIME"`
+
+	// Connecting on startup is retried with a delay that doubles up to the maximum
+	ConnectAttempts   int           `yaml:"connectAttempts" env:"DATABASE_CONNECT_ATTEMPTS"`
+	ConnectBackoff    time.Duration `yaml:"connectBackoff" env:"DATABASE_CONNECT_BACKOFF"`
+	ConnectMaxBackoff time.Duration `yaml:"connectMaxBackoff" env:"DATABASE_CONNECT_MAX_BACKOFF"`
+
+	// Read-only queries go to the replica unless it lags behind by more than ReplicaMaxLag,
+	// the lag is measured in the background every ReplicaCheckInterval
+	ReplicaDSN           string        `yaml:"replicaDSN" env:"DATABASE_REPLICA_DSN" secret:"true"`
+	ReplicaMaxLag        time.Duration `yaml:"replicaMaxLag" env:"DATABASE_REPLICA_MAX_LAG"`
+	ReplicaCheckInterval time.Duration `yaml:"replicaCheckInterval" env:"DATABASE_REPLICA_CHECK_INTERVAL"`
+}
+
+// DSN is the connection string in the key=value format.
+func (c DBConfig) DSN() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", c.Host, c.Port, c.User, c.Password, c.Name, c.SSLMode)
+}
+
+type AuthConfig struct {
+	JWTSecret string `yaml:"jwtSecret" env:"JWT_SECRET" secret:"true"`
+
+	// How long role permissions read from the database are cached
+	PermissionsCacheTTL time.Duration `yaml:"permissionsCacheTTL" env:"PERMISSIONS_CACHE_TTL"`
+
+	Password      PasswordPolicyConfig  `yaml:"password"`
+	Login         LoginProtectionConfig `yaml:"login"`
+	PasswordReset PasswordResetConfig   `yaml:"passwordReset"`
+	OIDC          OIDCConfig            `yaml:"oidc"`
+}
+
+// PasswordPolicyConfig is applied on registration and password changes.
+type PasswordPolicyConfig struct {
+	MinLength     int  `yaml:"minLength" env:"PASSWORD_MIN_LENGTH"`
+	RequireLetter bool `yaml:"requireLetter" env:"PASSWORD_REQUIRE_LETTER"`
+	RequireDigit  bool `yaml:"requireDigit" env:"PASSWORD_REQUIRE_DIGIT"`
+	RequireUpper  bool `yaml:"requireUpper" env:"PASSWORD_REQUIRE_UPPER"`
+	RequireSymbol bool `yaml:"requireSymbol" env:"PASSWORD_REQUIRE_SYMBOL"`
+}
+
+// LoginProtectionConfig limits brute force on /login, zero attempts disables a limit.
+type LoginProtectionConfig struct {
+	MaxAttempts     int           `yaml:"maxAttempts" env:"LOGIN_MAX_ATTEMPTS"`
+	IPMaxAttempts   int           `yaml:"ipMaxAttempts" env:"LOGIN_IP_MAX_ATTEMPTS"`
+	AttemptWindow   time.Duration `yaml:"attemptWindow" env:"LOGIN_ATTEMPT_WINDOW"`
+	LockoutDuration time.Duration `yaml:"lockoutDuration" env:"LOGIN_LOCKOUT_DURATION"`
+}
+
+type PasswordResetConfig struct {
+	TTL time.Duration `yaml:"ttl" env:"PASSWORD_RESET_TTL"`
+	URL string        `yaml:"url" env:"PASSWORD_RESET_URL"`
+}
+
+// OIDCConfig enables login through an external OpenID Connect provider when the issuer
+// is set.
+type OIDCConfig struct {
+	IssuerURL       string   `yaml:"issuerURL" env:"OIDC_ISSUER_URL"`
+	ClientID        string   `yaml:"clientID" env:"OIDC_CLIENT_ID"`
+	ClientSecret    string   `yaml:"clientSecret" env:"OIDC_CLIENT_SECRET" secret:"true"`
+	RedirectURL     string   `yaml:"redirectURL" env:"OIDC_REDIRECT_URL"`
+	GroupsClaim     string   `yaml:"groupsClaim" env:"OIDC_GROUPS_CLAIM"`
+	EmployeeGroups  []string `yaml:"employeeGroups" env:"OIDC_EMPLOYEE_GROUPS"`
+	ModeratorGroups []string `yaml:"moderatorGroups" env:"OIDC_MODERATOR_GROUPS"`
+}
+
+// NotifierConfig selects the delivery of password reset tokens: log, file or smtp.
+type NotifierConfig struct {
+	Type string     `yaml:"type" env:"NOTIFIER"`
+	File string     `yaml:"file" env:"NOTIFIER_FILE"`
+	SMTP SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host" env:"SMTP_HOST"`
+	Port     string `yaml:"port" env:"SMTP_PORT"`
+	Username string `yaml:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD" secret:"true"`
+	From     string `yaml:"from" env:"SMTP_FROM"`
 }
 
-func LoadConfig() Config {
-	dbHost := getEnv("DATABASE_HOST", "db")
-	dbPort := getEnv("DATABASE_PORT", "5432")
-	dbUser := getEnv("DATABASE_USER", "postgres")
-	dbPass := getEnv("DATABASE_PASSWORD", "postgres")
-	dbName := getEnv("DATABASE_NAME", "pvz")
+// CacheConfig enables the cache of PVZ reads, responses of a PVZ are dropped when it
+// changes. Values are kept in the memory of each instance: memory.
+type CacheConfig struct {
+	Enabled    bool          `yaml:"enabled" env:"CACHE_ENABLED"`
+	Backend    string        `yaml:"backend" env:"CACHE_BACKEND"`
+	TTL        time.Duration `yaml:"ttl" env:"CACHE_TTL"`
+	MaxEntries int           `yaml:"maxEntries" env:"CACHE_MAX_ENTRIES"`
+}
+
+// CapacityConfig sets what adding products to a PVZ over its capacity does: reject
+// refuses the products, warn accepts them and counts the overflow.
+type CapacityConfig struct {
+	Overflow string `yaml:"overflow" env:"CAPACITY_OVERFLOW"`
+}
 
+type MetricsConfig struct {
+	Port string `yaml:"port" env:"METRICS_PORT"`
+}
+
+// WorkersConfig configures background jobs.
+type WorkersConfig struct {
+	// How often expired idempotency keys and their stored responses are deleted
+	IdempotencyCleanupInterval time.Duration `yaml:"idempotencyCleanupInterval" env:"IDEMPOTENCY_CLEANUP_INTERVAL"`
+
+	// How often the capacity utilization of PVZ is exported to Prometheus
+	CapacityMetricsInterval time.Duration `yaml:"capacityMetricsInterval" env:"CAPACITY_METRICS_INTERVAL"`
+}
+
+// Default is the configuration used for settings that are not set anywhere.
+func Default() Config {
 	return Config{
-		DBDSN:     fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", dbHost, dbPort, dbUser, dbPass, dbName),
-		JWTSecret: getEnv("JWT_SECRET", "secret"),
-		Port:      getEnv("SERVER_PORT", "8080"),
+		Env:             EnvDev,
+		ShutdownTimeout: 20 * time.Second,
+
+		HTTP: HTTPConfig{
+			Port:             "8080",
+			ReadTimeout:      10 * time.Second,
+			WriteTimeout:     10 * time.Second,
+			IdleTimeout:      time.Minute,
+			BodyLimit:        1024 * 1024,
+			IdempotencyTTL:   24 * time.Hour,
+			IdempotencyLease: time.Minute,
+			RateLimit: RateLimitConfig{
+				Backend:       "memory",
+				AuthRequests:  20,
+				AuthWindow:    time.Minute,
+				WriteRequests: 120,
+				WriteWindow:   time.Minute,
+			},
+		},
+		GRPC: GRPCConfig{Port: "3000", HealthCheckInterval: 10 * time.Second},
+		DB: DBConfig{
+			Host:     "db",
+			Port:     "5432",
+			User:     "postgres",
+			Password: defaultDBPassword,
+			Name:     "pvz",
+			SSLMode:  "disable",
+
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+
+			ConnectAttempts:   8,
+			ConnectBackoff:    500 * time.Millisecond,
+			ConnectMaxBackoff: 10 * time.Second,
+
+			ReplicaMaxLag:        5 * time.Second,
+			ReplicaCheckInterval: 2 * time.Second,
+		},
+		Auth: AuthConfig{
+			JWTSecret:           defaultJWTSecret,
+			PermissionsCacheTTL: time.Minute,
+			Password: PasswordPolicyConfig{
+				MinLength:     8,
+				RequireLetter: true,
+				RequireDigit:  true,
+			},
+			Login: LoginProtectionConfig{
+				MaxAttempts:     5,
+				IPMaxAttempts:   20,
+				AttemptWindow:   15 * time.Minute,
+				LockoutDuration: 15 * time.Minute,
+			},
+			PasswordReset: PasswordResetConfig{TTL: time.Hour},
+			OIDC: OIDCConfig{
+				RedirectURL: "http://localhost:8080/auth/oidc/callback",
+				GroupsClaim: "groups",
+			},
+		},
+		Notifier: NotifierConfig{
+			Type: "log",
+			File: "notifications.jsonl",
+			SMTP: SMTPConfig{Port: "587"},
+		},
+		Cache: CacheConfig{
+			Backend:    "memory",
+			TTL:        5 * time.Second,
+			MaxEntries: 1000,
+		},
+		Capacity: CapacityConfig{Overflow: "reject"},
+		Metrics:  MetricsConfig{Port: "9000"},
+		Workers: WorkersConfig{
+			IdempotencyCleanupInterval: time.Hour,
+			CapacityMetricsInterval:    time.Minute,
+		},
 	}
 }
 
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
+// DummyLoginEnabled reports whether /dummyLogin, which hands out tokens without
+// credentials, is served. It is never served in prod.
+func (c Config) DummyLoginEnabled() bool {
+	return c.Env != EnvProd
 }
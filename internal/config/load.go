@@ -0,0 +1,151 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// setting is a leaf of the Config tree that can be set from a string.
+type setting struct {
+	key    string
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+// settings lists the leaves of cfg in declaration order, their values point into cfg.
+func settings(cfg *Config) []setting {
+	var result []setting
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			key := prefix + field.Tag.Get("yaml")
+			if field.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), key+".")
+				continue
+			}
+			result = append(result, setting{
+				key:    key,
+				env:    field.Tag.Get("env"),
+				secret: field.Tag.Get("secret") == "true",
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return result
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func (s setting) set(raw string) error {
+	switch {
+	case s.value.Type() == durationType:
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return errors.New("not a duration, use values like 30s, 15m or 24h")
+		}
+		s.value.SetInt(int64(duration))
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(raw)
+	case s.value.Kind() == reflect.Int:
+		number, err := strconv.Atoi(raw)
+		if err != nil {
+			return errors.New("not an integer")
+		}
+		s.value.SetInt(int64(number))
+	case s.value.Kind() == reflect.Bool:
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("not a boolean, use true or false")
+		}
+		s.value.SetBool(enabled)
+	case s.value.Kind() == reflect.Slice:
+		// Comma-separated, empty items are skipped
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", s.value.Type())
+	}
+	return nil
+}
+
+// Load builds the configuration in layers, each overriding the previous one: defaults,
+// the YAML file given by the -config flag or CONFIG_FILE, environment variables and
+// command-line flags named after the YAML keys, such as -http.port=8443. Empty
+// environment variables are ignored. Values that can't be parsed are reported
+// together instead of falling back to defaults.
+func Load(args []string) (Config, error) {
+	cfg := Default()
+	leaves := settings(&cfg)
+
+	flags := flag.NewFlagSet("pvzService", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	file := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration file")
+	overrides := map[string]string{}
+	for _, leaf := range leaves {
+		key := leaf.key
+		flags.Func(key, "overrides "+leaf.env, func(value string) error {
+			overrides[key] = value
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if *file != "" {
+		if err := loadFile(&cfg, *file); err != nil {
+			return Config{}, err
+		}
+	}
+
+	var errs []error
+	for _, leaf := range leaves {
+		if value := os.Getenv(leaf.env); value != "" {
+			if err := leaf.set(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s=%q: %w", leaf.env, value, err))
+			}
+		}
+	}
+	for _, leaf := range leaves {
+		if value, ok := overrides[leaf.key]; ok {
+			if err := leaf.set(value); err != nil {
+				errs = append(errs, fmt.Errorf("-%s=%q: %w", leaf.key, value, err))
+			}
+		}
+	}
+
+	return cfg, errors.Join(errs...)
+}
+
+// loadFile rejects unknown keys, so a typo in the file doesn't go unnoticed.
+func loadFile(cfg *Config, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
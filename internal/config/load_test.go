@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+
+	cfg, err := Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+}
+
+func TestLoad_LayersFileEnvAndFlags(t *testing.T) {
+	path := writeConfigFile(t, `
+http:
+  port: 8081
+  readTimeout: 5s
+  rateLimit:
+    backend: postgres
+db:
+  host: file-host
+  name: file-db
+auth:
+  oidc:
+    employeeGroups: [staff, support]
+`)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("DATABASE_HOST", "env-host")
+	t.Setenv("HTTP_READ_TIMEOUT", "7s")
+	t.Setenv("OIDC_MODERATOR_GROUPS", "admins, moderators")
+	t.Setenv("DATABASE_NAME", "")
+
+	cfg, err := Load([]string{"-db.host=flag-host", "-auth.password.requireUpper=true"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "8081", cfg.HTTP.Port)
+	assert.Equal(t, "postgres", cfg.HTTP.RateLimit.Backend)
+	assert.Equal(t, 7*time.Second, cfg.HTTP.ReadTimeout, "env overrides the file")
+	assert.Equal(t, "flag-host", cfg.DB.Host, "flags override env")
+	assert.Equal(t, "file-db", cfg.DB.Name, "empty env is ignored")
+	assert.True(t, cfg.Auth.Password.RequireUpper)
+	assert.Equal(t, []string{"staff", "support"}, cfg.Auth.OIDC.EmployeeGroups)
+	assert.Equal(t, []string{"admins", "moderators"}, cfg.Auth.OIDC.ModeratorGroups)
+	assert.Equal(t, Default().GRPC.Port, cfg.GRPC.Port, "unset settings keep defaults")
+}
+
+func TestLoad_ConfigFlagOverridesConfigFileEnv(t *testing.T) {
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+	path := writeConfigFile(t, "env: test\n")
+
+	cfg, err := Load([]string{"-config", path})
+	require.NoError(t, err)
+	assert.Equal(t, EnvTest, cfg.Env)
+}
+
+func TestLoad_RejectsUnknownFileKeys(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, "http:\n  prot: 8081\n"))
+
+	_, err := Load(nil)
+	assert.ErrorContains(t, err, "field prot not found")
+}
+
+func TestLoad_ReportsAllParseErrors(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("HTTP_READ_TIMEOUT", "10")
+	t.Setenv("LOGIN_MAX_ATTEMPTS", "five")
+
+	_, err := Load([]string{"-auth.password.requireDigit=maybe"})
+	assert.ErrorContains(t, err, `HTTP_READ_TIMEOUT="10": not a duration`)
+	assert.ErrorContains(t, err, `LOGIN_MAX_ATTEMPTS="five": not an integer`)
+	assert.ErrorContains(t, err, `-auth.password.requireDigit="maybe": not a boolean`)
+}
+
+func TestLoad_UnknownFlag(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+
+	_, err := Load([]string{"-http.prot=8081"})
+	assert.ErrorContains(t, err, "http.prot")
+}
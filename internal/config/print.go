@@ -0,0 +1,26 @@
+package config
+
+import (
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+const secretMask = "******"
+
+// Print writes the configuration as YAML in the format of the config file. Secrets
+// that are set are masked, empty ones are left empty to show they are missing.
+func Print(w io.Writer, cfg Config) error {
+	for _, leaf := range settings(&cfg) {
+		if leaf.secret && !leaf.value.IsZero() {
+			leaf.value.SetString(secretMask)
+		}
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrint_MasksSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Auth.JWTSecret = "jwt-secret-value"
+	cfg.Auth.OIDC.ClientSecret = ""
+
+	var out bytes.Buffer
+	require.NoError(t, Print(&out, cfg))
+
+	assert.NotContains(t, out.String(), "jwt-secret-value")
+	assert.Contains(t, out.String(), "jwtSecret: '******'")
+	assert.Contains(t, out.String(), "password: '******'")
+	assert.Contains(t, out.String(), `clientSecret: ""`)
+	assert.Equal(t, "jwt-secret-value", cfg.Auth.JWTSecret, "the printed config is a copy")
+}
+
+func TestPrint_OutputIsAConfigFile(t *testing.T) {
+	cfg := Default()
+	cfg.HTTP.Port = "8443"
+	cfg.Auth.OIDC.EmployeeGroups = []string{"staff"}
+	cfg.Auth.OIDC.ModeratorGroups = []string{"moderators"}
+
+	var out bytes.Buffer
+	require.NoError(t, Print(&out, cfg))
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, out.Bytes(), 0o600))
+	t.Setenv("CONFIG_FILE", path)
+
+	loaded, err := Load(nil)
+	require.NoError(t, err)
+	loaded.Auth.JWTSecret, loaded.DB.Password = cfg.Auth.JWTSecret, cfg.DB.Password
+	assert.Equal(t, cfg, loaded)
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Minimal length of JWT_SECRET in prod
+const minProdJWTSecretLength = 32
+
+// Validate reports every problem of the configuration at once, so a deployment can be
+// fixed in one go instead of failing on the first bad value. In prod it also rejects
+// the insecure defaults that are convenient for local development.
+func (c Config) Validate() error {
+	var errs []error
+	problem := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	switch c.Env {
+	case EnvDev, EnvTest, EnvProd, "":
+	default:
+		problem("APP_ENV=%q must be %s, %s or %s", c.Env, EnvDev, EnvTest, EnvProd)
+	}
+
+	ports := map[string]string{}
+	for _, port := range []struct{ name, value string }{
+		{"SERVER_PORT", c.HTTP.Port},
+		{"GRPC_PORT", c.GRPC.Port},
+		{"METRICS_PORT", c.Metrics.Port},
+	} {
+		number, err := strconv.Atoi(port.value)
+		if err != nil || number < 1 || number > 65535 {
+			problem("%s=%q is not a port number between 1 and 65535", port.name, port.value)
+			continue
+		}
+		if other, ok := ports[port.value]; ok {
+			problem("%s and %s both use port %s", other, port.name, port.value)
+		}
+		ports[port.value] = port.name
+	}
+
+	for _, duration := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"HTTP_READ_TIMEOUT", c.HTTP.ReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", c.HTTP.WriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", c.HTTP.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+		{"DATABASE_CONN_MAX_LIFETIME", c.DB.ConnMaxLifetime},
+		{"DATABASE_CONN_MAX_IDLE_TIME", c.DB.ConnMaxIdleTime},
+	} {
+		if duration.value < 0 {
+			problem("%s=%s must not be negative", duration.name, duration.value)
+		}
+	}
+	if c.HTTP.BodyLimit < 0 {
+		problem("HTTP_BODY_LIMIT=%d must not be negative", c.HTTP.BodyLimit)
+	}
+	if c.DB.MaxOpenConns < 0 || c.DB.MaxIdleConns < 0 {
+		problem("DATABASE_MAX_OPEN_CONNS and DATABASE_MAX_IDLE_CONNS must not be negative")
+	} else if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		problem("DATABASE_MAX_IDLE_CONNS=%d must not exceed DATABASE_MAX_OPEN_CONNS=%d", c.DB.MaxIdleConns, c.DB.MaxOpenConns)
+	}
+	if c.DB.ConnectAttempts < 1 {
+		problem("DATABASE_CONNECT_ATTEMPTS=%d must be at least 1", c.DB.ConnectAttempts)
+	}
+	if c.DB.ConnectBackoff <= 0 || c.DB.ConnectMaxBackoff < c.DB.ConnectBackoff {
+		problem("DATABASE_CONNECT_BACKOFF=%s must be positive and not exceed DATABASE_CONNECT_MAX_BACKOFF=%s", c.DB.ConnectBackoff, c.DB.ConnectMaxBackoff)
+	}
+	if c.DB.ReplicaDSN != "" && c.DB.ReplicaMaxLag <= 0 {
+		problem("DATABASE_REPLICA_MAX_LAG=%s must be positive", c.DB.ReplicaMaxLag)
+	}
+	if c.DB.ReplicaDSN != "" && c.DB.ReplicaCheckInterval <= 0 {
+		problem("DATABASE_REPLICA_CHECK_INTERVAL=%s must be positive", c.DB.ReplicaCheckInterval)
+	}
+	if c.GRPC.HealthCheckInterval <= 0 {
+		problem("GRPC_HEALTH_CHECK_INTERVAL=%s must be positive", c.GRPC.HealthCheckInterval)
+	}
+	if c.HTTP.IdempotencyLease <= 0 || c.HTTP.IdempotencyLease > c.HTTP.IdempotencyTTL {
+		problem("IDEMPOTENCY_LEASE=%s must be positive and not exceed IDEMPOTENCY_TTL=%s", c.HTTP.IdempotencyLease, c.HTTP.IdempotencyTTL)
+	}
+	if c.Workers.IdempotencyCleanupInterval <= 0 {
+		problem("IDEMPOTENCY_CLEANUP_INTERVAL=%s must be positive", c.Workers.IdempotencyCleanupInterval)
+	}
+	if c.Workers.CapacityMetricsInterval <= 0 {
+		problem("CAPACITY_METRICS_INTERVAL=%s must be positive", c.Workers.CapacityMetricsInterval)
+	}
+
+	switch c.HTTP.RateLimit.Backend {
+	case "memory", "postgres":
+	default:
+		problem("RATE_LIMIT_BACKEND=%q must be memory or postgres", c.HTTP.RateLimit.Backend)
+	}
+	if c.Cache.Enabled {
+		if c.Cache.Backend != "memory" {
+			problem("CACHE_BACKEND=%q must be memory", c.Cache.Backend)
+		}
+		if c.Cache.TTL <= 0 || c.Cache.MaxEntries < 1 {
+			problem("CACHE_TTL=%s and CACHE_MAX_ENTRIES=%d must be positive", c.Cache.TTL, c.Cache.MaxEntries)
+		}
+	}
+	switch c.Capacity.Overflow {
+	case "reject", "warn":
+	default:
+		problem("CAPACITY_OVERFLOW=%q must be reject or warn", c.Capacity.Overflow)
+	}
+	switch c.Notifier.Type {
+	case "log", "file":
+	case "smtp":
+		if c.Notifier.SMTP.Host == "" || c.Notifier.SMTP.From == "" {
+			problem("NOTIFIER=smtp requires SMTP_HOST and SMTP_FROM")
+		}
+	default:
+		problem("NOTIFIER=%q must be log, file or smtp", c.Notifier.Type)
+	}
+
+	validateKeyPair(problem, "HTTP_TLS", c.HTTP.TLSCertFile, c.HTTP.TLSKeyFile)
+	validateKeyPair(problem, "GRPC_TLS", c.GRPC.TLSCertFile, c.GRPC.TLSKeyFile)
+	if c.GRPC.TLSClientCAFile != "" {
+		if c.GRPC.TLSCertFile == "" {
+			problem("GRPC_TLS_CLIENT_CA_FILE requires GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE")
+		}
+		validateFile(problem, "GRPC_TLS_CLIENT_CA_FILE", c.GRPC.TLSClientCAFile)
+	}
+
+	if c.Env == EnvProd {
+		if c.Auth.JWTSecret == defaultJWTSecret || len(c.Auth.JWTSecret) < minProdJWTSecretLength {
+			problem("JWT_SECRET must be a random value of at least %d characters in prod", minProdJWTSecretLength)
+		}
+		if c.DB.Password == defaultDBPassword {
+			problem("DATABASE_PASSWORD must not be the default password in prod")
+		}
+		if c.Notifier.Type == "log" {
+			problem("NOTIFIER=log writes password reset tokens to the log and is not allowed in prod")
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateKeyPair(problem func(string, ...any), prefix, certFile, keyFile string) {
+	if (certFile == "") != (keyFile == "") {
+		problem("%s_CERT_FILE and %s_KEY_FILE must be set together", prefix, prefix)
+		return
+	}
+	if certFile != "" {
+		validateFile(problem, prefix+"_CERT_FILE", certFile)
+		validateFile(problem, prefix+"_KEY_FILE", keyFile)
+	}
+}
+
+func validateFile(problem func(string, ...any), name, path string) {
+	if _, err := os.Stat(path); err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		problem("%s=%q: %v", name, path, err)
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate_AcceptsDefaults(t *testing.T) {
+	assert.NoError(t, Default().Validate())
+}
+
+func TestValidate_ReportsAllProblems(t *testing.T) {
+	cfg := Default()
+	cfg.Env = "production"
+	cfg.HTTP.Port = "http"
+	cfg.Metrics.Port = "3000"
+	cfg.HTTP.ReadTimeout = -time.Second
+	cfg.HTTP.BodyLimit = -1
+	cfg.Notifier.Type = "smtp"
+	cfg.DB.MaxIdleConns = 50
+	cfg.DB.ConnectAttempts = 0
+	cfg.Cache.Enabled = true
+	cfg.Cache.Backend = "redis"
+	cfg.Cache.TTL = 0
+	cfg.Capacity.Overflow = "ignore"
+	cfg.HTTP.IdempotencyLease = 0
+	cfg.DB.ReplicaDSN = "host=replica"
+	cfg.DB.ReplicaCheckInterval = 0
+
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, `SERVER_PORT="http" is not a port number`)
+	assert.ErrorContains(t, err, "GRPC_PORT and METRICS_PORT both use port 3000")
+	assert.ErrorContains(t, err, "HTTP_READ_TIMEOUT=-1s must not be negative")
+	assert.ErrorContains(t, err, "HTTP_BODY_LIMIT=-1 must not be negative")
+	assert.ErrorContains(t, err, `APP_ENV="production" must be dev, test or prod`)
+	assert.ErrorContains(t, err, "NOTIFIER=smtp requires SMTP_HOST and SMTP_FROM")
+	assert.ErrorContains(t, err, "DATABASE_MAX_IDLE_CONNS=50 must not exceed DATABASE_MAX_OPEN_CONNS=25")
+	assert.ErrorContains(t, err, "DATABASE_CONNECT_ATTEMPTS=0 must be at least 1")
+	assert.ErrorContains(t, err, `CACHE_BACKEND="redis" must be memory`)
+	assert.ErrorContains(t, err, "CACHE_TTL=0s and CACHE_MAX_ENTRIES=1000 must be positive")
+	assert.ErrorContains(t, err, `CAPACITY_OVERFLOW="ignore" must be reject or warn`)
+	assert.ErrorContains(t, err, "IDEMPOTENCY_LEASE=0s must be positive and not exceed IDEMPOTENCY_TTL=24h0m0s")
+	assert.ErrorContains(t, err, "DATABASE_REPLICA_CHECK_INTERVAL=0s must be positive")
+}
+
+func TestValidate_RejectsInsecureDefaultsInProd(t *testing.T) {
+	cfg := Default()
+	cfg.Env = EnvProd
+
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, "JWT_SECRET must be a random value of at least 32 characters in prod")
+	assert.ErrorContains(t, err, "DATABASE_PASSWORD must not be the default password in prod")
+	assert.ErrorContains(t, err, "NOTIFIER=log writes password reset tokens to the log")
+
+	cfg.Auth.JWTSecret = "0123456789abcdef0123456789abcdef"
+	cfg.DB.Password = "s3cret-db-password"
+	cfg.Notifier.Type = "file"
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidate_TLSFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	for _, file := range []string{certFile, keyFile} {
+		assert.NoError(t, os.WriteFile(file, []byte("pem"), 0o600))
+	}
+
+	cfg := Default()
+	cfg.HTTP.TLSCertFile, cfg.HTTP.TLSKeyFile = certFile, keyFile
+	cfg.GRPC.TLSCertFile, cfg.GRPC.TLSKeyFile = certFile, keyFile
+	assert.NoError(t, cfg.Validate())
+
+	cfg.GRPC.TLSClientCAFile = filepath.Join(dir, "ca.crt")
+	assert.ErrorContains(t, cfg.Validate(), "GRPC_TLS_CLIENT_CA_FILE=")
+	assert.ErrorContains(t, cfg.Validate(), "no such file or directory")
+
+	cfg = Default()
+	cfg.HTTP.TLSCertFile = certFile
+	assert.EqualError(t, cfg.Validate(), "HTTP_TLS_CERT_FILE and HTTP_TLS_KEY_FILE must be set together")
+
+	cfg = Default()
+	cfg.GRPC.TLSClientCAFile = certFile
+	assert.EqualError(t, cfg.Validate(), "GRPC_TLS_CLIENT_CA_FILE requires GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE")
+}
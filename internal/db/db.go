@@ -1,35 +1,99 @@
 package db
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
+	"log"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func InitializeDB(dsn string) (*sql.DB, error) {
-	return initializeWithRetry(dsn, 5, 2*time.Second)
+// SchemaVersion is the latest migration in migrations/ the service relies on,
+// /readyz fails until the database is migrated to it.
+const SchemaVersion = 19
+
+// How long a single connection attempt may take
+const connectTimeout = 5 * time.Second
+
+// Pool configures the connection pool, zero values keep the pgxpool defaults.
+// MinConns connections are kept open while the service is idle.
+type Pool struct {
+	MaxConns        int
+	MinConns        int
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
 }
 
-func InitializeTestDB(dsn string) (*sql.DB, error) {
-	return initializeWithRetry(dsn, 3, 1*time.Second)
+// Backoff retries connecting with a delay that starts at Initial and doubles after
+// every failed attempt up to Max.
+type Backoff struct {
+	Attempts int
+	Initial  time.Duration
+	Max      time.Duration
 }
 
-func initializeWithRetry(dsn string, maxRetries int, delay time.Duration) (*sql.DB, error) {
-	var db *sql.DB
-	var err error
+// Delay is the pause after the given failed attempt, counting from zero.
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := b.Initial
+	for i := 0; i < attempt && delay < b.Max; i++ {
+		delay *= 2
+	}
+	return min(delay, b.Max)
+}
 
-	for i := 0; i < maxRetries; i++ {
-		db, err = sql.Open("postgres", dsn)
-		if err == nil {
-			err = db.Ping()
-			if err == nil {
+// Open configures the pool without connecting, connections are made on first use.
+// pgx prepares every statement once per connection and reuses it afterwards instead
+// of parsing the query on every call.
+func Open(dsn string, pool Pool) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN: %w", err)
+	}
+	config.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+	if pool.MaxConns > 0 {
+		config.MaxConns = int32(pool.MaxConns)
+	}
+	config.MinConns = int32(min(pool.MinConns, int(config.MaxConns)))
+	if pool.MaxConnLifetime > 0 {
+		config.MaxConnLifetime = pool.MaxConnLifetime
+	}
+	if pool.MaxConnIdleTime > 0 {
+		config.MaxConnIdleTime = pool.MaxConnIdleTime
+	}
+	return pgxpool.NewWithConfig(context.Background(), config)
+}
 
-				return db, nil
-			}
+func InitializeDB(dsn string, pool Pool, backoff Backoff) (*pgxpool.Pool, error) {
+	return initializeWithRetry(dsn, pool, backoff)
+}
+
+func InitializeTestDB(dsn string) (*pgxpool.Pool, error) {
+	return initializeWithRetry(dsn, Pool{}, Backoff{Attempts: 3, Initial: time.Second, Max: time.Second})
+}
+
+func initializeWithRetry(dsn string, pool Pool, backoff Backoff) (*pgxpool.Pool, error) {
+	db, err := Open(dsn, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < backoff.Attempts; attempt++ {
+		if attempt > 0 {
+			delay := backoff.Delay(attempt - 1)
+			log.Printf("Failed to connect to DB (attempt %d of %d), retrying in %s: %v", attempt, backoff.Attempts, delay, err)
+			time.Sleep(delay)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+		err = db.Ping(ctx)
+		cancel()
+		if err == nil {
+			return db, nil
 		}
-		time.Sleep(delay)
 	}
-	return nil, fmt.Errorf("failed to connect to DB after %d retries: %w", maxRetries, err)
+
+	db.Close()
+	return nil, fmt.Errorf("failed to connect to DB after %d attempts: %w", backoff.Attempts, err)
 }
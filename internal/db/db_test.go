@@ -0,0 +1,20 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	backoff := Backoff{Attempts: 8, Initial: 500 * time.Millisecond, Max: 5 * time.Second}
+
+	var delays []time.Duration
+	for attempt := 0; attempt < 6; attempt++ {
+		delays = append(delays, backoff.Delay(attempt))
+	}
+	assert.Equal(t, []time.Duration{
+		500 * time.Millisecond, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second,
+	}, delays)
+}
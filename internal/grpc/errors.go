@@ -0,0 +1,69 @@
+package grpcserver
+
+import (
+	"log"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+
+	"pvzService/internal/apperrors"
+)
+
+const errorDomain = "pvzService"
+
+// toStatus maps domain errors to gRPC statuses, mirroring the REST error handler. The error code
+// is attached as ErrorInfo.Reason and validation failures as BadRequest field violations.
+func toStatus(err error) error {
+	appErr := apperrors.From(err)
+	if appErr.Code == apperrors.CodeInternal {
+		log.Printf("Internal gRPC error: %v", appErr.Err)
+	}
+
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: string(appErr.Code), Domain: errorDomain}}
+	if len(appErr.Details) > 0 {
+		violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(appErr.Details))
+		for _, fieldErr := range appErr.Details {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       fieldErr.Field,
+				Description: fieldErr.Message,
+			})
+		}
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	}
+
+	st := status.New(grpcCode(appErr.Code), appErr.Message)
+	if detailed, err := st.WithDetails(details...); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+func grpcCode(code apperrors.Code) codes.Code {
+	switch code {
+	case apperrors.CodeInvalidRequest, apperrors.CodeValidationFailed, apperrors.CodeInvalidRole, apperrors.CodeInvalidCity,
+		apperrors.CodeInvalidProductType, apperrors.CodeInvalidCurrentPassword, apperrors.CodeInvalidResetToken:
+		return codes.InvalidArgument
+	case apperrors.CodeEmailAlreadyExists:
+		return codes.AlreadyExists
+	case apperrors.CodeReceptionAlreadyOpen, apperrors.CodeNoOpenReception, apperrors.CodeNoProductsToDelete,
+		apperrors.CodeIdempotencyInProgress, apperrors.CodeIdempotencyKeyReused, apperrors.CodeIdempotencyKeyExpired, apperrors.CodeCannotModifySelf,
+		apperrors.CodePVZArchived, apperrors.CodePVZHasOpenReception, apperrors.CodePVZCapacityExceeded,
+		apperrors.CodeProductAlreadyIssued, apperrors.CodeReceptionNotClosed:
+		return codes.FailedPrecondition
+	case apperrors.CodeInvalidCredentials, apperrors.CodeUnauthorized, apperrors.CodeTokenExpired, apperrors.CodeOIDCLoginFailed:
+		return codes.Unauthenticated
+	case apperrors.CodeForbidden, apperrors.CodeOIDCRoleNotMapped:
+		return codes.PermissionDenied
+	case apperrors.CodeTooManyLoginAttempts, apperrors.CodeRateLimited:
+		return codes.ResourceExhausted
+	case apperrors.CodeOIDCUnavailable:
+		return codes.Unavailable
+	case apperrors.CodeNotFound, apperrors.CodePVZNotFound, apperrors.CodeProductNotFound, apperrors.CodeUserNotFound,
+		apperrors.CodeAPIKeyNotFound:
+		return codes.NotFound
+	default:
+		return codes.Internal
+	}
+}
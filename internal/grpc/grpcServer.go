@@ -2,83 +2,165 @@ package grpcserver
 
 import (
 	"context"
-	"database/sql"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net"
+	"strings"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	"pvzService/internal/models"
+	"pvzService/internal/processors"
 	pb "pvzService/internal/proto"
+	"pvzService/internal/validation"
 )
 
 type PVZServer struct {
 	pb.UnimplementedPVZServiceServer
-	db *sql.DB
+	pvz processors.PVZProcessor
 }
 
-func NewPVZServer(db *sql.DB) *PVZServer {
-	return &PVZServer{db: db}
+func NewPVZServer(pvz processors.PVZProcessor) *PVZServer {
+	return &PVZServer{pvz: pvz}
 }
 
+// GetPVZList lists the PVZ in operation, archived PVZ are left out.
 func (s *PVZServer) GetPVZList(ctx context.Context, req *pb.GetPVZListRequest) (*pb.GetPVZListResponse, error) {
-	rows, err := s.db.Query(`
-        SELECT 
-          p.id, p.registration_date, p.city
-        FROM pvz p
-        ORDER BY p.registration_date
-      `)
+	result, err := s.pvz.ListActivePVZs()
 	if err != nil {
-		return nil, fmt.Errorf("failed to query PVZ list: %w", err)
+		return nil, toStatus(err)
 	}
-	defer rows.Close()
-
-	var pvzList []*pb.PVZ
-
-	for rows.Next() {
-		var pvzID sql.NullString
-		var pvzRegDate sql.NullTime
-		var pvzCity sql.NullString
-
-		err = rows.Scan(
-			&pvzID, &pvzRegDate, &pvzCity,
-		)
-
-		if err != nil {
-			log.Println("Error scanning row:", err)
-			continue
-		}
-		if pvzID.Valid {
-			pvz := &pb.PVZ{
-				Id:               pvzID.String,
-				RegistrationDate: timestamppb.New(pvzRegDate.Time),
-				City:             pvzCity.String,
-			}
-
-			pvzList = append(pvzList, pvz)
-		}
+
+	pvzs := make([]*pb.PVZ, 0, len(result))
+	for _, pvz := range result {
+		pvzs = append(pvzs, pvzToProto(pvz))
+	}
+	return &pb.GetPVZListResponse{Pvzs: pvzs}, nil
+}
+
+// FindNearbyPVZ finds the PVZ in operation nearest to a point, with open_at only the
+// PVZ open at that time.
+func (s *PVZServer) FindNearbyPVZ(ctx context.Context, req *pb.FindNearbyPVZRequest) (*pb.FindNearbyPVZResponse, error) {
+	lat, lon := req.GetLatitude(), req.GetLongitude()
+	query := models.NearbyPVZQuery{Lat: &lat, Lon: &lon, RadiusKm: req.GetRadiusKm(), Limit: int(req.GetLimit())}
+	if req.GetOpenAt() != nil {
+		query.OpenAt = req.GetOpenAt().AsTime().Format(time.RFC3339)
+	}
+	if err := validation.Struct(query); err != nil {
+		return nil, toStatus(err)
+	}
+
+	result, err := s.pvz.ListNearbyPVZs(lat, lon, query.RadiusKm, query.OpenAt, "", query.Limit)
+	if err != nil {
+		return nil, toStatus(err)
 	}
 
-	if err := rows.Err(); err != nil {
-		log.Println("Error iterating rows:", err)
+	pvzs := make([]*pb.NearbyPVZ, 0, len(result))
+	for _, nearby := range result {
+		pvzs = append(pvzs, &pb.NearbyPVZ{Pvz: pvzToProto(nearby.PVZ), DistanceKm: nearby.DistanceKm})
 	}
+	return &pb.FindNearbyPVZResponse{Pvzs: pvzs}, nil
+}
 
-	return &pb.GetPVZListResponse{Pvzs: pvzList}, nil
+func pvzToProto(pvz models.PVZ) *pb.PVZ {
+	return &pb.PVZ{
+		Id:               pvz.ID,
+		RegistrationDate: timestamppb.New(pvz.RegistrationDate),
+		City:             pvz.City,
+		Timezone:         pvz.Timezone,
+		Name:             pvz.Name,
+		Address:          pvz.Address,
+		Latitude:         pvz.Latitude,
+		Longitude:        pvz.Longitude,
+		WorkingHours:     workingHoursToProto(pvz.WorkingHours),
+	}
 }
 
-func StartGRPCServer(db *sql.DB, port string) error {
-	lis, err := net.Listen("tcp", ":"+port)
+func workingHoursToProto(hours []models.WorkingHours) []*pb.WorkingHours {
+	result := make([]*pb.WorkingHours, 0, len(hours))
+	for _, h := range hours {
+		result = append(result, &pb.WorkingHours{
+			Weekday: pb.Weekday(pb.Weekday_value["WEEKDAY_"+strings.ToUpper(h.Weekday)]),
+			Opens:   h.Opens,
+			Closes:  h.Closes,
+		})
+	}
+	return result
+}
+
+// Server is the gRPC server of the service, started and stopped by the lifecycle manager.
+type Server struct {
+	grpc   *grpc.Server
+	health *health.Server
+	port   string
+}
+
+// NewServer serves plaintext gRPC when tlsConfig is nil.
+func NewServer(pvz processors.PVZProcessor, port string, tlsConfig *tls.Config) *Server {
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	s := grpc.NewServer(opts...)
+	pb.RegisterPVZServiceServer(s, NewPVZServer(pvz))
+
+	// grpc.health.v1 reports NOT_SERVING until the first readiness check passes
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
+	server := &Server{grpc: s, health: healthServer, port: port}
+	server.SetServing(false)
+	return server
+}
+
+// SetServing updates the status reported by grpc.health.v1 for the whole server and
+// for PVZService.
+func (s *Server) SetServing(serving bool) {
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	s.health.SetServingStatus("", status)
+	s.health.SetServingStatus(pb.PVZService_ServiceDesc.ServiceName, status)
+}
+
+// Serve blocks until the server is stopped.
+func (s *Server) Serve() error {
+	lis, err := net.Listen("tcp", ":"+s.port)
 	if err != nil {
 		return fmt.Errorf("failed to listen: %w", err)
 	}
 
-	s := grpc.NewServer()
-	pb.RegisterPVZServiceServer(s, NewPVZServer(db))
-
 	log.Printf("gRPC server listening at %v", lis.Addr())
-	if err := s.Serve(lis); err != nil {
+	if err := s.grpc.Serve(lis); err != nil {
 		return fmt.Errorf("failed to serve: %w", err)
 	}
 
 	return nil
 }
+
+// Shutdown stops accepting calls and waits for the running ones. Calls still running
+// when ctx is done are cancelled.
+func (s *Server) Shutdown(ctx context.Context) error {
+	// Clients watching the health service move to other instances first
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpc.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,108 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
+	"pvzService/internal/processors"
+	pb "pvzService/internal/proto"
+)
+
+// nearbyProcessor answers nearby searches with fixed PVZ and records the arguments.
+type nearbyProcessor struct {
+	processors.PVZProcessor
+	result []models.NearbyPVZ
+	openAt string
+	limit  int
+}
+
+// listProcessor answers the listing with fixed PVZ or an error.
+type listProcessor struct {
+	processors.PVZProcessor
+	result []models.PVZ
+	err    error
+}
+
+func (p *listProcessor) ListActivePVZs() ([]models.PVZ, error) {
+	return p.result, p.err
+}
+
+func (p *nearbyProcessor) ListNearbyPVZs(lat, lon, radiusKm float64, openAt, city string, limit int) ([]models.NearbyPVZ, error) {
+	p.openAt, p.limit = openAt, limit
+	return p.result, nil
+}
+
+func TestPVZServer_GetPVZList(t *testing.T) {
+	registered := time.Date(2025, 4, 6, 12, 0, 0, 0, time.UTC)
+	server := NewPVZServer(&listProcessor{result: []models.PVZ{{
+		ID:               "pvz1",
+		RegistrationDate: registered.In(time.FixedZone("MSK", 3*60*60)),
+		City:             "Москва",
+		Timezone:         "Europe/Moscow",
+		Name:             "ПВЗ на Тверской",
+		WorkingHours:     []models.WorkingHours{{Weekday: "monday", Opens: "09:00", Closes: "21:00"}},
+	}}})
+
+	resp, err := server.GetPVZList(context.Background(), &pb.GetPVZListRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Pvzs, 1)
+	assert.Equal(t, "ПВЗ на Тверской", resp.Pvzs[0].Name)
+	assert.True(t, registered.Equal(resp.Pvzs[0].RegistrationDate.AsTime()))
+	require.Len(t, resp.Pvzs[0].WorkingHours, 1)
+	assert.Equal(t, pb.Weekday_WEEKDAY_MONDAY, resp.Pvzs[0].WorkingHours[0].Weekday)
+
+	server = NewPVZServer(&listProcessor{err: apperrors.Internal("failed to list PVZ", errors.New("scan error"))})
+	_, err = server.GetPVZList(context.Background(), &pb.GetPVZListRequest{})
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestPVZServer_FindNearbyPVZ(t *testing.T) {
+	lat, lon := 55.7601, 37.6186
+	processor := &nearbyProcessor{result: []models.NearbyPVZ{{
+		PVZ: models.PVZ{
+			ID:           "pvz1",
+			City:         "Москва",
+			Timezone:     "Europe/Moscow",
+			Name:         "ПВЗ на Тверской",
+			Latitude:     &lat,
+			Longitude:    &lon,
+			WorkingHours: []models.WorkingHours{{Weekday: "sunday", Opens: "10:00", Closes: "24:00"}},
+		},
+		DistanceKm: 1.5,
+	}}}
+	server := NewPVZServer(processor)
+
+	openAt := time.Date(2025, 4, 6, 12, 0, 0, 0, time.UTC)
+	resp, err := server.FindNearbyPVZ(context.Background(), &pb.FindNearbyPVZRequest{
+		Latitude: 55.75, Longitude: 37.62, RadiusKm: 5, Limit: 10, OpenAt: timestamppb.New(openAt),
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Pvzs, 1)
+	assert.Equal(t, "2025-04-06T12:00:00Z", processor.openAt)
+	assert.Equal(t, 10, processor.limit)
+
+	nearby := resp.Pvzs[0]
+	assert.Equal(t, 1.5, nearby.DistanceKm)
+	assert.Equal(t, "ПВЗ на Тверской", nearby.Pvz.Name)
+	assert.Equal(t, lat, nearby.Pvz.GetLatitude())
+	require.Len(t, nearby.Pvz.WorkingHours, 1)
+	assert.Equal(t, pb.Weekday_WEEKDAY_SUNDAY, nearby.Pvz.WorkingHours[0].Weekday)
+	assert.Equal(t, "24:00", nearby.Pvz.WorkingHours[0].Closes)
+}
+
+func TestPVZServer_FindNearbyPVZ_InvalidRequest(t *testing.T) {
+	server := NewPVZServer(&nearbyProcessor{})
+
+	_, err := server.FindNearbyPVZ(context.Background(), &pb.FindNearbyPVZRequest{Latitude: 91, Longitude: 37.62, RadiusKm: 5})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,45 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "pvzService/internal/proto"
+)
+
+func TestServer_Health(t *testing.T) {
+	server := NewServer(nil, "0", nil)
+	lis := bufconn.Listen(1024 * 1024)
+	go server.grpc.Serve(lis)
+	defer server.grpc.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	status := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		return resp.Status
+	}
+
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(""), "not serving before the first readiness check")
+
+	server.SetServing(true)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status(""))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status(pb.PVZService_ServiceDesc.ServiceName))
+
+	server.SetServing(false)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(pb.PVZService_ServiceDesc.ServiceName))
+}
@@ -0,0 +1,130 @@
+package grpcserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// issue creates a certificate signed by the parent or a self-signed CA without one.
+func issue(t *testing.T, name string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) write(t *testing.T, dir, name string) (string, string) {
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+// handshake connects to a TLS listener with the server config and reports the
+// handshake error seen by the server.
+func handshake(t *testing.T, serverConfig, clientConfig *tls.Config) error {
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	require.NoError(t, err)
+	defer lis.Close()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer conn.Close()
+		serverErr <- conn.(*tls.Conn).Handshake()
+	}()
+
+	conn, err := tls.Dial("tcp", lis.Addr().String(), clientConfig)
+	if err == nil {
+		conn.Close()
+	}
+	return <-serverErr
+}
+
+func TestServerTLSConfig_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "pvz-ca", nil)
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := issue(t, "localhost", ca).write(t, dir, "server")
+
+	serverConfig, err := ServerTLSConfig(certFile, keyFile, caFile)
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, serverConfig.ClientAuth)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	client := issue(t, "reports", ca)
+	assert.NoError(t, handshake(t, serverConfig, &tls.Config{RootCAs: roots, ServerName: "localhost",
+		Certificates: []tls.Certificate{client.tlsCertificate()}}))
+
+	assert.Error(t, handshake(t, serverConfig, &tls.Config{RootCAs: roots, ServerName: "localhost"}), "client without a certificate")
+
+	stranger := issue(t, "stranger", issue(t, "other-ca", nil))
+	assert.Error(t, handshake(t, serverConfig, &tls.Config{RootCAs: roots, ServerName: "localhost",
+		Certificates: []tls.Certificate{stranger.tlsCertificate()}}), "certificate of another CA")
+}
+
+func TestServerTLSConfig_Errors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := issue(t, "localhost", nil).write(t, dir, "server")
+
+	serverConfig, err := ServerTLSConfig(certFile, keyFile, "")
+	require.NoError(t, err)
+	assert.Equal(t, tls.NoClientCert, serverConfig.ClientAuth)
+
+	_, err = ServerTLSConfig(filepath.Join(dir, "missing.crt"), keyFile, "")
+	assert.ErrorContains(t, err, "failed to load gRPC server certificate")
+
+	_, err = ServerTLSConfig(certFile, keyFile, keyFile)
+	assert.ErrorContains(t, err, "no certificates found")
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"pvzService/internal/models"
+	"pvzService/internal/processors"
+)
+
+type APIKeyHandlers struct {
+	apiKeyProcessor processors.APIKeyProcessor
+}
+
+func NewAPIKeyHandlers(apiKeyProcessor processors.APIKeyProcessor) *APIKeyHandlers {
+	return &APIKeyHandlers{apiKeyProcessor: apiKeyProcessor}
+}
+
+func (h *APIKeyHandlers) CreateAPIKeyHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body models.CreateAPIKeyRequest
+		if err := parseBody(c, &body); err != nil {
+			return err
+		}
+
+		apiKey, err := h.apiKeyProcessor.CreateAPIKey(claimString(c, "userId"), userRole(c), body.Name, body.Permissions, body.ExpiresAt)
+		if err != nil {
+			return err
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(apiKey)
+	}
+}
+
+func (h *APIKeyHandlers) ListAPIKeysHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var query models.APIKeyListQuery
+		if err := parseQuery(c, &query); err != nil {
+			return err
+		}
+
+		apiKeys, err := h.apiKeyProcessor.ListAPIKeys(query.Page, query.Limit)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(apiKeys)
+	}
+}
+
+func (h *APIKeyHandlers) RevokeAPIKeyHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		keyID, err := pathUUID(c, "keyId")
+		if err != nil {
+			return err
+		}
+
+		if err := h.apiKeyProcessor.RevokeAPIKey(keyID); err != nil {
+			return err
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
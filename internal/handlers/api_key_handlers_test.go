@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
+)
+
+type MockAPIKeyProcessor struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyProcessor) CreateAPIKey(creatorID, creatorRole, name string, keyPermissions []string, expiresAt string) (models.CreatedAPIKey, error) {
+	args := m.Called(creatorID, creatorRole, name, keyPermissions, expiresAt)
+	return args.Get(0).(models.CreatedAPIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyProcessor) ListAPIKeys(page, limit int) ([]models.APIKey, error) {
+	args := m.Called(page, limit)
+	return args.Get(0).([]models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyProcessor) RevokeAPIKey(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyProcessor) Authenticate(key string) (models.APIKey, error) {
+	args := m.Called(key)
+	return args.Get(0).(models.APIKey), args.Error(1)
+}
+
+func newAPIKeyTestApp(mockProcessor *MockAPIKeyProcessor, actorID string) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("claims", jwt.MapClaims{"userId": actorID, "role": "moderator"})
+		c.Locals("role", "moderator")
+		return c.Next()
+	})
+
+	handler := NewAPIKeyHandlers(mockProcessor)
+	app.Post("/api-keys", handler.CreateAPIKeyHandler())
+	app.Get("/api-keys", handler.ListAPIKeysHandler())
+	app.Delete("/api-keys/:keyId", handler.RevokeAPIKeyHandler())
+	return app
+}
+
+func TestAPIKeyHandlers_CreateAPIKeyHandler(t *testing.T) {
+	actorID := uuid.NewString()
+	mockProcessor := new(MockAPIKeyProcessor)
+	app := newAPIKeyTestApp(mockProcessor, actorID)
+
+	t.Run("success", func(t *testing.T) {
+		created := models.CreatedAPIKey{APIKey: models.APIKey{ID: uuid.NewString(), Name: "reports"}, Key: "pvz_secret"}
+		mockProcessor.On("CreateAPIKey", actorID, "moderator", "reports", []string{"pvz:read"}, "").Return(created, nil).Once()
+
+		body, _ := json.Marshal(models.CreateAPIKeyRequest{Name: "reports", Permissions: []string{"pvz:read"}})
+		req := httptest.NewRequest("POST", "/api-keys", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+
+		var result models.CreatedAPIKey
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, "pvz_secret", result.Key)
+		mockProcessor.AssertExpectations(t)
+	})
+
+	t.Run("permission not held", func(t *testing.T) {
+		mockProcessor.On("CreateAPIKey", actorID, "moderator", "writer", []string{"product:create"}, "").
+			Return(models.CreatedAPIKey{}, apperrors.ErrAPIKeyPermissionNotHeld).Once()
+
+		body, _ := json.Marshal(models.CreateAPIKeyRequest{Name: "writer", Permissions: []string{"product:create"}})
+		req := httptest.NewRequest("POST", "/api-keys", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("missing name", func(t *testing.T) {
+		body, _ := json.Marshal(models.CreateAPIKeyRequest{Permissions: []string{"pvz:read"}})
+		req := httptest.NewRequest("POST", "/api-keys", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestAPIKeyHandlers_ListAPIKeysHandler(t *testing.T) {
+	mockProcessor := new(MockAPIKeyProcessor)
+	app := newAPIKeyTestApp(mockProcessor, uuid.NewString())
+
+	expected := []models.APIKey{{ID: uuid.NewString(), Name: "reports", Prefix: "pvz_abcdefgh"}}
+	mockProcessor.On("ListAPIKeys", 1, 20).Return(expected, nil)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api-keys?page=1&limit=20", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var keys []models.APIKey
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&keys))
+	assert.Equal(t, expected[0].Prefix, keys[0].Prefix)
+}
+
+func TestAPIKeyHandlers_RevokeAPIKeyHandler(t *testing.T) {
+	mockProcessor := new(MockAPIKeyProcessor)
+	app := newAPIKeyTestApp(mockProcessor, uuid.NewString())
+
+	keyID, unknownID := uuid.NewString(), uuid.NewString()
+	mockProcessor.On("RevokeAPIKey", keyID).Return(nil)
+	mockProcessor.On("RevokeAPIKey", unknownID).Return(apperrors.ErrAPIKeyNotFound)
+
+	resp, err := app.Test(httptest.NewRequest("DELETE", "/api-keys/"+keyID, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest("DELETE", "/api-keys/"+unknownID, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest("DELETE", "/api-keys/not-a-uuid", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
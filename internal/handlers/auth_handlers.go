@@ -5,6 +5,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"time"
 
+	"pvzService/internal/apperrors"
 	"pvzService/internal/models"
 	"pvzService/internal/processors"
 )
@@ -22,6 +23,10 @@ func NewAuthHandlers(authProcessor processors.AuthProcessor, secret string) *Aut
 }
 
 func (h *AuthHandlers) GenerateToken(userID, role string) (string, error) {
+	return generateToken(h.secret, userID, role)
+}
+
+func generateToken(secret, userID, role string) (string, error) {
 	claims := jwt.MapClaims{
 		"userId": userID,
 		"role":   role,
@@ -31,30 +36,24 @@ func (h *AuthHandlers) GenerateToken(userID, role string) (string, error) {
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(h.secret))
+	return token.SignedString([]byte(secret))
 }
 
 func (h *AuthHandlers) DummyLoginHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		var body struct {
-			Role string `json:"role"`
-		}
-		if err := c.BodyParser(&body); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{Message: "Invalid request body format"})
+		var body models.DummyLoginRequest
+		if err := parseBody(c, &body); err != nil {
+			return err
 		}
 
 		userID, err := h.authProcessor.DummyLogin(body.Role)
 		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{
-				Message: err.Error(),
-			})
+			return err
 		}
 
 		token, err := h.GenerateToken(userID, body.Role)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(models.Error{
-				Message: "Failed to generate token: " + err.Error(),
-			})
+			return apperrors.Internal("Failed to generate token", err)
 		}
 
 		return c.JSON(models.Token{Token: token})
@@ -63,34 +62,19 @@ func (h *AuthHandlers) DummyLoginHandler() fiber.Handler {
 
 func (h *AuthHandlers) RegisterHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		var body struct {
-			Email    string `json:"email"`
-			Password string `json:"password"`
-			Role     string `json:"role"`
+		var body models.RegisterRequest
+		if err := parseBody(c, &body); err != nil {
+			return err
 		}
 
-		if err := c.BodyParser(&body); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{
-				Message: "Invalid request body format",
-			})
-		}
-
-		userID, err := h.authProcessor.Register(body.Email, body.Password, body.Role)
+		userID, err := h.authProcessor.Register(body.Email, body.Password, body.Role, userRole(c))
 		if err != nil {
-			status := fiber.StatusInternalServerError
-			if err.Error() == "invalid role" || err.Error() == "email already exists" {
-				status = fiber.StatusBadRequest
-			}
-			return c.Status(status).JSON(models.Error{
-				Message: err.Error(),
-			})
+			return err
 		}
 
 		token, err := h.GenerateToken(userID, body.Role)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(models.Error{
-				Message: "Failed to generate token: " + err.Error(),
-			})
+			return apperrors.Internal("Failed to generate token", err)
 		}
 
 		return c.Status(fiber.StatusCreated).JSON(models.Token{Token: token})
@@ -99,29 +83,19 @@ func (h *AuthHandlers) RegisterHandler() fiber.Handler {
 
 func (h *AuthHandlers) LoginHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		var body struct {
-			Email    string `json:"email"`
-			Password string `json:"password"`
-		}
-
-		if err := c.BodyParser(&body); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{
-				Message: "Invalid request body format",
-			})
+		var body models.LoginRequest
+		if err := parseBody(c, &body); err != nil {
+			return err
 		}
 
-		userID, role, err := h.authProcessor.Login(body.Email, body.Password)
+		userID, role, err := h.authProcessor.Login(body.Email, body.Password, c.IP())
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(models.Error{
-				Message: err.Error(),
-			})
+			return err
 		}
 
 		token, err := h.GenerateToken(userID, role)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(models.Error{
-				Message: "Failed to generate token: " + err.Error(),
-			})
+			return apperrors.Internal("Failed to generate token", err)
 		}
 
 		return c.JSON(models.Token{Token: token})
@@ -2,7 +2,7 @@ package handlers
 
 import (
 	"bytes"
-	"errors"
+	"encoding/json"
 	"net/http/httptest"
 	"testing"
 	"time"
@@ -11,19 +11,22 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
 )
 
 type MockAuthProcessor struct {
 	mock.Mock
 }
 
-func (m *MockAuthProcessor) Register(email, password, role string) (string, error) {
-	args := m.Called(email, password, role)
+func (m *MockAuthProcessor) Register(email, password, role, actorRole string) (string, error) {
+	args := m.Called(email, password, role, actorRole)
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockAuthProcessor) Login(email, password string) (string, string, error) {
-	args := m.Called(email, password)
+func (m *MockAuthProcessor) Login(email, password, ip string) (string, string, error) {
+	args := m.Called(email, password, ip)
 	return args.String(0), args.String(1), args.Error(2)
 }
 
@@ -43,7 +46,7 @@ func (m *MockAuthProcessor) ComparePassword(hashedPassword, password string) err
 }
 
 func TestAuthHandlers_DummyLoginHandler_Success(t *testing.T) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 	mockProcessor := new(MockAuthProcessor)
 	handler := NewAuthHandlers(mockProcessor, "secret")
 
@@ -61,12 +64,10 @@ func TestAuthHandlers_DummyLoginHandler_Success(t *testing.T) {
 }
 
 func TestAuthHandlers_DummyLoginHandler_InvalidRole(t *testing.T) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 	mockProcessor := new(MockAuthProcessor)
 	handler := NewAuthHandlers(mockProcessor, "secret")
 
-	mockProcessor.On("DummyLogin", "invalid").Return("", errors.New("invalid role"))
-
 	app.Post("/dummyLogin", handler.DummyLoginHandler())
 
 	req := httptest.NewRequest("POST", "/dummyLogin", bytes.NewBufferString(`{"role":"invalid"}`))
@@ -75,19 +76,19 @@ func TestAuthHandlers_DummyLoginHandler_InvalidRole(t *testing.T) {
 	resp, err := app.Test(req)
 	assert.NoError(t, err)
 	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
-	mockProcessor.AssertExpectations(t)
+	mockProcessor.AssertNotCalled(t, "DummyLogin", mock.Anything)
 }
 
 func TestAuthHandlers_RegisterHandler_Success(t *testing.T) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 	mockProcessor := new(MockAuthProcessor)
 	handler := NewAuthHandlers(mockProcessor, "secret")
 
-	mockProcessor.On("Register", "test@example.com", "password", "employee").Return("user123", nil)
+	mockProcessor.On("Register", "test@example.com", "password123", "employee", "").Return("user123", nil)
 
 	app.Post("/register", handler.RegisterHandler())
 
-	req := httptest.NewRequest("POST", "/register", bytes.NewBufferString(`{"email":"test@example.com","password":"password","role":"employee"}`))
+	req := httptest.NewRequest("POST", "/register", bytes.NewBufferString(`{"email":"test@example.com","password":"password123","role":"employee"}`))
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := app.Test(req)
@@ -97,33 +98,53 @@ func TestAuthHandlers_RegisterHandler_Success(t *testing.T) {
 }
 
 func TestAuthHandlers_RegisterHandler_InvalidRole(t *testing.T) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 	mockProcessor := new(MockAuthProcessor)
 	handler := NewAuthHandlers(mockProcessor, "secret")
 
-	mockProcessor.On("Register", "test@example.com", "password", "invalid").Return("", errors.New("invalid role"))
-
 	app.Post("/register", handler.RegisterHandler())
 
-	req := httptest.NewRequest("POST", "/register", bytes.NewBufferString(`{"email":"test@example.com","password":"password","role":"invalid"}`))
+	req := httptest.NewRequest("POST", "/register", bytes.NewBufferString(`{"email":"test@example.com","password":"password123","role":"invalid"}`))
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := app.Test(req)
 	assert.NoError(t, err)
 	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	mockProcessor.AssertNotCalled(t, "Register", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthHandlers_RegisterHandler_PassesCallerRole(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	mockProcessor := new(MockAuthProcessor)
+	handler := NewAuthHandlers(mockProcessor, "secret")
+
+	mockProcessor.On("Register", "new@example.com", "password123", "moderator", "moderator").Return("user123", nil)
+
+	app.Post("/register", func(c *fiber.Ctx) error {
+		c.Locals("claims", jwt.MapClaims{"userId": "moderator1", "role": "moderator"})
+		c.Locals("role", "moderator")
+		return c.Next()
+	}, handler.RegisterHandler())
+
+	req := httptest.NewRequest("POST", "/register", bytes.NewBufferString(`{"email":"new@example.com","password":"password123","role":"moderator"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
 	mockProcessor.AssertExpectations(t)
 }
 
 func TestAuthHandlers_RegisterHandler_EmailExists(t *testing.T) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 	mockProcessor := new(MockAuthProcessor)
 	handler := NewAuthHandlers(mockProcessor, "secret")
 
-	mockProcessor.On("Register", "exists@example.com", "password", "employee").Return("", errors.New("email already exists"))
+	mockProcessor.On("Register", "exists@example.com", "password123", "employee", "").Return("", apperrors.ErrEmailAlreadyExists)
 
 	app.Post("/register", handler.RegisterHandler())
 
-	req := httptest.NewRequest("POST", "/register", bytes.NewBufferString(`{"email":"exists@example.com","password":"password","role":"employee"}`))
+	req := httptest.NewRequest("POST", "/register", bytes.NewBufferString(`{"email":"exists@example.com","password":"password123","role":"employee"}`))
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := app.Test(req)
@@ -132,12 +153,36 @@ func TestAuthHandlers_RegisterHandler_EmailExists(t *testing.T) {
 	mockProcessor.AssertExpectations(t)
 }
 
+func TestAuthHandlers_RegisterHandler_ValidationDetails(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	mockProcessor := new(MockAuthProcessor)
+	handler := NewAuthHandlers(mockProcessor, "secret")
+
+	app.Post("/register", handler.RegisterHandler())
+
+	req := httptest.NewRequest("POST", "/register", bytes.NewBufferString(`{"email":"not-an-email","password":"short","role":"employee"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	var errorResp models.Error
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResp))
+	assert.Equal(t, "VALIDATION_FAILED", errorResp.Code)
+	assert.Equal(t, []models.FieldError{
+		{Field: "email", Rule: "email", Message: "email must be a valid email address"},
+		{Field: "password", Rule: "password", Message: "password must be at least 8 characters long and contain letters and digits"},
+	}, errorResp.Details)
+	mockProcessor.AssertNotCalled(t, "Register", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestAuthHandlers_LoginHandler_Success(t *testing.T) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 	mockProcessor := new(MockAuthProcessor)
 	handler := NewAuthHandlers(mockProcessor, "secret")
 
-	mockProcessor.On("Login", "test@example.com", "password").Return("user123", "employee", nil)
+	mockProcessor.On("Login", "test@example.com", "password", mock.Anything).Return("user123", "employee", nil)
 
 	app.Post("/login", handler.LoginHandler())
 
@@ -151,11 +196,11 @@ func TestAuthHandlers_LoginHandler_Success(t *testing.T) {
 }
 
 func TestAuthHandlers_LoginHandler_InvalidCredentials(t *testing.T) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 	mockProcessor := new(MockAuthProcessor)
 	handler := NewAuthHandlers(mockProcessor, "secret")
 
-	mockProcessor.On("Login", "test@example.com", "wrong").Return("", "", errors.New("invalid email or password"))
+	mockProcessor.On("Login", "test@example.com", "wrong", mock.Anything).Return("", "", apperrors.ErrInvalidCredentials)
 
 	app.Post("/login", handler.LoginHandler())
 
@@ -168,6 +213,28 @@ func TestAuthHandlers_LoginHandler_InvalidCredentials(t *testing.T) {
 	mockProcessor.AssertExpectations(t)
 }
 
+func TestAuthHandlers_LoginHandler_TooManyAttempts(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	mockProcessor := new(MockAuthProcessor)
+	handler := NewAuthHandlers(mockProcessor, "secret")
+
+	mockProcessor.On("Login", "test@example.com", "password", "0.0.0.0").Return("", "", apperrors.ErrTooManyLoginAttempts)
+
+	app.Post("/login", handler.LoginHandler())
+
+	req := httptest.NewRequest("POST", "/login", bytes.NewBufferString(`{"email":"test@example.com","password":"password"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusTooManyRequests, resp.StatusCode)
+
+	var errorResp models.Error
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResp))
+	assert.Equal(t, "TOO_MANY_LOGIN_ATTEMPTS", errorResp.Code)
+	mockProcessor.AssertExpectations(t)
+}
+
 func TestGenerateToken(t *testing.T) {
 	handler := NewAuthHandlers(nil, "secret")
 	token, err := handler.GenerateToken("user123", "employee")
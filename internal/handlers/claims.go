@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// claimString returns a string claim of the authenticated user or an empty string
+// for anonymous requests.
+func claimString(c *fiber.Ctx, key string) string {
+	claims, ok := c.Locals("claims").(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	value, _ := claims[key].(string)
+	return value
+}
+
+// userRole returns the current role of the authenticated user from the database, not
+// the one in the token, or an empty string for anonymous requests.
+func userRole(c *fiber.Ctx) string {
+	role, _ := c.Locals("role").(string)
+	return role
+}
+
+// scopeCity returns the city the authenticated user is limited to or an empty string
+// when the user sees every city.
+func scopeCity(c *fiber.Ctx) string {
+	city, _ := c.Locals("city").(string)
+	return city
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
+)
+
+// ErrorHandler is the central Fiber error handler: it turns domain errors returned by
+// handlers and middleware into a JSON body with a machine-readable code.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return c.Status(fiberErr.Code).JSON(models.Error{
+			Code:    string(codeForStatus(fiberErr.Code)),
+			Message: fiberErr.Message,
+		})
+	}
+
+	appErr := apperrors.From(err)
+	if appErr.Code == apperrors.CodeInternal {
+		log.Printf("Internal error on %s %s: %v", c.Method(), c.Path(), appErr.Err)
+	}
+
+	return c.Status(apperrors.HTTPStatus(appErr.Code)).JSON(models.Error{
+		Code:    string(appErr.Code),
+		Message: appErr.Message,
+		Details: appErr.Details,
+	})
+}
+
+func codeForStatus(status int) apperrors.Code {
+	switch {
+	case status == fiber.StatusNotFound:
+		return apperrors.CodeNotFound
+	case status == fiber.StatusUnauthorized:
+		return apperrors.CodeUnauthorized
+	case status == fiber.StatusForbidden:
+		return apperrors.CodeForbidden
+	case status < fiber.StatusInternalServerError:
+		return apperrors.CodeInvalidRequest
+	default:
+		return apperrors.CodeInternal
+	}
+}
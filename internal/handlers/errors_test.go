@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
+)
+
+func TestErrorHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   string
+		expectedMsg    string
+	}{
+		{
+			name:           "domain error",
+			err:            apperrors.ErrReceptionAlreadyOpen,
+			expectedStatus: fiber.StatusBadRequest,
+			expectedCode:   "RECEPTION_ALREADY_OPEN",
+			expectedMsg:    "open reception already exists for this PVZ",
+		},
+		{
+			name:           "wrapped domain error",
+			err:            apperrors.Wrap(apperrors.ErrPVZNotFound, errors.New("fk violation")),
+			expectedStatus: fiber.StatusNotFound,
+			expectedCode:   "PVZ_NOT_FOUND",
+			expectedMsg:    "PVZ not found",
+		},
+		{
+			name:           "internal error hides cause",
+			err:            apperrors.Internal("database error", errors.New("connection refused")),
+			expectedStatus: fiber.StatusInternalServerError,
+			expectedCode:   "INTERNAL_ERROR",
+			expectedMsg:    "database error",
+		},
+		{
+			name:           "unknown error",
+			err:            errors.New("boom"),
+			expectedStatus: fiber.StatusInternalServerError,
+			expectedCode:   "INTERNAL_ERROR",
+			expectedMsg:    "internal server error",
+		},
+		{
+			name:           "fiber error",
+			err:            fiber.ErrNotFound,
+			expectedStatus: fiber.StatusNotFound,
+			expectedCode:   "NOT_FOUND",
+			expectedMsg:    "Not Found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Get("/", func(c *fiber.Ctx) error {
+				return tt.err
+			})
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+
+			var body models.Error
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, tt.expectedCode, body.Code)
+			assert.Equal(t, tt.expectedMsg, body.Message)
+		})
+	}
+}
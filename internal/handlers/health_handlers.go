@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"pvzService/internal/models"
+	"pvzService/internal/processors"
+)
+
+type HealthHandlers struct {
+	healthProcessor processors.HealthProcessor
+}
+
+func NewHealthHandlers(healthProcessor processors.HealthProcessor) *HealthHandlers {
+	return &HealthHandlers{healthProcessor: healthProcessor}
+}
+
+// LivenessHandler only reports that the process serves requests. It doesn't touch the
+// database, so an outage of the database doesn't get the service restarted.
+func (h *HealthHandlers) LivenessHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.SendString("OK")
+	}
+}
+
+// ReadinessHandler answers 503 while the service can't serve requests, so it is taken
+// out of load balancing until the checks pass again.
+func (h *HealthHandlers) ReadinessHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		readiness := h.healthProcessor.Readiness(c.UserContext())
+
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		if readiness.Status != models.HealthStatusOK {
+			c.Status(fiber.StatusServiceUnavailable)
+		}
+		return c.JSON(readiness)
+	}
+}
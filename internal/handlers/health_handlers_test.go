@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"pvzService/internal/models"
+)
+
+type MockHealthProcessor struct {
+	mock.Mock
+}
+
+func (m *MockHealthProcessor) Readiness(ctx context.Context) models.Readiness {
+	args := m.Called()
+	return args.Get(0).(models.Readiness)
+}
+
+func newHealthTestApp(mockProcessor *MockHealthProcessor) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	handler := NewHealthHandlers(mockProcessor)
+	app.Get("/livez", handler.LivenessHandler())
+	app.Get("/readyz", handler.ReadinessHandler())
+	return app
+}
+
+func TestHealthHandlers_LivenessHandler(t *testing.T) {
+	mockProcessor := new(MockHealthProcessor)
+	app := newHealthTestApp(mockProcessor)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/livez", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "OK", string(body))
+	mockProcessor.AssertNotCalled(t, "Readiness")
+}
+
+func TestHealthHandlers_ReadinessHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		readiness      models.Readiness
+		expectedStatus int
+	}{
+		{
+			name: "ready",
+			readiness: models.Readiness{Status: "ok", Checks: []models.HealthCheck{
+				{Name: "database", Status: "ok"},
+				{Name: "migrations", Status: "ok"},
+			}},
+			expectedStatus: fiber.StatusOK,
+		},
+		{
+			name: "not ready",
+			readiness: models.Readiness{Status: "unavailable", Checks: []models.HealthCheck{
+				{Name: "database", Status: "unavailable", Error: "database is unreachable"},
+			}},
+			expectedStatus: fiber.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProcessor := new(MockHealthProcessor)
+			app := newHealthTestApp(mockProcessor)
+			mockProcessor.On("Readiness").Return(tt.readiness)
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/readyz", nil))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+
+			var readiness models.Readiness
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&readiness))
+			assert.Equal(t, tt.readiness, readiness)
+			mockProcessor.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
+	"pvzService/internal/processors"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStateTTL    = 10 * time.Minute
+	oidcCookiePath  = "/auth/oidc"
+	// The audience of the state cookie, access tokens have none
+	oidcStateAudience = "oidc-state"
+)
+
+type OIDCHandlers struct {
+	oidcProcessor processors.OIDCProcessor
+	secret        string
+	stateKey      []byte
+}
+
+// NewOIDCHandlers signs access tokens with secret and the state cookie with a key
+// derived from it, so neither is ever accepted in place of the other.
+func NewOIDCHandlers(oidcProcessor processors.OIDCProcessor, secret string) *OIDCHandlers {
+	return &OIDCHandlers{oidcProcessor: oidcProcessor, secret: secret, stateKey: oidcStateKey(secret)}
+}
+
+// oidcStateKey is the HMAC-SHA256 of the audience of the state cookie under secret.
+func oidcStateKey(secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(oidcStateAudience))
+	return mac.Sum(nil)
+}
+
+// LoginHandler redirects to the IdP. State, nonce and the PKCE verifier travel in a
+// signed short-lived cookie, so the flow needs no server-side storage.
+func (h *OIDCHandlers) LoginHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		state, nonce, verifier, err := newOIDCSecrets()
+		if err != nil {
+			return apperrors.Internal("Failed to generate OIDC state", err)
+		}
+
+		url, err := h.oidcProcessor.AuthCodeURL(c.UserContext(), state, nonce, verifier)
+		if err != nil {
+			return err
+		}
+
+		cookie, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"state":    state,
+			"nonce":    nonce,
+			"verifier": verifier,
+			"aud":      oidcStateAudience,
+			"exp":      time.Now().Add(oidcStateTTL).Unix(),
+		}).SignedString(h.stateKey)
+		if err != nil {
+			return apperrors.Internal("Failed to sign OIDC state", err)
+		}
+
+		c.Cookie(&fiber.Cookie{
+			Name:     oidcStateCookie,
+			Value:    cookie,
+			Path:     oidcCookiePath,
+			MaxAge:   int(oidcStateTTL.Seconds()),
+			Secure:   c.Protocol() == "https",
+			HTTPOnly: true,
+			SameSite: fiber.CookieSameSiteLaxMode,
+		})
+		return c.Redirect(url, fiber.StatusFound)
+	}
+}
+
+// CallbackHandler finishes the login started by LoginHandler and returns a service token.
+func (h *OIDCHandlers) CallbackHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		stateCookie := c.Cookies(oidcStateCookie)
+		c.ClearCookie(oidcStateCookie)
+
+		if c.Query("error") != "" {
+			return apperrors.Wrap(apperrors.ErrOIDCLoginFailed, errors.New(c.Query("error")))
+		}
+
+		claims := jwt.MapClaims{}
+		_, err := jwt.ParseWithClaims(stateCookie, claims, func(token *jwt.Token) (interface{}, error) {
+			return h.stateKey, nil
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired(), jwt.WithAudience(oidcStateAudience))
+		if err != nil {
+			return apperrors.Wrap(apperrors.ErrOIDCLoginFailed, err)
+		}
+
+		state, _ := claims["state"].(string)
+		nonce, _ := claims["nonce"].(string)
+		verifier, _ := claims["verifier"].(string)
+		if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+			return apperrors.Wrap(apperrors.ErrOIDCLoginFailed, errors.New("state mismatch"))
+		}
+		if c.Query("code") == "" {
+			return apperrors.Wrap(apperrors.ErrOIDCLoginFailed, errors.New("missing code"))
+		}
+
+		userID, role, err := h.oidcProcessor.Login(c.UserContext(), c.Query("code"), verifier, nonce)
+		if err != nil {
+			return err
+		}
+
+		token, err := generateToken(h.secret, userID, role)
+		if err != nil {
+			return apperrors.Internal("Failed to generate token", err)
+		}
+
+		return c.JSON(models.Token{Token: token})
+	}
+}
+
+// newOIDCSecrets returns the state, nonce and PKCE verifier of a login, each made of
+// 32 random bytes in base64url.
+func newOIDCSecrets() (string, string, string, error) {
+	values := make([]string, 3)
+	for i := range values {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return "", "", "", err
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return values[0], values[1], values[2], nil
+}
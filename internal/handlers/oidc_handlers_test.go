@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"pvzService/internal/apperrors"
+)
+
+type MockOIDCProcessor struct {
+	mock.Mock
+}
+
+func (m *MockOIDCProcessor) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	args := m.Called(state, nonce, verifier)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockOIDCProcessor) Login(ctx context.Context, code, verifier, nonce string) (string, string, error) {
+	args := m.Called(code, verifier, nonce)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func newOIDCTestApp(mockProcessor *MockOIDCProcessor) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	handler := NewOIDCHandlers(mockProcessor, "secret")
+	app.Get("/auth/oidc/login", handler.LoginHandler())
+	app.Get("/auth/oidc/callback", handler.CallbackHandler())
+	return app
+}
+
+func stateCookie(t *testing.T, claims jwt.MapClaims) *http.Cookie {
+	return signedStateCookie(t, claims, oidcStateKey("secret"))
+}
+
+func signedStateCookie(t *testing.T, claims jwt.MapClaims, key []byte) *http.Cookie {
+	value, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+	require.NoError(t, err)
+	return &http.Cookie{Name: oidcStateCookie, Value: value}
+}
+
+func callbackRequest(query string, cookie *http.Cookie) *http.Request {
+	req := httptest.NewRequest("GET", "/auth/oidc/callback?"+query, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	return req
+}
+
+func TestOIDCHandlers_LoginHandler(t *testing.T) {
+	mockProcessor := new(MockOIDCProcessor)
+	app := newOIDCTestApp(mockProcessor)
+	mockProcessor.On("AuthCodeURL", mock.Anything, mock.Anything, mock.Anything).Return("https://idp/authorize?state=s", nil)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/auth/oidc/login", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusFound, resp.StatusCode)
+	assert.Equal(t, "https://idp/authorize?state=s", resp.Header.Get("Location"))
+
+	cookies := resp.Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, oidcStateCookie, cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(cookies[0].Value, claims, func(*jwt.Token) (interface{}, error) { return oidcStateKey("secret"), nil },
+		jwt.WithAudience(oidcStateAudience))
+	require.NoError(t, err)
+	// The cookie is not signed with the access token secret
+	_, err = jwt.Parse(cookies[0].Value, func(*jwt.Token) (interface{}, error) { return []byte("secret"), nil })
+	assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+	call := mockProcessor.Calls[0].Arguments
+	assert.Equal(t, call.String(0), claims["state"])
+	assert.Equal(t, call.String(1), claims["nonce"])
+	assert.Equal(t, call.String(2), claims["verifier"])
+}
+
+func TestOIDCHandlers_LoginHandler_ProviderUnavailable(t *testing.T) {
+	mockProcessor := new(MockOIDCProcessor)
+	app := newOIDCTestApp(mockProcessor)
+	mockProcessor.On("AuthCodeURL", mock.Anything, mock.Anything, mock.Anything).Return("", apperrors.ErrOIDCUnavailable)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/auth/oidc/login", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusServiceUnavailable, resp.StatusCode)
+	assert.Empty(t, resp.Cookies())
+}
+
+func TestOIDCHandlers_CallbackHandler(t *testing.T) {
+	validClaims := jwt.MapClaims{"state": "s1", "nonce": "n1", "verifier": "v1", "aud": oidcStateAudience, "exp": time.Now().Add(time.Minute).Unix()}
+	withoutAudience := jwt.MapClaims{"state": "s1", "nonce": "n1", "verifier": "v1", "exp": time.Now().Add(time.Minute).Unix()}
+
+	t.Run("success", func(t *testing.T) {
+		mockProcessor := new(MockOIDCProcessor)
+		app := newOIDCTestApp(mockProcessor)
+		mockProcessor.On("Login", "code1", "v1", "n1").Return("user1", "moderator", nil)
+
+		resp, err := app.Test(callbackRequest("code=code1&state=s1", stateCookie(t, validClaims)))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		mockProcessor.AssertExpectations(t)
+	})
+
+	t.Run("role not mapped", func(t *testing.T) {
+		mockProcessor := new(MockOIDCProcessor)
+		app := newOIDCTestApp(mockProcessor)
+		mockProcessor.On("Login", "code1", "v1", "n1").Return("", "", apperrors.ErrOIDCRoleNotMapped)
+
+		resp, err := app.Test(callbackRequest("code=code1&state=s1", stateCookie(t, validClaims)))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	rejected := map[string]*http.Request{
+		"state mismatch":   callbackRequest("code=code1&state=other", stateCookie(t, validClaims)),
+		"missing cookie":   callbackRequest("code=code1&state=s1", nil),
+		"missing code":     callbackRequest("state=s1", stateCookie(t, validClaims)),
+		"idp error":        callbackRequest("error=access_denied&state=s1", stateCookie(t, validClaims)),
+		"expired cookie":   callbackRequest("code=code1&state=s1", stateCookie(t, jwt.MapClaims{"state": "s1", "exp": time.Now().Add(-time.Minute).Unix()})),
+		"cookie w/o exp":   callbackRequest("code=code1&state=s1", stateCookie(t, jwt.MapClaims{"state": "s1"})),
+		"access token key": callbackRequest("code=code1&state=s1", signedStateCookie(t, validClaims, []byte("secret"))),
+		"cookie w/o aud":   callbackRequest("code=code1&state=s1", stateCookie(t, withoutAudience)),
+		"forged cookie":    callbackRequest("code=code1&state=s1", &http.Cookie{Name: oidcStateCookie, Value: "forged"}),
+		"cookie w/o state": callbackRequest(url.Values{"code": {"code1"}, "state": {""}}.Encode(), stateCookie(t, jwt.MapClaims{"aud": oidcStateAudience, "exp": time.Now().Add(time.Minute).Unix()})),
+	}
+	for name, req := range rejected {
+		t.Run(name, func(t *testing.T) {
+			mockProcessor := new(MockOIDCProcessor)
+			app := newOIDCTestApp(mockProcessor)
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+			mockProcessor.AssertNotCalled(t, "Login", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
+	"pvzService/internal/processors"
+)
+
+type PasswordHandlers struct {
+	passwordProcessor processors.PasswordProcessor
+	secret            string
+}
+
+func NewPasswordHandlers(passwordProcessor processors.PasswordProcessor, secret string) *PasswordHandlers {
+	return &PasswordHandlers{passwordProcessor: passwordProcessor, secret: secret}
+}
+
+// ChangePasswordHandler returns a new token, since the caller's token is revoked
+// together with all other tokens issued before the change.
+func (h *PasswordHandlers) ChangePasswordHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body models.ChangePasswordRequest
+		if err := parseBody(c, &body); err != nil {
+			return err
+		}
+
+		userID := claimString(c, "userId")
+		if err := h.passwordProcessor.ChangePassword(userID, body.CurrentPassword, body.NewPassword); err != nil {
+			return err
+		}
+
+		token, err := generateToken(h.secret, userID, userRole(c))
+		if err != nil {
+			return apperrors.Internal("Failed to generate token", err)
+		}
+
+		return c.JSON(models.Token{Token: token})
+	}
+}
+
+func (h *PasswordHandlers) RequestPasswordResetHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body models.PasswordResetRequest
+		if err := parseBody(c, &body); err != nil {
+			return err
+		}
+
+		if err := h.passwordProcessor.RequestPasswordReset(body.Email); err != nil {
+			return err
+		}
+
+		return c.SendStatus(fiber.StatusAccepted)
+	}
+}
+
+func (h *PasswordHandlers) ResetPasswordHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body models.ResetPasswordRequest
+		if err := parseBody(c, &body); err != nil {
+			return err
+		}
+
+		if err := h.passwordProcessor.ResetPassword(body.Token, body.NewPassword); err != nil {
+			return err
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
+)
+
+type MockPasswordProcessor struct {
+	mock.Mock
+}
+
+func (m *MockPasswordProcessor) ChangePassword(userID, currentPassword, newPassword string) error {
+	args := m.Called(userID, currentPassword, newPassword)
+	return args.Error(0)
+}
+
+func (m *MockPasswordProcessor) RequestPasswordReset(email string) error {
+	args := m.Called(email)
+	return args.Error(0)
+}
+
+func (m *MockPasswordProcessor) ResetPassword(token, newPassword string) error {
+	args := m.Called(token, newPassword)
+	return args.Error(0)
+}
+
+func newPasswordTestApp(mockProcessor *MockPasswordProcessor) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	handler := NewPasswordHandlers(mockProcessor, "secret")
+
+	app.Post("/me/password", func(c *fiber.Ctx) error {
+		c.Locals("claims", jwt.MapClaims{"userId": "user1", "role": "employee"})
+		c.Locals("role", "employee")
+		return c.Next()
+	}, handler.ChangePasswordHandler())
+	app.Post("/password/reset-request", handler.RequestPasswordResetHandler())
+	app.Post("/password/reset", handler.ResetPasswordHandler())
+	return app
+}
+
+func postJSON(app *fiber.App, path, body string) (*httptest.ResponseRecorder, error) {
+	req := httptest.NewRequest("POST", path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := httptest.NewRecorder()
+	rec.Code = resp.StatusCode
+	_, err = rec.Body.ReadFrom(resp.Body)
+	return rec, err
+}
+
+func TestPasswordHandlers_ChangePasswordHandler(t *testing.T) {
+	t.Run("success returns a new token", func(t *testing.T) {
+		mockProcessor := new(MockPasswordProcessor)
+		app := newPasswordTestApp(mockProcessor)
+		mockProcessor.On("ChangePassword", "user1", "oldPassword1", "newPassword1").Return(nil)
+
+		resp, err := postJSON(app, "/me/password", `{"currentPassword":"oldPassword1","newPassword":"newPassword1"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.Code)
+
+		var token models.Token
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &token))
+		claims := jwt.MapClaims{}
+		_, err = jwt.ParseWithClaims(token.Token, claims, func(*jwt.Token) (interface{}, error) { return []byte("secret"), nil })
+		assert.NoError(t, err)
+		assert.Equal(t, "user1", claims["userId"])
+		assert.Equal(t, "employee", claims["role"])
+		mockProcessor.AssertExpectations(t)
+	})
+
+	t.Run("wrong current password", func(t *testing.T) {
+		mockProcessor := new(MockPasswordProcessor)
+		app := newPasswordTestApp(mockProcessor)
+		mockProcessor.On("ChangePassword", "user1", "wrong", "newPassword1").Return(apperrors.ErrInvalidCurrentPassword)
+
+		resp, err := postJSON(app, "/me/password", `{"currentPassword":"wrong","newPassword":"newPassword1"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.Code)
+
+		var errorResp models.Error
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &errorResp))
+		assert.Equal(t, "INVALID_CURRENT_PASSWORD", errorResp.Code)
+	})
+
+	t.Run("weak new password", func(t *testing.T) {
+		mockProcessor := new(MockPasswordProcessor)
+		app := newPasswordTestApp(mockProcessor)
+
+		resp, err := postJSON(app, "/me/password", `{"currentPassword":"oldPassword1","newPassword":"short"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.Code)
+		mockProcessor.AssertNotCalled(t, "ChangePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestPasswordHandlers_RequestPasswordResetHandler(t *testing.T) {
+	mockProcessor := new(MockPasswordProcessor)
+	app := newPasswordTestApp(mockProcessor)
+	mockProcessor.On("RequestPasswordReset", "user@example.com").Return(nil)
+
+	resp, err := postJSON(app, "/password/reset-request", `{"email":"user@example.com"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusAccepted, resp.Code)
+
+	resp, err = postJSON(app, "/password/reset-request", `{"email":"not-an-email"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.Code)
+	mockProcessor.AssertExpectations(t)
+}
+
+func TestPasswordHandlers_ResetPasswordHandler(t *testing.T) {
+	mockProcessor := new(MockPasswordProcessor)
+	app := newPasswordTestApp(mockProcessor)
+	mockProcessor.On("ResetPassword", "good", "newPassword1").Return(nil)
+	mockProcessor.On("ResetPassword", "used", "newPassword1").Return(apperrors.ErrInvalidResetToken)
+
+	resp, err := postJSON(app, "/password/reset", `{"token":"good","newPassword":"newPassword1"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusNoContent, resp.Code)
+
+	resp, err = postJSON(app, "/password/reset", `{"token":"used","newPassword":"newPassword1"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "INVALID_RESET_TOKEN")
+	mockProcessor.AssertExpectations(t)
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"github.com/gofiber/fiber/v2"
-	"github.com/google/uuid"
 	"pvzService/internal/prometheus"
 
 	"pvzService/internal/models"
@@ -11,6 +10,7 @@ import (
 type ProductProcessor interface {
 	AddProduct(pvzID, productType string) (models.Product, error)
 	DeleteLastProduct(pvzID string) error
+	IssueProduct(pvzID, productID string) (models.Product, error)
 }
 
 type ProductHandlers struct {
@@ -21,34 +21,16 @@ func NewProductHandlers(productProcessor ProductProcessor) *ProductHandlers {
 	return &ProductHandlers{productProcessor: productProcessor}
 }
 
-var allowedProductTypes = map[string]bool{
-	"электроника": true,
-	"одежда":      true,
-	"обувь":       true,
-}
-
 func (h *ProductHandlers) AddProductHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		var body struct {
-			Type  string `json:"type"`
-			PvzId string `json:"pvzId"`
-		}
-
-		if err := c.BodyParser(&body); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{Message: "Invalid request body"})
-		}
-
-		if _, err := uuid.Parse(body.PvzId); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{Message: "Invalid pvzId format"})
-		}
-
-		if !allowedProductTypes[body.Type] {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{Message: "Invalid product type"})
+		var body models.AddProductRequest
+		if err := parseBody(c, &body); err != nil {
+			return err
 		}
 
 		product, err := h.productProcessor.AddProduct(body.PvzId, body.Type)
 		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{Message: err.Error()})
+			return err
 		}
 
 		prometheus.ProductsAdded.Inc()
@@ -58,16 +40,35 @@ func (h *ProductHandlers) AddProductHandler() fiber.Handler {
 
 func (h *ProductHandlers) DeleteLastProductHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		pvzId := c.Params("pvzId")
-
-		if _, err := uuid.Parse(pvzId); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{Message: "Invalid pvzId format"})
+		pvzId, err := pathUUID(c, "pvzId")
+		if err != nil {
+			return err
 		}
 
 		if err := h.productProcessor.DeleteLastProduct(pvzId); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{Message: err.Error()})
+			return err
 		}
 
 		return c.SendStatus(fiber.StatusOK)
 	}
 }
+
+func (h *ProductHandlers) IssueProductHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pvzId, err := pathUUID(c, "pvzId")
+		if err != nil {
+			return err
+		}
+		productId, err := pathUUID(c, "productId")
+		if err != nil {
+			return err
+		}
+
+		product, err := h.productProcessor.IssueProduct(pvzId, productId)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(product)
+	}
+}
@@ -2,14 +2,17 @@ package handlers
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	"pvzService/internal/apperrors"
 	"pvzService/internal/models"
 )
 
@@ -27,8 +30,13 @@ func (m *MockProductProcessor) DeleteLastProduct(pvzID string) error {
 	return args.Error(0)
 }
 
+func (m *MockProductProcessor) IssueProduct(pvzID, productID string) (models.Product, error) {
+	args := m.Called(pvzID, productID)
+	return args.Get(0).(models.Product), args.Error(1)
+}
+
 func TestProductHandlers_AddProductHandler_Success(t *testing.T) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 	mockProcessor := new(MockProductProcessor)
 	handler := NewProductHandlers(mockProcessor)
 
@@ -54,7 +62,7 @@ func TestProductHandlers_AddProductHandler_Success(t *testing.T) {
 }
 
 func TestProductHandlers_AddProductHandler_InvalidUUID(t *testing.T) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 	handler := NewProductHandlers(nil)
 
 	app.Post("/products", handler.AddProductHandler())
@@ -69,7 +77,7 @@ func TestProductHandlers_AddProductHandler_InvalidUUID(t *testing.T) {
 }
 
 func TestProductHandlers_DeleteLastProductHandler_Success(t *testing.T) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 	mockProcessor := new(MockProductProcessor)
 	handler := NewProductHandlers(mockProcessor)
 
@@ -84,3 +92,34 @@ func TestProductHandlers_DeleteLastProductHandler_Success(t *testing.T) {
 	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
 	mockProcessor.AssertExpectations(t)
 }
+
+func TestProductHandlers_IssueProductHandler(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	mockProcessor := new(MockProductProcessor)
+	handler := NewProductHandlers(mockProcessor)
+
+	pvzID := uuid.NewString()
+	issuedID := uuid.NewString()
+	missingID := uuid.NewString()
+	issuedAt := time.Date(2025, 4, 3, 12, 0, 0, 0, time.UTC)
+	mockProcessor.On("IssueProduct", pvzID, issuedID).Return(models.Product{ID: issuedID, Type: "обувь", IssuedAt: &issuedAt}, nil)
+	mockProcessor.On("IssueProduct", pvzID, missingID).Return(models.Product{}, apperrors.ErrProductNotFound)
+
+	app.Post("/pvz/:pvzId/products/:productId/issue", handler.IssueProductHandler())
+
+	resp, err := app.Test(httptest.NewRequest("POST", "/pvz/"+pvzID+"/products/"+issuedID+"/issue", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var product models.Product
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+	assert.Equal(t, issuedAt, *product.IssuedAt)
+
+	resp, err = app.Test(httptest.NewRequest("POST", "/pvz/"+pvzID+"/products/"+missingID+"/issue", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest("POST", "/pvz/"+pvzID+"/products/not-a-uuid/issue", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	mockProcessor.AssertExpectations(t)
+}
@@ -2,8 +2,6 @@ package handlers
 
 import (
 	"pvzService/internal/prometheus"
-	"strconv"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
 
@@ -21,14 +19,14 @@ func NewPVZHandlers(pvzProcessor processors.PVZProcessor) *PVZHandlers {
 
 func (h *PVZHandlers) CreatePVZHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		var body models.PVZ
-		if err := c.BodyParser(&body); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{Message: "Invalid request"})
+		var body models.CreatePVZRequest
+		if err := parseBody(c, &body); err != nil {
+			return err
 		}
 
 		pvz, err := h.pvzProcessor.CreatePVZ(body.City)
 		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{Message: err.Error()})
+			return err
 		}
 
 		prometheus.PickupPointsCreated.Inc()
@@ -40,57 +38,115 @@ func (h *PVZHandlers) CreatePVZHandler() fiber.Handler {
 
 func (h *PVZHandlers) GetPVZListHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		pageStr := c.Query("page")
-		if pageStr == "" {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{
-				Message: "page parameter is required",
-			})
+		var query models.PVZListQuery
+		if err := parseQuery(c, &query); err != nil {
+			return err
 		}
 
-		limitStr := c.Query("limit")
-		if limitStr == "" {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{
-				Message: "limit parameter is required",
-			})
+		result, err := h.pvzProcessor.ListPVZsWithRelations(query.StartDate, query.EndDate, scopeCity(c), query.IncludeArchived, query.Page, query.Limit)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(result)
+	}
+}
+
+func (h *PVZHandlers) GetPVZHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pvzId, err := pathUUID(c, "pvzId")
+		if err != nil {
+			return err
+		}
+
+		pvz, err := h.pvzProcessor.GetPVZByID(pvzId, scopeCity(c))
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(pvz)
+	}
+}
+
+// ArchivePVZHandler soft-deletes a PVZ, its receptions and products are kept.
+func (h *PVZHandlers) ArchivePVZHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pvzId, err := pathUUID(c, "pvzId")
+		if err != nil {
+			return err
 		}
 
-		page, err := strconv.Atoi(pageStr)
-		if err != nil || page < 1 {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{
-				Message: "page must be a positive integer",
-			})
+		if err := h.pvzProcessor.ArchivePVZ(pvzId, scopeCity(c), claimString(c, "userId")); err != nil {
+			return err
 		}
 
-		limit, err := strconv.Atoi(limitStr)
-		if err != nil || limit < 1 || limit > 30 {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{
-				Message: "limit must be between 1 and 30",
-			})
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func (h *PVZHandlers) RestorePVZHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pvzId, err := pathUUID(c, "pvzId")
+		if err != nil {
+			return err
 		}
 
-		startDate := c.Query("startDate")
-		if startDate != "" {
-			if _, err := time.Parse(time.RFC3339, startDate); err != nil {
-				return c.Status(fiber.StatusBadRequest).JSON(models.Error{
-					Message: "invalid startDate format, must be RFC3339",
-				})
-			}
+		pvz, err := h.pvzProcessor.RestorePVZ(pvzId, scopeCity(c))
+		if err != nil {
+			return err
 		}
 
-		endDate := c.Query("endDate")
-		if endDate != "" {
-			if _, err := time.Parse(time.RFC3339, endDate); err != nil {
-				return c.Status(fiber.StatusBadRequest).JSON(models.Error{
-					Message: "invalid endDate format, must be RFC3339",
-				})
-			}
+		return c.JSON(pvz)
+	}
+}
+
+func (h *PVZHandlers) UpdatePVZHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pvzId, err := pathUUID(c, "pvzId")
+		if err != nil {
+			return err
+		}
+
+		var body models.UpdatePVZRequest
+		if err := parseBody(c, &body); err != nil {
+			return err
+		}
+
+		pvz, err := h.pvzProcessor.UpdatePVZ(pvzId, scopeCity(c), body)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(pvz)
+	}
+}
+
+func (h *PVZHandlers) GetNearbyPVZHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var query models.NearbyPVZQuery
+		if err := parseQuery(c, &query); err != nil {
+			return err
+		}
+
+		result, err := h.pvzProcessor.ListNearbyPVZs(*query.Lat, *query.Lon, query.RadiusKm, query.OpenAt, scopeCity(c), query.Limit)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(result)
+	}
+}
+
+func (h *PVZHandlers) GetPVZUtilizationHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var query models.PVZUtilizationQuery
+		if err := parseQuery(c, &query); err != nil {
+			return err
 		}
 
-		result, err := h.pvzProcessor.ListPVZsWithRelations(startDate, endDate, page, limit)
+		result, err := h.pvzProcessor.ListPVZUtilization(query.MinUtilization, scopeCity(c), query.Page, query.Limit)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(models.Error{
-				Message: err.Error(),
-			})
+			return err
 		}
 
 		return c.JSON(result)
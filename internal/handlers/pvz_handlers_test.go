@@ -4,14 +4,18 @@ import (
 	"bytes"
 	"encoding/json"
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"testing"
 	"time"
 
+	"pvzService/internal/apperrors"
 	"pvzService/internal/models"
 	"pvzService/internal/repository"
 )
@@ -25,18 +29,52 @@ func (m *MockPVZProcessor) CreatePVZ(city string) (models.PVZ, error) {
 	return args.Get(0).(models.PVZ), args.Error(1)
 }
 
-func (m *MockPVZProcessor) GetPVZByID(id string) (models.PVZ, error) {
-	args := m.Called(id)
+func (m *MockPVZProcessor) GetPVZByID(id, city string) (models.PVZ, error) {
+	args := m.Called(id, city)
 	return args.Get(0).(models.PVZ), args.Error(1)
 }
 
-func (m *MockPVZProcessor) ListPVZsWithRelations(startDate, endDate string, page, limit int) ([]repository.PVZResponse, error) {
-	args := m.Called(startDate, endDate, page, limit)
+func (m *MockPVZProcessor) ListPVZsWithRelations(startDate, endDate, city string, includeArchived bool, page, limit int) ([]repository.PVZResponse, error) {
+	args := m.Called(startDate, endDate, city, includeArchived, page, limit)
 	return args.Get(0).([]repository.PVZResponse), args.Error(1)
 }
 
+func (m *MockPVZProcessor) ArchivePVZ(id, city, actorID string) error {
+	args := m.Called(id, city, actorID)
+	return args.Error(0)
+}
+
+func (m *MockPVZProcessor) RestorePVZ(id, city string) (models.PVZ, error) {
+	args := m.Called(id, city)
+	return args.Get(0).(models.PVZ), args.Error(1)
+}
+
+func (m *MockPVZProcessor) UpdatePVZ(id, city string, update models.UpdatePVZRequest) (models.PVZ, error) {
+	args := m.Called(id, city, update)
+	return args.Get(0).(models.PVZ), args.Error(1)
+}
+
+func (m *MockPVZProcessor) ListNearbyPVZs(lat, lon, radiusKm float64, openAt, city string, limit int) ([]models.NearbyPVZ, error) {
+	args := m.Called(lat, lon, radiusKm, openAt, city, limit)
+	return args.Get(0).([]models.NearbyPVZ), args.Error(1)
+}
+
+func (m *MockPVZProcessor) ListPVZUtilization(minUtilization float64, city string, page, limit int) ([]models.PVZUtilization, error) {
+	args := m.Called(minUtilization, city, page, limit)
+	return args.Get(0).([]models.PVZUtilization), args.Error(1)
+}
+
+func (m *MockPVZProcessor) ListActivePVZs() ([]models.PVZ, error) {
+	args := m.Called()
+	return args.Get(0).([]models.PVZ), args.Error(1)
+}
+
+func (m *MockPVZProcessor) RefreshCapacityMetrics() error {
+	return m.Called().Error(0)
+}
+
 func TestPVZHandlers_CreatePVZHandler(t *testing.T) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 	mockProcessor := new(MockPVZProcessor)
 	handler := NewPVZHandlers(mockProcessor)
 
@@ -72,7 +110,7 @@ func TestPVZHandlers_CreatePVZHandler(t *testing.T) {
 }
 
 func TestPVZHandlers_GetPVZListHandler(t *testing.T) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 	mockProcessor := new(MockPVZProcessor)
 	handler := NewPVZHandlers(mockProcessor)
 
@@ -91,7 +129,7 @@ func TestPVZHandlers_GetPVZListHandler(t *testing.T) {
 		page := 1
 		limit := 10
 
-		mockProcessor.On("ListPVZsWithRelations", startDate, endDate, page, limit).
+		mockProcessor.On("ListPVZsWithRelations", startDate, endDate, "", false, page, limit).
 			Return(expected, nil)
 
 		app.Get("/pvz", handler.GetPVZListHandler())
@@ -122,7 +160,7 @@ func TestPVZHandlers_GetPVZListHandler(t *testing.T) {
 		page := 1
 		limit := 10
 
-		mockProcessor.On("ListPVZsWithRelations", startDate, endDate, page, limit).
+		mockProcessor.On("ListPVZsWithRelations", startDate, endDate, "", false, page, limit).
 			Return(expected, nil)
 
 		app.Get("/pvz", handler.GetPVZListHandler())
@@ -150,7 +188,7 @@ func TestPVZHandlers_GetPVZListHandler(t *testing.T) {
 		page := 1
 		limit := 10
 
-		mockProcessor.On("ListPVZsWithRelations", "", "", page, limit).
+		mockProcessor.On("ListPVZsWithRelations", "", "", "", false, page, limit).
 			Return(expected, nil)
 
 		app.Get("/pvz", handler.GetPVZListHandler())
@@ -164,6 +202,18 @@ func TestPVZHandlers_GetPVZListHandler(t *testing.T) {
 		mockProcessor.AssertExpectations(t)
 	})
 
+	t.Run("including archived PVZ", func(t *testing.T) {
+		mockProcessor.On("ListPVZsWithRelations", "", "", "", true, 2, 10).
+			Return([]repository.PVZResponse{}, nil)
+
+		app.Get("/pvz", handler.GetPVZListHandler())
+		req := httptest.NewRequest("GET", "/pvz?page=2&limit=10&includeArchived=true", nil)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		mockProcessor.AssertExpectations(t)
+	})
+
 	t.Run("invalid page number (0)", func(t *testing.T) {
 		app.Get("/pvz", handler.GetPVZListHandler())
 		req := httptest.NewRequest("GET", "/pvz?page=0&limit=10", nil)
@@ -199,13 +249,16 @@ func TestPVZHandlers_GetPVZListHandler(t *testing.T) {
 		var errorResp models.Error
 		err = json.NewDecoder(resp.Body).Decode(&errorResp)
 		assert.NoError(t, err)
-		assert.Equal(t, "limit must be between 1 and 30", errorResp.Message)
+		assert.Equal(t, "VALIDATION_FAILED", errorResp.Code)
+		assert.Len(t, errorResp.Details, 1)
+		assert.Equal(t, "limit", errorResp.Details[0].Field)
+		assert.Equal(t, "limit must be at most 30", errorResp.Details[0].Message)
 	})
 
 	t.Run("valid maximum limit", func(t *testing.T) {
 		expected := []repository.PVZResponse{}
 
-		mockProcessor.On("ListPVZsWithRelations", "", "", 1, 30).
+		mockProcessor.On("ListPVZsWithRelations", "", "", "", false, 1, 30).
 			Return(expected, nil)
 
 		app.Get("/pvz", handler.GetPVZListHandler())
@@ -232,3 +285,281 @@ func TestPVZHandlers_GetPVZListHandler(t *testing.T) {
 		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
 	})
 }
+
+func TestPVZHandlers_GetPVZHandler(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	mockProcessor := new(MockPVZProcessor)
+	handler := NewPVZHandlers(mockProcessor)
+	app.Get("/pvz/:pvzId", handler.GetPVZHandler())
+
+	t.Run("success", func(t *testing.T) {
+		expectedPVZ := models.PVZ{ID: uuid.NewString(), City: "Казань"}
+		mockProcessor.On("GetPVZByID", expectedPVZ.ID, "").Return(expectedPVZ, nil)
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/pvz/"+expectedPVZ.ID, nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var pvz models.PVZ
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&pvz))
+		assert.Equal(t, expectedPVZ.ID, pvz.ID)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		pvzID := uuid.NewString()
+		mockProcessor.On("GetPVZByID", pvzID, "").Return(models.PVZ{}, apperrors.ErrPVZNotFound)
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/pvz/"+pvzID, nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("invalid pvzId format", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/pvz/invalid-uuid", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestPVZHandlers_ArchivePVZHandler(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	mockProcessor := new(MockPVZProcessor)
+	handler := NewPVZHandlers(mockProcessor)
+	actorID := uuid.NewString()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("claims", jwt.MapClaims{"userId": actorID, "role": "moderator"})
+		c.Locals("role", "moderator")
+		return c.Next()
+	})
+	app.Delete("/pvz/:pvzId", handler.ArchivePVZHandler())
+
+	t.Run("success", func(t *testing.T) {
+		pvzID := uuid.NewString()
+		mockProcessor.On("ArchivePVZ", pvzID, "", actorID).Return(nil)
+
+		resp, err := app.Test(httptest.NewRequest("DELETE", "/pvz/"+pvzID, nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+	})
+
+	t.Run("open reception", func(t *testing.T) {
+		pvzID := uuid.NewString()
+		mockProcessor.On("ArchivePVZ", pvzID, "", actorID).Return(apperrors.ErrPVZHasOpenReception)
+
+		resp, err := app.Test(httptest.NewRequest("DELETE", "/pvz/"+pvzID, nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var errorResp models.Error
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResp))
+		assert.Equal(t, "PVZ_HAS_OPEN_RECEPTION", errorResp.Code)
+	})
+
+	t.Run("invalid pvzId format", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest("DELETE", "/pvz/invalid-uuid", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+	mockProcessor.AssertExpectations(t)
+}
+
+func TestPVZHandlers_RestorePVZHandler(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	mockProcessor := new(MockPVZProcessor)
+	handler := NewPVZHandlers(mockProcessor)
+	app.Post("/pvz/:pvzId/restore", handler.RestorePVZHandler())
+
+	t.Run("success", func(t *testing.T) {
+		restored := models.PVZ{ID: uuid.NewString(), City: "Казань"}
+		mockProcessor.On("RestorePVZ", restored.ID, "").Return(restored, nil)
+
+		resp, err := app.Test(httptest.NewRequest("POST", "/pvz/"+restored.ID+"/restore", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var pvz models.PVZ
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&pvz))
+		assert.Equal(t, restored.ID, pvz.ID)
+		assert.False(t, pvz.Archived)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		pvzID := uuid.NewString()
+		mockProcessor.On("RestorePVZ", pvzID, "").Return(models.PVZ{}, apperrors.ErrPVZNotFound)
+
+		resp, err := app.Test(httptest.NewRequest("POST", "/pvz/"+pvzID+"/restore", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+	mockProcessor.AssertExpectations(t)
+}
+
+func TestPVZHandlers_UpdatePVZHandler(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	mockProcessor := new(MockPVZProcessor)
+	handler := NewPVZHandlers(mockProcessor)
+	app.Patch("/pvz/:pvzId", handler.UpdatePVZHandler())
+
+	patch := func(pvzID, body string) *http.Response {
+		req := httptest.NewRequest("PATCH", "/pvz/"+pvzID, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	t.Run("success", func(t *testing.T) {
+		pvzID := uuid.NewString()
+		name := "ПВЗ на Тверской"
+		hours := []models.WorkingHours{{Weekday: "monday", Opens: "09:00", Closes: "21:00"}}
+		update := models.UpdatePVZRequest{Name: &name, WorkingHours: &hours}
+		updated := models.PVZ{ID: pvzID, City: "Москва", Name: name, WorkingHours: hours}
+		mockProcessor.On("UpdatePVZ", pvzID, "", update).Return(updated, nil)
+
+		resp := patch(pvzID, `{"name":"ПВЗ на Тверской","workingHours":[{"weekday":"monday","opens":"09:00","closes":"21:00"}]}`)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var pvz models.PVZ
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&pvz))
+		assert.Equal(t, updated, pvz)
+	})
+
+	t.Run("invalid working hours", func(t *testing.T) {
+		resp := patch(uuid.NewString(), `{"workingHours":[{"weekday":"someday","opens":"9:00","closes":"21:00"}]}`)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var errorResp models.Error
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResp))
+		assert.Equal(t, "VALIDATION_FAILED", errorResp.Code)
+		assert.Equal(t, []string{"workingHours[0].weekday", "workingHours[0].opens"},
+			[]string{errorResp.Details[0].Field, errorResp.Details[1].Field})
+	})
+
+	t.Run("latitude out of range", func(t *testing.T) {
+		resp := patch(uuid.NewString(), `{"latitude":91,"longitude":37.6}`)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		pvzID := uuid.NewString()
+		address := "Тверская ул., 1"
+		mockProcessor.On("UpdatePVZ", pvzID, "", models.UpdatePVZRequest{Address: &address}).
+			Return(models.PVZ{}, apperrors.ErrPVZNotFound)
+
+		resp := patch(pvzID, `{"address":"Тверская ул., 1"}`)
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+	mockProcessor.AssertExpectations(t)
+}
+
+func TestPVZHandlers_GetNearbyPVZHandler(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	mockProcessor := new(MockPVZProcessor)
+	handler := NewPVZHandlers(mockProcessor)
+	app.Get("/pvz/nearby", handler.GetNearbyPVZHandler())
+
+	t.Run("success", func(t *testing.T) {
+		found := []models.NearbyPVZ{{PVZ: models.PVZ{ID: uuid.NewString(), City: "Москва"}, DistanceKm: 1.25}}
+		mockProcessor.On("ListNearbyPVZs", 0.0, 37.62, 5.0, "2025-04-01T10:00:00Z", "", 10).Return(found, nil)
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/pvz/nearby?lat=0&lon=37.62&radiusKm=5&limit=10&openAt=2025-04-01T10:00:00Z", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var result []models.NearbyPVZ
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, found, result)
+	})
+
+	for _, tc := range []struct {
+		name  string
+		query string
+		field string
+	}{
+		{"missing latitude", "lon=37.62&radiusKm=5&limit=10", "lat"},
+		{"longitude out of range", "lat=55.75&lon=181&radiusKm=5&limit=10", "lon"},
+		{"radius too large", "lat=55.75&lon=37.62&radiusKm=101&limit=10", "radiusKm"},
+		{"limit too large", "lat=55.75&lon=37.62&radiusKm=5&limit=51", "limit"},
+		{"invalid openAt", "lat=55.75&lon=37.62&radiusKm=5&limit=10&openAt=noon", "openAt"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", "/pvz/nearby?"+tc.query, nil))
+			assert.NoError(t, err)
+			assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+			var errorResp models.Error
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResp))
+			require.NotEmpty(t, errorResp.Details)
+			assert.Equal(t, tc.field, errorResp.Details[0].Field)
+		})
+	}
+	mockProcessor.AssertExpectations(t)
+}
+
+func TestPVZHandlers_GetPVZUtilizationHandler(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	mockProcessor := new(MockPVZProcessor)
+	handler := NewPVZHandlers(mockProcessor)
+	app.Get("/pvz/utilization", handler.GetPVZUtilizationHandler())
+
+	t.Run("success", func(t *testing.T) {
+		capacity := 100
+		full := []models.PVZUtilization{{PVZ: models.PVZ{ID: uuid.NewString(), City: "Москва", Capacity: &capacity, StoredItems: 95}, Utilization: 0.95}}
+		mockProcessor.On("ListPVZUtilization", 0.9, "", 1, 10).Return(full, nil)
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/pvz/utilization?page=1&limit=10&minUtilization=0.9", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var result []models.PVZUtilization
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, full, result)
+	})
+
+	for _, tc := range []struct {
+		name  string
+		query string
+		field string
+	}{
+		{"missing page", "limit=10", "page"},
+		{"limit too large", "page=1&limit=101", "limit"},
+		{"negative threshold", "page=1&limit=10&minUtilization=-1", "minUtilization"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", "/pvz/utilization?"+tc.query, nil))
+			assert.NoError(t, err)
+			assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+			var errorResp models.Error
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResp))
+			require.NotEmpty(t, errorResp.Details)
+			assert.Equal(t, tc.field, errorResp.Details[0].Field)
+		})
+	}
+	mockProcessor.AssertExpectations(t)
+}
+
+func TestPVZHandlers_ScopedToCity(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	mockProcessor := new(MockPVZProcessor)
+	handler := NewPVZHandlers(mockProcessor)
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("city", "Казань")
+		return c.Next()
+	})
+	app.Get("/pvz", handler.GetPVZListHandler())
+	app.Get("/pvz/:pvzId", handler.GetPVZHandler())
+
+	pvzID := uuid.NewString()
+	mockProcessor.On("ListPVZsWithRelations", "", "", "Казань", false, 1, 10).Return([]repository.PVZResponse{}, nil)
+	mockProcessor.On("GetPVZByID", pvzID, "Казань").Return(models.PVZ{}, apperrors.ErrPVZNotFound)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/pvz?page=1&limit=10", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/pvz/"+pvzID, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	mockProcessor.AssertExpectations(t)
+}
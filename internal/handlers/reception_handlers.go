@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"github.com/gofiber/fiber/v2"
-	"github.com/google/uuid"
 	"pvzService/internal/prometheus"
 
 	"pvzService/internal/models"
@@ -19,20 +18,14 @@ func NewReceptionHandlers(receptionProcessor processors.ReceptionProcessor) *Rec
 
 func (h *ReceptionHandlers) CreateReceptionHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		var body struct {
-			PvzId string `json:"pvzId"`
-		}
-		if err := c.BodyParser(&body); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{Message: "Invalid request"})
-		}
-
-		if _, err := uuid.Parse(body.PvzId); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{Message: "Invalid pvzId format"})
+		var body models.CreateReceptionRequest
+		if err := parseBody(c, &body); err != nil {
+			return err
 		}
 
 		reception, err := h.receptionProcessor.CreateReception(body.PvzId)
 		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{Message: err.Error()})
+			return err
 		}
 
 		prometheus.OrderAcceptancesCreated.Inc()
@@ -42,15 +35,14 @@ func (h *ReceptionHandlers) CreateReceptionHandler() fiber.Handler {
 
 func (h *ReceptionHandlers) CloseLastReceptionHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		pvzId := c.Params("pvzId")
-
-		if _, err := uuid.Parse(pvzId); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{Message: "Invalid pvzId format"})
+		pvzId, err := pathUUID(c, "pvzId")
+		if err != nil {
+			return err
 		}
 
 		reception, err := h.receptionProcessor.CloseLastReception(pvzId)
 		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Error{Message: err.Error()})
+			return err
 		}
 
 		return c.JSON(reception)
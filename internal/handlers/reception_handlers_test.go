@@ -28,7 +28,7 @@ func (m *MockReceptionProcessor) CloseLastReception(pvzID string) (models.Recept
 }
 
 func TestReceptionHandlers_CreateReceptionHandler(t *testing.T) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 	mockProcessor := new(MockReceptionProcessor)
 	handler := NewReceptionHandlers(mockProcessor)
 
@@ -80,7 +80,7 @@ func TestReceptionHandlers_CreateReceptionHandler(t *testing.T) {
 }
 
 func TestReceptionHandlers_CloseLastReceptionHandler(t *testing.T) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 	mockProcessor := new(MockReceptionProcessor)
 	handler := NewReceptionHandlers(mockProcessor)
 
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"pvzService/internal/models"
+	"pvzService/internal/processors"
+)
+
+type UserHandlers struct {
+	userProcessor processors.UserProcessor
+}
+
+func NewUserHandlers(userProcessor processors.UserProcessor) *UserHandlers {
+	return &UserHandlers{userProcessor: userProcessor}
+}
+
+func (h *UserHandlers) ListUsersHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var query models.UserListQuery
+		if err := parseQuery(c, &query); err != nil {
+			return err
+		}
+
+		users, err := h.userProcessor.ListUsers(query.Role, query.Page, query.Limit)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(users)
+	}
+}
+
+func (h *UserHandlers) UpdateUserHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := pathUUID(c, "userId")
+		if err != nil {
+			return err
+		}
+
+		var body models.UpdateUserRequest
+		if err := parseBody(c, &body); err != nil {
+			return err
+		}
+
+		user, err := h.userProcessor.UpdateUser(claimString(c, "userId"), userRole(c), userID, body.Role, body.City, body.Active)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(user)
+	}
+}
+
+func (h *UserHandlers) DeleteUserHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := pathUUID(c, "userId")
+		if err != nil {
+			return err
+		}
+
+		if err := h.userProcessor.DeleteUser(claimString(c, "userId"), userRole(c), userID); err != nil {
+			return err
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
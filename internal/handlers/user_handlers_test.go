@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
+)
+
+type MockUserProcessor struct {
+	mock.Mock
+}
+
+func (m *MockUserProcessor) ListUsers(role string, page, limit int) ([]models.User, error) {
+	args := m.Called(role, page, limit)
+	return args.Get(0).([]models.User), args.Error(1)
+}
+
+func (m *MockUserProcessor) UpdateUser(actorID, actorRole, id string, role, city *string, active *bool) (models.User, error) {
+	args := m.Called(actorID, actorRole, id, role, city, active)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockUserProcessor) DeleteUser(actorID, actorRole, id string) error {
+	args := m.Called(actorID, actorRole, id)
+	return args.Error(0)
+}
+
+func newUserTestApp(mockProcessor *MockUserProcessor, actorID string) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("claims", jwt.MapClaims{"userId": actorID, "role": "moderator"})
+		c.Locals("role", "moderator")
+		return c.Next()
+	})
+
+	handler := NewUserHandlers(mockProcessor)
+	app.Get("/users", handler.ListUsersHandler())
+	app.Patch("/users/:userId", handler.UpdateUserHandler())
+	app.Delete("/users/:userId", handler.DeleteUserHandler())
+	return app
+}
+
+func TestUserHandlers_ListUsersHandler(t *testing.T) {
+	mockProcessor := new(MockUserProcessor)
+	app := newUserTestApp(mockProcessor, uuid.NewString())
+
+	t.Run("success", func(t *testing.T) {
+		expected := []models.User{{ID: uuid.NewString(), Email: "a@example.com", Role: "employee", Active: true}}
+		mockProcessor.On("ListUsers", "employee", 1, 20).Return(expected, nil)
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/users?page=1&limit=20&role=employee", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var users []models.User
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&users))
+		assert.Equal(t, expected[0].ID, users[0].ID)
+		mockProcessor.AssertExpectations(t)
+	})
+
+	t.Run("invalid role filter", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/users?page=1&limit=20&role=superuser", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestUserHandlers_UpdateUserHandler(t *testing.T) {
+	actorID := uuid.NewString()
+	mockProcessor := new(MockUserProcessor)
+	app := newUserTestApp(mockProcessor, actorID)
+
+	t.Run("success", func(t *testing.T) {
+		userID := uuid.NewString()
+		mockProcessor.On("UpdateUser", actorID, "moderator", userID, (*string)(nil), (*string)(nil), mock.MatchedBy(func(active *bool) bool {
+			return active != nil && !*active
+		})).Return(models.User{ID: userID, Role: "employee", Active: false}, nil)
+
+		req := httptest.NewRequest("PATCH", "/users/"+userID, bytes.NewBufferString(`{"active":false}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		mockProcessor.AssertExpectations(t)
+	})
+
+	t.Run("city manager", func(t *testing.T) {
+		userID := uuid.NewString()
+		city := "Казань"
+		mockProcessor.On("UpdateUser", actorID, "moderator", userID, mock.MatchedBy(func(role *string) bool {
+			return role != nil && *role == "city_manager"
+		}), &city, (*bool)(nil)).Return(models.User{ID: userID, Role: "city_manager", City: &city, Active: true}, nil)
+
+		req := httptest.NewRequest("PATCH", "/users/"+userID, bytes.NewBufferString(`{"role":"city_manager","city":"Казань"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var user models.User
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&user))
+		assert.Equal(t, "Казань", *user.City)
+	})
+
+	t.Run("invalid city", func(t *testing.T) {
+		req := httptest.NewRequest("PATCH", "/users/"+uuid.NewString(), bytes.NewBufferString(`{"role":"city_manager","city":"Нью-Йорк"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("invalid role", func(t *testing.T) {
+		req := httptest.NewRequest("PATCH", "/users/"+uuid.NewString(), bytes.NewBufferString(`{"role":"superuser"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		userID := uuid.NewString()
+		mockProcessor.On("UpdateUser", actorID, "moderator", userID, mock.Anything, mock.Anything, mock.Anything).Return(models.User{}, apperrors.ErrUserNotFound)
+
+		req := httptest.NewRequest("PATCH", "/users/"+userID, bytes.NewBufferString(`{"role":"moderator"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestUserHandlers_DeleteUserHandler(t *testing.T) {
+	actorID := uuid.NewString()
+	mockProcessor := new(MockUserProcessor)
+	app := newUserTestApp(mockProcessor, actorID)
+
+	t.Run("success", func(t *testing.T) {
+		userID := uuid.NewString()
+		mockProcessor.On("DeleteUser", actorID, "moderator", userID).Return(nil)
+
+		resp, err := app.Test(httptest.NewRequest("DELETE", "/users/"+userID, nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+		mockProcessor.AssertExpectations(t)
+	})
+
+	t.Run("self", func(t *testing.T) {
+		mockProcessor.On("DeleteUser", actorID, "moderator", actorID).Return(apperrors.ErrCannotModifySelf)
+
+		resp, err := app.Test(httptest.NewRequest("DELETE", "/users/"+actorID, nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("invalid userId", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest("DELETE", "/users/invalid", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/validation"
+)
+
+func parseBody(c *fiber.Ctx, body interface{}) error {
+	if err := c.BodyParser(body); err != nil {
+		return apperrors.ErrInvalidRequestBody
+	}
+	return validation.Struct(body)
+}
+
+func parseQuery(c *fiber.Ctx, query interface{}) error {
+	if err := c.QueryParser(query); err != nil {
+		return apperrors.New(apperrors.CodeInvalidRequest, "Invalid query parameters")
+	}
+	return validation.Struct(query)
+}
+
+func pathUUID(c *fiber.Ctx, param string) (string, error) {
+	value := c.Params(param)
+	if err := validation.Var(param, value, "required,uuid"); err != nil {
+		return "", err
+	}
+	return value, nil
+}
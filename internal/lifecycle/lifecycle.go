@@ -0,0 +1,191 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var errStoppedUnexpectedly = errors.New("stopped unexpectedly")
+
+type server struct {
+	name     string
+	serve    func() error
+	shutdown func(ctx context.Context) error
+}
+
+type worker struct {
+	name string
+	run  func(ctx context.Context)
+}
+
+type closer struct {
+	name  string
+	close func() error
+}
+
+// Manager runs the servers and background workers of the service until the context is
+// cancelled or one of the servers fails, then stops everything within the shutdown
+// timeout: servers drain in-flight requests, workers are cancelled and resources such
+// as the database are closed last.
+type Manager struct {
+	shutdownTimeout time.Duration
+	servers         []server
+	workers         []worker
+	closers         []closer
+}
+
+func NewManager(shutdownTimeout time.Duration) *Manager {
+	return &Manager{shutdownTimeout: shutdownTimeout}
+}
+
+// AddServer registers a server. Serve blocks until the server stops, shutdown stops
+// accepting requests and waits for the running ones until ctx is done.
+func (m *Manager) AddServer(name string, serve func() error, shutdown func(ctx context.Context) error) {
+	m.servers = append(m.servers, server{name: name, serve: serve, shutdown: shutdown})
+}
+
+// AddWorker registers a background job that runs until its context is cancelled.
+func (m *Manager) AddWorker(name string, run func(ctx context.Context)) {
+	m.workers = append(m.workers, worker{name: name, run: run})
+}
+
+// AddCloser registers a resource closed after servers and workers have stopped.
+// Closers run in reverse order of registration.
+func (m *Manager) AddCloser(name string, close func() error) {
+	m.closers = append(m.closers, closer{name: name, close: close})
+}
+
+// Run blocks until shutdown is complete. It returns an error when a server failed or
+// stopped on its own, or when something could not be stopped in time.
+func (m *Manager) Run(ctx context.Context) error {
+	failures := make(chan error, len(m.servers))
+	for _, s := range m.servers {
+		go func() {
+			err := s.serve()
+			if err == nil {
+				err = errStoppedUnexpectedly
+			}
+			failures <- fmt.Errorf("%s: %w", s.name, err)
+		}()
+	}
+
+	workerCtx, cancelWorkers := context.WithCancel(context.Background())
+	defer cancelWorkers()
+	var workers sync.WaitGroup
+	for _, w := range m.workers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			w.run(workerCtx)
+		}()
+	}
+
+	var failure error
+	select {
+	case <-ctx.Done():
+		log.Println("Shutting down")
+	case failure = <-failures:
+		log.Printf("Shutting down after a failure: %v", failure)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), m.shutdownTimeout)
+	defer cancel()
+
+	errs := []error{failure}
+	for i := len(m.servers) - 1; i >= 0; i-- {
+		s := m.servers[i]
+		if err := s.shutdown(shutdownCtx); err != nil {
+			errs = append(errs, fmt.Errorf("stop %s: %w", s.name, err))
+		}
+	}
+
+	cancelWorkers()
+	if err := waitGroup(shutdownCtx, &workers); err != nil {
+		errs = append(errs, fmt.Errorf("stop workers: %w", err))
+	}
+
+	for i := len(m.closers) - 1; i >= 0; i-- {
+		c := m.closers[i]
+		if err := c.close(); err != nil {
+			errs = append(errs, fmt.Errorf("close %s: %w", c.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Periodic returns a worker that runs the task every interval until it is stopped.
+func Periodic(interval time.Duration, task func()) func(ctx context.Context) {
+	return func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				task()
+			}
+		}
+	}
+}
+
+// Queue runs jobs handed over by requests on a single worker, so that they don't hold
+// up the response and are not lost on shutdown: jobs queued before the worker is
+// stopped still run within the shutdown timeout.
+type Queue struct {
+	name string
+	jobs chan func()
+}
+
+func NewQueue(name string, size int) *Queue {
+	return &Queue{name: name, jobs: make(chan func(), size)}
+}
+
+// Submit queues the job without blocking, a job that doesn't fit into a full queue is
+// dropped and logged.
+func (q *Queue) Submit(job func()) {
+	select {
+	case q.jobs <- job:
+	default:
+		log.Printf("Queue %s is full, job dropped", q.name)
+	}
+}
+
+// Run is the worker of the queue, it runs the queued jobs until it is stopped.
+func (q *Queue) Run(ctx context.Context) {
+	for {
+		select {
+		case job := <-q.jobs:
+			job()
+		case <-ctx.Done():
+			for {
+				select {
+				case job := <-q.jobs:
+					job()
+				default:
+					return
+				}
+			}
+		}
+	}
+}
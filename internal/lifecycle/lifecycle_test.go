@@ -0,0 +1,146 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingServer serves until it is shut down, like http.Server and grpc.Server.
+type blockingServer struct {
+	stop    chan struct{}
+	stopped atomic.Bool
+}
+
+func newBlockingServer() *blockingServer {
+	return &blockingServer{stop: make(chan struct{})}
+}
+
+func (s *blockingServer) serve() error {
+	<-s.stop
+	return nil
+}
+
+func (s *blockingServer) shutdown(ctx context.Context) error {
+	s.stopped.Store(true)
+	close(s.stop)
+	return nil
+}
+
+func TestManager_StopsEverythingOnCancel(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+
+	manager := NewManager(time.Second)
+	http, grpc := newBlockingServer(), newBlockingServer()
+	manager.AddServer("http", http.serve, func(ctx context.Context) error {
+		record("http")
+		return http.shutdown(ctx)
+	})
+	manager.AddServer("grpc", grpc.serve, func(ctx context.Context) error {
+		record("grpc")
+		return grpc.shutdown(ctx)
+	})
+	manager.AddWorker("cleanup", func(ctx context.Context) {
+		<-ctx.Done()
+		record("cleanup")
+	})
+	manager.AddCloser("cache", func() error {
+		record("cache")
+		return nil
+	})
+	manager.AddCloser("db", func() error {
+		record("db")
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	assert.NoError(t, manager.Run(ctx))
+	assert.Equal(t, []string{"grpc", "http", "cleanup", "db", "cache"}, order)
+}
+
+func TestManager_FailedServerStopsTheOthers(t *testing.T) {
+	manager := NewManager(time.Second)
+	http := newBlockingServer()
+	manager.AddServer("http", http.serve, http.shutdown)
+	manager.AddServer("grpc", func() error {
+		return errors.New("address already in use")
+	}, func(ctx context.Context) error {
+		return nil
+	})
+
+	err := manager.Run(context.Background())
+	assert.ErrorContains(t, err, "grpc: address already in use")
+	assert.True(t, http.stopped.Load())
+}
+
+func TestManager_ServerStoppingOnItsOwnIsAFailure(t *testing.T) {
+	manager := NewManager(time.Second)
+	manager.AddServer("metrics", func() error {
+		return nil
+	}, func(ctx context.Context) error {
+		return nil
+	})
+
+	assert.ErrorIs(t, manager.Run(context.Background()), errStoppedUnexpectedly)
+}
+
+func TestManager_ShutdownTimeout(t *testing.T) {
+	manager := NewManager(20 * time.Millisecond)
+	manager.AddWorker("stuck", func(ctx context.Context) {
+		time.Sleep(200 * time.Millisecond)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, manager.Run(ctx), context.DeadlineExceeded)
+}
+
+func TestPeriodic(t *testing.T) {
+	var runs atomic.Int32
+	ctx, cancel := context.WithTimeout(context.Background(), 55*time.Millisecond)
+	defer cancel()
+
+	Periodic(10*time.Millisecond, func() { runs.Add(1) })(ctx)
+	assert.GreaterOrEqual(t, runs.Load(), int32(3))
+}
+
+func TestQueue(t *testing.T) {
+	queue := NewQueue("test", 2)
+	var runs atomic.Int32
+	for range 3 {
+		queue.Submit(func() { runs.Add(1) })
+	}
+
+	// Jobs queued before the worker stops still run, the one over the size is dropped
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	queue.Run(ctx)
+	assert.Equal(t, int32(2), runs.Load())
+
+	ctx, cancel = context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		queue.Run(ctx)
+		close(done)
+	}()
+	queue.Submit(func() {
+		runs.Add(1)
+		cancel()
+	})
+	<-done
+	assert.Equal(t, int32(3), runs.Load())
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
+	"pvzService/internal/processors"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyMiddleware authenticates requests carrying an X-API-Key header and stores the
+// key in the "apiKey" local and the city its creator is limited to in the "city" local.
+// AuthMiddleware lets such requests through without a token and RequirePermission checks
+// the permissions of the key instead of a role.
+func APIKeyMiddleware(keys processors.APIKeyProcessor) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(APIKeyHeader)
+		if key == "" {
+			return c.Next()
+		}
+
+		apiKey, err := keys.Authenticate(key)
+		if err != nil {
+			return err
+		}
+		c.Locals("apiKey", apiKey)
+		if apiKey.CreatorCity != nil {
+			c.Locals("city", *apiKey.CreatorCity)
+		}
+		return c.Next()
+	}
+}
+
+// RequireUserToken rejects requests authenticated with an API key, for endpoints that
+// act on behalf of a user.
+func RequireUserToken() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := requestAPIKey(c); ok {
+			return apperrors.ErrUserTokenRequired
+		}
+		return c.Next()
+	}
+}
+
+func requestAPIKey(c *fiber.Ctx) (models.APIKey, bool) {
+	apiKey, ok := c.Locals("apiKey").(models.APIKey)
+	return apiKey, ok
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/handlers"
+	"pvzService/internal/models"
+	"pvzService/internal/permissions"
+)
+
+type fakeAPIKeyProcessor struct {
+	keys map[string]models.APIKey
+}
+
+func (p *fakeAPIKeyProcessor) CreateAPIKey(creatorID, creatorRole, name string, keyPermissions []string, expiresAt string) (models.CreatedAPIKey, error) {
+	return models.CreatedAPIKey{}, nil
+}
+
+func (p *fakeAPIKeyProcessor) ListAPIKeys(page, limit int) ([]models.APIKey, error) {
+	return nil, nil
+}
+
+func (p *fakeAPIKeyProcessor) RevokeAPIKey(id string) error {
+	return nil
+}
+
+func (p *fakeAPIKeyProcessor) Authenticate(key string) (models.APIKey, error) {
+	apiKey, ok := p.keys[key]
+	if !ok {
+		return models.APIKey{}, apperrors.ErrInvalidAPIKey
+	}
+	return apiKey, nil
+}
+
+func apiKeyStatus(t *testing.T, app *fiber.App, key, token string) int {
+	req := httptest.NewRequest("GET", "/", nil)
+	if key != "" {
+		req.Header.Set(APIKeyHeader, key)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	return resp.StatusCode
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	userID := uuid.NewString()
+	users := &fakeUserRepo{active: map[string]bool{userID: true}}
+	keys := &fakeAPIKeyProcessor{keys: map[string]models.APIKey{
+		"pvz_reader": {ID: "key1", Permissions: []string{permissions.PVZRead}},
+		"pvz_writer": {ID: "key2", Permissions: []string{permissions.PVZCreate}},
+	}}
+	table := permissions.Table{"employee": {permissions.PVZRead}}
+	app := newAuthTestApp(APIKeyMiddleware(keys), AuthMiddleware(testSecret, users), RequirePermission(table, permissions.PVZRead))
+
+	assert.Equal(t, fiber.StatusOK, apiKeyStatus(t, app, "pvz_reader", ""))
+	assert.Equal(t, fiber.StatusForbidden, apiKeyStatus(t, app, "pvz_writer", ""), "key without the permission")
+	assert.Equal(t, fiber.StatusUnauthorized, apiKeyStatus(t, app, "pvz_revoked", ""))
+	assert.Equal(t, fiber.StatusUnauthorized, apiKeyStatus(t, app, "pvz_revoked", signTestToken(t, userID, "employee")), "invalid key is not ignored")
+	assert.Equal(t, fiber.StatusOK, apiKeyStatus(t, app, "", signTestToken(t, userID, "employee")))
+	assert.Equal(t, fiber.StatusUnauthorized, apiKeyStatus(t, app, "", ""))
+}
+
+func TestAPIKeyMiddleware_StoresCreatorCity(t *testing.T) {
+	city := "Казань"
+	keys := &fakeAPIKeyProcessor{keys: map[string]models.APIKey{
+		"pvz_manager": {ID: "key1", CreatorCity: &city},
+		"pvz_admin":   {ID: "key2"},
+	}}
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Get("/", APIKeyMiddleware(keys), func(c *fiber.Ctx) error {
+		city, _ := c.Locals("city").(string)
+		return c.SendString(city)
+	})
+
+	for key, city := range map[string]string{"pvz_manager": "Казань", "pvz_admin": ""} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(APIKeyHeader, key)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, city, string(body))
+	}
+}
+
+func TestRequireUserToken(t *testing.T) {
+	userID := uuid.NewString()
+	users := &fakeUserRepo{active: map[string]bool{userID: true}}
+	keys := &fakeAPIKeyProcessor{keys: map[string]models.APIKey{"pvz_reader": {ID: "key1"}}}
+	app := newAuthTestApp(APIKeyMiddleware(keys), AuthMiddleware(testSecret, users), RequireUserToken())
+
+	assert.Equal(t, fiber.StatusOK, apiKeyStatus(t, app, "", signTestToken(t, userID, "employee")))
+	assert.Equal(t, fiber.StatusForbidden, apiKeyStatus(t, app, "pvz_reader", ""))
+}
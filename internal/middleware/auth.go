@@ -1,54 +1,124 @@
 package middleware
 
 import (
+	"database/sql"
 	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
-	"pvzService/internal/models"
+	"github.com/google/uuid"
+	"pvzService/internal/apperrors"
+	"pvzService/internal/permissions"
+	"pvzService/internal/repository"
+	"slices"
 	"strings"
 )
 
-func AuthMiddleware(secret string) fiber.Handler {
+// AuthMiddleware requires a valid token of an active user and stores its claims in
+// the "claims" local, the current role of the user in the "role" local and the city the
+// user is limited to in the "city" local. The role and city are read from the database,
+// so a role change applies to tokens issued before it. Tokens of deactivated and deleted
+// users and tokens issued before the last password change are rejected even if they
+// have not expired yet.
+// Requests already authenticated by APIKeyMiddleware don't need a token.
+func AuthMiddleware(secret string, users repository.UserRepository) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		if _, ok := requestAPIKey(c); ok {
+			return c.Next()
+		}
+
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(models.Error{Message: "Missing authorization header"})
+			return apperrors.ErrMissingAuthHeader
 		}
 
-		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-
-		claims := jwt.MapClaims{}
-		tkn, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(secret), nil
-		})
+		if err := authenticate(c, authHeader, secret, users); err != nil {
+			return err
+		}
+		return c.Next()
+	}
+}
 
-		if err != nil {
-			if errors.Is(err, jwt.ErrTokenExpired) && !tkn.Valid {
-				return c.Status(fiber.StatusUnauthorized).JSON(models.Error{Message: "Invalid token expiration"})
-			}
-			return c.Status(fiber.StatusUnauthorized).JSON(models.Error{Message: "Invalid token"})
+// OptionalAuthMiddleware authenticates the request when it carries a token and lets
+// anonymous requests through, for public endpoints that behave differently for users.
+func OptionalAuthMiddleware(secret string, users repository.UserRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		authHeader := c.Get("Authorization")
+		if authHeader == "" {
+			return c.Next()
 		}
 
-		c.Locals("claims", claims)
+		if err := authenticate(c, authHeader, secret, users); err != nil {
+			return err
+		}
 		return c.Next()
 	}
 }
 
-func CheckRole(roles ...string) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		claims := c.Locals("claims").(jwt.MapClaims)
+func authenticate(c *fiber.Ctx, authHeader, secret string, users repository.UserRepository) error {
+	tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
 
-		role, ok := claims["role"].(string)
-		if !ok {
-			return c.Status(fiber.StatusForbidden).JSON(models.Error{Message: "Invalid role in token"})
+	claims := jwt.MapClaims{}
+	tkn, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	})
+
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) && !tkn.Valid {
+			return apperrors.ErrTokenExpired
 		}
+		return apperrors.ErrInvalidToken
+	}
 
-		for _, allowedRole := range roles {
-			if role == allowedRole {
-				return c.Next()
+	userID, _ := claims["userId"].(string)
+	if _, err := uuid.Parse(userID); err != nil {
+		return apperrors.ErrInvalidToken
+	}
+
+	status, err := users.GetUserStatus(userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return apperrors.Internal("failed to check user status", err)
+	}
+	if err != nil || !status.Active {
+		return apperrors.ErrUserDeactivated
+	}
+	if status.PasswordChangedAt != nil {
+		issuedAt, err := claims.GetIssuedAt()
+		if err != nil || issuedAt == nil || issuedAt.Before(*status.PasswordChangedAt) {
+			return apperrors.ErrTokenRevoked
+		}
+	}
+
+	c.Locals("claims", claims)
+	c.Locals("role", status.Role)
+	if status.City != nil {
+		c.Locals("city", *status.City)
+	}
+	return nil
+}
+
+// RequirePermission lets the request through when the current role of the user has the
+// permission in the role_permissions table, or when the API key was granted it.
+func RequirePermission(checker permissions.Checker, permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if apiKey, ok := requestAPIKey(c); ok {
+			if !slices.Contains(apiKey.Permissions, permission) {
+				return apperrors.ErrInsufficientPermission
 			}
+			return c.Next()
+		}
+
+		role, ok := c.Locals("role").(string)
+		if !ok {
+			return apperrors.ErrInvalidRoleInToken
 		}
 
-		return c.Status(fiber.StatusForbidden).JSON(models.Error{Message: "Insufficient role"})
+		allowed, err := checker.Has(role, permission)
+		if err != nil {
+			return apperrors.Internal("failed to check permissions", err)
+		}
+		if !allowed {
+			return apperrors.ErrInsufficientPermission
+		}
+		return c.Next()
 	}
 }
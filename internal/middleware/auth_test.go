@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"pvzService/internal/handlers"
+	"pvzService/internal/models"
+	"pvzService/internal/permissions"
+)
+
+const testSecret = "secret"
+
+type fakeUserRepo struct {
+	active    map[string]bool
+	roles     map[string]string
+	changedAt map[string]time.Time
+	cities    map[string]string
+}
+
+func (r *fakeUserRepo) ListUsers(role string, page, limit int) ([]models.User, error) {
+	return nil, nil
+}
+
+func (r *fakeUserRepo) UpdateUser(id string, role, city *string, active *bool) (models.User, error) {
+	return models.User{}, nil
+}
+
+func (r *fakeUserRepo) DeleteUser(id string) error {
+	return nil
+}
+
+func (r *fakeUserRepo) GetUserStatus(id string) (models.UserStatus, error) {
+	active, ok := r.active[id]
+	if !ok {
+		return models.UserStatus{}, sql.ErrNoRows
+	}
+
+	status := models.UserStatus{Role: models.RoleEmployee, Active: active}
+	if role, ok := r.roles[id]; ok {
+		status.Role = role
+	}
+	if changedAt, ok := r.changedAt[id]; ok {
+		status.PasswordChangedAt = &changedAt
+	}
+	if city, ok := r.cities[id]; ok {
+		status.City = &city
+	}
+	return status, nil
+}
+
+func signTestToken(t *testing.T, userID, role string) string {
+	return signTestTokenIssuedAt(t, userID, role, time.Now())
+}
+
+func signTestTokenIssuedAt(t *testing.T, userID, role string, issuedAt time.Time) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userId": userID,
+		"role":   role,
+		"iat":    issuedAt.Unix(),
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(testSecret))
+	assert.NoError(t, err)
+	return token
+}
+
+func newAuthTestApp(middlewares ...fiber.Handler) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	middlewares = append(middlewares, func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	app.Get("/", middlewares...)
+	return app
+}
+
+func authStatus(t *testing.T, app *fiber.App, token string) int {
+	req := httptest.NewRequest("GET", "/", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	return resp.StatusCode
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	activeID, inactiveID := uuid.NewString(), uuid.NewString()
+	users := &fakeUserRepo{active: map[string]bool{activeID: true, inactiveID: false}}
+	app := newAuthTestApp(AuthMiddleware(testSecret, users))
+
+	assert.Equal(t, fiber.StatusOK, authStatus(t, app, signTestToken(t, activeID, "employee")))
+	assert.Equal(t, fiber.StatusUnauthorized, authStatus(t, app, ""))
+	assert.Equal(t, fiber.StatusUnauthorized, authStatus(t, app, "invalid"))
+	assert.Equal(t, fiber.StatusUnauthorized, authStatus(t, app, signTestToken(t, inactiveID, "employee")), "deactivated user")
+	assert.Equal(t, fiber.StatusUnauthorized, authStatus(t, app, signTestToken(t, uuid.NewString(), "employee")), "deleted user")
+	assert.Equal(t, fiber.StatusUnauthorized, authStatus(t, app, signTestToken(t, "test-user", "employee")), "userId is not a UUID")
+}
+
+func TestAuthMiddleware_RevokesTokensIssuedBeforePasswordChange(t *testing.T) {
+	userID := uuid.NewString()
+	changedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	users := &fakeUserRepo{active: map[string]bool{userID: true}, changedAt: map[string]time.Time{userID: changedAt}}
+	app := newAuthTestApp(AuthMiddleware(testSecret, users))
+
+	assert.Equal(t, fiber.StatusUnauthorized, authStatus(t, app, signTestTokenIssuedAt(t, userID, "employee", changedAt.Add(-time.Second))))
+	assert.Equal(t, fiber.StatusOK, authStatus(t, app, signTestTokenIssuedAt(t, userID, "employee", changedAt)))
+	assert.Equal(t, fiber.StatusOK, authStatus(t, app, signTestToken(t, userID, "employee")))
+}
+
+func TestOptionalAuthMiddleware(t *testing.T) {
+	activeID, inactiveID := uuid.NewString(), uuid.NewString()
+	users := &fakeUserRepo{active: map[string]bool{activeID: true, inactiveID: false}}
+	app := newAuthTestApp(OptionalAuthMiddleware(testSecret, users))
+
+	assert.Equal(t, fiber.StatusOK, authStatus(t, app, ""))
+	assert.Equal(t, fiber.StatusOK, authStatus(t, app, signTestToken(t, activeID, "moderator")))
+	assert.Equal(t, fiber.StatusUnauthorized, authStatus(t, app, signTestToken(t, inactiveID, "moderator")))
+	assert.Equal(t, fiber.StatusUnauthorized, authStatus(t, app, "invalid"))
+}
+
+func TestAuthMiddleware_StoresCity(t *testing.T) {
+	managerID, employeeID := uuid.NewString(), uuid.NewString()
+	users := &fakeUserRepo{
+		active: map[string]bool{managerID: true, employeeID: true},
+		roles:  map[string]string{managerID: models.RoleCityManager},
+		cities: map[string]string{managerID: "Казань"},
+	}
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Get("/", AuthMiddleware(testSecret, users), func(c *fiber.Ctx) error {
+		city, _ := c.Locals("city").(string)
+		return c.SendString(city)
+	})
+
+	for id, city := range map[string]string{managerID: "Казань", employeeID: ""} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+signTestToken(t, id, "employee"))
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, city, string(body))
+	}
+}
+
+type failingChecker struct{}
+
+func (failingChecker) Has(role, permission string) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func TestRequirePermission(t *testing.T) {
+	auditorID, employeeID := uuid.NewString(), uuid.NewString()
+	users := &fakeUserRepo{
+		active: map[string]bool{auditorID: true, employeeID: true},
+		roles:  map[string]string{auditorID: "auditor"},
+	}
+	table := permissions.Table{"auditor": {permissions.PVZRead}}
+	app := newAuthTestApp(AuthMiddleware(testSecret, users), RequirePermission(table, permissions.PVZRead))
+
+	assert.Equal(t, fiber.StatusOK, authStatus(t, app, signTestToken(t, auditorID, "auditor")))
+	assert.Equal(t, fiber.StatusForbidden, authStatus(t, app, signTestToken(t, employeeID, "employee")))
+	assert.Equal(t, fiber.StatusForbidden, authStatus(t, app, signTestToken(t, employeeID, "auditor")), "role in the token was revoked")
+	assert.Equal(t, fiber.StatusOK, authStatus(t, app, signTestToken(t, auditorID, "employee")), "role granted after the token was issued")
+
+	app = newAuthTestApp(AuthMiddleware(testSecret, users), RequirePermission(failingChecker{}, permissions.PVZRead))
+	assert.Equal(t, fiber.StatusInternalServerError, authStatus(t, app, signTestToken(t, auditorID, "auditor")))
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/repository"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// Idempotency replays the stored response when a request is retried with the same
+// Idempotency-Key. Keys are scoped to the user from the token claims or to the API key and only
+// successful responses are stored, so a failed request can be retried with the same key.
+// A request holds its key for the lease, a retry after the lease takes over the key of a
+// request that never finished.
+func Idempotency(repo repository.IdempotencyRepository, ttl, lease time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(IdempotencyKeyHeader)
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return apperrors.ErrIdempotencyKeyTooLong
+		}
+
+		userID := ""
+		if claims, ok := c.Locals("claims").(jwt.MapClaims); ok {
+			userID, _ = claims["userId"].(string)
+		} else if apiKey, ok := requestAPIKey(c); ok {
+			userID = "apikey:" + apiKey.ID
+		}
+
+		requestHash := hashRequest(c, userID)
+
+		lockedUntil, reserved, err := repo.ReserveKey(key, userID, requestHash, lease, time.Now().Add(ttl))
+		if err != nil {
+			return apperrors.Internal("database error", err)
+		}
+
+		if !reserved {
+			record, err := repo.GetKey(key, userID)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return apperrors.ErrIdempotencyKeyExpired
+				}
+				return apperrors.Internal("database error", err)
+			}
+
+			if record.RequestHash != requestHash {
+				return apperrors.ErrIdempotencyKeyReused
+			}
+
+			if record.StatusCode == 0 {
+				return apperrors.ErrIdempotencyInProgress
+			}
+
+			c.Set(IdempotentReplayedHeader, "true")
+			if record.ContentType != "" {
+				c.Set(fiber.HeaderContentType, record.ContentType)
+			}
+			return c.Status(record.StatusCode).Send(record.ResponseBody)
+		}
+
+		err = c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil || status < 200 || status >= 300 {
+			if delErr := repo.DeleteKey(key, userID, lockedUntil); delErr != nil {
+				log.Printf("Failed to release idempotency key: %v", delErr)
+			}
+			return err
+		}
+
+		body := append([]byte(nil), c.Response().Body()...)
+		contentType := string(c.Response().Header.ContentType())
+		if saveErr := repo.SaveResponse(key, userID, lockedUntil, status, contentType, body); saveErr != nil {
+			log.Printf("Failed to save idempotent response: %v", saveErr)
+		}
+
+		return nil
+	}
+}
+
+// hashRequest identifies the request by its method, URI with the query string, caller
+// and body.
+func hashRequest(c *fiber.Ctx, userID string) string {
+	h := sha256.New()
+	h.Write([]byte(c.Method()))
+	h.Write([]byte(" "))
+	h.Write([]byte(c.OriginalURL()))
+	h.Write([]byte("\n"))
+	h.Write([]byte(userID))
+	h.Write([]byte("\n"))
+	h.Write(c.Body())
+	return hex.EncodeToString(h.Sum(nil))
+}
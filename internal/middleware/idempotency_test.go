@@ -0,0 +1,264 @@
+package middleware
+
+import (
+	"bytes"
+	"database/sql"
+	"io"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"pvzService/internal/handlers"
+	"pvzService/internal/models"
+)
+
+type fakeIdempotencyRepo struct {
+	mu      sync.Mutex
+	records map[string]models.IdempotencyKey
+}
+
+func newFakeIdempotencyRepo() *fakeIdempotencyRepo {
+	return &fakeIdempotencyRepo{records: make(map[string]models.IdempotencyKey)}
+}
+
+func (r *fakeIdempotencyRepo) ReserveKey(key, userID, requestHash string, lease time.Duration, expiresAt time.Time) (time.Time, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if existing, ok := r.records[userID+"/"+key]; ok && existing.ExpiresAt.After(now) &&
+		(existing.StatusCode != 0 || existing.LockedUntil.After(now)) {
+		return time.Time{}, false, nil
+	}
+	lockedUntil := now.Add(lease)
+	r.records[userID+"/"+key] = models.IdempotencyKey{Key: key, UserID: userID, RequestHash: requestHash, LockedUntil: lockedUntil, ExpiresAt: expiresAt}
+	return lockedUntil, true, nil
+}
+
+func (r *fakeIdempotencyRepo) GetKey(key, userID string) (models.IdempotencyKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.records[userID+"/"+key]
+	if !ok || !record.ExpiresAt.After(time.Now()) {
+		return models.IdempotencyKey{}, sql.ErrNoRows
+	}
+	return record, nil
+}
+
+func (r *fakeIdempotencyRepo) SaveResponse(key, userID string, lockedUntil time.Time, statusCode int, contentType string, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record := r.records[userID+"/"+key]
+	if !record.LockedUntil.Equal(lockedUntil) {
+		return nil
+	}
+	record.LockedUntil = time.Time{}
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.ResponseBody = body
+	r.records[userID+"/"+key] = record
+	return nil
+}
+
+func (r *fakeIdempotencyRepo) DeleteKey(key, userID string, lockedUntil time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.records[userID+"/"+key].LockedUntil.Equal(lockedUntil) {
+		delete(r.records, userID+"/"+key)
+	}
+	return nil
+}
+
+func (r *fakeIdempotencyRepo) DeleteExpired() (int64, error) {
+	return 0, nil
+}
+
+func newIdempotencyTestApp(repo *fakeIdempotencyRepo, calls *int, status int) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("claims", jwt.MapClaims{"userId": c.Get("X-User")})
+		return c.Next()
+	})
+	app.Post("/products", Idempotency(repo, time.Hour, time.Minute), func(c *fiber.Ctx) error {
+		*calls++
+		return c.Status(status).JSON(fiber.Map{"call": *calls})
+	})
+	return app
+}
+
+func doIdempotentRequest(t *testing.T, app *fiber.App, key, user, body string) (int, string, string) {
+	return doIdempotentRequestTo(t, app, "/products", key, user, body)
+}
+
+func doIdempotentRequestTo(t *testing.T, app *fiber.App, target, key, user, body string) (int, string, string) {
+	req := httptest.NewRequest("POST", target, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", user)
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	respBody, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(respBody), resp.Header.Get(IdempotentReplayedHeader)
+}
+
+func TestIdempotency_ReplaysStoredResponse(t *testing.T) {
+	calls := 0
+	app := newIdempotencyTestApp(newFakeIdempotencyRepo(), &calls, fiber.StatusCreated)
+
+	status, body, replayed := doIdempotentRequest(t, app, "key-1", "user-1", `{"type":"обувь"}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.Equal(t, `{"call":1}`, body)
+	assert.Empty(t, replayed)
+
+	status, body, replayed = doIdempotentRequest(t, app, "key-1", "user-1", `{"type":"обувь"}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.Equal(t, `{"call":1}`, body)
+	assert.Equal(t, "true", replayed)
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotency_DifferentBodyIsRejected(t *testing.T) {
+	calls := 0
+	app := newIdempotencyTestApp(newFakeIdempotencyRepo(), &calls, fiber.StatusCreated)
+
+	status, _, _ := doIdempotentRequest(t, app, "key-1", "user-1", `{"type":"обувь"}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	status, _, _ = doIdempotentRequest(t, app, "key-1", "user-1", `{"type":"одежда"}`)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotency_KeysAreScopedPerUser(t *testing.T) {
+	calls := 0
+	app := newIdempotencyTestApp(newFakeIdempotencyRepo(), &calls, fiber.StatusCreated)
+
+	doIdempotentRequest(t, app, "key-1", "user-1", `{}`)
+	_, _, replayed := doIdempotentRequest(t, app, "key-1", "user-2", `{}`)
+
+	assert.Empty(t, replayed)
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotency_FailedResponseReleasesKey(t *testing.T) {
+	calls := 0
+	repo := newFakeIdempotencyRepo()
+	app := newIdempotencyTestApp(repo, &calls, fiber.StatusBadRequest)
+
+	doIdempotentRequest(t, app, "key-1", "user-1", `{}`)
+	doIdempotentRequest(t, app, "key-1", "user-1", `{}`)
+
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, repo.records)
+}
+
+func TestIdempotency_InProgressKeyConflicts(t *testing.T) {
+	calls := 0
+	repo := newFakeIdempotencyRepo()
+	app := newIdempotencyTestApp(repo, &calls, fiber.StatusCreated)
+
+	doIdempotentRequest(t, app, "key-1", "user-1", `{}`)
+
+	// Simulate the first request still being handled by another instance
+	record := repo.records["user-1/key-1"]
+	record.StatusCode = 0
+	record.LockedUntil = time.Now().Add(time.Minute)
+	repo.records["user-1/key-1"] = record
+
+	status, _, _ := doIdempotentRequest(t, app, "key-1", "user-1", `{}`)
+	assert.Equal(t, fiber.StatusConflict, status)
+	assert.Equal(t, 1, calls)
+}
+
+// expiringIdempotencyRepo loses every key between reserving and reading it, as when the
+// key expires in between.
+type expiringIdempotencyRepo struct {
+	*fakeIdempotencyRepo
+}
+
+func (r expiringIdempotencyRepo) ReserveKey(key, userID, requestHash string, lease time.Duration, expiresAt time.Time) (time.Time, bool, error) {
+	return time.Time{}, false, nil
+}
+
+func TestIdempotency_KeyExpiredWhileChecked(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Post("/products", Idempotency(expiringIdempotencyRepo{newFakeIdempotencyRepo()}, time.Hour, time.Minute), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusCreated)
+	})
+
+	status, body, _ := doIdempotentRequest(t, app, "key-1", "user-1", `{"type":"обувь"}`)
+	assert.Equal(t, fiber.StatusConflict, status)
+	assert.Contains(t, body, "IDEMPOTENCY_KEY_EXPIRED")
+}
+
+func TestIdempotency_ExpiredLeaseIsTakenOver(t *testing.T) {
+	calls := 0
+	repo := newFakeIdempotencyRepo()
+	app := newIdempotencyTestApp(repo, &calls, fiber.StatusCreated)
+
+	// The first request crashed without finishing or releasing the key
+	staleLease := time.Now().Add(-time.Second)
+	repo.records["user-1/key-1"] = models.IdempotencyKey{Key: "key-1", UserID: "user-1",
+		LockedUntil: staleLease, ExpiresAt: time.Now().Add(time.Hour)}
+
+	status, body, replayed := doIdempotentRequest(t, app, "key-1", "user-1", `{}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.Equal(t, `{"call":1}`, body)
+	assert.Empty(t, replayed)
+
+	// The crashed request can't overwrite the response of the retry
+	assert.NoError(t, repo.SaveResponse("key-1", "user-1", staleLease, fiber.StatusCreated, "", []byte(`{"call":0}`)))
+	_, body, replayed = doIdempotentRequest(t, app, "key-1", "user-1", `{}`)
+	assert.Equal(t, `{"call":1}`, body)
+	assert.Equal(t, "true", replayed)
+}
+
+func TestIdempotency_DifferentQueryIsRejected(t *testing.T) {
+	calls := 0
+	app := newIdempotencyTestApp(newFakeIdempotencyRepo(), &calls, fiber.StatusCreated)
+
+	status, _, _ := doIdempotentRequestTo(t, app, "/products?dryRun=true", "key-1", "user-1", `{}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	status, _, _ = doIdempotentRequestTo(t, app, "/products", "key-1", "user-1", `{}`)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotency_WithoutHeaderPassesThrough(t *testing.T) {
+	calls := 0
+	repo := newFakeIdempotencyRepo()
+	app := newIdempotencyTestApp(repo, &calls, fiber.StatusCreated)
+
+	doIdempotentRequest(t, app, "", "user-1", `{}`)
+	doIdempotentRequest(t, app, "", "user-1", `{}`)
+
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, repo.records)
+}
+
+func TestIdempotency_KeysAreScopedPerAPIKey(t *testing.T) {
+	repo := newFakeIdempotencyRepo()
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Post("/products", func(c *fiber.Ctx) error {
+		c.Locals("apiKey", models.APIKey{ID: "key1"})
+		return c.Next()
+	}, Idempotency(repo, time.Hour, time.Minute), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusCreated)
+	})
+
+	req := httptest.NewRequest("POST", "/products", nil)
+	req.Header.Set(IdempotencyKeyHeader, "key-1")
+	_, err := app.Test(req)
+	assert.NoError(t, err)
+
+	_, err = repo.GetKey("key-1", "apikey:key1")
+	assert.NoError(t, err)
+}
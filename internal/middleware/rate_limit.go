@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"pvzService/internal/apperrors"
+	"pvzService/internal/ratelimit"
+)
+
+// RateLimit allows limit.Requests requests per window to every client and answers
+// 429 with Retry-After afterwards. Clients are told apart by the user in the token
+// claims or the API key, anonymous requests by IP, so it has to run after the
+// authentication middleware to count users rather than addresses.
+func RateLimit(store ratelimit.Store, limit ratelimit.Limit) fiber.Handler {
+	if limit.Requests <= 0 {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	return func(c *fiber.Ctx) error {
+		hits, resetAt, err := store.Hit(limit.Name+":"+rateLimitClient(c), limit.Window)
+		if err != nil {
+			// An unavailable store must not take the whole API down
+			log.Printf("Rate limit check failed, request is let through: %v", err)
+			return c.Next()
+		}
+		if hits > limit.Requests {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfterSeconds(resetAt)))
+			return apperrors.ErrRateLimited
+		}
+		return c.Next()
+	}
+}
+
+func rateLimitClient(c *fiber.Ctx) string {
+	if claims, ok := c.Locals("claims").(jwt.MapClaims); ok {
+		if userID, _ := claims["userId"].(string); userID != "" {
+			return "user:" + userID
+		}
+	}
+	if apiKey, ok := requestAPIKey(c); ok {
+		return "apikey:" + apiKey.ID
+	}
+	return "ip:" + c.IP()
+}
+
+// retryAfterSeconds rounds up, so a client waiting the advertised time lands in the
+// next window.
+func retryAfterSeconds(resetAt time.Time) int {
+	return max(1, int(math.Ceil(time.Until(resetAt).Seconds())))
+}
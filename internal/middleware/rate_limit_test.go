@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"pvzService/internal/handlers"
+	"pvzService/internal/ratelimit"
+)
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Hit(key string, window time.Duration) (int, time.Time, error) {
+	return 0, time.Time{}, errors.New("connection refused")
+}
+
+func newRateLimitTestApp(store ratelimit.Store, limit ratelimit.Limit) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		if user := c.Get("X-User"); user != "" {
+			c.Locals("claims", jwt.MapClaims{"userId": user})
+		}
+		return c.Next()
+	})
+	app.Post("/products", RateLimit(store, limit), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusCreated)
+	})
+	return app
+}
+
+func rateLimitedRequest(t *testing.T, app *fiber.App, user string) (int, string) {
+	req := httptest.NewRequest("POST", "/products", nil)
+	if user != "" {
+		req.Header.Set("X-User", user)
+	}
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	return resp.StatusCode, resp.Header.Get(fiber.HeaderRetryAfter)
+}
+
+func TestRateLimit_RejectsRequestsOverBudget(t *testing.T) {
+	app := newRateLimitTestApp(ratelimit.NewMemoryStore(), ratelimit.Limit{Name: "write", Requests: 2, Window: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		status, _ := rateLimitedRequest(t, app, "user-1")
+		assert.Equal(t, fiber.StatusCreated, status)
+	}
+
+	status, retryAfter := rateLimitedRequest(t, app, "user-1")
+	assert.Equal(t, fiber.StatusTooManyRequests, status)
+	assert.Equal(t, "60", retryAfter)
+}
+
+func TestRateLimit_BudgetsArePerClient(t *testing.T) {
+	app := newRateLimitTestApp(ratelimit.NewMemoryStore(), ratelimit.Limit{Name: "write", Requests: 1, Window: time.Minute})
+
+	status, _ := rateLimitedRequest(t, app, "user-1")
+	assert.Equal(t, fiber.StatusCreated, status)
+	status, _ = rateLimitedRequest(t, app, "user-2")
+	assert.Equal(t, fiber.StatusCreated, status)
+	status, _ = rateLimitedRequest(t, app, "")
+	assert.Equal(t, fiber.StatusCreated, status, "anonymous requests are counted per IP")
+	status, _ = rateLimitedRequest(t, app, "")
+	assert.Equal(t, fiber.StatusTooManyRequests, status)
+}
+
+func TestRateLimit_DisabledAndFailingStore(t *testing.T) {
+	app := newRateLimitTestApp(failingRateLimitStore{}, ratelimit.Limit{Name: "write", Requests: 0, Window: time.Minute})
+	status, _ := rateLimitedRequest(t, app, "user-1")
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	app = newRateLimitTestApp(failingRateLimitStore{}, ratelimit.Limit{Name: "write", Requests: 1, Window: time.Minute})
+	status, _ = rateLimitedRequest(t, app, "user-1")
+	assert.Equal(t, fiber.StatusCreated, status, "requests are let through when the store fails")
+}
@@ -4,6 +4,24 @@ import (
 	"time"
 )
 
+const (
+	RoleEmployee    = "employee"
+	RoleModerator   = "moderator"
+	RoleCityManager = "city_manager"
+	RoleAuditor     = "auditor"
+	RoleAdmin       = "admin"
+)
+
+// IsValidRole reports whether the role is one of the roles above, what a role can do
+// is defined by its permissions.
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleEmployee, RoleModerator, RoleCityManager, RoleAuditor, RoleAdmin:
+		return true
+	}
+	return false
+}
+
 type Token struct {
 	Token string `json:"token"`
 }
@@ -12,13 +30,73 @@ type User struct {
 	ID        string    `json:"id"`
 	Email     string    `json:"email"`
 	Role      string    `json:"role"`
+	City      *string   `json:"city,omitempty"`
+	Active    bool      `json:"active"`
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+// UserCredentials is what login needs to know about a user and is never serialized.
+type UserCredentials struct {
+	ID           string
+	PasswordHash string
+	Role         string
+	Active       bool
+	LockedUntil  *time.Time
+}
+
+// UserStatus is checked on every authenticated request. City is set for users who
+// only see the PVZ of their city.
+type UserStatus struct {
+	Role              string
+	Active            bool
+	PasswordChangedAt *time.Time
+	City              *string
+}
+
+// PVZ times are in the local time zone of the PVZ, the time zone of its city. An
+// archived PVZ is decommissioned and accepts no receptions, ArchivedBy is empty when
+// it was archived with an API key or the user was deleted since. Latitude and
+// Longitude are WGS 84 coordinates and are either both set or both empty. Capacity is
+// the number of products the PVZ can store, empty for a PVZ without a limit.
 type PVZ struct {
-	ID               string    `json:"id"`
-	RegistrationDate time.Time `json:"registrationDate"`
-	City             string    `json:"city"`
+	ID               string         `json:"id"`
+	RegistrationDate time.Time      `json:"registrationDate"`
+	City             string         `json:"city"`
+	Timezone         string         `json:"timezone"`
+	Name             string         `json:"name,omitempty"`
+	Address          string         `json:"address,omitempty"`
+	Latitude         *float64       `json:"latitude,omitempty"`
+	Longitude        *float64       `json:"longitude,omitempty"`
+	WorkingHours     []WorkingHours `json:"workingHours,omitempty"`
+	Capacity         *int           `json:"capacity,omitempty"`
+	StoredItems      int            `json:"storedItems"`
+	Archived         bool           `json:"archived"`
+	ArchivedAt       *time.Time     `json:"archivedAt,omitempty"`
+	ArchivedBy       *string        `json:"archivedBy,omitempty"`
+}
+
+// Weekdays in the order working hours are kept.
+var Weekdays = []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"}
+
+// WorkingHours are the opening hours of a PVZ on a weekday in its local time. Closes
+// is 24:00 for a PVZ open until midnight, a PVZ is closed on weekdays without hours.
+type WorkingHours struct {
+	Weekday string `json:"weekday" validate:"required,oneof=monday tuesday wednesday thursday friday saturday sunday"`
+	Opens   string `json:"opens" validate:"required,timeofday"`
+	Closes  string `json:"closes" validate:"required,timeofday"`
+}
+
+// NearbyPVZ is a PVZ with its great-circle distance from the searched point.
+type NearbyPVZ struct {
+	PVZ
+	DistanceKm float64 `json:"distanceKm"`
+}
+
+// PVZUtilization is a PVZ with the share of its capacity taken by stored products, it
+// is above 1 when products were accepted over capacity.
+type PVZUtilization struct {
+	PVZ
+	Utilization float64 `json:"utilization"`
 }
 
 type Reception struct {
@@ -29,13 +107,180 @@ type Reception struct {
 	ClosedAt *time.Time `json:"closedAt"`
 }
 
+// Product is stored in the PVZ until it is issued, IssuedAt is nil for a stored product.
 type Product struct {
-	ID          string    `json:"id"`
-	DateTime    time.Time `json:"dateTime"`
-	Type        string    `json:"type"`
-	ReceptionId string    `json:"receptionId"`
+	ID          string     `json:"id"`
+	DateTime    time.Time  `json:"dateTime"`
+	Type        string     `json:"type"`
+	ReceptionId string     `json:"receptionId"`
+	IssuedAt    *time.Time `json:"issuedAt"`
 }
 
 type Error struct {
+	Code    string       `json:"code,omitempty"`
+	Message string       `json:"message"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
 	Message string `json:"message"`
 }
+
+// APIKey is a key for service-to-service access. Only the hash of the key is stored,
+// Prefix is kept to tell keys apart.
+type APIKey struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Permissions []string   `json:"permissions"`
+	CreatedBy   *string    `json:"createdBy,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt  *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+	// CreatorCity is the city the creator of the key is limited to, set by UseAPIKey only.
+	CreatorCity *string `json:"-"`
+}
+
+// CreatedAPIKey is returned once on creation, Key can't be retrieved later.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+)
+
+// Readiness is the result of the readiness checks, the service is ready when all of
+// them pass.
+type Readiness struct {
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+type HealthCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type IdempotencyKey struct {
+	Key          string
+	UserID       string
+	RequestHash  string
+	StatusCode   int
+	ContentType  string
+	ResponseBody []byte
+	LockedUntil  time.Time
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+type DummyLoginRequest struct {
+	Role string `json:"role" validate:"required,oneof=employee moderator"`
+}
+
+type RegisterRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,password"`
+	Role     string `json:"role" validate:"required,oneof=employee moderator auditor admin"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+type CreatePVZRequest struct {
+	City string `json:"city" validate:"required,oneof=Москва Санкт-Петербург Казань"`
+}
+
+// UpdatePVZRequest changes only the fields that are present in the body. Working hours
+// replace the hours of every weekday, an empty list clears them. Capacity 0 removes the
+// limit.
+type UpdatePVZRequest struct {
+	Name         *string         `json:"name" validate:"max=200"`
+	Address      *string         `json:"address" validate:"max=500"`
+	Latitude     *float64        `json:"latitude" validate:"min=-90,max=90"`
+	Longitude    *float64        `json:"longitude" validate:"min=-180,max=180"`
+	WorkingHours *[]WorkingHours `json:"workingHours" validate:"max=7"`
+	Capacity     *int            `json:"capacity" validate:"min=0,max=1000000"`
+}
+
+type CreateReceptionRequest struct {
+	PvzId string `json:"pvzId" validate:"required,uuid"`
+}
+
+type AddProductRequest struct {
+	Type  string `json:"type" validate:"required,oneof=электроника одежда обувь"`
+	PvzId string `json:"pvzId" validate:"required,uuid"`
+}
+
+type PVZListQuery struct {
+	Page            int    `query:"page" validate:"required,min=1"`
+	Limit           int    `query:"limit" validate:"required,min=1,max=30"`
+	StartDate       string `query:"startDate" validate:"rfc3339"`
+	EndDate         string `query:"endDate" validate:"rfc3339"`
+	IncludeArchived bool   `query:"includeArchived"`
+}
+
+// NearbyPVZQuery searches PVZ around a point, with OpenAt only the PVZ whose working
+// hours include that time.
+type NearbyPVZQuery struct {
+	Lat      *float64 `query:"lat" validate:"required,min=-90,max=90"`
+	Lon      *float64 `query:"lon" validate:"required,min=-180,max=180"`
+	RadiusKm float64  `query:"radiusKm" validate:"required,min=0,max=100"`
+	Limit    int      `query:"limit" validate:"required,min=1,max=50"`
+	OpenAt   string   `query:"openAt" validate:"rfc3339"`
+}
+
+// PVZUtilizationQuery lists PVZ with a capacity whose utilization is at least
+// MinUtilization, the fullest first.
+type PVZUtilizationQuery struct {
+	Page           int     `query:"page" validate:"required,min=1"`
+	Limit          int     `query:"limit" validate:"required,min=1,max=100"`
+	MinUtilization float64 `query:"minUtilization" validate:"min=0,max=10"`
+}
+
+type UserListQuery struct {
+	Page  int    `query:"page" validate:"required,min=1"`
+	Limit int    `query:"limit" validate:"required,min=1,max=100"`
+	Role  string `query:"role" validate:"oneof=employee moderator city_manager auditor admin"`
+}
+
+// UpdateUserRequest changes only the fields that are present in the body. A city is
+// required for city managers and dropped when a user stops being one.
+type UpdateUserRequest struct {
+	Role   *string `json:"role" validate:"oneof=employee moderator city_manager auditor admin"`
+	City   *string `json:"city" validate:"oneof=Москва Санкт-Петербург Казань"`
+	Active *bool   `json:"active"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name        string   `json:"name" validate:"required,max=100"`
+	Permissions []string `json:"permissions" validate:"required"`
+	ExpiresAt   string   `json:"expiresAt" validate:"rfc3339"`
+}
+
+type APIKeyListQuery struct {
+	Page  int `query:"page" validate:"required,min=1"`
+	Limit int `query:"limit" validate:"required,min=1,max=100"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required,password"`
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required,password"`
+}
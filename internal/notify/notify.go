@@ -0,0 +1,24 @@
+package notify
+
+import (
+	"fmt"
+	"time"
+)
+
+// Notifier delivers messages to users. Implementations are safe for concurrent use.
+type Notifier interface {
+	SendPasswordReset(email, token string, expiresAt time.Time) error
+}
+
+const passwordResetSubject = "Сброс пароля"
+
+// passwordResetText builds the message body. When resetURL is set the token is
+// appended to it as a query parameter, otherwise the bare token is sent.
+func passwordResetText(resetURL, token string, expiresAt time.Time) string {
+	instruction := "Код для сброса пароля: " + token
+	if resetURL != "" {
+		instruction = "Для сброса пароля перейдите по ссылке: " + resetURL + "?token=" + token
+	}
+	return fmt.Sprintf("%s\nСсылка действует до %s. Если вы не запрашивали сброс пароля, проигнорируйте это письмо.\n",
+		instruction, expiresAt.UTC().Format(time.RFC3339))
+}
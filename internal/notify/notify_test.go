@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileNotifier_AppendsMessages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.log")
+	notifier := NewFileNotifier(path, "https://pvz.example.com/reset")
+	expiresAt := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, notifier.SendPasswordReset("a@example.com", "token-1", expiresAt))
+	assert.NoError(t, notifier.SendPasswordReset("b@example.com", "token-2", expiresAt))
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	var messages []sinkMessage
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var message sinkMessage
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &message))
+		messages = append(messages, message)
+	}
+
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "password_reset", messages[0].Type)
+	assert.Equal(t, "a@example.com", messages[0].Email)
+	assert.Equal(t, "token-1", messages[0].Token)
+	assert.Contains(t, messages[0].Text, "https://pvz.example.com/reset?token=token-1")
+	assert.Equal(t, "token-2", messages[1].Token)
+}
+
+func TestSMTPNotifier_SendPasswordReset(t *testing.T) {
+	notifier := NewSMTPNotifier("smtp.example.com", "587", "user", "pass", "noreply@example.com", "")
+
+	var sentTo []string
+	var sentMsg string
+	notifier.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		assert.Equal(t, "smtp.example.com:587", addr)
+		assert.NotNil(t, a)
+		assert.Equal(t, "noreply@example.com", from)
+		sentTo, sentMsg = to, string(msg)
+		return nil
+	}
+
+	assert.NoError(t, notifier.SendPasswordReset("user@example.com", "secret-token", time.Now().Add(time.Hour)))
+	assert.Equal(t, []string{"user@example.com"}, sentTo)
+	assert.Contains(t, sentMsg, "To: user@example.com\r\n")
+	assert.Contains(t, sentMsg, "Content-Type: text/plain; charset=utf-8")
+	assert.Contains(t, sentMsg, "Код для сброса пароля: secret-token")
+	assert.True(t, strings.Contains(sentMsg, "\r\n\r\n"))
+}
+
+func TestSMTPNotifier_RejectsHeaderInjection(t *testing.T) {
+	notifier := NewSMTPNotifier("smtp.example.com", "25", "", "", "noreply@example.com", "")
+	notifier.sendMail = func(string, smtp.Auth, string, []string, []byte) error {
+		t.Fatal("message must not be sent")
+		return nil
+	}
+
+	assert.Error(t, notifier.SendPasswordReset("user@example.com\r\nBcc: evil@example.com", "token", time.Now()))
+}
@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// sinkMessage is a line written by LogNotifier and FileNotifier.
+type sinkMessage struct {
+	Type      string    `json:"type"`
+	Email     string    `json:"email"`
+	Token     string    `json:"token"`
+	Text      string    `json:"text"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// LogNotifier writes messages, including secrets, to the application log. It is meant
+// for local development only.
+type LogNotifier struct {
+	resetURL string
+}
+
+func NewLogNotifier(resetURL string) *LogNotifier {
+	return &LogNotifier{resetURL: resetURL}
+}
+
+func (n *LogNotifier) SendPasswordReset(email, token string, expiresAt time.Time) error {
+	log.Printf("Password reset for %s: %s", email, passwordResetText(n.resetURL, token, expiresAt))
+	return nil
+}
+
+// FileNotifier appends messages as JSON lines to a file, so local setups and tests
+// can pick up the tokens.
+type FileNotifier struct {
+	mu       sync.Mutex
+	path     string
+	resetURL string
+}
+
+func NewFileNotifier(path, resetURL string) *FileNotifier {
+	return &FileNotifier{path: path, resetURL: resetURL}
+}
+
+func (n *FileNotifier) SendPasswordReset(email, token string, expiresAt time.Time) error {
+	line, err := json.Marshal(sinkMessage{
+		Type:      "password_reset",
+		Email:     email,
+		Token:     token,
+		Text:      passwordResetText(n.resetURL, token, expiresAt),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	file, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPNotifier sends messages as plain text emails.
+type SMTPNotifier struct {
+	addr     string
+	auth     smtp.Auth
+	from     string
+	resetURL string
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTPNotifier authenticates with PLAIN auth when username is set.
+func NewSMTPNotifier(host, port, username, password, from, resetURL string) *SMTPNotifier {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPNotifier{
+		addr:     net.JoinHostPort(host, port),
+		auth:     auth,
+		from:     from,
+		resetURL: resetURL,
+		sendMail: smtp.SendMail,
+	}
+}
+
+func (n *SMTPNotifier) SendPasswordReset(email, token string, expiresAt time.Time) error {
+	if strings.ContainsAny(email, "\r\n") {
+		return fmt.Errorf("invalid recipient address %q", email)
+	}
+
+	headers := []string{
+		"From: " + n.from,
+		"To: " + email,
+		"Subject: " + mime.QEncoding.Encode("utf-8", passwordResetSubject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Transfer-Encoding: 8bit",
+	}
+	body := strings.ReplaceAll(passwordResetText(n.resetURL, token, expiresAt), "\n", "\r\n")
+	msg := strings.Join(headers, "\r\n") + "\r\n\r\n" + body
+
+	if err := n.sendMail(n.addr, n.auth, n.from, []string{email}, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send password reset email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Package oidc implements the authorization code flow against an external OpenID
+// Connect identity provider.
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Identity is the verified user information taken from the ID token.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Groups        []string
+}
+
+// Authenticator runs the authorization code flow with PKCE.
+type Authenticator interface {
+	// AuthCodeURL returns the IdP URL the user is redirected to.
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	// Exchange trades the code for tokens and returns the verified identity.
+	Exchange(ctx context.Context, code, verifier, nonce string) (Identity, error)
+}
+
+type Config struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// GroupsClaim is the ID token claim with the user's groups, "groups" by default
+	GroupsClaim string
+}
+
+// Client discovers the provider on first use and retries discovery until it succeeds,
+// so the service starts even while the IdP is unavailable.
+type Client struct {
+	cfg Config
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
+
+func NewClient(cfg Config) *Client {
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	return &Client{cfg: cfg}
+}
+
+func (c *Client) discover(ctx context.Context) (*oauth2.Config, *gooidc.IDTokenVerifier, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.oauth == nil {
+		provider, err := gooidc.NewProvider(ctx, c.cfg.IssuerURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("discover OIDC provider: %w", err)
+		}
+		c.oauth = &oauth2.Config{
+			ClientID:     c.cfg.ClientID,
+			ClientSecret: c.cfg.ClientSecret,
+			RedirectURL:  c.cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{gooidc.ScopeOpenID, "email", "profile"},
+		}
+		c.verifier = provider.Verifier(&gooidc.Config{ClientID: c.cfg.ClientID})
+	}
+	return c.oauth, c.verifier, nil
+}
+
+func (c *Client) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	oauth, _, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return oauth.AuthCodeURL(state, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+func (c *Client) Exchange(ctx context.Context, code, verifier, nonce string) (Identity, error) {
+	oauth, idTokenVerifier, err := c.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	token, err := oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return Identity{}, fmt.Errorf("exchange code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Identity{}, errors.New("token response has no id_token")
+	}
+
+	idToken, err := idTokenVerifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return Identity{}, fmt.Errorf("verify id_token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return Identity{}, errors.New("id_token nonce mismatch")
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return Identity{}, fmt.Errorf("decode id_token claims: %w", err)
+	}
+
+	identity := Identity{Issuer: idToken.Issuer, Subject: idToken.Subject}
+	identity.Email, _ = claims["email"].(string)
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	identity.Groups = stringList(claims[c.cfg.GroupsClaim])
+	return identity, nil
+}
+
+// stringList accepts both a list and a single string, IdPs differ here.
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
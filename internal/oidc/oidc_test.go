@@ -0,0 +1,133 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pvzService/internal/oidc/mockidp"
+)
+
+const testRedirectURL = "http://localhost:8080/auth/oidc/callback"
+
+func newTestClient(t *testing.T) (*Client, *mockidp.Server) {
+	idp, server, err := mockidp.NewTestServer("pvz", "pvz-secret")
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	idp.AddUser("alice", mockidp.User{Subject: "alice-sub", Email: "alice@example.com", EmailVerified: true, Groups: []string{"pvz-moderators"}})
+	return NewClient(Config{IssuerURL: idp.Issuer, ClientID: "pvz", ClientSecret: "pvz-secret", RedirectURL: testRedirectURL}), idp
+}
+
+// authorize follows the IdP authorization URL and returns the callback query.
+func authorize(t *testing.T, authURL string) url.Values {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return location.Query()
+}
+
+func TestClient_AuthorizationCodeFlow(t *testing.T) {
+	client, idp := newTestClient(t)
+	ctx := context.Background()
+
+	authURL, err := client.AuthCodeURL(ctx, "state1", "nonce1", "verifier-0123456789-0123456789-0123456789")
+	require.NoError(t, err)
+	assert.Contains(t, authURL, idp.Issuer+"/authorize")
+
+	callback := authorize(t, authURL)
+	assert.Equal(t, "state1", callback.Get("state"))
+
+	identity, err := client.Exchange(ctx, callback.Get("code"), "verifier-0123456789-0123456789-0123456789", "nonce1")
+	require.NoError(t, err)
+	assert.Equal(t, Identity{
+		Issuer:        idp.Issuer,
+		Subject:       "alice-sub",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		Groups:        []string{"pvz-moderators"},
+	}, identity)
+}
+
+func TestClient_Exchange_Rejects(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+	verifier := "verifier-0123456789-0123456789-0123456789"
+
+	t.Run("wrong PKCE verifier", func(t *testing.T) {
+		authURL, err := client.AuthCodeURL(ctx, "state", "nonce", verifier)
+		require.NoError(t, err)
+		_, err = client.Exchange(ctx, authorize(t, authURL).Get("code"), verifier+"x", "nonce")
+		assert.Error(t, err)
+	})
+
+	t.Run("wrong nonce", func(t *testing.T) {
+		authURL, err := client.AuthCodeURL(ctx, "state", "nonce", verifier)
+		require.NoError(t, err)
+		_, err = client.Exchange(ctx, authorize(t, authURL).Get("code"), verifier, "other")
+		assert.Error(t, err)
+	})
+
+	t.Run("reused code", func(t *testing.T) {
+		authURL, err := client.AuthCodeURL(ctx, "state", "nonce", verifier)
+		require.NoError(t, err)
+		code := authorize(t, authURL).Get("code")
+		_, err = client.Exchange(ctx, code, verifier, "nonce")
+		require.NoError(t, err)
+		_, err = client.Exchange(ctx, code, verifier, "nonce")
+		assert.Error(t, err)
+	})
+}
+
+func TestClient_DiscoveryIsRetried(t *testing.T) {
+	client := NewClient(Config{IssuerURL: "http://127.0.0.1:1", ClientID: "pvz"})
+
+	_, err := client.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	assert.Error(t, err)
+	assert.Nil(t, client.oauth)
+}
+
+func TestRoleMapper(t *testing.T) {
+	mapper := RoleMapper{EmployeeGroups: []string{"pvz-staff"}, ModeratorGroups: []string{"pvz-admins", "pvz-moderators"}}
+
+	tests := []struct {
+		groups []string
+		role   string
+		ok     bool
+	}{
+		{[]string{"pvz-staff"}, "employee", true},
+		{[]string{"other", "pvz-moderators"}, "moderator", true},
+		{[]string{"pvz-staff", "pvz-admins"}, "moderator", true},
+		{[]string{"other"}, "", false},
+		{nil, "", false},
+	}
+	for _, tt := range tests {
+		role, ok := mapper.Role(tt.groups)
+		assert.Equal(t, tt.role, role, "groups %v", tt.groups)
+		assert.Equal(t, tt.ok, ok, "groups %v", tt.groups)
+	}
+}
+
+func TestRoleMapper_Owns(t *testing.T) {
+	var mapper RoleMapper
+	assert.True(t, mapper.Owns("employee"))
+	assert.True(t, mapper.Owns("moderator"))
+	assert.False(t, mapper.Owns("admin"))
+	assert.False(t, mapper.Owns("auditor"))
+	assert.False(t, mapper.Owns("city_manager"))
+}
+
+func TestStringList(t *testing.T) {
+	assert.Equal(t, []string{"a"}, stringList("a"))
+	assert.Equal(t, []string{"a", "b"}, stringList([]interface{}{"a", 1, "b"}))
+	assert.Nil(t, stringList(nil))
+}
@@ -0,0 +1,37 @@
+package oidc
+
+// RoleMapper maps IdP groups to service roles. Moderator groups take precedence when
+// a user is in groups of both roles.
+type RoleMapper struct {
+	EmployeeGroups  []string
+	ModeratorGroups []string
+}
+
+// Role returns the role for the groups and false when none of them is mapped.
+func (m RoleMapper) Role(groups []string) (string, bool) {
+	switch {
+	case intersects(groups, m.ModeratorGroups):
+		return "moderator", true
+	case intersects(groups, m.EmployeeGroups):
+		return "employee", true
+	default:
+		return "", false
+	}
+}
+
+// Owns reports whether the role is assigned by groups. Other roles are granted in the
+// service and are kept when the user logs in through the IdP.
+func (m RoleMapper) Owns(role string) bool {
+	return role == "moderator" || role == "employee"
+}
+
+func intersects(groups, mapped []string) bool {
+	for _, group := range groups {
+		for _, candidate := range mapped {
+			if group == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
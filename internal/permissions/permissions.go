@@ -0,0 +1,90 @@
+// Package permissions maps roles to the named permissions checked by the API. The
+// mapping lives in the role_permissions table, so granting a permission to a role
+// needs no release.
+package permissions
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"pvzService/internal/repository"
+)
+
+const (
+	PVZCreate       = "pvz:create"
+	PVZRead         = "pvz:read"
+	PVZUpdate       = "pvz:update"
+	PVZArchive      = "pvz:archive"
+	ReceptionCreate = "reception:create"
+	ReceptionClose  = "reception:close"
+	ProductCreate   = "product:create"
+	ProductDelete   = "product:delete"
+	ProductIssue    = "product:issue"
+	UserRead        = "user:read"
+	UserManage      = "user:manage"
+	APIKeyManage    = "apikey:manage"
+)
+
+// All lists every permission checked by the API.
+var All = []string{PVZCreate, PVZRead, PVZUpdate, PVZArchive, ReceptionCreate, ReceptionClose, ProductCreate, ProductDelete, ProductIssue, UserRead, UserManage, APIKeyManage}
+
+type Checker interface {
+	Has(role, permission string) (bool, error)
+}
+
+// Table is a fixed mapping of roles to their permissions.
+type Table map[string][]string
+
+func (t Table) Has(role, permission string) (bool, error) {
+	for _, p := range t[role] {
+		if p == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Cache reads the role_permissions table at most once per ttl. When a reload fails the
+// previous table keeps being used, so a short database outage does not lock everyone out.
+type Cache struct {
+	repo repository.PermissionRepository
+	ttl  time.Duration
+
+	mu       sync.Mutex
+	table    Table
+	loadedAt time.Time
+}
+
+func NewCache(repo repository.PermissionRepository, ttl time.Duration) *Cache {
+	return &Cache{repo: repo, ttl: ttl}
+}
+
+func (c *Cache) Has(role, permission string) (bool, error) {
+	table, err := c.load()
+	if err != nil {
+		return false, err
+	}
+	return table.Has(role, permission)
+}
+
+func (c *Cache) load() (Table, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.table != nil && time.Since(c.loadedAt) < c.ttl {
+		return c.table, nil
+	}
+
+	table, err := c.repo.ListRolePermissions()
+	if err != nil {
+		if c.table != nil {
+			log.Printf("Failed to reload role permissions, keeping the previous ones: %v", err)
+			c.loadedAt = time.Now()
+			return c.table, nil
+		}
+		return nil, err
+	}
+	c.table, c.loadedAt = table, time.Now()
+	return c.table, nil
+}
@@ -0,0 +1,69 @@
+package permissions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePermissionRepo struct {
+	table Table
+	err   error
+	calls int
+}
+
+func (r *fakePermissionRepo) ListRolePermissions() (map[string][]string, error) {
+	r.calls++
+	return r.table, r.err
+}
+
+func TestTable_Has(t *testing.T) {
+	table := Table{"auditor": {PVZRead, UserRead}}
+
+	ok, err := table.Has("auditor", UserRead)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, _ = table.Has("auditor", UserManage)
+	assert.False(t, ok)
+	ok, _ = table.Has("unknown", PVZRead)
+	assert.False(t, ok)
+}
+
+func TestCache_ReloadsAfterTTL(t *testing.T) {
+	repo := &fakePermissionRepo{table: Table{"moderator": {PVZCreate}}}
+	cache := NewCache(repo, time.Hour)
+
+	ok, err := cache.Has("moderator", PVZCreate)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	_, _ = cache.Has("moderator", PVZRead)
+	assert.Equal(t, 1, repo.calls)
+
+	cache.loadedAt = time.Now().Add(-2 * time.Hour)
+	repo.table = Table{"moderator": {PVZRead}}
+	ok, _ = cache.Has("moderator", PVZRead)
+	assert.True(t, ok)
+	assert.Equal(t, 2, repo.calls)
+}
+
+func TestCache_KeepsPreviousTableOnError(t *testing.T) {
+	repo := &fakePermissionRepo{err: errors.New("connection refused")}
+	cache := NewCache(repo, time.Hour)
+
+	_, err := cache.Has("moderator", PVZCreate)
+	assert.Error(t, err)
+
+	repo.table, repo.err = Table{"moderator": {PVZCreate}}, nil
+	ok, err := cache.Has("moderator", PVZCreate)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	cache.loadedAt = time.Now().Add(-2 * time.Hour)
+	repo.err = errors.New("connection refused")
+	ok, err = cache.Has("moderator", PVZCreate)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
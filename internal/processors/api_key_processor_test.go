@@ -0,0 +1,122 @@
+package processors
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/clock"
+	"pvzService/internal/models"
+	"pvzService/internal/permissions"
+)
+
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) CreateAPIKey(name, keyHash, prefix string, keyPermissions []string, createdBy string, expiresAt *time.Time) (models.APIKey, error) {
+	args := m.Called(name, keyHash, prefix, keyPermissions, createdBy, expiresAt)
+	return args.Get(0).(models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) ListAPIKeys(page, limit int) ([]models.APIKey, error) {
+	args := m.Called(page, limit)
+	return args.Get(0).([]models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) RevokeAPIKey(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) UseAPIKey(keyHash string) (models.APIKey, error) {
+	args := m.Called(keyHash)
+	return args.Get(0).(models.APIKey), args.Error(1)
+}
+
+var apiKeyTestPermissions = permissions.Table{
+	"moderator": {permissions.PVZCreate, permissions.PVZRead, permissions.APIKeyManage},
+}
+
+func TestAPIKeyProcessor_CreateAPIKey(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	processor := NewAPIKeyProcessor(mockRepo, apiKeyTestPermissions, clock.NewFake(testNow))
+	expiresAt := testNow.Add(24 * time.Hour)
+
+	var storedHash, storedPrefix string
+	mockRepo.On("CreateAPIKey", "reports", mock.Anything, mock.Anything, []string{"pvz:read"}, "user1", &expiresAt).
+		Run(func(args mock.Arguments) {
+			storedHash, storedPrefix = args.String(1), args.String(2)
+		}).
+		Return(models.APIKey{ID: "key1", Name: "reports"}, nil)
+
+	created, err := processor.CreateAPIKey("user1", "moderator", "reports", []string{"pvz:read"}, expiresAt.Format(time.RFC3339))
+	assert.NoError(t, err)
+	assert.Equal(t, "key1", created.ID)
+	assert.True(t, strings.HasPrefix(created.Key, "pvz_"))
+	assert.Equal(t, hashAPIKey(created.Key), storedHash)
+	assert.NotEqual(t, created.Key, storedHash)
+	assert.Equal(t, created.Key[:12], storedPrefix)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAPIKeyProcessor_CreateAPIKey_Rejects(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions []string
+		expiresAt   string
+		expected    error
+	}{
+		{"no permissions", nil, "", apperrors.Validation(nil)},
+		{"unknown permission", []string{"report:read"}, "", apperrors.Validation(nil)},
+		{"key management", []string{"apikey:manage"}, "", apperrors.Validation(nil)},
+		{"permission the creator lacks", []string{"pvz:read", "reception:create"}, "", apperrors.ErrAPIKeyPermissionNotHeld},
+		{"expiry in the past", []string{"pvz:read"}, testNow.Add(-time.Hour).Format(time.RFC3339), apperrors.ErrAPIKeyExpiryInPast},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockAPIKeyRepository)
+			processor := NewAPIKeyProcessor(mockRepo, apiKeyTestPermissions, clock.NewFake(testNow))
+
+			_, err := processor.CreateAPIKey("user1", "moderator", "reports", tt.permissions, tt.expiresAt)
+			assert.ErrorIs(t, err, tt.expected)
+			mockRepo.AssertNotCalled(t, "CreateAPIKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestAPIKeyProcessor_Authenticate(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	processor := NewAPIKeyProcessor(mockRepo, apiKeyTestPermissions, clock.NewFake(testNow))
+
+	mockRepo.On("UseAPIKey", hashAPIKey("pvz_valid")).Return(models.APIKey{ID: "key1", Permissions: []string{"pvz:read"}}, nil)
+	mockRepo.On("UseAPIKey", hashAPIKey("pvz_revoked")).Return(models.APIKey{}, sql.ErrNoRows)
+
+	key, err := processor.Authenticate("pvz_valid")
+	assert.NoError(t, err)
+	assert.Equal(t, "key1", key.ID)
+
+	_, err = processor.Authenticate("pvz_revoked")
+	assert.ErrorIs(t, err, apperrors.ErrInvalidAPIKey)
+
+	_, err = processor.Authenticate("not-a-key")
+	assert.ErrorIs(t, err, apperrors.ErrInvalidAPIKey)
+	mockRepo.AssertNumberOfCalls(t, "UseAPIKey", 2)
+}
+
+func TestAPIKeyProcessor_RevokeAPIKey(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	processor := NewAPIKeyProcessor(mockRepo, apiKeyTestPermissions, clock.NewFake(testNow))
+
+	mockRepo.On("RevokeAPIKey", "key1").Return(nil)
+	mockRepo.On("RevokeAPIKey", "key2").Return(sql.ErrNoRows)
+
+	assert.NoError(t, processor.RevokeAPIKey("key1"))
+	assert.ErrorIs(t, processor.RevokeAPIKey("key2"), apperrors.ErrAPIKeyNotFound)
+}
@@ -4,75 +4,227 @@ import (
 	"database/sql"
 	"errors"
 	"golang.org/x/crypto/bcrypt"
-
+	"sync"
+	"time"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/clock"
+	"pvzService/internal/models"
+	"pvzService/internal/permissions"
+	"pvzService/internal/prometheus"
 	"pvzService/internal/repository"
+	"pvzService/internal/validation"
 )
 
 type AuthProcessor interface {
-	Register(email, password, role string) (string, error)
-	Login(email, password string) (string, string, error)
+	Register(email, password, role, actorRole string) (string, error)
+	Login(email, password, ip string) (string, string, error)
 	DummyLogin(role string) (string, error)
 	HashPassword(password string) (string, error)
 	ComparePassword(hashedPassword, password string) error
 }
 
+// LoginProtection limits failed logins per account and per client IP within Window.
+// Reaching a limit locks the account or IP for LockoutDuration, zero disables a limit.
+type LoginProtection struct {
+	MaxAccountAttempts int
+	MaxIPAttempts      int
+	Window             time.Duration
+	LockoutDuration    time.Duration
+}
+
 type AuthProcessorImpl struct {
-	authRepo repository.AuthRepository
+	authRepo         repository.AuthRepository
+	loginAttemptRepo repository.LoginAttemptRepository
+	checker          permissions.Checker
+	protection       LoginProtection
+	clock            clock.Clock
+
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
-func NewAuthProcessor(authRepo repository.AuthRepository) AuthProcessor {
-	return &AuthProcessorImpl{authRepo: authRepo}
+func NewAuthProcessor(authRepo repository.AuthRepository, loginAttemptRepo repository.LoginAttemptRepository,
+	checker permissions.Checker, protection LoginProtection, clock clock.Clock) AuthProcessor {
+	return &AuthProcessorImpl{authRepo: authRepo, loginAttemptRepo: loginAttemptRepo, checker: checker, protection: protection, clock: clock}
 }
 
 func (p *AuthProcessorImpl) HashPassword(password string) (string, error) {
+	return hashPassword(password)
+}
+
+func (p *AuthProcessorImpl) ComparePassword(hashedPassword, password string) error {
+	return comparePassword(hashedPassword, password)
+}
+
+func hashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 	return string(bytes), err
 }
 
-func (p *AuthProcessorImpl) ComparePassword(hashedPassword, password string) error {
+func comparePassword(hashedPassword, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
 
-func (p *AuthProcessorImpl) Register(email, password, role string) (string, error) {
-	if role != "employee" && role != "moderator" {
-		return "", errors.New("invalid role")
+// Register creates a user. Anyone can register an employee, other roles can only be
+// registered by a caller with the user:manage permission and admins only by an admin,
+// actorRole is the role of the caller or empty for anonymous requests. City managers
+// need a city, so users become city managers through UpdateUser.
+func (p *AuthProcessorImpl) Register(email, password, role, actorRole string) (string, error) {
+	if !models.IsValidRole(role) || role == models.RoleCityManager {
+		return "", apperrors.ErrInvalidRole
+	}
+	if role == models.RoleAdmin && actorRole != models.RoleAdmin {
+		return "", apperrors.ErrAdminOnly
+	}
+	if role != models.RoleEmployee {
+		allowed := false
+		if actorRole != "" {
+			var err error
+			if allowed, err = p.checker.Has(actorRole, permissions.UserManage); err != nil {
+				return "", apperrors.Internal("failed to check permissions", err)
+			}
+		}
+		if !allowed {
+			return "", apperrors.ErrPrivilegedRegistration
+		}
+	}
+
+	if err := validation.Var("password", password, "required,password"); err != nil {
+		return "", err
 	}
 
 	hashedPassword, err := p.HashPassword(password)
 	if err != nil {
-		return "", errors.New("failed to process password")
+		return "", apperrors.Internal("failed to process password", err)
 	}
 
-	return p.authRepo.CreateUser(email, hashedPassword, role)
+	userID, err := p.authRepo.CreateUser(email, hashedPassword, role)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrEmailAlreadyExists) {
+			return "", apperrors.ErrEmailAlreadyExists
+		}
+		return "", apperrors.Internal("failed to create user", err)
+	}
+	return userID, nil
 }
 
-func (p *AuthProcessorImpl) Login(email, password string) (string, string, error) {
-	userID, hashedPassword, role, err := p.authRepo.FindUserByEmail(email)
-	if err != nil {
-		return "", "", errors.New("invalid email or password")
+// Login checks the credentials and enforces LoginProtection. Unknown emails, wrong
+// passwords, deactivated and locked accounts all look the same to the client and take the same
+// bcrypt time, so the response does not reveal whether an account exists.
+func (p *AuthProcessorImpl) Login(email, password, ip string) (string, string, error) {
+	if p.protection.MaxIPAttempts > 0 {
+		locked, err := p.loginAttemptRepo.IsIPLocked(ip)
+		if err != nil {
+			return "", "", apperrors.Internal("failed to check login attempts", err)
+		}
+		if locked {
+			prometheus.FailedLogins.WithLabelValues("ip_locked").Inc()
+			return "", "", apperrors.ErrTooManyLoginAttempts
+		}
+	}
+
+	user, err := p.authRepo.FindUserByEmail(email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", "", apperrors.Internal("failed to find user", err)
 	}
+	found := err == nil
 
-	if err := p.ComparePassword(hashedPassword, password); err != nil {
-		return "", "", errors.New("invalid email or password")
+	hasPassword := found && user.PasswordHash != unusablePasswordHash
+	hashedPassword := user.PasswordHash
+	if !hasPassword {
+		hashedPassword = p.dummyPasswordHash()
+	}
+	passwordErr := p.ComparePassword(hashedPassword, password)
+
+	switch {
+	case !found:
+		return "", "", p.loginFailed("unknown_user", "", ip)
+	case !hasPassword:
+		return "", "", p.loginFailed("invalid_password", user.ID, ip)
+	case !user.Active:
+		return "", "", p.loginFailed("account_deactivated", "", ip)
+	case user.LockedUntil != nil && user.LockedUntil.After(p.clock.Now()):
+		return "", "", p.loginFailed("account_locked", "", ip)
+	case passwordErr != nil:
+		return "", "", p.loginFailed("invalid_password", user.ID, ip)
 	}
 
-	return userID, role, nil
+	if p.protection.MaxAccountAttempts > 0 {
+		if err := p.authRepo.ResetFailedLogins(user.ID); err != nil {
+			return "", "", apperrors.Internal("failed to reset failed logins", err)
+		}
+	}
+	return user.ID, user.Role, nil
 }
 
+// loginFailed records the failure for the account (when userID is set) and the IP and
+// returns the error shown to the client.
+func (p *AuthProcessorImpl) loginFailed(reason, userID, ip string) error {
+	prometheus.FailedLogins.WithLabelValues(reason).Inc()
+
+	if userID != "" && p.protection.MaxAccountAttempts > 0 {
+		locked, err := p.authRepo.RegisterFailedLogin(userID, p.protection.MaxAccountAttempts, p.protection.Window, p.protection.LockoutDuration)
+		if err != nil {
+			return apperrors.Internal("failed to register failed login", err)
+		}
+		if locked {
+			prometheus.LoginLockouts.WithLabelValues("account").Inc()
+		}
+	}
+
+	if p.protection.MaxIPAttempts > 0 {
+		locked, err := p.loginAttemptRepo.RegisterIPFailure(ip, p.protection.MaxIPAttempts, p.protection.Window, p.protection.LockoutDuration)
+		if err != nil {
+			return apperrors.Internal("failed to register failed login", err)
+		}
+		if locked {
+			prometheus.LoginLockouts.WithLabelValues("ip").Inc()
+		}
+	}
+
+	return apperrors.ErrInvalidCredentials
+}
+
+// dummyPasswordHash is compared against when the email is unknown to keep the timing
+// of failed logins consistent. It is computed once with the regular bcrypt cost.
+func (p *AuthProcessorImpl) dummyPasswordHash() string {
+	p.dummyHashOnce.Do(func() {
+		p.dummyHash, _ = p.HashPassword("dummy-password-for-timing")
+	})
+	return p.dummyHash
+}
+
+// unusablePasswordHash is stored for accounts that must not log in with a password.
+// It is not a bcrypt hash, so no password matches it.
+const unusablePasswordHash = "!"
+
+// DummyLogin returns the dummy user of the role, creating it on first use. Every role
+// has its own dummy user without a usable password, so it can't be used on /login.
 func (p *AuthProcessorImpl) DummyLogin(role string) (string, error) {
 	if role != "employee" && role != "moderator" {
-		return "", errors.New("invalid role")
+		return "", apperrors.ErrInvalidRole
 	}
 
-	userID, err := p.authRepo.FindUserByRole(role)
+	email := "dummy-" + role + "@example.com"
+	user, err := p.authRepo.FindUserByEmail(email)
 	if errors.Is(err, sql.ErrNoRows) {
-		hashedPassword, err := p.HashPassword("password")
-		if err != nil {
-			return "", errors.New("failed to create dummy user")
+		userID, createErr := p.authRepo.CreateUser(email, unusablePasswordHash, role)
+		if createErr == nil {
+			return userID, nil
 		}
-
-		return p.authRepo.CreateUser("dummy@example.com", hashedPassword, role)
+		if !errors.Is(createErr, apperrors.ErrEmailAlreadyExists) {
+			return "", apperrors.Internal("failed to create dummy user", createErr)
+		}
+		// Created by a concurrent request
+		user, err = p.authRepo.FindUserByEmail(email)
+	}
+	if err != nil {
+		return "", apperrors.Internal("failed to find dummy user", err)
+	}
+	if !user.Active {
+		return "", apperrors.ErrUserDeactivated
 	}
 
-	return userID, err
+	return user.ID, nil
 }
@@ -2,11 +2,16 @@ package processors
 
 import (
 	"database/sql"
-	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/clock"
+	"pvzService/internal/models"
+	"pvzService/internal/permissions"
 )
 
 type MockAuthRepository struct {
@@ -18,23 +23,68 @@ func (m *MockAuthRepository) CreateUser(email, hashedPassword, role string) (str
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockAuthRepository) FindUserByEmail(email string) (string, string, string, error) {
+func (m *MockAuthRepository) FindUserByEmail(email string) (models.UserCredentials, error) {
 	args := m.Called(email)
-	return args.String(0), args.String(1), args.String(2), args.Error(3)
+	return args.Get(0).(models.UserCredentials), args.Error(1)
 }
 
-func (m *MockAuthRepository) FindUserByRole(role string) (string, error) {
-	args := m.Called(role)
-	return args.String(0), args.Error(1)
+func (m *MockAuthRepository) FindUserByID(id string) (models.UserCredentials, error) {
+	args := m.Called(id)
+	return args.Get(0).(models.UserCredentials), args.Error(1)
+}
+
+func (m *MockAuthRepository) UpdatePassword(userID, hashedPassword string, changedAt time.Time) error {
+	args := m.Called(userID, hashedPassword, changedAt)
+	return args.Error(0)
+}
+
+func (m *MockAuthRepository) RegisterFailedLogin(userID string, maxAttempts int, window, lockout time.Duration) (bool, error) {
+	args := m.Called(userID, maxAttempts, window, lockout)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockAuthRepository) ResetFailedLogins(userID string) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+type MockLoginAttemptRepository struct {
+	mock.Mock
+}
+
+func (m *MockLoginAttemptRepository) IsIPLocked(ip string) (bool, error) {
+	args := m.Called(ip)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) RegisterIPFailure(ip string, maxAttempts int, window, lockout time.Duration) (bool, error) {
+	args := m.Called(ip, maxAttempts, window, lockout)
+	return args.Bool(0), args.Error(1)
+}
+
+var testPermissions = permissions.Table{
+	"moderator": {permissions.UserManage},
+	"admin":     {permissions.UserManage},
+	"auditor":   {permissions.UserRead},
+}
+
+// testNow is the time of the fake clock given to processors in tests.
+var testNow = time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC)
+
+var testLoginProtection = LoginProtection{
+	MaxAccountAttempts: 3,
+	MaxIPAttempts:      10,
+	Window:             15 * time.Minute,
+	LockoutDuration:    time.Hour,
 }
 
 func TestAuthProcessor_Register_Success(t *testing.T) {
 	mockRepo := new(MockAuthRepository)
-	processor := NewAuthProcessor(mockRepo)
+	processor := NewAuthProcessor(mockRepo, nil, testPermissions, LoginProtection{}, clock.NewFake(testNow))
 
 	mockRepo.On("CreateUser", "test@example.com", mock.Anything, "employee").Return("user123", nil)
 
-	userID, err := processor.Register("test@example.com", "password", "employee")
+	userID, err := processor.Register("test@example.com", "password123", "employee", "")
 	assert.NoError(t, err)
 	assert.Equal(t, "user123", userID)
 	mockRepo.AssertExpectations(t)
@@ -42,20 +92,20 @@ func TestAuthProcessor_Register_Success(t *testing.T) {
 
 func TestAuthProcessor_Register_InvalidRole(t *testing.T) {
 	mockRepo := new(MockAuthRepository)
-	processor := NewAuthProcessor(mockRepo)
+	processor := NewAuthProcessor(mockRepo, nil, testPermissions, LoginProtection{}, clock.NewFake(testNow))
 
-	_, err := processor.Register("test@example.com", "password", "invalid")
+	_, err := processor.Register("test@example.com", "password", "invalid", "")
 	assert.Error(t, err)
 	assert.Equal(t, "invalid role", err.Error())
 }
 
 func TestAuthProcessor_Register_EmailExists(t *testing.T) {
 	mockRepo := new(MockAuthRepository)
-	processor := NewAuthProcessor(mockRepo)
+	processor := NewAuthProcessor(mockRepo, nil, testPermissions, LoginProtection{}, clock.NewFake(testNow))
 
-	mockRepo.On("CreateUser", "exists@example.com", mock.Anything, "employee").Return("", errors.New("email already exists"))
+	mockRepo.On("CreateUser", "exists@example.com", mock.Anything, "employee").Return("", apperrors.ErrEmailAlreadyExists)
 
-	_, err := processor.Register("exists@example.com", "password", "employee")
+	_, err := processor.Register("exists@example.com", "password123", "employee", "")
 	assert.Error(t, err)
 	assert.Equal(t, "email already exists", err.Error())
 	mockRepo.AssertExpectations(t)
@@ -63,12 +113,12 @@ func TestAuthProcessor_Register_EmailExists(t *testing.T) {
 
 func TestAuthProcessor_Login_Success(t *testing.T) {
 	mockRepo := new(MockAuthRepository)
-	processor := NewAuthProcessor(mockRepo)
+	processor := NewAuthProcessor(mockRepo, nil, testPermissions, LoginProtection{}, clock.NewFake(testNow))
 
 	hashedPassword, _ := processor.HashPassword("password")
-	mockRepo.On("FindUserByEmail", "test@example.com").Return("user123", hashedPassword, "employee", nil)
+	mockRepo.On("FindUserByEmail", "test@example.com").Return(models.UserCredentials{ID: "user123", PasswordHash: hashedPassword, Role: "employee", Active: true}, nil)
 
-	userID, role, err := processor.Login("test@example.com", "password")
+	userID, role, err := processor.Login("test@example.com", "password", "10.0.0.1")
 	assert.NoError(t, err)
 	assert.Equal(t, "user123", userID)
 	assert.Equal(t, "employee", role)
@@ -77,12 +127,12 @@ func TestAuthProcessor_Login_Success(t *testing.T) {
 
 func TestAuthProcessor_Login_InvalidPassword(t *testing.T) {
 	mockRepo := new(MockAuthRepository)
-	processor := NewAuthProcessor(mockRepo)
+	processor := NewAuthProcessor(mockRepo, nil, testPermissions, LoginProtection{}, clock.NewFake(testNow))
 
 	hashedPassword, _ := processor.HashPassword("password")
-	mockRepo.On("FindUserByEmail", "test@example.com").Return("user123", hashedPassword, "employee", nil)
+	mockRepo.On("FindUserByEmail", "test@example.com").Return(models.UserCredentials{ID: "user123", PasswordHash: hashedPassword, Role: "employee", Active: true}, nil)
 
-	_, _, err := processor.Login("test@example.com", "wrong")
+	_, _, err := processor.Login("test@example.com", "wrong", "10.0.0.1")
 	assert.Error(t, err)
 	assert.Equal(t, "invalid email or password", err.Error())
 	mockRepo.AssertExpectations(t)
@@ -90,21 +140,171 @@ func TestAuthProcessor_Login_InvalidPassword(t *testing.T) {
 
 func TestAuthProcessor_Login_UserNotFound(t *testing.T) {
 	mockRepo := new(MockAuthRepository)
-	processor := NewAuthProcessor(mockRepo)
+	processor := NewAuthProcessor(mockRepo, nil, testPermissions, LoginProtection{}, clock.NewFake(testNow))
 
-	mockRepo.On("FindUserByEmail", "nonexistent@example.com").Return("", "", "", sql.ErrNoRows)
+	mockRepo.On("FindUserByEmail", "nonexistent@example.com").Return(models.UserCredentials{}, sql.ErrNoRows)
 
-	_, _, err := processor.Login("nonexistent@example.com", "password")
+	_, _, err := processor.Login("nonexistent@example.com", "password", "10.0.0.1")
 	assert.Error(t, err)
 	assert.Equal(t, "invalid email or password", err.Error())
 	mockRepo.AssertExpectations(t)
 }
 
+func TestAuthProcessor_Register_PrivilegedRoleRequiresUserManage(t *testing.T) {
+	mockRepo := new(MockAuthRepository)
+	processor := NewAuthProcessor(mockRepo, nil, testPermissions, LoginProtection{}, clock.NewFake(testNow))
+
+	_, err := processor.Register("moderator@example.com", "password123", "moderator", "")
+	assert.ErrorIs(t, err, apperrors.ErrPrivilegedRegistration)
+
+	_, err = processor.Register("moderator@example.com", "password123", "moderator", "employee")
+	assert.ErrorIs(t, err, apperrors.ErrPrivilegedRegistration)
+
+	_, err = processor.Register("auditor@example.com", "password123", "auditor", "auditor")
+	assert.ErrorIs(t, err, apperrors.ErrPrivilegedRegistration)
+
+	_, err = processor.Register("manager@example.com", "password123", "city_manager", "moderator")
+	assert.ErrorIs(t, err, apperrors.ErrInvalidRole)
+	mockRepo.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything, mock.Anything)
+
+	mockRepo.On("CreateUser", "moderator@example.com", mock.Anything, "moderator").Return("user123", nil)
+	userID, err := processor.Register("moderator@example.com", "password123", "moderator", "moderator")
+	assert.NoError(t, err)
+	assert.Equal(t, "user123", userID)
+
+	mockRepo.On("CreateUser", "auditor@example.com", mock.Anything, "auditor").Return("user456", nil)
+	userID, err = processor.Register("auditor@example.com", "password123", "auditor", "admin")
+	assert.NoError(t, err)
+	assert.Equal(t, "user456", userID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthProcessor_Register_AdminOnlyByAdmin(t *testing.T) {
+	mockRepo := new(MockAuthRepository)
+	processor := NewAuthProcessor(mockRepo, nil, testPermissions, LoginProtection{}, clock.NewFake(testNow))
+
+	// Moderators hold user:manage, which must not be enough to create admins
+	for _, actorRole := range []string{"", "employee", "moderator"} {
+		_, err := processor.Register("admin@example.com", "password123", "admin", actorRole)
+		assert.ErrorIs(t, err, apperrors.ErrAdminOnly)
+	}
+	mockRepo.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything, mock.Anything)
+
+	mockRepo.On("CreateUser", "admin@example.com", mock.Anything, "admin").Return("user789", nil)
+	userID, err := processor.Register("admin@example.com", "password123", "admin", "admin")
+	assert.NoError(t, err)
+	assert.Equal(t, "user789", userID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthProcessor_Register_WeakPassword(t *testing.T) {
+	mockRepo := new(MockAuthRepository)
+	processor := NewAuthProcessor(mockRepo, nil, testPermissions, LoginProtection{}, clock.NewFake(testNow))
+
+	_, err := processor.Register("test@example.com", "", "employee", "")
+	assert.ErrorIs(t, err, apperrors.Validation(nil))
+
+	_, err = processor.Register("test@example.com", "password", "employee", "")
+	assert.ErrorIs(t, err, apperrors.Validation(nil))
+	mockRepo.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthProcessor_Login_SuccessResetsFailedAttempts(t *testing.T) {
+	mockRepo := new(MockAuthRepository)
+	mockAttempts := new(MockLoginAttemptRepository)
+	processor := NewAuthProcessor(mockRepo, mockAttempts, testPermissions, testLoginProtection, clock.NewFake(testNow))
+
+	hashedPassword, _ := processor.HashPassword("password")
+	mockAttempts.On("IsIPLocked", "10.0.0.1").Return(false, nil)
+	mockRepo.On("FindUserByEmail", "test@example.com").Return(models.UserCredentials{ID: "user123", PasswordHash: hashedPassword, Role: "employee", Active: true}, nil)
+	mockRepo.On("ResetFailedLogins", "user123").Return(nil)
+
+	userID, _, err := processor.Login("test@example.com", "password", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "user123", userID)
+	mockRepo.AssertExpectations(t)
+	mockAttempts.AssertExpectations(t)
+}
+
+func TestAuthProcessor_Login_WrongPasswordCountsFailures(t *testing.T) {
+	mockRepo := new(MockAuthRepository)
+	mockAttempts := new(MockLoginAttemptRepository)
+	processor := NewAuthProcessor(mockRepo, mockAttempts, testPermissions, testLoginProtection, clock.NewFake(testNow))
+
+	hashedPassword, _ := processor.HashPassword("password")
+	mockAttempts.On("IsIPLocked", "10.0.0.1").Return(false, nil)
+	mockRepo.On("FindUserByEmail", "test@example.com").Return(models.UserCredentials{ID: "user123", PasswordHash: hashedPassword, Role: "employee", Active: true}, nil)
+	mockRepo.On("RegisterFailedLogin", "user123", 3, 15*time.Minute, time.Hour).Return(true, nil)
+	mockAttempts.On("RegisterIPFailure", "10.0.0.1", 10, 15*time.Minute, time.Hour).Return(false, nil)
+
+	_, _, err := processor.Login("test@example.com", "wrong", "10.0.0.1")
+	assert.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+	mockRepo.AssertExpectations(t)
+	mockAttempts.AssertExpectations(t)
+}
+
+func TestAuthProcessor_Login_UnknownUserCountsOnlyIP(t *testing.T) {
+	mockRepo := new(MockAuthRepository)
+	mockAttempts := new(MockLoginAttemptRepository)
+	processor := NewAuthProcessor(mockRepo, mockAttempts, testPermissions, testLoginProtection, clock.NewFake(testNow))
+
+	mockAttempts.On("IsIPLocked", "10.0.0.1").Return(false, nil)
+	mockRepo.On("FindUserByEmail", "nonexistent@example.com").Return(models.UserCredentials{}, sql.ErrNoRows)
+	mockAttempts.On("RegisterIPFailure", "10.0.0.1", 10, 15*time.Minute, time.Hour).Return(false, nil)
+
+	_, _, err := processor.Login("nonexistent@example.com", "password", "10.0.0.1")
+	assert.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+	mockRepo.AssertNotCalled(t, "RegisterFailedLogin", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockAttempts.AssertExpectations(t)
+}
+
+func TestAuthProcessor_Login_LockedAccountRejectsCorrectPassword(t *testing.T) {
+	mockRepo := new(MockAuthRepository)
+	mockAttempts := new(MockLoginAttemptRepository)
+	processor := NewAuthProcessor(mockRepo, mockAttempts, testPermissions, testLoginProtection, clock.NewFake(testNow))
+
+	hashedPassword, _ := processor.HashPassword("password")
+	lockedUntil := testNow.Add(time.Minute)
+	mockAttempts.On("IsIPLocked", "10.0.0.1").Return(false, nil)
+	mockRepo.On("FindUserByEmail", "test@example.com").
+		Return(models.UserCredentials{ID: "user123", PasswordHash: hashedPassword, Role: "employee", Active: true, LockedUntil: &lockedUntil}, nil)
+	mockAttempts.On("RegisterIPFailure", "10.0.0.1", 10, 15*time.Minute, time.Hour).Return(false, nil)
+
+	_, _, err := processor.Login("test@example.com", "password", "10.0.0.1")
+	assert.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+	mockRepo.AssertNotCalled(t, "ResetFailedLogins", mock.Anything)
+	mockAttempts.AssertExpectations(t)
+}
+
+func TestAuthProcessor_Login_DeactivatedUser(t *testing.T) {
+	mockRepo := new(MockAuthRepository)
+	processor := NewAuthProcessor(mockRepo, nil, testPermissions, LoginProtection{}, clock.NewFake(testNow))
+
+	hashedPassword, _ := processor.HashPassword("password")
+	mockRepo.On("FindUserByEmail", "test@example.com").
+		Return(models.UserCredentials{ID: "user123", PasswordHash: hashedPassword, Role: "employee", Active: false}, nil)
+
+	_, _, err := processor.Login("test@example.com", "password", "10.0.0.1")
+	assert.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+}
+
+func TestAuthProcessor_Login_LockedIP(t *testing.T) {
+	mockRepo := new(MockAuthRepository)
+	mockAttempts := new(MockLoginAttemptRepository)
+	processor := NewAuthProcessor(mockRepo, mockAttempts, testPermissions, testLoginProtection, clock.NewFake(testNow))
+
+	mockAttempts.On("IsIPLocked", "10.0.0.1").Return(true, nil)
+
+	_, _, err := processor.Login("test@example.com", "password", "10.0.0.1")
+	assert.ErrorIs(t, err, apperrors.ErrTooManyLoginAttempts)
+	mockRepo.AssertNotCalled(t, "FindUserByEmail", mock.Anything)
+}
+
 func TestAuthProcessor_DummyLogin_Success(t *testing.T) {
 	mockRepo := new(MockAuthRepository)
-	processor := NewAuthProcessor(mockRepo)
+	processor := NewAuthProcessor(mockRepo, nil, testPermissions, LoginProtection{}, clock.NewFake(testNow))
 
-	mockRepo.On("FindUserByRole", "employee").Return("user123", nil)
+	mockRepo.On("FindUserByEmail", "dummy-employee@example.com").Return(models.UserCredentials{ID: "user123", Role: "employee", Active: true}, nil)
 
 	userID, err := processor.DummyLogin("employee")
 	assert.NoError(t, err)
@@ -112,12 +312,22 @@ func TestAuthProcessor_DummyLogin_Success(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestAuthProcessor_DummyLogin_Deactivated(t *testing.T) {
+	mockRepo := new(MockAuthRepository)
+	processor := NewAuthProcessor(mockRepo, nil, testPermissions, LoginProtection{}, clock.NewFake(testNow))
+
+	mockRepo.On("FindUserByEmail", "dummy-moderator@example.com").Return(models.UserCredentials{ID: "user123", Role: "moderator"}, nil)
+
+	_, err := processor.DummyLogin("moderator")
+	assert.ErrorIs(t, err, apperrors.ErrUserDeactivated)
+}
+
 func TestAuthProcessor_DummyLogin_CreateNewUser(t *testing.T) {
 	mockRepo := new(MockAuthRepository)
-	processor := NewAuthProcessor(mockRepo)
+	processor := NewAuthProcessor(mockRepo, nil, testPermissions, LoginProtection{}, clock.NewFake(testNow))
 
-	mockRepo.On("FindUserByRole", "employee").Return("", sql.ErrNoRows)
-	mockRepo.On("CreateUser", "dummy@example.com", mock.Anything, "employee").Return("newuser123", nil)
+	mockRepo.On("FindUserByEmail", "dummy-employee@example.com").Return(models.UserCredentials{}, sql.ErrNoRows).Once()
+	mockRepo.On("CreateUser", "dummy-employee@example.com", unusablePasswordHash, "employee").Return("newuser123", nil)
 
 	userID, err := processor.DummyLogin("employee")
 	assert.NoError(t, err)
@@ -125,9 +335,35 @@ func TestAuthProcessor_DummyLogin_CreateNewUser(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestAuthProcessor_DummyLogin_ConcurrentCreate(t *testing.T) {
+	mockRepo := new(MockAuthRepository)
+	processor := NewAuthProcessor(mockRepo, nil, testPermissions, LoginProtection{}, clock.NewFake(testNow))
+
+	mockRepo.On("FindUserByEmail", "dummy-employee@example.com").Return(models.UserCredentials{}, sql.ErrNoRows).Once()
+	mockRepo.On("CreateUser", "dummy-employee@example.com", unusablePasswordHash, "employee").Return("", apperrors.ErrEmailAlreadyExists)
+	mockRepo.On("FindUserByEmail", "dummy-employee@example.com").Return(models.UserCredentials{ID: "user123", Role: "employee", Active: true}, nil).Once()
+
+	userID, err := processor.DummyLogin("employee")
+	assert.NoError(t, err)
+	assert.Equal(t, "user123", userID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthProcessor_Login_DummyUserHasNoPassword(t *testing.T) {
+	mockRepo := new(MockAuthRepository)
+	processor := NewAuthProcessor(mockRepo, nil, testPermissions, LoginProtection{}, clock.NewFake(testNow))
+
+	mockRepo.On("FindUserByEmail", "dummy-employee@example.com").Return(models.UserCredentials{ID: "user123", PasswordHash: unusablePasswordHash, Role: "employee", Active: true}, nil)
+
+	for _, password := range []string{"password", "", "dummy-password-for-timing"} {
+		_, _, err := processor.Login("dummy-employee@example.com", password, "10.0.0.1")
+		assert.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+	}
+}
+
 func TestAuthProcessor_DummyLogin_InvalidRole(t *testing.T) {
 	mockRepo := new(MockAuthRepository)
-	processor := NewAuthProcessor(mockRepo)
+	processor := NewAuthProcessor(mockRepo, nil, testPermissions, LoginProtection{}, clock.NewFake(testNow))
 
 	_, err := processor.DummyLogin("invalid")
 	assert.Error(t, err)
@@ -135,7 +371,7 @@ func TestAuthProcessor_DummyLogin_InvalidRole(t *testing.T) {
 }
 
 func TestHashAndComparePassword(t *testing.T) {
-	processor := NewAuthProcessor(nil)
+	processor := NewAuthProcessor(nil, nil, testPermissions, LoginProtection{}, clock.NewFake(testNow))
 	password := "testpassword123"
 
 	hashed, err := processor.HashPassword(password)
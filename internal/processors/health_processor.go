@@ -0,0 +1,64 @@
+package processors
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"pvzService/internal/models"
+	"pvzService/internal/repository"
+)
+
+// Time given to each readiness check, so a hanging database doesn't hang the probe
+const healthCheckTimeout = 2 * time.Second
+
+type HealthProcessor interface {
+	Readiness(ctx context.Context) models.Readiness
+}
+
+type HealthProcessorImpl struct {
+	healthRepo    repository.HealthRepository
+	schemaVersion int
+}
+
+// NewHealthProcessor checks that the database is reachable and migrated at least to
+// schemaVersion, the version the service was built for.
+func NewHealthProcessor(healthRepo repository.HealthRepository, schemaVersion int) *HealthProcessorImpl {
+	return &HealthProcessorImpl{healthRepo: healthRepo, schemaVersion: schemaVersion}
+}
+
+// Readiness runs the checks in order and skips the migration check when the database
+// is unreachable. Causes are logged, clients only see a short reason.
+func (p *HealthProcessorImpl) Readiness(ctx context.Context) models.Readiness {
+	readiness := models.Readiness{Status: models.HealthStatusOK}
+	fail := func(name, reason string) {
+		readiness.Status = models.HealthStatusUnavailable
+		readiness.Checks = append(readiness.Checks, models.HealthCheck{Name: name, Status: models.HealthStatusUnavailable, Error: reason})
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	if err := p.healthRepo.Ping(checkCtx); err != nil {
+		log.Printf("Readiness check failed, database is unreachable: %v", err)
+		fail("database", "database is unreachable")
+		fail("migrations", "skipped, database is unreachable")
+		return readiness
+	}
+	readiness.Checks = append(readiness.Checks, models.HealthCheck{Name: "database", Status: models.HealthStatusOK})
+
+	checkCtx, cancel = context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	version, err := p.healthRepo.SchemaVersion(checkCtx)
+	switch {
+	case err != nil:
+		log.Printf("Readiness check failed, schema version is unknown: %v", err)
+		fail("migrations", "failed to read the schema version")
+	case version < p.schemaVersion:
+		fail("migrations", fmt.Sprintf("database schema version is %d, the service requires %d", version, p.schemaVersion))
+	default:
+		readiness.Checks = append(readiness.Checks, models.HealthCheck{Name: "migrations", Status: models.HealthStatusOK})
+	}
+
+	return readiness
+}
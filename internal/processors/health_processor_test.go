@@ -0,0 +1,96 @@
+package processors
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"pvzService/internal/models"
+)
+
+type MockHealthRepository struct {
+	mock.Mock
+}
+
+func (m *MockHealthRepository) Ping(ctx context.Context) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockHealthRepository) SchemaVersion(ctx context.Context) (int, error) {
+	args := m.Called()
+	return args.Int(0), args.Error(1)
+}
+
+func TestHealthProcessor_Readiness(t *testing.T) {
+	tests := []struct {
+		name     string
+		pingErr  error
+		version  int
+		expected models.Readiness
+	}{
+		{
+			name:    "ready",
+			version: 11,
+			expected: models.Readiness{Status: "ok", Checks: []models.HealthCheck{
+				{Name: "database", Status: "ok"},
+				{Name: "migrations", Status: "ok"},
+			}},
+		},
+		{
+			name:    "newer schema",
+			version: 12,
+			expected: models.Readiness{Status: "ok", Checks: []models.HealthCheck{
+				{Name: "database", Status: "ok"},
+				{Name: "migrations", Status: "ok"},
+			}},
+		},
+		{
+			name:    "pending migrations",
+			version: 10,
+			expected: models.Readiness{Status: "unavailable", Checks: []models.HealthCheck{
+				{Name: "database", Status: "ok"},
+				{Name: "migrations", Status: "unavailable", Error: "database schema version is 10, the service requires 11"},
+			}},
+		},
+		{
+			name:    "database unreachable",
+			pingErr: errors.New("connection refused"),
+			expected: models.Readiness{Status: "unavailable", Checks: []models.HealthCheck{
+				{Name: "database", Status: "unavailable", Error: "database is unreachable"},
+				{Name: "migrations", Status: "unavailable", Error: "skipped, database is unreachable"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockHealthRepository)
+			processor := NewHealthProcessor(mockRepo, 11)
+
+			mockRepo.On("Ping").Return(tt.pingErr)
+			if tt.pingErr == nil {
+				mockRepo.On("SchemaVersion").Return(tt.version, nil)
+			}
+
+			assert.Equal(t, tt.expected, processor.Readiness(context.Background()))
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHealthProcessor_ReadinessSchemaVersionError(t *testing.T) {
+	mockRepo := new(MockHealthRepository)
+	processor := NewHealthProcessor(mockRepo, 11)
+
+	mockRepo.On("Ping").Return(nil)
+	mockRepo.On("SchemaVersion").Return(0, errors.New("permission denied"))
+
+	readiness := processor.Readiness(context.Background())
+	assert.Equal(t, "unavailable", readiness.Status)
+	assert.Equal(t, models.HealthCheck{Name: "migrations", Status: "unavailable", Error: "failed to read the schema version"}, readiness.Checks[1])
+	mockRepo.AssertExpectations(t)
+}
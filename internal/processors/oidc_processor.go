@@ -0,0 +1,132 @@
+package processors
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
+	"pvzService/internal/oidc"
+	"pvzService/internal/prometheus"
+	"pvzService/internal/repository"
+)
+
+type OIDCProcessor interface {
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	Login(ctx context.Context, code, verifier, nonce string) (string, string, error)
+}
+
+type OIDCProcessorImpl struct {
+	authenticator oidc.Authenticator
+	roles         oidc.RoleMapper
+	authRepo      repository.AuthRepository
+	identityRepo  repository.IdentityRepository
+	userRepo      repository.UserRepository
+}
+
+func NewOIDCProcessor(authenticator oidc.Authenticator, roles oidc.RoleMapper, authRepo repository.AuthRepository,
+	identityRepo repository.IdentityRepository, userRepo repository.UserRepository) *OIDCProcessorImpl {
+	return &OIDCProcessorImpl{
+		authenticator: authenticator,
+		roles:         roles,
+		authRepo:      authRepo,
+		identityRepo:  identityRepo,
+		userRepo:      userRepo,
+	}
+}
+
+func (p *OIDCProcessorImpl) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	url, err := p.authenticator.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		log.Printf("OIDC provider is unavailable: %v", err)
+		return "", apperrors.Wrap(apperrors.ErrOIDCUnavailable, err)
+	}
+	return url, nil
+}
+
+// Login completes the authorization code flow and returns the user and role for the
+// service token. The employee and moderator roles follow the IdP groups, roles granted
+// in the service (admin, auditor, city manager) are kept and need no mapped group. Users
+// are provisioned on the first login; an existing account with the same email is linked
+// only when the IdP verified the email, otherwise anyone able to set an email at the IdP
+// could take it over.
+func (p *OIDCProcessorImpl) Login(ctx context.Context, code, verifier, nonce string) (string, string, error) {
+	identity, err := p.authenticator.Exchange(ctx, code, verifier, nonce)
+	if err != nil {
+		log.Printf("OIDC code exchange failed: %v", err)
+		prometheus.FailedLogins.WithLabelValues("oidc_exchange_failed").Inc()
+		return "", "", apperrors.Wrap(apperrors.ErrOIDCLoginFailed, err)
+	}
+
+	role, mapped := p.roles.Role(identity.Groups)
+	user, err := p.identityRepo.FindUserByIdentity(identity.Issuer, identity.Subject)
+	if errors.Is(err, sql.ErrNoRows) {
+		if !mapped {
+			prometheus.FailedLogins.WithLabelValues("oidc_role_not_mapped").Inc()
+			return "", "", apperrors.ErrOIDCRoleNotMapped
+		}
+		user, err = p.provision(identity, role)
+	} else if err != nil {
+		err = apperrors.Internal("failed to find user", err)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	if !user.Active {
+		prometheus.FailedLogins.WithLabelValues("account_deactivated").Inc()
+		return "", "", apperrors.ErrUserDeactivated
+	}
+	if !p.roles.Owns(user.Role) {
+		return user.ID, user.Role, nil
+	}
+	if !mapped {
+		prometheus.FailedLogins.WithLabelValues("oidc_role_not_mapped").Inc()
+		return "", "", apperrors.ErrOIDCRoleNotMapped
+	}
+	if user.Role != role {
+		if _, err := p.userRepo.UpdateUser(user.ID, &role, nil, nil); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return "", "", apperrors.ErrUserNotFound
+			}
+			if errors.Is(err, apperrors.ErrCityAssignment) {
+				return "", "", apperrors.ErrCityAssignment
+			}
+			return "", "", apperrors.Internal("failed to update user role", err)
+		}
+	}
+	return user.ID, role, nil
+}
+
+// provision links the identity to the account with the same verified email or creates
+// a user without a usable password.
+func (p *OIDCProcessorImpl) provision(identity oidc.Identity, role string) (models.UserCredentials, error) {
+	if identity.Email == "" {
+		return models.UserCredentials{}, apperrors.Wrap(apperrors.ErrOIDCLoginFailed, errors.New("id_token has no email"))
+	}
+
+	user, err := p.authRepo.FindUserByEmail(identity.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		userID, err := p.identityRepo.CreateUserWithIdentity(identity.Email, unusablePasswordHash, role, identity.Issuer, identity.Subject)
+		if errors.Is(err, apperrors.ErrEmailAlreadyExists) {
+			return models.UserCredentials{}, apperrors.ErrEmailAlreadyExists
+		}
+		if err != nil {
+			return models.UserCredentials{}, apperrors.Internal("failed to create user", err)
+		}
+		return models.UserCredentials{ID: userID, PasswordHash: unusablePasswordHash, Role: role, Active: true}, nil
+	}
+	if err != nil {
+		return models.UserCredentials{}, apperrors.Internal("failed to find user", err)
+	}
+
+	if !identity.EmailVerified {
+		return models.UserCredentials{}, apperrors.ErrOIDCEmailConflict
+	}
+	if err := p.identityRepo.LinkIdentity(user.ID, identity.Issuer, identity.Subject); err != nil {
+		return models.UserCredentials{}, apperrors.Internal("failed to link identity", err)
+	}
+	return user, nil
+}
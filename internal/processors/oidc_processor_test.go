@@ -0,0 +1,209 @@
+package processors
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
+	"pvzService/internal/oidc"
+)
+
+type MockAuthenticator struct {
+	mock.Mock
+}
+
+func (m *MockAuthenticator) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	args := m.Called(state, nonce, verifier)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockAuthenticator) Exchange(ctx context.Context, code, verifier, nonce string) (oidc.Identity, error) {
+	args := m.Called(code, verifier, nonce)
+	return args.Get(0).(oidc.Identity), args.Error(1)
+}
+
+type MockIdentityRepository struct {
+	mock.Mock
+}
+
+func (m *MockIdentityRepository) FindUserByIdentity(issuer, subject string) (models.UserCredentials, error) {
+	args := m.Called(issuer, subject)
+	return args.Get(0).(models.UserCredentials), args.Error(1)
+}
+
+func (m *MockIdentityRepository) LinkIdentity(userID, issuer, subject string) error {
+	args := m.Called(userID, issuer, subject)
+	return args.Error(0)
+}
+
+func (m *MockIdentityRepository) CreateUserWithIdentity(email, hashedPassword, role, issuer, subject string) (string, error) {
+	args := m.Called(email, hashedPassword, role, issuer, subject)
+	return args.String(0), args.Error(1)
+}
+
+var testRoleMapper = oidc.RoleMapper{EmployeeGroups: []string{"pvz-staff"}, ModeratorGroups: []string{"pvz-moderators"}}
+
+type oidcTestDeps struct {
+	authenticator *MockAuthenticator
+	authRepo      *MockAuthRepository
+	identityRepo  *MockIdentityRepository
+	userRepo      *MockUserRepository
+	processor     *OIDCProcessorImpl
+}
+
+func newOIDCTestDeps(identity oidc.Identity) *oidcTestDeps {
+	d := &oidcTestDeps{
+		authenticator: new(MockAuthenticator),
+		authRepo:      new(MockAuthRepository),
+		identityRepo:  new(MockIdentityRepository),
+		userRepo:      new(MockUserRepository),
+	}
+	d.processor = NewOIDCProcessor(d.authenticator, testRoleMapper, d.authRepo, d.identityRepo, d.userRepo)
+	d.authenticator.On("Exchange", "code", "verifier", "nonce").Return(identity, nil)
+	return d
+}
+
+func TestOIDCProcessor_Login_KnownIdentity(t *testing.T) {
+	d := newOIDCTestDeps(oidc.Identity{Issuer: "https://idp", Subject: "sub1", Email: "a@example.com", Groups: []string{"pvz-staff"}})
+	d.identityRepo.On("FindUserByIdentity", "https://idp", "sub1").Return(models.UserCredentials{ID: "user1", Role: "employee", Active: true}, nil)
+
+	userID, role, err := d.processor.Login(context.Background(), "code", "verifier", "nonce")
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", userID)
+	assert.Equal(t, "employee", role)
+	d.userRepo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestOIDCProcessor_Login_SyncsRoleFromGroups(t *testing.T) {
+	d := newOIDCTestDeps(oidc.Identity{Issuer: "https://idp", Subject: "sub1", Groups: []string{"pvz-moderators"}})
+	d.identityRepo.On("FindUserByIdentity", "https://idp", "sub1").Return(models.UserCredentials{ID: "user1", Role: "employee", Active: true}, nil)
+	d.userRepo.On("UpdateUser", "user1", stringPtr("moderator"), (*string)(nil), (*bool)(nil)).Return(models.User{ID: "user1", Role: "moderator"}, nil)
+
+	_, role, err := d.processor.Login(context.Background(), "code", "verifier", "nonce")
+	assert.NoError(t, err)
+	assert.Equal(t, "moderator", role)
+	d.userRepo.AssertExpectations(t)
+}
+
+func TestOIDCProcessor_Login_KeepsRolesGrantedInService(t *testing.T) {
+	for _, role := range []string{"admin", "auditor", "city_manager"} {
+		t.Run(role, func(t *testing.T) {
+			d := newOIDCTestDeps(oidc.Identity{Issuer: "https://idp", Subject: "sub1", Groups: []string{"pvz-staff"}})
+			d.identityRepo.On("FindUserByIdentity", "https://idp", "sub1").Return(models.UserCredentials{ID: "user1", Role: role, Active: true}, nil)
+
+			userID, gotRole, err := d.processor.Login(context.Background(), "code", "verifier", "nonce")
+			assert.NoError(t, err)
+			assert.Equal(t, "user1", userID)
+			assert.Equal(t, role, gotRole)
+			d.userRepo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("without a mapped group", func(t *testing.T) {
+		d := newOIDCTestDeps(oidc.Identity{Issuer: "https://idp", Subject: "sub1"})
+		d.identityRepo.On("FindUserByIdentity", "https://idp", "sub1").Return(models.UserCredentials{ID: "user1", Role: "admin", Active: true}, nil)
+
+		_, role, err := d.processor.Login(context.Background(), "code", "verifier", "nonce")
+		assert.NoError(t, err)
+		assert.Equal(t, "admin", role)
+	})
+}
+
+func TestOIDCProcessor_Login_ProvisionsNewUser(t *testing.T) {
+	d := newOIDCTestDeps(oidc.Identity{Issuer: "https://idp", Subject: "sub1", Email: "new@example.com", Groups: []string{"pvz-staff"}})
+	d.identityRepo.On("FindUserByIdentity", "https://idp", "sub1").Return(models.UserCredentials{}, sql.ErrNoRows)
+	d.authRepo.On("FindUserByEmail", "new@example.com").Return(models.UserCredentials{}, sql.ErrNoRows)
+	d.identityRepo.On("CreateUserWithIdentity", "new@example.com", unusablePasswordHash, "employee", "https://idp", "sub1").Return("user1", nil)
+
+	userID, role, err := d.processor.Login(context.Background(), "code", "verifier", "nonce")
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", userID)
+	assert.Equal(t, "employee", role)
+	d.identityRepo.AssertExpectations(t)
+}
+
+func TestOIDCProcessor_Login_LinksVerifiedEmail(t *testing.T) {
+	d := newOIDCTestDeps(oidc.Identity{Issuer: "https://idp", Subject: "sub1", Email: "a@example.com", EmailVerified: true, Groups: []string{"pvz-staff"}})
+	d.identityRepo.On("FindUserByIdentity", "https://idp", "sub1").Return(models.UserCredentials{}, sql.ErrNoRows)
+	d.authRepo.On("FindUserByEmail", "a@example.com").Return(models.UserCredentials{ID: "user1", Role: "employee", Active: true}, nil)
+	d.identityRepo.On("LinkIdentity", "user1", "https://idp", "sub1").Return(nil)
+
+	userID, _, err := d.processor.Login(context.Background(), "code", "verifier", "nonce")
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", userID)
+	d.identityRepo.AssertExpectations(t)
+}
+
+func TestOIDCProcessor_Login_Rejects(t *testing.T) {
+	t.Run("unverified email of an existing account", func(t *testing.T) {
+		d := newOIDCTestDeps(oidc.Identity{Issuer: "https://idp", Subject: "sub1", Email: "a@example.com", Groups: []string{"pvz-staff"}})
+		d.identityRepo.On("FindUserByIdentity", "https://idp", "sub1").Return(models.UserCredentials{}, sql.ErrNoRows)
+		d.authRepo.On("FindUserByEmail", "a@example.com").Return(models.UserCredentials{ID: "user1", Role: "moderator", Active: true}, nil)
+
+		_, _, err := d.processor.Login(context.Background(), "code", "verifier", "nonce")
+		assert.ErrorIs(t, err, apperrors.ErrOIDCEmailConflict)
+		d.identityRepo.AssertNotCalled(t, "LinkIdentity", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("no mapped group", func(t *testing.T) {
+		d := newOIDCTestDeps(oidc.Identity{Issuer: "https://idp", Subject: "sub1", Email: "a@example.com", Groups: []string{"finance"}})
+		d.identityRepo.On("FindUserByIdentity", "https://idp", "sub1").Return(models.UserCredentials{}, sql.ErrNoRows)
+
+		_, _, err := d.processor.Login(context.Background(), "code", "verifier", "nonce")
+		assert.ErrorIs(t, err, apperrors.ErrOIDCRoleNotMapped)
+		d.authRepo.AssertNotCalled(t, "FindUserByEmail", mock.Anything)
+		d.identityRepo.AssertNotCalled(t, "CreateUserWithIdentity", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("employee left the mapped groups", func(t *testing.T) {
+		d := newOIDCTestDeps(oidc.Identity{Issuer: "https://idp", Subject: "sub1", Groups: []string{"finance"}})
+		d.identityRepo.On("FindUserByIdentity", "https://idp", "sub1").Return(models.UserCredentials{ID: "user1", Role: "employee", Active: true}, nil)
+
+		_, _, err := d.processor.Login(context.Background(), "code", "verifier", "nonce")
+		assert.ErrorIs(t, err, apperrors.ErrOIDCRoleNotMapped)
+	})
+
+	t.Run("deactivated user", func(t *testing.T) {
+		d := newOIDCTestDeps(oidc.Identity{Issuer: "https://idp", Subject: "sub1", Groups: []string{"pvz-staff"}})
+		d.identityRepo.On("FindUserByIdentity", "https://idp", "sub1").Return(models.UserCredentials{ID: "user1", Role: "employee"}, nil)
+
+		_, _, err := d.processor.Login(context.Background(), "code", "verifier", "nonce")
+		assert.ErrorIs(t, err, apperrors.ErrUserDeactivated)
+	})
+
+	t.Run("failed exchange", func(t *testing.T) {
+		d := newOIDCTestDeps(oidc.Identity{})
+		d.authenticator.ExpectedCalls = nil
+		d.authenticator.On("Exchange", "code", "verifier", "nonce").Return(oidc.Identity{}, errors.New("invalid_grant"))
+
+		_, _, err := d.processor.Login(context.Background(), "code", "verifier", "nonce")
+		assert.ErrorIs(t, err, apperrors.ErrOIDCLoginFailed)
+	})
+
+	t.Run("missing email", func(t *testing.T) {
+		d := newOIDCTestDeps(oidc.Identity{Issuer: "https://idp", Subject: "sub1", Groups: []string{"pvz-staff"}})
+		d.identityRepo.On("FindUserByIdentity", "https://idp", "sub1").Return(models.UserCredentials{}, sql.ErrNoRows)
+
+		_, _, err := d.processor.Login(context.Background(), "code", "verifier", "nonce")
+		assert.ErrorIs(t, err, apperrors.ErrOIDCLoginFailed)
+	})
+}
+
+func TestOIDCProcessor_AuthCodeURL(t *testing.T) {
+	d := newOIDCTestDeps(oidc.Identity{})
+	d.authenticator.On("AuthCodeURL", "state", "nonce", "verifier").Return("https://idp/authorize?state=state", nil).Once()
+	d.authenticator.On("AuthCodeURL", "state", "nonce", "verifier").Return("", errors.New("discovery failed")).Once()
+
+	url, err := d.processor.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://idp/authorize?state=state", url)
+
+	_, err = d.processor.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	assert.ErrorIs(t, err, apperrors.ErrOIDCUnavailable)
+}
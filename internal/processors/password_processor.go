@@ -0,0 +1,147 @@
+package processors
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/clock"
+	"pvzService/internal/notify"
+	"pvzService/internal/repository"
+	"pvzService/internal/validation"
+)
+
+type PasswordProcessor interface {
+	ChangePassword(userID, currentPassword, newPassword string) error
+	RequestPasswordReset(email string) error
+	ResetPassword(token, newPassword string) error
+}
+
+type PasswordProcessorImpl struct {
+	authRepo  repository.AuthRepository
+	resetRepo repository.PasswordResetRepository
+	notifier  notify.Notifier
+	resetTTL  time.Duration
+	clock     clock.Clock
+	// background runs the reset token delivery off the request
+	background func(func())
+}
+
+// NewPasswordProcessor delivers reset tokens through background, such as Submit of a
+// lifecycle.Queue.
+func NewPasswordProcessor(authRepo repository.AuthRepository, resetRepo repository.PasswordResetRepository, notifier notify.Notifier, resetTTL time.Duration, clock clock.Clock, background func(func())) *PasswordProcessorImpl {
+	return &PasswordProcessorImpl{authRepo: authRepo, resetRepo: resetRepo, notifier: notifier, resetTTL: resetTTL, clock: clock,
+		background: background}
+}
+
+// ChangePassword replaces the password of an authenticated user after checking the
+// current one. Tokens issued before the change stop being accepted.
+func (p *PasswordProcessorImpl) ChangePassword(userID, currentPassword, newPassword string) error {
+	if err := validation.Var("newPassword", newPassword, "required,password"); err != nil {
+		return err
+	}
+
+	user, err := p.authRepo.FindUserByID(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperrors.ErrUserNotFound
+	}
+	if err != nil {
+		return apperrors.Internal("failed to find user", err)
+	}
+
+	if err := comparePassword(user.PasswordHash, currentPassword); err != nil {
+		return apperrors.ErrInvalidCurrentPassword
+	}
+
+	return p.setPassword(userID, newPassword)
+}
+
+// RequestPasswordReset sends a one-time reset token to an active user. Unknown and
+// deactivated emails and delivery failures are not reported, and the token is stored and
+// sent in the background, so neither the response nor its timing reveal whether an
+// account exists.
+func (p *PasswordProcessorImpl) RequestPasswordReset(email string) error {
+	user, err := p.authRepo.FindUserByEmail(email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return apperrors.Internal("failed to find user", err)
+	}
+	if !user.Active {
+		return nil
+	}
+
+	expiresAt := p.clock.Now().Add(p.resetTTL)
+	p.background(func() {
+		if err := p.sendResetToken(user.ID, email, expiresAt); err != nil {
+			log.Printf("Failed to send password reset to user %s: %v", user.ID, err)
+		}
+	})
+	return nil
+}
+
+func (p *PasswordProcessorImpl) sendResetToken(userID, email string, expiresAt time.Time) error {
+	token, err := newResetToken()
+	if err != nil {
+		return err
+	}
+	if err := p.resetRepo.CreateResetToken(userID, hashResetToken(token), expiresAt); err != nil {
+		return err
+	}
+	return p.notifier.SendPasswordReset(email, token, expiresAt)
+}
+
+// ResetPassword sets a new password using a token from RequestPasswordReset. A token
+// works once, and all tokens of the user are dropped when the password changes.
+func (p *PasswordProcessorImpl) ResetPassword(token, newPassword string) error {
+	if err := validation.Var("newPassword", newPassword, "required,password"); err != nil {
+		return err
+	}
+
+	userID, err := p.resetRepo.ConsumeResetToken(hashResetToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperrors.ErrInvalidResetToken
+	}
+	if err != nil {
+		return apperrors.Internal("failed to check reset token", err)
+	}
+
+	return p.setPassword(userID, newPassword)
+}
+
+func (p *PasswordProcessorImpl) setPassword(userID, newPassword string) error {
+	hashedPassword, err := hashPassword(newPassword)
+	if err != nil {
+		return apperrors.Internal("failed to process password", err)
+	}
+
+	err = p.authRepo.UpdatePassword(userID, hashedPassword, p.clock.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperrors.ErrUserNotFound
+	}
+	if err != nil {
+		return apperrors.Internal("failed to update password", err)
+	}
+	return nil
+}
+
+func newResetToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashResetToken is what gets stored, so a leaked table can't be used to reset passwords.
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
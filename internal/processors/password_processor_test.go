@@ -0,0 +1,216 @@
+package processors
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/clock"
+	"pvzService/internal/models"
+)
+
+type MockPasswordResetRepository struct {
+	mock.Mock
+}
+
+func (m *MockPasswordResetRepository) CreateResetToken(userID, tokenHash string, expiresAt time.Time) error {
+	args := m.Called(userID, tokenHash, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockPasswordResetRepository) ConsumeResetToken(tokenHash string) (string, error) {
+	args := m.Called(tokenHash)
+	return args.String(0), args.Error(1)
+}
+
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) SendPasswordReset(email, token string, expiresAt time.Time) error {
+	args := m.Called(email, token, expiresAt)
+	return args.Error(0)
+}
+
+// runInPlace delivers reset tokens before RequestPasswordReset returns.
+func runInPlace(job func()) {
+	job()
+}
+
+func testPasswordHash(t *testing.T, password string) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	assert.NoError(t, err)
+	return string(hash)
+}
+
+func TestPasswordProcessor_ChangePassword(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		authRepo := new(MockAuthRepository)
+		processor := NewPasswordProcessor(authRepo, nil, nil, time.Hour, clock.NewFake(testNow), runInPlace)
+
+		authRepo.On("FindUserByID", "user1").Return(models.UserCredentials{ID: "user1", PasswordHash: testPasswordHash(t, "oldPassword1"), Active: true}, nil)
+		authRepo.On("UpdatePassword", "user1", mock.MatchedBy(func(hash string) bool {
+			return bcrypt.CompareHashAndPassword([]byte(hash), []byte("newPassword1")) == nil
+		}), testNow).Return(nil)
+
+		assert.NoError(t, processor.ChangePassword("user1", "oldPassword1", "newPassword1"))
+		authRepo.AssertExpectations(t)
+	})
+
+	t.Run("wrong current password", func(t *testing.T) {
+		authRepo := new(MockAuthRepository)
+		processor := NewPasswordProcessor(authRepo, nil, nil, time.Hour, clock.NewFake(testNow), runInPlace)
+
+		authRepo.On("FindUserByID", "user1").Return(models.UserCredentials{ID: "user1", PasswordHash: testPasswordHash(t, "oldPassword1")}, nil)
+
+		err := processor.ChangePassword("user1", "wrongPassword1", "newPassword1")
+		assert.ErrorIs(t, err, apperrors.ErrInvalidCurrentPassword)
+		authRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("weak new password", func(t *testing.T) {
+		authRepo := new(MockAuthRepository)
+		processor := NewPasswordProcessor(authRepo, nil, nil, time.Hour, clock.NewFake(testNow), runInPlace)
+
+		err := processor.ChangePassword("user1", "oldPassword1", "short")
+		assert.ErrorIs(t, err, apperrors.Validation(nil))
+		authRepo.AssertNotCalled(t, "FindUserByID", mock.Anything)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		authRepo := new(MockAuthRepository)
+		processor := NewPasswordProcessor(authRepo, nil, nil, time.Hour, clock.NewFake(testNow), runInPlace)
+
+		authRepo.On("FindUserByID", "user1").Return(models.UserCredentials{}, sql.ErrNoRows)
+
+		err := processor.ChangePassword("user1", "oldPassword1", "newPassword1")
+		assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+	})
+}
+
+func TestPasswordProcessor_RequestPasswordReset(t *testing.T) {
+	t.Run("sends token and stores its hash", func(t *testing.T) {
+		authRepo := new(MockAuthRepository)
+		resetRepo := new(MockPasswordResetRepository)
+		notifier := new(MockNotifier)
+		processor := NewPasswordProcessor(authRepo, resetRepo, notifier, time.Hour, clock.NewFake(testNow), runInPlace)
+
+		authRepo.On("FindUserByEmail", "user@example.com").Return(models.UserCredentials{ID: "user1", Active: true}, nil)
+		resetRepo.On("CreateResetToken", "user1", mock.Anything, mock.Anything).Return(nil)
+		notifier.On("SendPasswordReset", "user@example.com", mock.Anything, mock.Anything).Return(nil)
+
+		assert.NoError(t, processor.RequestPasswordReset("user@example.com"))
+
+		token := notifier.Calls[0].Arguments.String(1)
+		expiresAt := notifier.Calls[0].Arguments.Get(2).(time.Time)
+		assert.NotEmpty(t, token)
+		assert.Equal(t, hashResetToken(token), resetRepo.Calls[0].Arguments.String(1))
+		assert.NotEqual(t, token, resetRepo.Calls[0].Arguments.String(1))
+		assert.Equal(t, testNow.Add(time.Hour), expiresAt)
+	})
+
+	t.Run("unknown email is not reported", func(t *testing.T) {
+		authRepo := new(MockAuthRepository)
+		resetRepo := new(MockPasswordResetRepository)
+		notifier := new(MockNotifier)
+		processor := NewPasswordProcessor(authRepo, resetRepo, notifier, time.Hour, clock.NewFake(testNow), runInPlace)
+
+		authRepo.On("FindUserByEmail", "unknown@example.com").Return(models.UserCredentials{}, sql.ErrNoRows)
+
+		assert.NoError(t, processor.RequestPasswordReset("unknown@example.com"))
+		notifier.AssertNotCalled(t, "SendPasswordReset", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("deactivated user gets no token", func(t *testing.T) {
+		authRepo := new(MockAuthRepository)
+		resetRepo := new(MockPasswordResetRepository)
+		notifier := new(MockNotifier)
+		processor := NewPasswordProcessor(authRepo, resetRepo, notifier, time.Hour, clock.NewFake(testNow), runInPlace)
+
+		authRepo.On("FindUserByEmail", "user@example.com").Return(models.UserCredentials{ID: "user1", Active: false}, nil)
+
+		assert.NoError(t, processor.RequestPasswordReset("user@example.com"))
+		resetRepo.AssertNotCalled(t, "CreateResetToken", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("delivery failure is not reported", func(t *testing.T) {
+		authRepo := new(MockAuthRepository)
+		resetRepo := new(MockPasswordResetRepository)
+		notifier := new(MockNotifier)
+		processor := NewPasswordProcessor(authRepo, resetRepo, notifier, time.Hour, clock.NewFake(testNow), runInPlace)
+
+		authRepo.On("FindUserByEmail", "user@example.com").Return(models.UserCredentials{ID: "user1", Active: true}, nil)
+		resetRepo.On("CreateResetToken", "user1", mock.Anything, mock.Anything).Return(nil)
+		notifier.On("SendPasswordReset", "user@example.com", mock.Anything, mock.Anything).Return(errors.New("smtp down"))
+
+		assert.NoError(t, processor.RequestPasswordReset("user@example.com"))
+	})
+
+	t.Run("token is stored and sent after the response", func(t *testing.T) {
+		authRepo := new(MockAuthRepository)
+		resetRepo := new(MockPasswordResetRepository)
+		notifier := new(MockNotifier)
+		processor := NewPasswordProcessor(authRepo, resetRepo, notifier, time.Hour, clock.NewFake(testNow), runInPlace)
+		var pending []func()
+		processor.background = func(send func()) { pending = append(pending, send) }
+
+		authRepo.On("FindUserByEmail", "user@example.com").Return(models.UserCredentials{ID: "user1", Active: true}, nil)
+		resetRepo.On("CreateResetToken", "user1", mock.Anything, testNow.Add(time.Hour)).Return(errors.New("db error"))
+
+		assert.NoError(t, processor.RequestPasswordReset("user@example.com"))
+		resetRepo.AssertNotCalled(t, "CreateResetToken", mock.Anything, mock.Anything, mock.Anything)
+
+		assert.Len(t, pending, 1)
+		pending[0]()
+		resetRepo.AssertExpectations(t)
+		notifier.AssertNotCalled(t, "SendPasswordReset", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		authRepo := new(MockAuthRepository)
+		processor := NewPasswordProcessor(authRepo, nil, nil, time.Hour, clock.NewFake(testNow), runInPlace)
+
+		authRepo.On("FindUserByEmail", "user@example.com").Return(models.UserCredentials{}, errors.New("db error"))
+
+		assert.ErrorIs(t, processor.RequestPasswordReset("user@example.com"), apperrors.Internal("", nil))
+	})
+}
+
+func TestPasswordProcessor_ResetPassword(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		authRepo := new(MockAuthRepository)
+		resetRepo := new(MockPasswordResetRepository)
+		processor := NewPasswordProcessor(authRepo, resetRepo, nil, time.Hour, clock.NewFake(testNow), runInPlace)
+
+		resetRepo.On("ConsumeResetToken", hashResetToken("token")).Return("user1", nil)
+		authRepo.On("UpdatePassword", "user1", mock.Anything, testNow).Return(nil)
+
+		assert.NoError(t, processor.ResetPassword("token", "newPassword1"))
+		authRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		authRepo := new(MockAuthRepository)
+		resetRepo := new(MockPasswordResetRepository)
+		processor := NewPasswordProcessor(authRepo, resetRepo, nil, time.Hour, clock.NewFake(testNow), runInPlace)
+
+		resetRepo.On("ConsumeResetToken", hashResetToken("used")).Return("", sql.ErrNoRows)
+
+		assert.ErrorIs(t, processor.ResetPassword("used", "newPassword1"), apperrors.ErrInvalidResetToken)
+		authRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("weak password keeps the token", func(t *testing.T) {
+		resetRepo := new(MockPasswordResetRepository)
+		processor := NewPasswordProcessor(nil, resetRepo, nil, time.Hour, clock.NewFake(testNow), runInPlace)
+
+		assert.ErrorIs(t, processor.ResetPassword("token", "short"), apperrors.Validation(nil))
+		resetRepo.AssertNotCalled(t, "ConsumeResetToken", mock.Anything)
+	})
+}
@@ -4,15 +4,21 @@ import (
 	"database/sql"
 	"errors"
 	"github.com/google/uuid"
+	"log"
+	"time"
 
+	"pvzService/internal/apperrors"
+	"pvzService/internal/clock"
 	"pvzService/internal/models"
+	"pvzService/internal/prometheus"
 )
 
 type ProductRepository interface {
-	AddProduct(receptionID string, productType string, idGenerator func() uuid.UUID) (string, error)
+	AddProduct(receptionID string, productType string, createdAt time.Time, idGenerator func() uuid.UUID, allowOverflow bool) (string, bool, error)
 	GetProductByID(id string) (models.Product, error)
 	GetLastProduct(receptionID string) (models.Product, error)
 	DeleteProduct(id string) error
+	IssueProduct(pvzID, productID string, issuedAt time.Time) (models.Product, error)
 }
 
 type ReceptionRepository interface {
@@ -22,61 +28,107 @@ type ReceptionRepository interface {
 type ProductProcessor struct {
 	productRepo   ProductRepository
 	receptionRepo ReceptionRepository
+	allowOverflow bool
+	clock         clock.Clock
 }
 
+// NewProductProcessor rejects products that don't fit into the capacity of the PVZ,
+// with allowOverflow it adds them and logs a warning.
 func NewProductProcessor(
 	productRepo ProductRepository,
 	receptionRepo ReceptionRepository,
+	allowOverflow bool,
+	clock clock.Clock,
 ) *ProductProcessor {
 	return &ProductProcessor{
 		productRepo:   productRepo,
 		receptionRepo: receptionRepo,
+		allowOverflow: allowOverflow,
+		clock:         clock,
 	}
 }
 
-func (p *ProductProcessor) AddProduct(pvzID, productType string) (models.Product, error) {
-	allowedTypes := map[string]bool{
-		"электроника": true,
-		"одежда":      true,
-		"обувь":       true,
-	}
+var allowedProductTypes = map[string]bool{
+	"электроника": true,
+	"одежда":      true,
+	"обувь":       true,
+}
 
-	if !allowedTypes[productType] {
-		return models.Product{}, errors.New("invalid product type")
+func (p *ProductProcessor) AddProduct(pvzID, productType string) (models.Product, error) {
+	if !allowedProductTypes[productType] {
+		return models.Product{}, apperrors.ErrInvalidProductType
 	}
 
 	reception, err := p.receptionRepo.GetOpenReception(pvzID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return models.Product{}, errors.New("no open reception for this PVZ")
+			return models.Product{}, apperrors.ErrNoOpenReception
 		}
-		return models.Product{}, errors.New("database error")
+		return models.Product{}, apperrors.Internal("database error", err)
 	}
 
-	productID, err := p.productRepo.AddProduct(reception.ID, productType, uuid.New)
+	productID, overflow, err := p.productRepo.AddProduct(reception.ID, productType, p.clock.Now(), uuid.New, p.allowOverflow)
 	if err != nil {
-		return models.Product{}, errors.New("failed to add product")
+		if errors.Is(err, apperrors.ErrPVZCapacityExceeded) {
+			capacityOverflow(pvzID, "rejected")
+			return models.Product{}, apperrors.ErrPVZCapacityExceeded
+		}
+		return models.Product{}, apperrors.Internal("failed to add product", err)
+	}
+	if overflow {
+		capacityOverflow(pvzID, "accepted")
 	}
 
-	return p.productRepo.GetProductByID(productID)
+	product, err := p.productRepo.GetProductByID(productID)
+	if err != nil {
+		return models.Product{}, apperrors.Internal("database error", err)
+	}
+	return product, nil
+}
+
+// capacityOverflow records a product added to a PVZ over its capacity.
+func capacityOverflow(pvzID, action string) {
+	prometheus.CapacityOverflows.WithLabelValues(action).Inc()
+	if action == "accepted" {
+		log.Printf("PVZ %s is over its capacity after a product was added", pvzID)
+	}
 }
 
 func (p *ProductProcessor) DeleteLastProduct(pvzID string) error {
 	reception, err := p.receptionRepo.GetOpenReception(pvzID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return errors.New("no open reception for this PVZ")
+			return apperrors.ErrNoOpenReception
 		}
-		return errors.New("database error")
+		return apperrors.Internal("database error", err)
 	}
 
 	product, err := p.productRepo.GetLastProduct(reception.ID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return errors.New("no products to delete in this reception")
+			return apperrors.ErrNoProductsToDelete
 		}
-		return errors.New("database error")
+		return apperrors.Internal("database error", err)
+	}
+
+	if err := p.productRepo.DeleteProduct(product.ID); err != nil {
+		return apperrors.Internal("failed to delete product", err)
 	}
+	return nil
+}
 
-	return p.productRepo.DeleteProduct(product.ID)
+// IssueProduct hands a stored product of a closed reception over to the recipient and
+// frees its room in the PVZ.
+func (p *ProductProcessor) IssueProduct(pvzID, productID string) (models.Product, error) {
+	product, err := p.productRepo.IssueProduct(pvzID, productID, p.clock.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return models.Product{}, apperrors.ErrProductNotFound
+		case errors.Is(err, apperrors.ErrProductAlreadyIssued), errors.Is(err, apperrors.ErrReceptionNotClosed):
+			return models.Product{}, err
+		}
+		return models.Product{}, apperrors.Internal("failed to issue product", err)
+	}
+	return product, nil
 }
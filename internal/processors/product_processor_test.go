@@ -1,12 +1,17 @@
 package processors
 
 import (
+	"database/sql"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	"pvzService/internal/apperrors"
+	"pvzService/internal/clock"
 	"pvzService/internal/models"
 )
 
@@ -14,9 +19,9 @@ type MockProductRepo struct {
 	mock.Mock
 }
 
-func (m *MockProductRepo) AddProduct(receptionID, productType string, idGenerator func() uuid.UUID) (string, error) {
-	args := m.Called(receptionID, productType, idGenerator)
-	return args.String(0), args.Error(1)
+func (m *MockProductRepo) AddProduct(receptionID, productType string, createdAt time.Time, idGenerator func() uuid.UUID, allowOverflow bool) (string, bool, error) {
+	args := m.Called(receptionID, productType, createdAt, idGenerator, allowOverflow)
+	return args.String(0), args.Bool(1), args.Error(2)
 }
 
 func (m *MockProductRepo) GetProductByID(id string) (models.Product, error) {
@@ -34,6 +39,11 @@ func (m *MockProductRepo) DeleteProduct(id string) error {
 	return args.Error(0)
 }
 
+func (m *MockProductRepo) IssueProduct(pvzID, productID string, issuedAt time.Time) (models.Product, error) {
+	args := m.Called(pvzID, productID, issuedAt)
+	return args.Get(0).(models.Product), args.Error(1)
+}
+
 type MockReceptionRepo struct {
 	mock.Mock
 }
@@ -46,7 +56,7 @@ func (m *MockReceptionRepo) GetOpenReception(pvzID string) (models.Reception, er
 func TestProductProcessor_AddProduct_Success(t *testing.T) {
 	mockProductRepo := new(MockProductRepo)
 	mockReceptionRepo := new(MockReceptionRepo)
-	processor := NewProductProcessor(mockProductRepo, mockReceptionRepo)
+	processor := NewProductProcessor(mockProductRepo, mockReceptionRepo, false, clock.NewFake(testNow))
 
 	pvzID := uuid.NewString()
 	receptionID := uuid.NewString()
@@ -55,8 +65,8 @@ func TestProductProcessor_AddProduct_Success(t *testing.T) {
 	mockReceptionRepo.On("GetOpenReception", pvzID).Return(
 		models.Reception{ID: receptionID}, nil)
 
-	mockProductRepo.On("AddProduct", receptionID, "электроника", mock.AnythingOfType("func() uuid.UUID")).
-		Return(productID, nil)
+	mockProductRepo.On("AddProduct", receptionID, "электроника", testNow, mock.AnythingOfType("func() uuid.UUID"), false).
+		Return(productID, false, nil)
 
 	mockProductRepo.On("GetProductByID", productID).Return(
 		models.Product{ID: productID, Type: "электроника"}, nil)
@@ -68,10 +78,42 @@ func TestProductProcessor_AddProduct_Success(t *testing.T) {
 	mockReceptionRepo.AssertExpectations(t)
 }
 
+func TestProductProcessor_AddProduct_CapacityExceeded(t *testing.T) {
+	mockProductRepo := new(MockProductRepo)
+	mockReceptionRepo := new(MockReceptionRepo)
+	processor := NewProductProcessor(mockProductRepo, mockReceptionRepo, false, clock.NewFake(testNow))
+
+	pvzID, receptionID := uuid.NewString(), uuid.NewString()
+	mockReceptionRepo.On("GetOpenReception", pvzID).Return(models.Reception{ID: receptionID}, nil)
+	mockProductRepo.On("AddProduct", receptionID, "обувь", testNow, mock.AnythingOfType("func() uuid.UUID"), false).
+		Return("", false, apperrors.ErrPVZCapacityExceeded)
+
+	_, err := processor.AddProduct(pvzID, "обувь")
+	assert.ErrorIs(t, err, apperrors.ErrPVZCapacityExceeded)
+	mockProductRepo.AssertNotCalled(t, "GetProductByID", mock.Anything)
+}
+
+func TestProductProcessor_AddProduct_OverflowAllowed(t *testing.T) {
+	mockProductRepo := new(MockProductRepo)
+	mockReceptionRepo := new(MockReceptionRepo)
+	processor := NewProductProcessor(mockProductRepo, mockReceptionRepo, true, clock.NewFake(testNow))
+
+	pvzID, receptionID, productID := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	mockReceptionRepo.On("GetOpenReception", pvzID).Return(models.Reception{ID: receptionID}, nil)
+	mockProductRepo.On("AddProduct", receptionID, "обувь", testNow, mock.AnythingOfType("func() uuid.UUID"), true).
+		Return(productID, true, nil)
+	mockProductRepo.On("GetProductByID", productID).Return(models.Product{ID: productID, Type: "обувь"}, nil)
+
+	product, err := processor.AddProduct(pvzID, "обувь")
+	assert.NoError(t, err)
+	assert.Equal(t, productID, product.ID)
+	mockProductRepo.AssertExpectations(t)
+}
+
 func TestProductProcessor_DeleteLastProduct_Success(t *testing.T) {
 	mockProductRepo := new(MockProductRepo)
 	mockReceptionRepo := new(MockReceptionRepo)
-	processor := NewProductProcessor(mockProductRepo, mockReceptionRepo)
+	processor := NewProductProcessor(mockProductRepo, mockReceptionRepo, false, clock.NewFake(testNow))
 
 	pvzID := uuid.NewString()
 	receptionID := uuid.NewString()
@@ -88,3 +130,43 @@ func TestProductProcessor_DeleteLastProduct_Success(t *testing.T) {
 	mockProductRepo.AssertExpectations(t)
 	mockReceptionRepo.AssertExpectations(t)
 }
+
+func TestProductProcessor_IssueProduct(t *testing.T) {
+	pvzID := uuid.NewString()
+	productID := uuid.NewString()
+	issued := models.Product{ID: productID, Type: "обувь", IssuedAt: &testNow}
+
+	tests := []struct {
+		name     string
+		repoErr  error
+		expected error
+	}{
+		{name: "success"},
+		{name: "not in the PVZ", repoErr: sql.ErrNoRows, expected: apperrors.ErrProductNotFound},
+		{name: "already issued", repoErr: apperrors.ErrProductAlreadyIssued, expected: apperrors.ErrProductAlreadyIssued},
+		{name: "open reception", repoErr: apperrors.ErrReceptionNotClosed, expected: apperrors.ErrReceptionNotClosed},
+		{name: "database error", repoErr: errors.New("connection reset"), expected: apperrors.New(apperrors.CodeInternal, "")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProductRepo := new(MockProductRepo)
+			processor := NewProductProcessor(mockProductRepo, new(MockReceptionRepo), false, clock.NewFake(testNow))
+
+			if tt.repoErr != nil {
+				mockProductRepo.On("IssueProduct", pvzID, productID, testNow).Return(models.Product{}, tt.repoErr)
+			} else {
+				mockProductRepo.On("IssueProduct", pvzID, productID, testNow).Return(issued, nil)
+			}
+
+			product, err := processor.IssueProduct(pvzID, productID)
+			if tt.expected != nil {
+				assert.ErrorIs(t, err, tt.expected)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, issued, product)
+			}
+			mockProductRepo.AssertExpectations(t)
+		})
+	}
+}
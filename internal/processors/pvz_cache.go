@@ -0,0 +1,269 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"pvzService/internal/cache"
+	"pvzService/internal/clock"
+	"pvzService/internal/models"
+	"pvzService/internal/prometheus"
+	"pvzService/internal/repository"
+)
+
+// pvzListTag is on every cached list, a new, archived or restored PVZ may move PVZ
+// between pages.
+const pvzListTag = "pvz:list"
+
+func pvzTag(pvzID string) string {
+	return "pvz:" + pvzID
+}
+
+// PVZCache keeps PVZ responses tagged with the PVZ they contain. Errors of the store
+// are logged and the response is built as if there was no cache. A read that races
+// with a write may keep the old response, but not longer than ttl.
+//
+// Lists are read from the replica, which may not have a write yet. A response built
+// within staleReads after one of its tags was invalidated is returned but not cached,
+// so a lagging replica makes a response stale only as long as it lags, not for ttl
+// on top of that.
+type PVZCache struct {
+	store      cache.Store
+	ttl        time.Duration
+	staleReads time.Duration
+	clock      clock.Clock
+
+	mu            sync.Mutex
+	invalidatedAt map[string]time.Time
+}
+
+// NewPVZCache caches every response when staleReads is zero, which is the case
+// without a replica.
+func NewPVZCache(store cache.Store, ttl, staleReads time.Duration, clk clock.Clock) *PVZCache {
+	return &PVZCache{store: store, ttl: ttl, staleReads: staleReads, clock: clk, invalidatedAt: make(map[string]time.Time)}
+}
+
+// InvalidatePVZ drops the responses that contain the PVZ.
+func (c *PVZCache) InvalidatePVZ(pvzID string) {
+	c.invalidate(pvzTag(pvzID))
+}
+
+func (c *PVZCache) invalidate(tags ...string) {
+	if c.staleReads > 0 {
+		now := c.clock.Now()
+		c.mu.Lock()
+		for tag, at := range c.invalidatedAt {
+			if now.Sub(at) >= c.staleReads {
+				delete(c.invalidatedAt, tag)
+			}
+		}
+		for _, tag := range tags {
+			c.invalidatedAt[tag] = now
+		}
+		c.mu.Unlock()
+	}
+
+	if err := c.store.Invalidate(tags...); err != nil {
+		log.Printf("Failed to invalidate cached PVZ responses %v: %v", tags, err)
+	}
+}
+
+// recentlyInvalidated reports whether a response with the tags may have been read
+// before the replica caught up with the last write to them.
+func (c *PVZCache) recentlyInvalidated(tags []string) bool {
+	if c.staleReads <= 0 {
+		return false
+	}
+
+	now := c.clock.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tag := range tags {
+		if at, ok := c.invalidatedAt[tag]; ok && now.Sub(at) < c.staleReads {
+			return true
+		}
+	}
+	return false
+}
+
+// load returns the cached value of key or builds it with build and caches it. Tags of
+// the value come from tags, a value with a recently invalidated tag is not cached.
+func load[T any](c *PVZCache, key string, build func() (T, error), tags func(T) []string) (T, error) {
+	if data, ok, err := c.store.Get(key); err != nil {
+		log.Printf("Failed to read cached response %s: %v", key, err)
+	} else if ok {
+		var value T
+		if err := json.Unmarshal(data, &value); err == nil {
+			prometheus.CacheRequests.WithLabelValues("hit").Inc()
+			return value, nil
+		}
+	}
+	prometheus.CacheRequests.WithLabelValues("miss").Inc()
+
+	value, err := build()
+	if err != nil {
+		return value, err
+	}
+	valueTags := tags(value)
+	if c.recentlyInvalidated(valueTags) {
+		return value, nil
+	}
+	data, err := json.Marshal(value)
+	if err == nil {
+		err = c.store.Set(key, data, valueTags, c.ttl)
+	}
+	if err != nil {
+		log.Printf("Failed to cache response %s: %v", key, err)
+	}
+	return value, nil
+}
+
+// CachedPVZProcessor serves PVZ reads from the cache, responses of a PVZ are dropped
+// when the PVZ, its receptions or products change.
+type CachedPVZProcessor struct {
+	next  PVZProcessor
+	cache *PVZCache
+}
+
+func NewCachedPVZProcessor(next PVZProcessor, cache *PVZCache) *CachedPVZProcessor {
+	return &CachedPVZProcessor{next: next, cache: cache}
+}
+
+func (p *CachedPVZProcessor) CreatePVZ(city string) (models.PVZ, error) {
+	pvz, err := p.next.CreatePVZ(city)
+	if err == nil {
+		p.cache.invalidate(pvzListTag)
+	}
+	return pvz, err
+}
+
+func (p *CachedPVZProcessor) GetPVZByID(id, city string) (models.PVZ, error) {
+	key := fmt.Sprintf("pvz:get:%s:%s", id, city)
+	return load(p.cache, key, func() (models.PVZ, error) {
+		return p.next.GetPVZByID(id, city)
+	}, func(pvz models.PVZ) []string {
+		return []string{pvzTag(pvz.ID)}
+	})
+}
+
+func (p *CachedPVZProcessor) ListPVZsWithRelations(startDate, endDate, city string, includeArchived bool, page, limit int) ([]repository.PVZResponse, error) {
+	key := fmt.Sprintf("pvz:list:%s:%s:%s:%t:%d:%d", startDate, endDate, city, includeArchived, page, limit)
+	return load(p.cache, key, func() ([]repository.PVZResponse, error) {
+		return p.next.ListPVZsWithRelations(startDate, endDate, city, includeArchived, page, limit)
+	}, func(result []repository.PVZResponse) []string {
+		tags := []string{pvzListTag}
+		for _, pvz := range result {
+			tags = append(tags, pvzTag(pvz.PVZ.ID))
+		}
+		return tags
+	})
+}
+
+func (p *CachedPVZProcessor) ArchivePVZ(id, city, actorID string) error {
+	err := p.next.ArchivePVZ(id, city, actorID)
+	if err == nil {
+		p.cache.invalidate(pvzTag(id), pvzListTag)
+	}
+	return err
+}
+
+func (p *CachedPVZProcessor) RestorePVZ(id, city string) (models.PVZ, error) {
+	pvz, err := p.next.RestorePVZ(id, city)
+	if err == nil {
+		p.cache.invalidate(pvzTag(id), pvzListTag)
+	}
+	return pvz, err
+}
+
+func (p *CachedPVZProcessor) UpdatePVZ(id, city string, update models.UpdatePVZRequest) (models.PVZ, error) {
+	pvz, err := p.next.UpdatePVZ(id, city, update)
+	if err == nil {
+		p.cache.invalidate(pvzTag(id))
+	}
+	return pvz, err
+}
+
+// ListNearbyPVZs isn't cached, searched points rarely repeat.
+func (p *CachedPVZProcessor) ListNearbyPVZs(lat, lon, radiusKm float64, openAt, city string, limit int) ([]models.NearbyPVZ, error) {
+	return p.next.ListNearbyPVZs(lat, lon, radiusKm, openAt, city, limit)
+}
+
+// ListPVZUtilization isn't cached, it changes with every added or issued product.
+func (p *CachedPVZProcessor) ListPVZUtilization(minUtilization float64, city string, page, limit int) ([]models.PVZUtilization, error) {
+	return p.next.ListPVZUtilization(minUtilization, city, page, limit)
+}
+
+// ListActivePVZs isn't cached, it serves the gRPC listing only.
+func (p *CachedPVZProcessor) ListActivePVZs() ([]models.PVZ, error) {
+	return p.next.ListActivePVZs()
+}
+
+// RefreshCapacityMetrics reads the utilization past the cache as ListPVZUtilization.
+func (p *CachedPVZProcessor) RefreshCapacityMetrics() error {
+	return p.next.RefreshCapacityMetrics()
+}
+
+// InvalidatingReceptionProcessor drops cached responses of a PVZ when its receptions
+// change.
+type InvalidatingReceptionProcessor struct {
+	next  ReceptionProcessor
+	cache *PVZCache
+}
+
+func NewInvalidatingReceptionProcessor(next ReceptionProcessor, cache *PVZCache) *InvalidatingReceptionProcessor {
+	return &InvalidatingReceptionProcessor{next: next, cache: cache}
+}
+
+func (p *InvalidatingReceptionProcessor) CreateReception(pvzID string) (models.Reception, error) {
+	reception, err := p.next.CreateReception(pvzID)
+	if err == nil {
+		p.cache.InvalidatePVZ(pvzID)
+	}
+	return reception, err
+}
+
+func (p *InvalidatingReceptionProcessor) CloseLastReception(pvzID string) (models.Reception, error) {
+	reception, err := p.next.CloseLastReception(pvzID)
+	if err == nil {
+		p.cache.InvalidatePVZ(pvzID)
+	}
+	return reception, err
+}
+
+// InvalidatingProductProcessor drops cached responses of a PVZ when products of its
+// receptions change.
+type InvalidatingProductProcessor struct {
+	next  *ProductProcessor
+	cache *PVZCache
+}
+
+func NewInvalidatingProductProcessor(next *ProductProcessor, cache *PVZCache) *InvalidatingProductProcessor {
+	return &InvalidatingProductProcessor{next: next, cache: cache}
+}
+
+func (p *InvalidatingProductProcessor) AddProduct(pvzID, productType string) (models.Product, error) {
+	product, err := p.next.AddProduct(pvzID, productType)
+	if err == nil {
+		p.cache.InvalidatePVZ(pvzID)
+	}
+	return product, err
+}
+
+func (p *InvalidatingProductProcessor) DeleteLastProduct(pvzID string) error {
+	err := p.next.DeleteLastProduct(pvzID)
+	if err == nil {
+		p.cache.InvalidatePVZ(pvzID)
+	}
+	return err
+}
+
+func (p *InvalidatingProductProcessor) IssueProduct(pvzID, productID string) (models.Product, error) {
+	product, err := p.next.IssueProduct(pvzID, productID)
+	if err == nil {
+		p.cache.InvalidatePVZ(pvzID)
+	}
+	return product, err
+}
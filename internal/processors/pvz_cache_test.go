@@ -0,0 +1,203 @@
+package processors
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"pvzService/internal/cache"
+	"pvzService/internal/clock"
+	"pvzService/internal/models"
+	"pvzService/internal/repository"
+)
+
+type failingStore struct{}
+
+func (failingStore) Get(string) ([]byte, bool, error) { return nil, false, errors.New("unavailable") }
+func (failingStore) Set(string, []byte, []string, time.Duration) error {
+	return errors.New("unavailable")
+}
+func (failingStore) Invalidate(...string) error { return errors.New("unavailable") }
+
+func newTestPVZCache() *PVZCache {
+	return NewPVZCache(cache.NewMemoryStore(100, clock.NewFake(testNow)), time.Minute, 0, clock.NewFake(testNow))
+}
+
+func TestCachedPVZProcessor_ListServedFromCache(t *testing.T) {
+	mockRepo := new(MockPVZRepo)
+	processor := NewCachedPVZProcessor(NewPVZProcessor(mockRepo, clock.NewFake(testNow)), newTestPVZCache())
+
+	pvz := models.PVZ{ID: "pvz1", RegistrationDate: testNow, City: "Москва", Timezone: "Europe/Moscow"}
+	mockRepo.On("ListPVZsWithRelations", time.Time{}, time.Time{}, "", false, 10, 0).
+		Return([]repository.PVZResponse{{PVZ: pvz, Receptions: []repository.ReceptionResponse{}}}, nil).Once()
+
+	first, err := processor.ListPVZsWithRelations("", "", "", false, 1, 10)
+	assert.NoError(t, err)
+	second, err := processor.ListPVZsWithRelations("", "", "", false, 1, 10)
+	assert.NoError(t, err)
+
+	assert.Equal(t, first[0].PVZ.ID, second[0].PVZ.ID)
+	assert.True(t, first[0].PVZ.RegistrationDate.Equal(second[0].PVZ.RegistrationDate))
+	mockRepo.AssertNumberOfCalls(t, "ListPVZsWithRelations", 1)
+}
+
+func TestCachedPVZProcessor_InvalidatedByWrites(t *testing.T) {
+	pvzCache := newTestPVZCache()
+	mockPVZRepo := new(MockPVZRepo)
+	pvzProcessor := NewCachedPVZProcessor(NewPVZProcessor(mockPVZRepo, clock.NewFake(testNow)), pvzCache)
+	mockReceptionRepo := new(MockReceptionRepository)
+	receptionProcessor := NewInvalidatingReceptionProcessor(NewReceptionProcessor(mockReceptionRepo, clock.NewFake(testNow)), pvzCache)
+
+	pvz1 := models.PVZ{ID: uuid.NewString(), City: "Москва"}
+	pvz2 := models.PVZ{ID: uuid.NewString(), City: "Казань"}
+	mockPVZRepo.On("ListPVZsWithRelations", time.Time{}, time.Time{}, "", false, 10, 0).
+		Return([]repository.PVZResponse{{PVZ: pvz1}}, nil)
+	mockPVZRepo.On("ListPVZsWithRelations", time.Time{}, time.Time{}, "Казань", false, 10, 0).
+		Return([]repository.PVZResponse{{PVZ: pvz2}}, nil)
+
+	pvzProcessor.ListPVZsWithRelations("", "", "", false, 1, 10)
+	pvzProcessor.ListPVZsWithRelations("", "", "Казань", false, 1, 10)
+
+	// A new reception of the first PVZ drops only the page that contains it
+	receptionID := uuid.NewString()
+	mockReceptionRepo.On("HasOpenReception", pvz1.ID).Return(false, nil)
+	mockReceptionRepo.On("CreateReception", pvz1.ID, testNow, mock.Anything).Return(receptionID, nil)
+	mockReceptionRepo.On("GetReceptionByID", receptionID).Return(models.Reception{ID: receptionID}, nil)
+	_, err := receptionProcessor.CreateReception(pvz1.ID)
+	assert.NoError(t, err)
+
+	pvzProcessor.ListPVZsWithRelations("", "", "", false, 1, 10)
+	pvzProcessor.ListPVZsWithRelations("", "", "Казань", false, 1, 10)
+	mockPVZRepo.AssertNumberOfCalls(t, "ListPVZsWithRelations", 3)
+
+	// A new PVZ drops every page
+	mockPVZRepo.On("CreatePVZ", "Казань", testNow, mock.Anything).Return(models.PVZ{ID: uuid.NewString(), City: "Казань"}, nil)
+	_, err = pvzProcessor.CreatePVZ("Казань")
+	assert.NoError(t, err)
+
+	pvzProcessor.ListPVZsWithRelations("", "", "Казань", false, 1, 10)
+	mockPVZRepo.AssertNumberOfCalls(t, "ListPVZsWithRelations", 4)
+}
+
+func TestCachedPVZProcessor_NotRefilledFromLaggingReplica(t *testing.T) {
+	clk := clock.NewFake(testNow)
+	pvzCache := NewPVZCache(cache.NewMemoryStore(100, clk), time.Minute, 10*time.Second, clk)
+	mockPVZRepo := new(MockPVZRepo)
+	pvzProcessor := NewCachedPVZProcessor(NewPVZProcessor(mockPVZRepo, clk), pvzCache)
+	mockReceptionRepo := new(MockReceptionRepository)
+	receptionProcessor := NewInvalidatingReceptionProcessor(NewReceptionProcessor(mockReceptionRepo, clk), pvzCache)
+
+	pvz1 := models.PVZ{ID: uuid.NewString(), City: "Москва"}
+	pvz2 := models.PVZ{ID: uuid.NewString(), City: "Казань"}
+	mockPVZRepo.On("ListPVZsWithRelations", time.Time{}, time.Time{}, "", false, 10, 0).
+		Return([]repository.PVZResponse{{PVZ: pvz1}}, nil)
+	mockPVZRepo.On("ListPVZsWithRelations", time.Time{}, time.Time{}, "Казань", false, 10, 0).
+		Return([]repository.PVZResponse{{PVZ: pvz2}}, nil)
+	pvzProcessor.ListPVZsWithRelations("", "", "", false, 1, 10)
+	pvzProcessor.ListPVZsWithRelations("", "", "Казань", false, 1, 10)
+
+	receptionID := uuid.NewString()
+	mockReceptionRepo.On("HasOpenReception", pvz1.ID).Return(false, nil)
+	mockReceptionRepo.On("CreateReception", pvz1.ID, testNow, mock.Anything).Return(receptionID, nil)
+	mockReceptionRepo.On("GetReceptionByID", receptionID).Return(models.Reception{ID: receptionID}, nil)
+	_, err := receptionProcessor.CreateReception(pvz1.ID)
+	assert.NoError(t, err)
+
+	// While the replica may not have the reception yet, the page with the PVZ is read
+	// again on every request instead of caching what may be the old page
+	clk.Advance(9 * time.Second)
+	pvzProcessor.ListPVZsWithRelations("", "", "", false, 1, 10)
+	pvzProcessor.ListPVZsWithRelations("", "", "", false, 1, 10)
+	pvzProcessor.ListPVZsWithRelations("", "", "Казань", false, 1, 10)
+	mockPVZRepo.AssertNumberOfCalls(t, "ListPVZsWithRelations", 4)
+
+	clk.Advance(time.Second)
+	pvzProcessor.ListPVZsWithRelations("", "", "", false, 1, 10)
+	pvzProcessor.ListPVZsWithRelations("", "", "", false, 1, 10)
+	mockPVZRepo.AssertNumberOfCalls(t, "ListPVZsWithRelations", 5)
+}
+
+func TestCachedPVZProcessor_InvalidatedByArchiving(t *testing.T) {
+	mockRepo := new(MockPVZRepo)
+	processor := NewCachedPVZProcessor(NewPVZProcessor(mockRepo, clock.NewFake(testNow)), newTestPVZCache())
+
+	pvz1 := models.PVZ{ID: uuid.NewString(), City: "Москва"}
+	pvz2 := models.PVZ{ID: uuid.NewString(), City: "Москва"}
+	mockRepo.On("ListPVZsWithRelations", time.Time{}, time.Time{}, "", false, 10, 0).
+		Return([]repository.PVZResponse{{PVZ: pvz1}}, nil)
+	mockRepo.On("ListPVZsWithRelations", time.Time{}, time.Time{}, "", true, 10, 0).
+		Return([]repository.PVZResponse{{PVZ: pvz1}}, nil)
+	mockRepo.On("GetPVZByID", pvz2.ID, "").Return(pvz2, nil)
+
+	// Lists with and without archived PVZ are cached apart
+	processor.ListPVZsWithRelations("", "", "", false, 1, 10)
+	processor.ListPVZsWithRelations("", "", "", true, 1, 10)
+	processor.GetPVZByID(pvz2.ID, "")
+	mockRepo.AssertNumberOfCalls(t, "ListPVZsWithRelations", 2)
+
+	// Archiving a PVZ that is on no cached page still drops every page and the PVZ itself
+	mockRepo.On("ArchivePVZ", pvz2.ID, "", "user1", testNow).Return(nil)
+	assert.NoError(t, processor.ArchivePVZ(pvz2.ID, "", "user1"))
+
+	processor.ListPVZsWithRelations("", "", "", false, 1, 10)
+	processor.ListPVZsWithRelations("", "", "", true, 1, 10)
+	processor.GetPVZByID(pvz2.ID, "")
+	mockRepo.AssertNumberOfCalls(t, "ListPVZsWithRelations", 4)
+	mockRepo.AssertNumberOfCalls(t, "GetPVZByID", 2)
+}
+
+func TestCachedPVZProcessor_ErrorsAreNotCached(t *testing.T) {
+	mockRepo := new(MockPVZRepo)
+	processor := NewCachedPVZProcessor(NewPVZProcessor(mockRepo, clock.NewFake(testNow)), newTestPVZCache())
+
+	mockRepo.On("GetPVZByID", "pvz1", "").Return(models.PVZ{}, errors.New("db error")).Once()
+	mockRepo.On("GetPVZByID", "pvz1", "").Return(models.PVZ{ID: "pvz1"}, nil).Once()
+
+	_, err := processor.GetPVZByID("pvz1", "")
+	assert.Error(t, err)
+	pvz, err := processor.GetPVZByID("pvz1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "pvz1", pvz.ID)
+}
+
+func TestCachedPVZProcessor_WorksWithoutStore(t *testing.T) {
+	mockRepo := new(MockPVZRepo)
+	processor := NewCachedPVZProcessor(NewPVZProcessor(mockRepo, clock.NewFake(testNow)), NewPVZCache(failingStore{}, time.Minute, 0, clock.NewFake(testNow)))
+
+	mockRepo.On("GetPVZByID", "pvz1", "").Return(models.PVZ{ID: "pvz1"}, nil)
+
+	pvz, err := processor.GetPVZByID("pvz1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "pvz1", pvz.ID)
+
+	mockRepo.On("CreatePVZ", "Москва", testNow, mock.Anything).Return(models.PVZ{ID: "pvz2"}, nil)
+	_, err = processor.CreatePVZ("Москва")
+	assert.NoError(t, err)
+}
+
+func TestInvalidatingProductProcessor(t *testing.T) {
+	pvzCache := newTestPVZCache()
+	mockPVZRepo := new(MockPVZRepo)
+	pvzProcessor := NewCachedPVZProcessor(NewPVZProcessor(mockPVZRepo, clock.NewFake(testNow)), pvzCache)
+	mockProductRepo := new(MockProductRepo)
+	mockReceptionRepo := new(MockReceptionRepo)
+	productProcessor := NewInvalidatingProductProcessor(NewProductProcessor(mockProductRepo, mockReceptionRepo, false, clock.NewFake(testNow)), pvzCache)
+
+	pvzID, receptionID := uuid.NewString(), uuid.NewString()
+	mockPVZRepo.On("GetPVZByID", pvzID, "").Return(models.PVZ{ID: pvzID}, nil)
+	mockReceptionRepo.On("GetOpenReception", pvzID).Return(models.Reception{ID: receptionID}, nil)
+	mockProductRepo.On("GetLastProduct", receptionID).Return(models.Product{ID: "prod1"}, nil)
+	mockProductRepo.On("DeleteProduct", "prod1").Return(nil)
+
+	pvzProcessor.GetPVZByID(pvzID, "")
+	pvzProcessor.GetPVZByID(pvzID, "")
+	mockPVZRepo.AssertNumberOfCalls(t, "GetPVZByID", 1)
+
+	assert.NoError(t, productProcessor.DeleteLastProduct(pvzID))
+	pvzProcessor.GetPVZByID(pvzID, "")
+	mockPVZRepo.AssertNumberOfCalls(t, "GetPVZByID", 2)
+}
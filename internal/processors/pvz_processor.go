@@ -1,26 +1,40 @@
 package processors
 
 import (
+	"database/sql"
 	"errors"
+	"fmt"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
+	"pvzService/internal/apperrors"
+	"pvzService/internal/clock"
 	"pvzService/internal/models"
+	"pvzService/internal/prometheus"
 	"pvzService/internal/repository"
 )
 
 type PVZProcessor interface {
 	CreatePVZ(city string) (models.PVZ, error)
-	GetPVZByID(id string) (models.PVZ, error)
-	ListPVZsWithRelations(startDate, endDate string, page, limit int) ([]repository.PVZResponse, error)
+	GetPVZByID(id, city string) (models.PVZ, error)
+	ListPVZsWithRelations(startDate, endDate, city string, includeArchived bool, page, limit int) ([]repository.PVZResponse, error)
+	ArchivePVZ(id, city, actorID string) error
+	RestorePVZ(id, city string) (models.PVZ, error)
+	UpdatePVZ(id, city string, update models.UpdatePVZRequest) (models.PVZ, error)
+	ListNearbyPVZs(lat, lon, radiusKm float64, openAt, city string, limit int) ([]models.NearbyPVZ, error)
+	ListPVZUtilization(minUtilization float64, city string, page, limit int) ([]models.PVZUtilization, error)
+	ListActivePVZs() ([]models.PVZ, error)
+	RefreshCapacityMetrics() error
 }
 
 type PVZProcessorImpl struct {
 	pvzRepo repository.PVZRepository
+	clock   clock.Clock
 }
 
-func NewPVZProcessor(pvzRepo repository.PVZRepository) *PVZProcessorImpl {
-	return &PVZProcessorImpl{pvzRepo: pvzRepo}
+func NewPVZProcessor(pvzRepo repository.PVZRepository, clock clock.Clock) *PVZProcessorImpl {
+	return &PVZProcessorImpl{pvzRepo: pvzRepo, clock: clock}
 }
 
 func (p *PVZProcessorImpl) CreatePVZ(city string) (models.PVZ, error) {
@@ -31,42 +45,258 @@ func (p *PVZProcessorImpl) CreatePVZ(city string) (models.PVZ, error) {
 	}
 
 	if !allowedCities[city] {
-		return models.PVZ{}, errors.New("invalid city")
+		return models.PVZ{}, apperrors.ErrInvalidCity
 	}
 
-	return p.pvzRepo.CreatePVZ(city, uuid.New)
+	pvz, err := p.pvzRepo.CreatePVZ(city, p.clock.Now(), uuid.New)
+	if err != nil {
+		return models.PVZ{}, apperrors.Internal("failed to create PVZ", err)
+	}
+	return inLocalTime(pvz), nil
 }
 
-func (p *PVZProcessorImpl) GetPVZByID(id string) (models.PVZ, error) {
-	return p.pvzRepo.GetPVZByID(id)
+// GetPVZByID looks up a PVZ, archived ones included, a non-empty city hides the PVZ of
+// other cities.
+func (p *PVZProcessorImpl) GetPVZByID(id, city string) (models.PVZ, error) {
+	pvz, err := p.pvzRepo.GetPVZByID(id, city)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.PVZ{}, apperrors.ErrPVZNotFound
+		}
+		return models.PVZ{}, apperrors.Internal("database error", err)
+	}
+	return inLocalTime(pvz), nil
 }
 
-func (p *PVZProcessorImpl) ListPVZsWithRelations(startDate, endDate string, page, limit int) ([]repository.PVZResponse, error) {
+// ListPVZsWithRelations lists PVZ page by page, a non-empty city hides the PVZ of other cities.
+// Archived PVZ are listed only with includeArchived.
+func (p *PVZProcessorImpl) ListPVZsWithRelations(startDate, endDate, city string, includeArchived bool, page, limit int) ([]repository.PVZResponse, error) {
 	var start, end time.Time
 	var err error
 
 	if startDate != "" {
 		start, err = time.Parse(time.RFC3339, startDate)
 		if err != nil {
-			return nil, errors.New("invalid start date format")
+			return nil, apperrors.New(apperrors.CodeInvalidRequest, "invalid start date format")
 		}
 	}
 
 	if endDate != "" {
 		end, err = time.Parse(time.RFC3339, endDate)
 		if err != nil {
-			return nil, errors.New("invalid end date format")
+			return nil, apperrors.New(apperrors.CodeInvalidRequest, "invalid end date format")
 		}
 	}
 
 	if page < 1 {
-		return nil, errors.New("invalid page number")
+		return nil, apperrors.New(apperrors.CodeInvalidRequest, "invalid page number")
 	}
 
 	if limit < 1 || limit > 30 {
-		return nil, errors.New("invalid limit")
+		return nil, apperrors.New(apperrors.CodeInvalidRequest, "invalid limit")
 	}
 
 	offset := (page - 1) * limit
-	return p.pvzRepo.ListPVZsWithRelations(start, end, limit, offset)
+	result, err := p.pvzRepo.ListPVZsWithRelations(start, end, city, includeArchived, limit, offset)
+	if err != nil {
+		return nil, apperrors.Internal("failed to list PVZ", err)
+	}
+	for i := range result {
+		result[i] = responseInLocalTime(result[i])
+	}
+	return result, nil
+}
+
+// ArchivePVZ decommissions a PVZ on behalf of actorID, which is empty for API keys. A
+// PVZ with an open reception is not archived.
+func (p *PVZProcessorImpl) ArchivePVZ(id, city, actorID string) error {
+	err := p.pvzRepo.ArchivePVZ(id, city, actorID, p.clock.Now())
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return apperrors.ErrPVZNotFound
+	case errors.Is(err, apperrors.ErrPVZHasOpenReception):
+		return apperrors.ErrPVZHasOpenReception
+	case err != nil:
+		return apperrors.Internal("failed to archive PVZ", err)
+	}
+	return nil
+}
+
+// RestorePVZ takes a PVZ out of the archive and returns it.
+func (p *PVZProcessorImpl) RestorePVZ(id, city string) (models.PVZ, error) {
+	if err := p.pvzRepo.RestorePVZ(id, city); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.PVZ{}, apperrors.ErrPVZNotFound
+		}
+		return models.PVZ{}, apperrors.Internal("failed to restore PVZ", err)
+	}
+	return p.GetPVZByID(id, city)
+}
+
+// UpdatePVZ changes the profile of a PVZ and returns it, archived PVZ can be updated
+// too. Coordinates are changed together, working hours are kept in the order of
+// models.Weekdays.
+func (p *PVZProcessorImpl) UpdatePVZ(id, city string, update models.UpdatePVZRequest) (models.PVZ, error) {
+	if update.Name == nil && update.Address == nil && update.Latitude == nil && update.Longitude == nil &&
+		update.WorkingHours == nil && update.Capacity == nil {
+		return models.PVZ{}, apperrors.ErrNothingToUpdatePVZ
+	}
+	if details := validateProfile(update); len(details) > 0 {
+		return models.PVZ{}, apperrors.Validation(details)
+	}
+	if update.WorkingHours != nil {
+		hours := slices.Clone(*update.WorkingHours)
+		slices.SortFunc(hours, func(a, b models.WorkingHours) int {
+			return slices.Index(models.Weekdays, a.Weekday) - slices.Index(models.Weekdays, b.Weekday)
+		})
+		update.WorkingHours = &hours
+	}
+
+	if err := p.pvzRepo.UpdatePVZ(id, city, update); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.PVZ{}, apperrors.ErrPVZNotFound
+		}
+		return models.PVZ{}, apperrors.Internal("failed to update PVZ", err)
+	}
+	return p.GetPVZByID(id, city)
+}
+
+// ListNearbyPVZs finds the PVZ in operation nearest to the point, a non-empty openAt
+// leaves only the PVZ open at that time and a non-empty city hides the PVZ of other
+// cities.
+func (p *PVZProcessorImpl) ListNearbyPVZs(lat, lon, radiusKm float64, openAt, city string, limit int) ([]models.NearbyPVZ, error) {
+	var at time.Time
+	if openAt != "" {
+		var err error
+		at, err = time.Parse(time.RFC3339, openAt)
+		if err != nil {
+			return nil, apperrors.New(apperrors.CodeInvalidRequest, "invalid openAt format")
+		}
+	}
+
+	result, err := p.pvzRepo.ListNearbyPVZs(lat, lon, radiusKm, at, city, limit)
+	if err != nil {
+		return nil, apperrors.Internal("failed to find nearby PVZ", err)
+	}
+	for i := range result {
+		result[i].PVZ = inLocalTime(result[i].PVZ)
+	}
+	return result, nil
+}
+
+// ListPVZUtilization lists PVZ with a capacity that are filled at least to
+// minUtilization, the fullest first. A non-empty city hides the PVZ of other cities.
+func (p *PVZProcessorImpl) ListPVZUtilization(minUtilization float64, city string, page, limit int) ([]models.PVZUtilization, error) {
+	result, err := p.pvzRepo.ListPVZUtilization(minUtilization, city, limit, (page-1)*limit)
+	if err != nil {
+		return nil, apperrors.Internal("failed to list PVZ utilization", err)
+	}
+	for i := range result {
+		result[i].PVZ = inLocalTime(result[i].PVZ)
+	}
+	return result, nil
+}
+
+// ListActivePVZs lists every PVZ in operation, archived PVZ are left out as in the
+// default listing.
+func (p *PVZProcessorImpl) ListActivePVZs() ([]models.PVZ, error) {
+	result, err := p.pvzRepo.ListActivePVZs()
+	if err != nil {
+		return nil, apperrors.Internal("failed to list PVZ", err)
+	}
+	for i := range result {
+		result[i] = inLocalTime(result[i])
+	}
+	return result, nil
+}
+
+// RefreshCapacityMetrics exports the utilization of every PVZ with a capacity, PVZ that
+// lost their capacity or were archived since the last refresh are dropped.
+func (p *PVZProcessorImpl) RefreshCapacityMetrics() error {
+	result, err := p.pvzRepo.ListAllPVZUtilization()
+	if err != nil {
+		return err
+	}
+	prometheus.CapacityUtilization.Reset()
+	for _, pvz := range result {
+		prometheus.CapacityUtilization.WithLabelValues(pvz.ID, pvz.City).Set(pvz.Utilization)
+	}
+	return nil
+}
+
+// validateProfile checks what the validate tags can't: coordinates come in pairs, a
+// PVZ opens before it closes and has at most one entry per weekday.
+func validateProfile(update models.UpdatePVZRequest) []models.FieldError {
+	var details []models.FieldError
+	if (update.Latitude == nil) != (update.Longitude == nil) {
+		field, other := "longitude", "latitude"
+		if update.Latitude == nil {
+			field, other = other, field
+		}
+		details = append(details, models.FieldError{
+			Field:   field,
+			Rule:    "required_with",
+			Message: fmt.Sprintf("%s is required with %s", field, other),
+		})
+	}
+	if update.WorkingHours == nil {
+		return details
+	}
+
+	seen := make(map[string]bool, len(models.Weekdays))
+	for i, hours := range *update.WorkingHours {
+		if hours.Opens >= hours.Closes {
+			field := fmt.Sprintf("workingHours[%d].closes", i)
+			details = append(details, models.FieldError{
+				Field:   field,
+				Rule:    "gtfield",
+				Message: fmt.Sprintf("%s must be after workingHours[%d].opens", field, i),
+			})
+		}
+		if seen[hours.Weekday] {
+			field := fmt.Sprintf("workingHours[%d].weekday", i)
+			details = append(details, models.FieldError{
+				Field:   field,
+				Rule:    "unique",
+				Message: fmt.Sprintf("%s must be unique, %s is listed twice", field, hours.Weekday),
+			})
+		}
+		seen[hours.Weekday] = true
+	}
+	return details
+}
+
+// inLocalTime shows the times of a PVZ in its time zone, so the date of a timestamp is
+// the local day of the PVZ.
+func inLocalTime(pvz models.PVZ) models.PVZ {
+	loc := clock.Location(pvz.Timezone)
+	pvz.RegistrationDate = pvz.RegistrationDate.In(loc)
+	if pvz.ArchivedAt != nil {
+		archivedAt := pvz.ArchivedAt.In(loc)
+		pvz.ArchivedAt = &archivedAt
+	}
+	return pvz
+}
+
+// responseInLocalTime shows the receptions and products of a PVZ in its time zone.
+func responseInLocalTime(resp repository.PVZResponse) repository.PVZResponse {
+	resp.PVZ = inLocalTime(resp.PVZ)
+	loc := clock.Location(resp.PVZ.Timezone)
+	for i := range resp.Receptions {
+		reception := &resp.Receptions[i]
+		reception.Reception.DateTime = reception.Reception.DateTime.In(loc)
+		if reception.Reception.ClosedAt != nil {
+			closedAt := reception.Reception.ClosedAt.In(loc)
+			reception.Reception.ClosedAt = &closedAt
+		}
+		for j := range reception.Products {
+			product := &reception.Products[j]
+			product.DateTime = product.DateTime.In(loc)
+			if product.IssuedAt != nil {
+				issuedAt := product.IssuedAt.In(loc)
+				product.IssuedAt = &issuedAt
+			}
+		}
+	}
+	return resp
 }
@@ -1,13 +1,18 @@
 package processors
 
 import (
+	"database/sql"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
+	"pvzService/internal/apperrors"
+	"pvzService/internal/clock"
 	"pvzService/internal/models"
 	"pvzService/internal/repository"
 )
@@ -16,38 +21,76 @@ type MockPVZRepo struct {
 	mock.Mock
 }
 
-func (m *MockPVZRepo) CreatePVZ(city string, idGenerator func() uuid.UUID) (models.PVZ, error) {
-	args := m.Called(city, idGenerator)
+func (m *MockPVZRepo) CreatePVZ(city string, registeredAt time.Time, idGenerator func() uuid.UUID) (models.PVZ, error) {
+	args := m.Called(city, registeredAt, idGenerator)
 	return args.Get(0).(models.PVZ), args.Error(1)
 }
 
-func (m *MockPVZRepo) GetPVZByID(id string) (models.PVZ, error) {
-	args := m.Called(id)
+func (m *MockPVZRepo) GetPVZByID(id, city string) (models.PVZ, error) {
+	args := m.Called(id, city)
 	return args.Get(0).(models.PVZ), args.Error(1)
 }
 
-func (m *MockPVZRepo) ListPVZsWithRelations(startDate, endDate time.Time, limit, offset int) ([]repository.PVZResponse, error) {
-	args := m.Called(startDate, endDate, limit, offset)
+func (m *MockPVZRepo) ListPVZsWithRelations(startDate, endDate time.Time, city string, includeArchived bool, limit, offset int) ([]repository.PVZResponse, error) {
+	args := m.Called(startDate, endDate, city, includeArchived, limit, offset)
 	return args.Get(0).([]repository.PVZResponse), args.Error(1)
 }
 
+func (m *MockPVZRepo) ArchivePVZ(id, city, archivedBy string, archivedAt time.Time) error {
+	args := m.Called(id, city, archivedBy, archivedAt)
+	return args.Error(0)
+}
+
+func (m *MockPVZRepo) RestorePVZ(id, city string) error {
+	args := m.Called(id, city)
+	return args.Error(0)
+}
+
+func (m *MockPVZRepo) UpdatePVZ(id, city string, update models.UpdatePVZRequest) error {
+	args := m.Called(id, city, update)
+	return args.Error(0)
+}
+
+func (m *MockPVZRepo) ListNearbyPVZs(lat, lon, radiusKm float64, openAt time.Time, city string, limit int) ([]models.NearbyPVZ, error) {
+	args := m.Called(lat, lon, radiusKm, openAt, city, limit)
+	return args.Get(0).([]models.NearbyPVZ), args.Error(1)
+}
+
+func (m *MockPVZRepo) ListPVZUtilization(minUtilization float64, city string, limit, offset int) ([]models.PVZUtilization, error) {
+	args := m.Called(minUtilization, city, limit, offset)
+	return args.Get(0).([]models.PVZUtilization), args.Error(1)
+}
+
+func (m *MockPVZRepo) ListAllPVZUtilization() ([]models.PVZUtilization, error) {
+	args := m.Called()
+	return args.Get(0).([]models.PVZUtilization), args.Error(1)
+}
+
+func (m *MockPVZRepo) ListActivePVZs() ([]models.PVZ, error) {
+	args := m.Called()
+	return args.Get(0).([]models.PVZ), args.Error(1)
+}
+
 func TestPVZProcessor_CreatePVZ(t *testing.T) {
 	mockRepo := new(MockPVZRepo)
-	processor := NewPVZProcessor(mockRepo)
+	processor := NewPVZProcessor(mockRepo, clock.NewFake(testNow))
 
 	t.Run("success", func(t *testing.T) {
 		expectedPVZ := models.PVZ{
-			ID:   uuid.NewString(),
-			City: "Москва",
+			ID:               uuid.NewString(),
+			RegistrationDate: testNow,
+			City:             "Москва",
+			Timezone:         "Europe/Moscow",
 		}
 
-		mockRepo.On("CreatePVZ", "Москва", mock.AnythingOfType("func() uuid.UUID")).
+		mockRepo.On("CreatePVZ", "Москва", testNow, mock.AnythingOfType("func() uuid.UUID")).
 			Return(expectedPVZ, nil)
 
 		pvz, err := processor.CreatePVZ("Москва")
 
 		assert.NoError(t, err)
 		assert.Equal(t, "Москва", pvz.City)
+		assert.Equal(t, "2025-04-01T13:00:00+03:00", pvz.RegistrationDate.Format(time.RFC3339))
 		mockRepo.AssertExpectations(t)
 	})
 
@@ -60,7 +103,7 @@ func TestPVZProcessor_CreatePVZ(t *testing.T) {
 
 func TestPVZProcessor_GetPVZByID(t *testing.T) {
 	mockRepo := new(MockPVZRepo)
-	processor := NewPVZProcessor(mockRepo)
+	processor := NewPVZProcessor(mockRepo, clock.NewFake(testNow))
 
 	t.Run("success", func(t *testing.T) {
 		expectedPVZ := models.PVZ{
@@ -68,19 +111,27 @@ func TestPVZProcessor_GetPVZByID(t *testing.T) {
 			City: "Москва",
 		}
 
-		mockRepo.On("GetPVZByID", "test-id").Return(expectedPVZ, nil)
+		mockRepo.On("GetPVZByID", "test-id", "").Return(expectedPVZ, nil)
 
-		pvz, err := processor.GetPVZByID("test-id")
+		pvz, err := processor.GetPVZByID("test-id", "")
 
 		assert.NoError(t, err)
 		assert.Equal(t, "Москва", pvz.City)
 		mockRepo.AssertExpectations(t)
 	})
+
+	t.Run("other city", func(t *testing.T) {
+		mockRepo.On("GetPVZByID", "test-id", "Казань").Return(models.PVZ{}, sql.ErrNoRows)
+
+		_, err := processor.GetPVZByID("test-id", "Казань")
+
+		assert.ErrorIs(t, err, apperrors.ErrPVZNotFound)
+	})
 }
 
 func TestPVZProcessor_ListPVZsWithRelations(t *testing.T) {
 	mockRepo := new(MockPVZRepo)
-	processor := NewPVZProcessor(mockRepo)
+	processor := NewPVZProcessor(mockRepo, clock.NewFake(testNow))
 
 	t.Run("success", func(t *testing.T) {
 		expected := []repository.PVZResponse{
@@ -92,23 +143,235 @@ func TestPVZProcessor_ListPVZsWithRelations(t *testing.T) {
 			},
 		}
 
-		mockRepo.On("ListPVZsWithRelations", time.Time{}, time.Time{}, 10, 0).
+		mockRepo.On("ListPVZsWithRelations", time.Time{}, time.Time{}, "Москва", false, 10, 0).
 			Return(expected, nil)
 
-		result, err := processor.ListPVZsWithRelations("", "", 1, 10)
+		result, err := processor.ListPVZsWithRelations("", "", "Москва", false, 1, 10)
 
 		assert.NoError(t, err)
 		assert.Len(t, result, 1)
 		mockRepo.AssertExpectations(t)
 	})
 
+	t.Run("times in the local time zone of the PVZ", func(t *testing.T) {
+		// Late evening in UTC is already the next day in Moscow
+		createdAt := time.Date(2025, 3, 31, 22, 30, 0, 0, time.UTC)
+		closedAt := createdAt.Add(time.Hour)
+		mockRepo.On("ListPVZsWithRelations", time.Time{}, time.Time{}, "Казань", false, 10, 0).
+			Return([]repository.PVZResponse{{
+				PVZ: models.PVZ{ID: "pvz2", RegistrationDate: createdAt, City: "Казань", Timezone: "Europe/Moscow"},
+				Receptions: []repository.ReceptionResponse{{
+					Reception: models.Reception{ID: "rec1", DateTime: createdAt, ClosedAt: &closedAt},
+					Products:  []models.Product{{ID: "prod1", DateTime: createdAt}},
+				}},
+			}}, nil)
+
+		result, err := processor.ListPVZsWithRelations("", "", "Казань", false, 1, 10)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "2025-04-01T01:30:00+03:00", result[0].PVZ.RegistrationDate.Format(time.RFC3339))
+		assert.Equal(t, "2025-04-01T01:30:00+03:00", result[0].Receptions[0].Reception.DateTime.Format(time.RFC3339))
+		assert.Equal(t, "2025-04-01T02:30:00+03:00", result[0].Receptions[0].Reception.ClosedAt.Format(time.RFC3339))
+		assert.Equal(t, "2025-04-01T01:30:00+03:00", result[0].Receptions[0].Products[0].DateTime.Format(time.RFC3339))
+	})
+
 	t.Run("invalid date format", func(t *testing.T) {
-		_, err := processor.ListPVZsWithRelations("invalid", "", 1, 10)
+		_, err := processor.ListPVZsWithRelations("invalid", "", "", false, 1, 10)
 		assert.Error(t, err)
 	})
 
 	t.Run("invalid pagination", func(t *testing.T) {
-		_, err := processor.ListPVZsWithRelations("", "", 0, 10)
+		_, err := processor.ListPVZsWithRelations("", "", "", false, 0, 10)
 		assert.Error(t, err)
 	})
 }
+
+func TestPVZProcessor_ArchivePVZ(t *testing.T) {
+	mockRepo := new(MockPVZRepo)
+	processor := NewPVZProcessor(mockRepo, clock.NewFake(testNow))
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.On("ArchivePVZ", "pvz1", "", "user1", testNow).Return(nil)
+
+		assert.NoError(t, processor.ArchivePVZ("pvz1", "", "user1"))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("open reception", func(t *testing.T) {
+		mockRepo.On("ArchivePVZ", "pvz2", "", "user1", testNow).Return(apperrors.ErrPVZHasOpenReception)
+
+		assert.ErrorIs(t, processor.ArchivePVZ("pvz2", "", "user1"), apperrors.ErrPVZHasOpenReception)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockRepo.On("ArchivePVZ", "pvz3", "Казань", "user1", testNow).Return(sql.ErrNoRows)
+
+		assert.ErrorIs(t, processor.ArchivePVZ("pvz3", "Казань", "user1"), apperrors.ErrPVZNotFound)
+	})
+}
+
+func TestPVZProcessor_RestorePVZ(t *testing.T) {
+	mockRepo := new(MockPVZRepo)
+	processor := NewPVZProcessor(mockRepo, clock.NewFake(testNow))
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.On("RestorePVZ", "pvz1", "").Return(nil)
+		mockRepo.On("GetPVZByID", "pvz1", "").Return(models.PVZ{ID: "pvz1", City: "Москва"}, nil)
+
+		pvz, err := processor.RestorePVZ("pvz1", "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "pvz1", pvz.ID)
+		assert.False(t, pvz.Archived)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockRepo.On("RestorePVZ", "pvz2", "").Return(sql.ErrNoRows)
+
+		_, err := processor.RestorePVZ("pvz2", "")
+
+		assert.ErrorIs(t, err, apperrors.ErrPVZNotFound)
+	})
+}
+
+func TestPVZProcessor_UpdatePVZ(t *testing.T) {
+	mockRepo := new(MockPVZRepo)
+	processor := NewPVZProcessor(mockRepo, clock.NewFake(testNow))
+	lat, lon := 55.76, 37.61
+
+	t.Run("success", func(t *testing.T) {
+		hours := []models.WorkingHours{
+			{Weekday: "sunday", Opens: "10:00", Closes: "18:00"},
+			{Weekday: "monday", Opens: "09:00", Closes: "24:00"},
+		}
+		sorted := []models.WorkingHours{hours[1], hours[0]}
+		mockRepo.On("UpdatePVZ", "pvz1", "", models.UpdatePVZRequest{Latitude: &lat, Longitude: &lon, WorkingHours: &sorted}).
+			Return(nil)
+		mockRepo.On("GetPVZByID", "pvz1", "").
+			Return(models.PVZ{ID: "pvz1", Latitude: &lat, Longitude: &lon, WorkingHours: sorted}, nil)
+
+		pvz, err := processor.UpdatePVZ("pvz1", "", models.UpdatePVZRequest{Latitude: &lat, Longitude: &lon, WorkingHours: &hours})
+
+		assert.NoError(t, err)
+		assert.Equal(t, sorted, pvz.WorkingHours)
+		assert.Equal(t, "sunday", hours[0].Weekday)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("nothing to update", func(t *testing.T) {
+		_, err := processor.UpdatePVZ("pvz1", "", models.UpdatePVZRequest{})
+
+		assert.ErrorIs(t, err, apperrors.ErrNothingToUpdatePVZ)
+	})
+
+	t.Run("latitude without longitude", func(t *testing.T) {
+		_, err := processor.UpdatePVZ("pvz1", "", models.UpdatePVZRequest{Latitude: &lat})
+
+		assert.Equal(t, []models.FieldError{{Field: "longitude", Rule: "required_with", Message: "longitude is required with latitude"}},
+			apperrors.From(err).Details)
+	})
+
+	t.Run("invalid working hours", func(t *testing.T) {
+		hours := []models.WorkingHours{
+			{Weekday: "monday", Opens: "09:00", Closes: "21:00"},
+			{Weekday: "monday", Opens: "21:00", Closes: "09:00"},
+		}
+
+		_, err := processor.UpdatePVZ("pvz1", "", models.UpdatePVZRequest{WorkingHours: &hours})
+
+		assert.Equal(t, apperrors.CodeValidationFailed, apperrors.From(err).Code)
+		assert.Equal(t, []models.FieldError{
+			{Field: "workingHours[1].closes", Rule: "gtfield", Message: "workingHours[1].closes must be after workingHours[1].opens"},
+			{Field: "workingHours[1].weekday", Rule: "unique", Message: "workingHours[1].weekday must be unique, monday is listed twice"},
+		}, apperrors.From(err).Details)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		name := "ПВЗ"
+		mockRepo.On("UpdatePVZ", "pvz2", "Казань", models.UpdatePVZRequest{Name: &name}).Return(sql.ErrNoRows)
+
+		_, err := processor.UpdatePVZ("pvz2", "Казань", models.UpdatePVZRequest{Name: &name})
+
+		assert.ErrorIs(t, err, apperrors.ErrPVZNotFound)
+	})
+}
+
+func TestPVZProcessor_ListNearbyPVZs(t *testing.T) {
+	mockRepo := new(MockPVZRepo)
+	processor := NewPVZProcessor(mockRepo, clock.NewFake(testNow))
+
+	t.Run("open at a time", func(t *testing.T) {
+		found := []models.NearbyPVZ{{PVZ: models.PVZ{ID: "pvz1", RegistrationDate: testNow, Timezone: "Europe/Moscow"}, DistanceKm: 2}}
+		mockRepo.On("ListNearbyPVZs", 55.75, 37.62, 5.0, testNow, "", 10).Return(found, nil)
+
+		result, err := processor.ListNearbyPVZs(55.75, 37.62, 5, testNow.Format(time.RFC3339), "", 10)
+
+		assert.NoError(t, err)
+		require.Len(t, result, 1)
+		assert.Equal(t, "Europe/Moscow", result[0].RegistrationDate.Location().String())
+		assert.Equal(t, 2.0, result[0].DistanceKm)
+	})
+
+	t.Run("any time", func(t *testing.T) {
+		mockRepo.On("ListNearbyPVZs", 55.75, 37.62, 5.0, time.Time{}, "Москва", 10).Return([]models.NearbyPVZ{}, nil)
+
+		result, err := processor.ListNearbyPVZs(55.75, 37.62, 5, "", "Москва", 10)
+
+		assert.NoError(t, err)
+		assert.Empty(t, result)
+	})
+
+	t.Run("invalid openAt", func(t *testing.T) {
+		_, err := processor.ListNearbyPVZs(55.75, 37.62, 5, "noon", "", 10)
+
+		assert.Equal(t, apperrors.CodeInvalidRequest, apperrors.From(err).Code)
+	})
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPVZProcessor_ListPVZUtilization(t *testing.T) {
+	mockRepo := new(MockPVZRepo)
+	processor := NewPVZProcessor(mockRepo, clock.NewFake(testNow))
+
+	full := []models.PVZUtilization{{PVZ: models.PVZ{ID: "pvz1", RegistrationDate: testNow, Timezone: "Asia/Yekaterinburg"}, Utilization: 0.9}}
+	mockRepo.On("ListPVZUtilization", 0.8, "Москва", 10, 10).Return(full, nil)
+	mockRepo.On("ListPVZUtilization", 0.8, "", 10, 0).Return([]models.PVZUtilization{}, errors.New("db error"))
+
+	result, err := processor.ListPVZUtilization(0.8, "Москва", 2, 10)
+	assert.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "Asia/Yekaterinburg", result[0].RegistrationDate.Location().String())
+
+	_, err = processor.ListPVZUtilization(0.8, "", 1, 10)
+	assert.Equal(t, apperrors.CodeInternal, apperrors.From(err).Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPVZProcessor_ListActivePVZs(t *testing.T) {
+	mockRepo := new(MockPVZRepo)
+	processor := NewPVZProcessor(mockRepo, clock.NewFake(testNow))
+
+	mockRepo.On("ListActivePVZs").Return([]models.PVZ{{ID: "pvz1", RegistrationDate: testNow, Timezone: "Asia/Yekaterinburg"}}, nil).Once()
+	mockRepo.On("ListActivePVZs").Return([]models.PVZ{}, errors.New("db error")).Once()
+
+	result, err := processor.ListActivePVZs()
+	assert.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "Asia/Yekaterinburg", result[0].RegistrationDate.Location().String())
+
+	_, err = processor.ListActivePVZs()
+	assert.Equal(t, apperrors.CodeInternal, apperrors.From(err).Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPVZProcessor_RefreshCapacityMetrics(t *testing.T) {
+	mockRepo := new(MockPVZRepo)
+	processor := NewPVZProcessor(mockRepo, clock.NewFake(testNow))
+
+	mockRepo.On("ListAllPVZUtilization").Return([]models.PVZUtilization{
+		{PVZ: models.PVZ{ID: "pvz1", City: "Москва"}, Utilization: 0.5},
+	}, nil)
+
+	assert.NoError(t, processor.RefreshCapacityMetrics())
+	mockRepo.AssertExpectations(t)
+}
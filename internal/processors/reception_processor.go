@@ -4,8 +4,9 @@ import (
 	"database/sql"
 	"errors"
 	"github.com/google/uuid"
-	"time"
 
+	"pvzService/internal/apperrors"
+	"pvzService/internal/clock"
 	"pvzService/internal/models"
 	"pvzService/internal/repository"
 )
@@ -17,41 +18,52 @@ type ReceptionProcessor interface {
 
 type ReceptionProcessorImpl struct {
 	receptionRepo repository.ReceptionRepository
+	clock         clock.Clock
 }
 
-func NewReceptionProcessor(receptionRepo repository.ReceptionRepository) *ReceptionProcessorImpl {
-	return &ReceptionProcessorImpl{receptionRepo: receptionRepo}
+func NewReceptionProcessor(receptionRepo repository.ReceptionRepository, clock clock.Clock) *ReceptionProcessorImpl {
+	return &ReceptionProcessorImpl{receptionRepo: receptionRepo, clock: clock}
 }
 
 func (p *ReceptionProcessorImpl) CreateReception(pvzID string) (models.Reception, error) {
 	hasOpen, err := p.receptionRepo.HasOpenReception(pvzID)
 	if err != nil {
-		return models.Reception{}, errors.New("database error")
+		return models.Reception{}, apperrors.Internal("database error", err)
 	}
 	if hasOpen {
-		return models.Reception{}, errors.New("open reception already exists for this PVZ")
+		return models.Reception{}, apperrors.ErrReceptionAlreadyOpen
 	}
 
-	receptionID, err := p.receptionRepo.CreateReception(pvzID, uuid.New)
+	receptionID, err := p.receptionRepo.CreateReception(pvzID, p.clock.Now(), uuid.New)
 	if err != nil {
-		return models.Reception{}, errors.New("failed to create reception")
+		if errors.Is(err, apperrors.ErrPVZNotFound) {
+			return models.Reception{}, apperrors.ErrPVZNotFound
+		}
+		if errors.Is(err, apperrors.ErrPVZArchived) {
+			return models.Reception{}, apperrors.ErrPVZArchived
+		}
+		return models.Reception{}, apperrors.Internal("failed to create reception", err)
 	}
 
-	return p.receptionRepo.GetReceptionByID(receptionID)
+	reception, err := p.receptionRepo.GetReceptionByID(receptionID)
+	if err != nil {
+		return models.Reception{}, apperrors.Internal("database error", err)
+	}
+	return reception, nil
 }
 
 func (p *ReceptionProcessorImpl) CloseLastReception(pvzID string) (models.Reception, error) {
 	reception, err := p.receptionRepo.GetOpenReception(pvzID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return models.Reception{}, errors.New("no open reception found for this PVZ")
+			return models.Reception{}, apperrors.ErrNoOpenReception
 		}
-		return models.Reception{}, errors.New("database error")
+		return models.Reception{}, apperrors.Internal("database error", err)
 	}
 
-	now := time.Now()
+	now := p.clock.Now()
 	if err := p.receptionRepo.CloseReception(reception.ID, now); err != nil {
-		return models.Reception{}, errors.New("failed to close reception")
+		return models.Reception{}, apperrors.Internal("failed to close reception", err)
 	}
 
 	reception.Status = "close"
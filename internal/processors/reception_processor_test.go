@@ -9,6 +9,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"pvzService/internal/apperrors"
+	"pvzService/internal/clock"
 	"pvzService/internal/models"
 )
 
@@ -16,8 +18,8 @@ type MockReceptionRepository struct {
 	mock.Mock
 }
 
-func (m *MockReceptionRepository) CreateReception(pvzID string, idGenerator func() uuid.UUID) (string, error) {
-	args := m.Called(pvzID, idGenerator)
+func (m *MockReceptionRepository) CreateReception(pvzID string, createdAt time.Time, idGenerator func() uuid.UUID) (string, error) {
+	args := m.Called(pvzID, createdAt, idGenerator)
 	return args.String(0), args.Error(1)
 }
 
@@ -43,7 +45,7 @@ func (m *MockReceptionRepository) HasOpenReception(pvzID string) (bool, error) {
 
 func TestReceptionProcessor_CreateReception(t *testing.T) {
 	mockRepo := new(MockReceptionRepository)
-	processor := NewReceptionProcessor(mockRepo)
+	processor := NewReceptionProcessor(mockRepo, clock.NewFake(testNow))
 
 	t.Run("success", func(t *testing.T) {
 		pvzID := uuid.New().String()
@@ -56,7 +58,7 @@ func TestReceptionProcessor_CreateReception(t *testing.T) {
 		}
 
 		mockRepo.On("HasOpenReception", pvzID).Return(false, nil)
-		mockRepo.On("CreateReception", pvzID, mock.AnythingOfType("func() uuid.UUID")).Return(receptionID, nil)
+		mockRepo.On("CreateReception", pvzID, testNow, mock.AnythingOfType("func() uuid.UUID")).Return(receptionID, nil)
 		mockRepo.On("GetReceptionByID", receptionID).Return(expectedReception, nil)
 
 		result, err := processor.CreateReception(pvzID)
@@ -71,6 +73,7 @@ func TestReceptionProcessor_CreateReception(t *testing.T) {
 
 		_, err := processor.CreateReception(pvzID)
 		assert.EqualError(t, err, "open reception already exists for this PVZ")
+		assert.ErrorIs(t, err, apperrors.ErrReceptionAlreadyOpen)
 		mockRepo.AssertExpectations(t)
 	})
 
@@ -80,45 +83,51 @@ func TestReceptionProcessor_CreateReception(t *testing.T) {
 
 		_, err := processor.CreateReception(pvzID)
 		assert.EqualError(t, err, "database error")
+		assert.Equal(t, apperrors.CodeInternal, apperrors.From(err).Code)
 		mockRepo.AssertExpectations(t)
 	})
 
 	t.Run("repository error on create", func(t *testing.T) {
 		pvzID := uuid.New().String()
 		mockRepo.On("HasOpenReception", pvzID).Return(false, nil)
-		mockRepo.On("CreateReception", pvzID, mock.AnythingOfType("func() uuid.UUID")).Return("", errors.New("db error"))
+		mockRepo.On("CreateReception", pvzID, testNow, mock.AnythingOfType("func() uuid.UUID")).Return("", errors.New("db error"))
 
 		_, err := processor.CreateReception(pvzID)
 		assert.EqualError(t, err, "failed to create reception")
 		mockRepo.AssertExpectations(t)
 	})
+
+	t.Run("archived PVZ", func(t *testing.T) {
+		pvzID := uuid.New().String()
+		mockRepo.On("HasOpenReception", pvzID).Return(false, nil)
+		mockRepo.On("CreateReception", pvzID, testNow, mock.AnythingOfType("func() uuid.UUID")).Return("", apperrors.ErrPVZArchived)
+
+		_, err := processor.CreateReception(pvzID)
+		assert.ErrorIs(t, err, apperrors.ErrPVZArchived)
+		mockRepo.AssertExpectations(t)
+	})
 }
 
 func TestReceptionProcessor_CloseLastReception(t *testing.T) {
 	mockRepo := new(MockReceptionRepository)
-	processor := NewReceptionProcessor(mockRepo)
+	processor := NewReceptionProcessor(mockRepo, clock.NewFake(testNow))
 
 	t.Run("success", func(t *testing.T) {
 		pvzID := uuid.New().String()
 		receptionID := uuid.New().String()
-		now := time.Now()
 		openReception := models.Reception{
 			ID:       receptionID,
 			PvzId:    pvzID,
 			Status:   "in_progress",
 			DateTime: time.Now(),
 		}
-		expectedReception := openReception
-		expectedReception.Status = "close"
-		expectedReception.ClosedAt = &now
-
 		mockRepo.On("GetOpenReception", pvzID).Return(openReception, nil)
-		mockRepo.On("CloseReception", receptionID, mock.AnythingOfType("time.Time")).Return(nil)
+		mockRepo.On("CloseReception", receptionID, testNow).Return(nil)
 
 		result, err := processor.CloseLastReception(pvzID)
 		assert.NoError(t, err)
-		assert.Equal(t, expectedReception.Status, result.Status)
-		assert.NotNil(t, result.ClosedAt)
+		assert.Equal(t, "close", result.Status)
+		assert.Equal(t, &testNow, result.ClosedAt)
 		mockRepo.AssertExpectations(t)
 	})
 
@@ -128,6 +137,7 @@ func TestReceptionProcessor_CloseLastReception(t *testing.T) {
 
 		_, err := processor.CloseLastReception(pvzID)
 		assert.EqualError(t, err, "no open reception found for this PVZ")
+		assert.ErrorIs(t, err, apperrors.ErrNoOpenReception)
 		mockRepo.AssertExpectations(t)
 	})
 
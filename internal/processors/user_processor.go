@@ -0,0 +1,102 @@
+package processors
+
+import (
+	"database/sql"
+	"errors"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
+	"pvzService/internal/repository"
+)
+
+type UserProcessor interface {
+	ListUsers(role string, page, limit int) ([]models.User, error)
+	UpdateUser(actorID, actorRole, id string, role, city *string, active *bool) (models.User, error)
+	DeleteUser(actorID, actorRole, id string) error
+}
+
+type UserProcessorImpl struct {
+	userRepo repository.UserRepository
+}
+
+func NewUserProcessor(userRepo repository.UserRepository) *UserProcessorImpl {
+	return &UserProcessorImpl{userRepo: userRepo}
+}
+
+func (p *UserProcessorImpl) ListUsers(role string, page, limit int) ([]models.User, error) {
+	users, err := p.userRepo.ListUsers(role, page, limit)
+	if err != nil {
+		return nil, apperrors.Internal("failed to list users", err)
+	}
+	return users, nil
+}
+
+// UpdateUser changes the role, city and active flag of a user. Users cannot change
+// their own role or deactivate themselves, so the last user administrator can't lock
+// everyone out. Only an admin grants the admin role or changes an admin account.
+func (p *UserProcessorImpl) UpdateUser(actorID, actorRole, id string, role, city *string, active *bool) (models.User, error) {
+	if role == nil && city == nil && active == nil {
+		return models.User{}, apperrors.ErrNothingToUpdate
+	}
+	if role != nil && !models.IsValidRole(*role) {
+		return models.User{}, apperrors.ErrInvalidRole
+	}
+	if actorID == id && (role != nil || (active != nil && !*active)) {
+		return models.User{}, apperrors.ErrCannotModifySelf
+	}
+	if actorRole != models.RoleAdmin && role != nil && *role == models.RoleAdmin {
+		return models.User{}, apperrors.ErrAdminOnly
+	}
+	if err := p.checkAdminAccount(actorRole, id); err != nil {
+		return models.User{}, err
+	}
+
+	user, err := p.userRepo.UpdateUser(id, role, city, active)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, apperrors.ErrUserNotFound
+		}
+		if errors.Is(err, apperrors.ErrCityAssignment) {
+			return models.User{}, apperrors.ErrCityAssignment
+		}
+		return models.User{}, apperrors.Internal("failed to update user", err)
+	}
+	return user, nil
+}
+
+// DeleteUser deletes a user, an admin account is only deleted by an admin.
+func (p *UserProcessorImpl) DeleteUser(actorID, actorRole, id string) error {
+	if actorID == id {
+		return apperrors.ErrCannotModifySelf
+	}
+	if err := p.checkAdminAccount(actorRole, id); err != nil {
+		return err
+	}
+
+	if err := p.userRepo.DeleteUser(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperrors.ErrUserNotFound
+		}
+		return apperrors.Internal("failed to delete user", err)
+	}
+	return nil
+}
+
+// checkAdminAccount keeps users other than admins away from admin accounts, so the
+// user:manage permission of a moderator doesn't reach the admins.
+func (p *UserProcessorImpl) checkAdminAccount(actorRole, id string) error {
+	if actorRole == models.RoleAdmin {
+		return nil
+	}
+	status, err := p.userRepo.GetUserStatus(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperrors.ErrUserNotFound
+		}
+		return apperrors.Internal("failed to find user", err)
+	}
+	if status.Role == models.RoleAdmin {
+		return apperrors.ErrAdminOnly
+	}
+	return nil
+}
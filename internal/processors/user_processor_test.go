@@ -0,0 +1,180 @@
+package processors
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
+)
+
+type MockUserRepository struct {
+	mock.Mock
+}
+
+func (m *MockUserRepository) ListUsers(role string, page, limit int) ([]models.User, error) {
+	args := m.Called(role, page, limit)
+	return args.Get(0).([]models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) UpdateUser(id string, role, city *string, active *bool) (models.User, error) {
+	args := m.Called(id, role, city, active)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) DeleteUser(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) GetUserStatus(id string) (models.UserStatus, error) {
+	args := m.Called(id)
+	return args.Get(0).(models.UserStatus), args.Error(1)
+}
+
+func stringPtr(s string) *string { return &s }
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestUserProcessor_ListUsers(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	processor := NewUserProcessor(mockRepo)
+
+	expected := []models.User{{ID: "user1", Email: "a@example.com", Role: "employee", Active: true}}
+	mockRepo.On("ListUsers", "employee", 1, 10).Return(expected, nil)
+
+	users, err := processor.ListUsers("employee", 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, users)
+
+	mockRepo.On("ListUsers", "", 2, 10).Return([]models.User(nil), errors.New("db error"))
+	_, err = processor.ListUsers("", 2, 10)
+	assert.ErrorIs(t, err, apperrors.Internal("", nil))
+}
+
+func TestUserProcessor_UpdateUser(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		processor := NewUserProcessor(mockRepo)
+
+		role := stringPtr("moderator")
+		mockRepo.On("GetUserStatus", "user2").Return(models.UserStatus{Role: "employee", Active: true}, nil)
+		mockRepo.On("UpdateUser", "user2", role, (*string)(nil), (*bool)(nil)).Return(models.User{ID: "user2", Role: "moderator", Active: true}, nil)
+
+		user, err := processor.UpdateUser("user1", "moderator", "user2", role, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "moderator", user.Role)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		processor := NewUserProcessor(mockRepo)
+
+		active := boolPtr(false)
+		mockRepo.On("UpdateUser", "user2", (*string)(nil), (*string)(nil), active).Return(models.User{}, sql.ErrNoRows)
+
+		_, err := processor.UpdateUser("user1", "admin", "user2", nil, nil, active)
+		assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+	})
+
+	t.Run("city manager without a city", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		processor := NewUserProcessor(mockRepo)
+
+		role := stringPtr("city_manager")
+		mockRepo.On("UpdateUser", "user2", role, (*string)(nil), (*bool)(nil)).Return(models.User{}, apperrors.ErrCityAssignment)
+
+		_, err := processor.UpdateUser("user1", "admin", "user2", role, nil, nil)
+		assert.ErrorIs(t, err, apperrors.ErrCityAssignment)
+	})
+
+	t.Run("admin promotes a moderator to admin", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		processor := NewUserProcessor(mockRepo)
+
+		role := stringPtr("admin")
+		mockRepo.On("UpdateUser", "user2", role, (*string)(nil), (*bool)(nil)).Return(models.User{ID: "user2", Role: "admin", Active: true}, nil)
+
+		user, err := processor.UpdateUser("user1", "admin", "user2", role, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "admin", user.Role)
+		mockRepo.AssertNotCalled(t, "GetUserStatus", mock.Anything)
+	})
+
+	// A moderator has user:manage but must not reach admin accounts or the admin role
+	moderatorTests := []struct {
+		name       string
+		targetRole string
+		role       *string
+		active     *bool
+	}{
+		{"grant admin", "moderator", stringPtr("admin"), nil},
+		{"demote an admin", "admin", stringPtr("employee"), nil},
+		{"deactivate an admin", "admin", nil, boolPtr(false)},
+	}
+
+	for _, tt := range moderatorTests {
+		t.Run("moderator cannot "+tt.name, func(t *testing.T) {
+			mockRepo := new(MockUserRepository)
+			processor := NewUserProcessor(mockRepo)
+			mockRepo.On("GetUserStatus", "user2").Return(models.UserStatus{Role: tt.targetRole, Active: true}, nil).Maybe()
+
+			_, err := processor.UpdateUser("user1", "moderator", "user2", tt.role, nil, tt.active)
+			assert.ErrorIs(t, err, apperrors.ErrAdminOnly)
+			mockRepo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+
+	tests := []struct {
+		name     string
+		actorID  string
+		role     *string
+		active   *bool
+		expected error
+	}{
+		{"nothing to update", "user1", nil, nil, apperrors.ErrNothingToUpdate},
+		{"invalid role", "user1", stringPtr(""), nil, apperrors.ErrInvalidRole},
+		{"demote self", "user2", stringPtr("employee"), nil, apperrors.ErrCannotModifySelf},
+		{"change own role", "user2", stringPtr("admin"), nil, apperrors.ErrCannotModifySelf},
+		{"deactivate self", "user2", nil, boolPtr(false), apperrors.ErrCannotModifySelf},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockUserRepository)
+			processor := NewUserProcessor(mockRepo)
+
+			_, err := processor.UpdateUser(tt.actorID, "admin", "user2", tt.role, nil, tt.active)
+			assert.ErrorIs(t, err, tt.expected)
+			mockRepo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUserProcessor_DeleteUser(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	processor := NewUserProcessor(mockRepo)
+
+	mockRepo.On("DeleteUser", "user2").Return(nil)
+	assert.NoError(t, processor.DeleteUser("user1", "admin", "user2"))
+
+	mockRepo.On("DeleteUser", "user3").Return(sql.ErrNoRows)
+	assert.ErrorIs(t, processor.DeleteUser("user1", "admin", "user3"), apperrors.ErrUserNotFound)
+
+	assert.ErrorIs(t, processor.DeleteUser("user1", "admin", "user1"), apperrors.ErrCannotModifySelf)
+	mockRepo.AssertNotCalled(t, "DeleteUser", "user1")
+
+	// Moderators delete other accounts but not admins
+	mockRepo.On("GetUserStatus", "user4").Return(models.UserStatus{Role: "employee", Active: true}, nil)
+	mockRepo.On("DeleteUser", "user4").Return(nil)
+	assert.NoError(t, processor.DeleteUser("user1", "moderator", "user4"))
+
+	mockRepo.On("GetUserStatus", "user5").Return(models.UserStatus{Role: "admin", Active: true}, nil)
+	assert.ErrorIs(t, processor.DeleteUser("user1", "moderator", "user5"), apperrors.ErrAdminOnly)
+	mockRepo.AssertNotCalled(t, "DeleteUser", "user5")
+}
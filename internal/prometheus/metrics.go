@@ -1,6 +1,7 @@
 package prometheus
 
 import (
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -18,6 +19,21 @@ var (
 		Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 2, 5},
 	}, []string{"method", "path"})
 
+	ReadQueries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_read_queries_total",
+		Help: "Total number of read-only queries by the database they were sent to",
+	}, []string{"target"})
+
+	ReplicaLag = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "db_replica_lag_seconds",
+		Help: "Replication lag of the read replica at the last check",
+	})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pvz_cache_requests_total",
+		Help: "Total number of PVZ reads served from the cache (hit) or built (miss)",
+	}, []string{"result"})
+
 	// Бизнесовые метрики
 	PickupPointsCreated = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "pickup_points_created_total",
@@ -33,4 +49,87 @@ var (
 		Name: "products_added_total",
 		Help: "Total number of added products",
 	})
+
+	CapacityOverflows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pvz_capacity_overflows_total",
+		Help: "Total number of product additions over the capacity of a PVZ by what was done with them",
+	}, []string{"action"})
+
+	CapacityUtilization = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pvz_capacity_utilization",
+		Help: "Stored products of a PVZ with a capacity divided by the capacity at the last refresh",
+	}, []string{"pvz_id", "city"})
+
+	// Метрики безопасности
+	FailedLogins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "failed_logins_total",
+		Help: "Total number of failed login attempts",
+	}, []string{"reason"})
+
+	LoginLockouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "login_lockouts_total",
+		Help: "Total number of temporary lockouts after repeated failed logins",
+	}, []string{"scope"})
 )
+
+// RegisterDBStats exports the statistics of the connection pool as pgxpool_* metrics
+// labeled with name: open, acquired and idle connections, acquires that had to wait
+// for a free connection and connections closed by the pool limits.
+func RegisterDBStats(pool *pgxpool.Pool, name string) {
+	prometheus.MustRegister(newPoolStatsCollector(pool, name))
+}
+
+type poolStatsCollector struct {
+	pool *pgxpool.Pool
+
+	maxConns          *prometheus.Desc
+	totalConns        *prometheus.Desc
+	acquiredConns     *prometheus.Desc
+	idleConns         *prometheus.Desc
+	acquireCount      *prometheus.Desc
+	acquireDuration   *prometheus.Desc
+	emptyAcquireCount *prometheus.Desc
+	canceledAcquires  *prometheus.Desc
+	newConns          *prometheus.Desc
+	lifetimeDestroys  *prometheus.Desc
+	idleDestroys      *prometheus.Desc
+}
+
+func newPoolStatsCollector(pool *pgxpool.Pool, name string) *poolStatsCollector {
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc("pgxpool_"+metric, help, nil, prometheus.Labels{"db_name": name})
+	}
+	return &poolStatsCollector{
+		pool:              pool,
+		maxConns:          desc("max_conns", "Maximum size of the pool."),
+		totalConns:        desc("total_conns", "Number of open connections, acquired, idle and being opened."),
+		acquiredConns:     desc("acquired_conns", "Number of connections in use."),
+		idleConns:         desc("idle_conns", "Number of idle connections."),
+		acquireCount:      desc("acquire_count_total", "Number of successful connection acquires."),
+		acquireDuration:   desc("acquire_duration_seconds_total", "Total time spent acquiring connections."),
+		emptyAcquireCount: desc("empty_acquire_count_total", "Number of acquires that had to wait for a free connection."),
+		canceledAcquires:  desc("canceled_acquire_count_total", "Number of acquires canceled by their context."),
+		newConns:          desc("new_conns_total", "Number of connections opened."),
+		lifetimeDestroys:  desc("max_lifetime_destroy_count_total", "Number of connections closed by the maximum lifetime."),
+		idleDestroys:      desc("max_idle_destroy_count_total", "Number of connections closed by the maximum idle time."),
+	}
+}
+
+func (c *poolStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquires, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.newConns, prometheus.CounterValue, float64(stat.NewConnsCount()))
+	ch <- prometheus.MustNewConstMetric(c.lifetimeDestroys, prometheus.CounterValue, float64(stat.MaxLifetimeDestroyCount()))
+	ch <- prometheus.MustNewConstMetric(c.idleDestroys, prometheus.CounterValue, float64(stat.MaxIdleDestroyCount()))
+}
@@ -12,6 +12,12 @@ func PrometheusMiddleware() fiber.Handler {
 		start := time.Now()
 
 		err := c.Next()
+		if err != nil {
+			// Let the error handler write the response so the real status is recorded
+			if handlerErr := c.App().ErrorHandler(c, err); handlerErr != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
 
 		duration := time.Since(start).Seconds()
 		status := strconv.Itoa(c.Response().StatusCode())
@@ -28,6 +34,6 @@ func PrometheusMiddleware() fiber.Handler {
 			path,
 		).Observe(duration)
 
-		return err
+		return nil
 	}
 }
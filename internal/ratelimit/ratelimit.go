@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limit is a request budget: Requests per Window for every client. Name separates the
+// counters of different budgets, zero Requests disables the limit.
+type Limit struct {
+	Name     string
+	Requests int
+	Window   time.Duration
+}
+
+// Store counts requests per key in fixed windows. Hit registers a request and returns
+// the number of requests in the current window including this one and when the window
+// ends. A store shared between instances gives a limit for the whole deployment.
+type Store interface {
+	Hit(key string, window time.Duration) (int, time.Time, error)
+}
+
+type counter struct {
+	hits    int
+	resetAt time.Time
+}
+
+// MemoryStore keeps counters in the memory of one instance, each instance gets its
+// own budget.
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]counter
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]counter), lastSweep: time.Now()}
+}
+
+func (s *MemoryStore) Hit(key string, window time.Duration) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now, window)
+
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.resetAt) {
+		c = counter{resetAt: now.Add(window)}
+	}
+	c.hits++
+	s.counters[key] = c
+	return c.hits, c.resetAt, nil
+}
+
+// sweep drops finished windows at most once per window, so clients that stopped
+// sending requests don't stay in memory.
+func (s *MemoryStore) sweep(now time.Time, window time.Duration) {
+	if now.Sub(s.lastSweep) < window {
+		return
+	}
+	for key, c := range s.counters {
+		if !now.Before(c.resetAt) {
+			delete(s.counters, key)
+		}
+	}
+	s.lastSweep = now
+}
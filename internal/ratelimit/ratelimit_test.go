@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore_CountsPerKeyAndWindow(t *testing.T) {
+	store := NewMemoryStore()
+
+	for expected := 1; expected <= 3; expected++ {
+		hits, resetAt, err := store.Hit("write:user:1", time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, hits)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), resetAt, time.Second)
+	}
+
+	hits, _, err := store.Hit("write:user:2", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, hits)
+}
+
+func TestMemoryStore_StartsNewWindow(t *testing.T) {
+	store := NewMemoryStore()
+
+	store.Hit("auth:ip:127.0.0.1", 20*time.Millisecond)
+	store.Hit("auth:ip:127.0.0.1", 20*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+
+	hits, _, err := store.Hit("auth:ip:127.0.0.1", 20*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, hits)
+}
+
+func TestMemoryStore_SweepsFinishedWindows(t *testing.T) {
+	store := NewMemoryStore()
+
+	store.Hit("auth:ip:10.0.0.1", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	store.Hit("auth:ip:10.0.0.2", 10*time.Millisecond)
+
+	assert.Len(t, store.counters, 1)
+}
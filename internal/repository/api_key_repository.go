@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"pvzService/internal/models"
+)
+
+// APIKeyRepository stores API keys by their hash only.
+type APIKeyRepository interface {
+	CreateAPIKey(name, keyHash, prefix string, permissions []string, createdBy string, expiresAt *time.Time) (models.APIKey, error)
+	ListAPIKeys(page, limit int) ([]models.APIKey, error)
+	RevokeAPIKey(id string) error
+	UseAPIKey(keyHash string) (models.APIKey, error)
+}
+
+type APIKeyRepositoryImpl struct {
+	db DB
+}
+
+func NewAPIKeyRepository(db DB) *APIKeyRepositoryImpl {
+	return &APIKeyRepositoryImpl{db: db}
+}
+
+const apiKeyColumns = "id, name, prefix, permissions, created_by, created_at, expires_at, last_used_at, revoked_at"
+
+func scanAPIKey(row pgx.Row) (models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Permissions, &key.CreatedBy,
+		&key.CreatedAt, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt)
+	return key, err
+}
+
+func (r *APIKeyRepositoryImpl) CreateAPIKey(name, keyHash, prefix string, permissions []string, createdBy string, expiresAt *time.Time) (models.APIKey, error) {
+	return scanAPIKey(r.db.QueryRow(context.Background(), `
+        INSERT INTO api_keys (name, key_hash, prefix, permissions, created_by, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING `+apiKeyColumns,
+		name, keyHash, prefix, permissions, createdBy, expiresAt))
+}
+
+// ListAPIKeys returns keys including revoked and expired ones, newest first.
+func (r *APIKeyRepositoryImpl) ListAPIKeys(page, limit int) ([]models.APIKey, error) {
+	rows, err := r.db.Query(context.Background(), "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY created_at DESC, id LIMIT $1 OFFSET $2",
+		limit, (page-1)*limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey returns sql.ErrNoRows for unknown and already revoked keys.
+func (r *APIKeyRepositoryImpl) RevokeAPIKey(id string) error {
+	res, err := r.db.Exec(context.Background(), "UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// apiKeyUseInterval limits how often last_used_at of a key is rewritten, so that busy
+// keys do not turn every request into a row update.
+const apiKeyUseInterval = time.Minute
+
+// UseAPIKey records the use of an active key and returns it with the permissions its
+// creator still has and the city the creator is limited to. sql.ErrNoRows is returned for unknown, revoked and expired keys and
+// for keys of deactivated and deleted users. last_used_at is updated at most once per
+// apiKeyUseInterval.
+func (r *APIKeyRepositoryImpl) UseAPIKey(keyHash string) (models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.QueryRow(context.Background(), `
+        WITH used AS (
+            SELECT k.id AS key_id, u.role, u.city
+            FROM api_keys k JOIN users u ON u.id = k.created_by
+            WHERE k.key_hash = $1 AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > NOW())
+                AND u.is_active
+        ), touched AS (
+            UPDATE api_keys SET last_used_at = NOW()
+            WHERE id = (SELECT key_id FROM used)
+                AND (last_used_at IS NULL OR last_used_at <= NOW() - make_interval(secs => $2))
+            RETURNING last_used_at
+        )
+        SELECT k.id, k.name, k.prefix,
+            ARRAY(SELECT p FROM unnest(k.permissions) p
+                WHERE p IN (SELECT permission FROM role_permissions WHERE role = used.role)),
+            k.created_by, k.created_at, k.expires_at,
+            COALESCE((SELECT last_used_at FROM touched), k.last_used_at), k.revoked_at, used.city
+        FROM api_keys k JOIN used ON used.key_id = k.id`,
+		keyHash, apiKeyUseInterval.Seconds(),
+	).Scan(&key.ID, &key.Name, &key.Prefix, &key.Permissions, &key.CreatedBy,
+		&key.CreatedAt, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.CreatorCity)
+	return key, err
+}
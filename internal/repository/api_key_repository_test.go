@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// ptr returns a pointer to v, pgxmock scans row values into nullable columns as is.
+func ptr[T any](v T) *T {
+	return &v
+}
+
+var apiKeyTestColumns = []string{"id", "name", "prefix", "permissions", "created_by", "created_at", "expires_at", "last_used_at", "revoked_at"}
+
+func TestAPIKeyRepository_CreateAPIKey(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewAPIKeyRepository(mock)
+	createdBy := "user1"
+	expiresAt := time.Now().Add(time.Hour)
+
+	mock.ExpectQuery("INSERT INTO api_keys").
+		WithArgs("reports", "hash", "pvz_abcdefgh", []string{"pvz:read"}, "user1", &expiresAt).
+		WillReturnRows(pgxmock.NewRows(apiKeyTestColumns).
+			AddRow("key1", "reports", "pvz_abcdefgh", []string{"pvz:read"}, &createdBy, time.Now(), &expiresAt, nil, nil))
+
+	key, err := repo.CreateAPIKey("reports", "hash", "pvz_abcdefgh", []string{"pvz:read"}, "user1", &expiresAt)
+	assert.NoError(t, err)
+	assert.Equal(t, "key1", key.ID)
+	assert.Equal(t, []string{"pvz:read"}, key.Permissions)
+	assert.Equal(t, "user1", *key.CreatedBy)
+	assert.Equal(t, expiresAt, *key.ExpiresAt)
+	assert.Nil(t, key.LastUsedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPIKeyRepository_ListAPIKeys(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewAPIKeyRepository(mock)
+	usedAt := time.Now()
+
+	mock.ExpectQuery("SELECT .* FROM api_keys ORDER BY created_at DESC, id LIMIT \\$1 OFFSET \\$2").
+		WithArgs(10, 10).
+		WillReturnRows(pgxmock.NewRows(apiKeyTestColumns).
+			AddRow("key1", "reports", "pvz_abcdefgh", []string{"pvz:read", "user:read"}, nil, time.Now(), nil, &usedAt, &usedAt))
+
+	keys, err := repo.ListAPIKeys(2, 10)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, []string{"pvz:read", "user:read"}, keys[0].Permissions)
+	assert.Nil(t, keys[0].CreatedBy)
+	assert.NotNil(t, keys[0].RevokedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPIKeyRepository_RevokeAPIKey(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewAPIKeyRepository(mock)
+
+	mock.ExpectExec("UPDATE api_keys SET revoked_at = NOW\\(\\) WHERE id = \\$1 AND revoked_at IS NULL").
+		WithArgs("key1").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("UPDATE api_keys SET revoked_at").
+		WithArgs("key1").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	assert.NoError(t, repo.RevokeAPIKey("key1"))
+	assert.ErrorIs(t, repo.RevokeAPIKey("key1"), sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPIKeyRepository_UseAPIKey(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewAPIKeyRepository(mock)
+	createdBy, usedAt, city := "user1", time.Now(), "Казань"
+
+	mock.ExpectQuery("SELECT k.id AS key_id, u.role, u.city FROM api_keys k JOIN users u ON u.id = k.created_by WHERE k.key_hash = \\$1 AND k.revoked_at IS NULL .* AND u.is_active "+
+		".*UPDATE api_keys SET last_used_at = NOW\\(\\) .* last_used_at <= NOW\\(\\) - make_interval\\(secs => \\$2\\)"+
+		".*SELECT permission FROM role_permissions WHERE role = used.role").
+		WithArgs("hash", float64(60)).
+		WillReturnRows(pgxmock.NewRows(append(apiKeyTestColumns, "city")).
+			AddRow("key1", "reports", "pvz_abcdefgh", []string{"pvz:read"}, &createdBy, time.Now(), nil, &usedAt, nil, &city))
+	mock.ExpectQuery("WITH used AS").
+		WithArgs("revoked", float64(60)).
+		WillReturnError(sql.ErrNoRows)
+
+	key, err := repo.UseAPIKey("hash")
+	assert.NoError(t, err)
+	assert.Equal(t, "key1", key.ID)
+	assert.NotNil(t, key.LastUsedAt)
+	assert.Equal(t, &city, key.CreatorCity)
+
+	_, err = repo.UseAPIKey("revoked")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
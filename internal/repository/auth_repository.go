@@ -1,52 +1,128 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-	"errors"
 	"github.com/google/uuid"
-	"github.com/lib/pq"
+	"github.com/jackc/pgx/v5"
+	"time"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
 )
 
 type AuthRepository interface {
 	CreateUser(email, hashedPassword, role string) (string, error)
-	FindUserByEmail(email string) (string, string, string, error)
-	FindUserByRole(role string) (string, error)
+	FindUserByEmail(email string) (models.UserCredentials, error)
+	FindUserByID(id string) (models.UserCredentials, error)
+	RegisterFailedLogin(userID string, maxAttempts int, window, lockout time.Duration) (bool, error)
+	ResetFailedLogins(userID string) error
+	UpdatePassword(userID, hashedPassword string, changedAt time.Time) error
 }
 
 type AuthRepositoryImpl struct {
-	db *sql.DB
+	db DB
 }
 
-func NewAuthRepository(db *sql.DB) AuthRepository {
+func NewAuthRepository(db DB) AuthRepository {
 	return &AuthRepositoryImpl{db: db}
 }
 
 func (r *AuthRepositoryImpl) CreateUser(email, hashedPassword, role string) (string, error) {
 	userID := uuid.New().String()
-	_, err := r.db.Exec(
-		"INSERT INTO users (id, email, password, role) VALUES ($1, $2, $3, $4)",
+	_, err := r.db.Exec(context.Background(), "INSERT INTO users (id, email, password, role) VALUES ($1, $2, $3, $4)",
 		userID, email, hashedPassword, role,
 	)
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-			return "", errors.New("email already exists")
+		if isPgError(err, pgUniqueViolation) {
+			return "", apperrors.ErrEmailAlreadyExists
 		}
 		return "", err
 	}
 	return userID, nil
 }
 
-func (r *AuthRepositoryImpl) FindUserByEmail(email string) (string, string, string, error) {
-	var userID, hashedPassword, role string
-	err := r.db.QueryRow(
-		"SELECT id, password, role FROM users WHERE email = $1",
-		email,
-	).Scan(&userID, &hashedPassword, &role)
-	return userID, hashedPassword, role, err
+const credentialColumns = "id, password, role, is_active, locked_until"
+
+func scanCredentials(row pgx.Row) (models.UserCredentials, error) {
+	var user models.UserCredentials
+	if err := row.Scan(&user.ID, &user.PasswordHash, &user.Role, &user.Active, &user.LockedUntil); err != nil {
+		return models.UserCredentials{}, err
+	}
+	return user, nil
+}
+
+func (r *AuthRepositoryImpl) FindUserByEmail(email string) (models.UserCredentials, error) {
+	return scanCredentials(r.db.QueryRow(context.Background(), "SELECT "+credentialColumns+" FROM users WHERE email = $1", email))
+}
+
+func (r *AuthRepositoryImpl) FindUserByID(id string) (models.UserCredentials, error) {
+	return scanCredentials(r.db.QueryRow(context.Background(), "SELECT "+credentialColumns+" FROM users WHERE id = $1", id))
+}
+
+// RegisterFailedLogin counts a failed attempt within the window and locks the account
+// once maxAttempts is reached. It reports whether the account got locked.
+func (r *AuthRepositoryImpl) RegisterFailedLogin(userID string, maxAttempts int, window, lockout time.Duration) (bool, error) {
+	var locked bool
+	err := r.db.QueryRow(context.Background(), `
+        UPDATE users SET
+            failed_login_attempts = CASE
+                WHEN `+attemptsInWindow("failed_login_attempts", "last_failed_login_at")+` >= $2 THEN 0
+                ELSE `+attemptsInWindow("failed_login_attempts", "last_failed_login_at")+`
+            END,
+            locked_until = CASE
+                WHEN `+attemptsInWindow("failed_login_attempts", "last_failed_login_at")+` >= $2 THEN NOW() + make_interval(secs => $4)
+                ELSE locked_until
+            END,
+            last_failed_login_at = NOW()
+        WHERE id = $1
+        RETURNING COALESCE(locked_until > NOW(), FALSE)`,
+		userID, maxAttempts, window.Seconds(), lockout.Seconds()).Scan(&locked)
+	return locked, err
+}
+
+func (r *AuthRepositoryImpl) ResetFailedLogins(userID string) error {
+	_, err := r.db.Exec(context.Background(), "UPDATE users SET failed_login_attempts = 0, last_failed_login_at = NULL, locked_until = NULL WHERE id = $1",
+		userID)
+	return err
+}
+
+// UpdatePassword stores the new hash, lifts a lockout and drops outstanding reset
+// tokens. changedAt comes from the clock of the service that also sets the token iat,
+// and is truncated to seconds to compare with it, tokens issued earlier are rejected by
+// AuthMiddleware.
+func (r *AuthRepositoryImpl) UpdatePassword(userID, hashedPassword string, changedAt time.Time) error {
+	ctx := context.Background()
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	res, err := tx.Exec(ctx, `
+        UPDATE users SET
+            password = $2,
+            password_changed_at = $3,
+            failed_login_attempts = 0,
+            last_failed_login_at = NULL,
+            locked_until = NULL
+        WHERE id = $1`,
+		userID, hashedPassword, changedAt.Truncate(time.Second))
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM password_reset_tokens WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
-func (r *AuthRepositoryImpl) FindUserByRole(role string) (string, error) {
-	var userID string
-	err := r.db.QueryRow("SELECT id FROM users WHERE role = $1 LIMIT 1", role).Scan(&userID)
-	return userID, err
+// attemptsInWindow is the failure count including the current attempt; failures older
+// than the window ($3 seconds) are forgotten.
+func attemptsInWindow(counter, lastFailedAt string) string {
+	return "(CASE WHEN " + lastFailedAt + " > NOW() - make_interval(secs => $3) THEN " + counter + " + 1 ELSE 1 END)"
 }
@@ -4,23 +4,24 @@ import (
 	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
-	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pashagolub/pgxmock/v4"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestAuthRepository_CreateUser_Success(t *testing.T) {
-	db, mock, err := sqlmock.New()
+	mock, err := pgxmock.NewPool()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
-	defer db.Close()
+	defer mock.Close()
 
-	repo := NewAuthRepository(db)
+	repo := NewAuthRepository(mock)
 
 	mock.ExpectExec("INSERT INTO users").
-		WithArgs(sqlmock.AnyArg(), "test@example.com", sqlmock.AnyArg(), "employee").
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		WithArgs(pgxmock.AnyArg(), "test@example.com", pgxmock.AnyArg(), "employee").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
 	_, err = repo.CreateUser("test@example.com", "hashedpassword", "employee")
 	assert.NoError(t, err)
@@ -28,16 +29,16 @@ func TestAuthRepository_CreateUser_Success(t *testing.T) {
 }
 
 func TestAuthRepository_CreateUser_EmailExists(t *testing.T) {
-	db, mock, err := sqlmock.New()
+	mock, err := pgxmock.NewPool()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
-	defer db.Close()
+	defer mock.Close()
 
-	repo := NewAuthRepository(db)
+	repo := NewAuthRepository(mock)
 
 	mock.ExpectExec("INSERT INTO users").
-		WithArgs(sqlmock.AnyArg(), "exists@example.com", sqlmock.AnyArg(), "employee").
+		WithArgs(pgxmock.AnyArg(), "exists@example.com", pgxmock.AnyArg(), "employee").
 		WillReturnError(errors.New("email already exists"))
 
 	_, err = repo.CreateUser("exists@example.com", "hashedpassword", "employee")
@@ -47,86 +48,149 @@ func TestAuthRepository_CreateUser_EmailExists(t *testing.T) {
 }
 
 func TestAuthRepository_FindUserByEmail_Success(t *testing.T) {
-	db, mock, err := sqlmock.New()
+	mock, err := pgxmock.NewPool()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
-	defer db.Close()
+	defer mock.Close()
 
-	repo := NewAuthRepository(db)
+	repo := NewAuthRepository(mock)
 
 	expectedID := "user123"
 	expectedPassword := "hashedpassword"
 	expectedRole := "employee"
 
-	mock.ExpectQuery("SELECT id, password, role FROM users WHERE email =").
+	lockedUntil := time.Now().Add(time.Minute)
+
+	mock.ExpectQuery("SELECT id, password, role, is_active, locked_until FROM users WHERE email =").
 		WithArgs("test@example.com").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "password", "role"}).
-			AddRow(expectedID, expectedPassword, expectedRole))
+		WillReturnRows(pgxmock.NewRows([]string{"id", "password", "role", "is_active", "locked_until"}).
+			AddRow(expectedID, expectedPassword, expectedRole, true, &lockedUntil))
 
-	id, password, role, err := repo.FindUserByEmail("test@example.com")
+	user, err := repo.FindUserByEmail("test@example.com")
 	assert.NoError(t, err)
-	assert.Equal(t, expectedID, id)
-	assert.Equal(t, expectedPassword, password)
-	assert.Equal(t, expectedRole, role)
+	assert.Equal(t, expectedID, user.ID)
+	assert.Equal(t, expectedPassword, user.PasswordHash)
+	assert.Equal(t, expectedRole, user.Role)
+	assert.True(t, user.Active)
+	assert.Equal(t, lockedUntil, *user.LockedUntil)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestAuthRepository_FindUserByEmail_NotFound(t *testing.T) {
-	db, mock, err := sqlmock.New()
+	mock, err := pgxmock.NewPool()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
-	defer db.Close()
+	defer mock.Close()
 
-	repo := NewAuthRepository(db)
+	repo := NewAuthRepository(mock)
 
-	mock.ExpectQuery("SELECT id, password, role FROM users WHERE email =").
+	mock.ExpectQuery("SELECT id, password, role, is_active, locked_until FROM users WHERE email =").
 		WithArgs("nonexistent@example.com").
 		WillReturnError(sql.ErrNoRows)
 
-	_, _, _, err = repo.FindUserByEmail("nonexistent@example.com")
+	_, err = repo.FindUserByEmail("nonexistent@example.com")
 	assert.Error(t, err)
 	assert.True(t, errors.Is(err, sql.ErrNoRows))
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestAuthRepository_FindUserByRole_Success(t *testing.T) {
-	db, mock, err := sqlmock.New()
+func TestAuthRepository_RegisterFailedLogin(t *testing.T) {
+	mock, err := pgxmock.NewPool()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
-	defer db.Close()
+	defer mock.Close()
 
-	repo := NewAuthRepository(db)
+	repo := NewAuthRepository(mock)
 
-	expectedID := "user123"
+	mock.ExpectQuery("UPDATE users SET").
+		WithArgs("user123", 5, float64(900), float64(3600)).
+		WillReturnRows(pgxmock.NewRows([]string{"locked"}).AddRow(true))
+
+	locked, err := repo.RegisterFailedLogin("user123", 5, 15*time.Minute, time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, locked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthRepository_ResetFailedLogins(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewAuthRepository(mock)
+
+	mock.ExpectExec("UPDATE users SET failed_login_attempts = 0").
+		WithArgs("user123").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	assert.NoError(t, repo.ResetFailedLogins("user123"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthRepository_FindUserByID(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewAuthRepository(mock)
 
-	mock.ExpectQuery("SELECT id FROM users WHERE role =").
-		WithArgs("employee").
-		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(expectedID))
+	mock.ExpectQuery("SELECT id, password, role, is_active, locked_until FROM users WHERE id =").
+		WithArgs("user123").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "password", "role", "is_active", "locked_until"}).
+			AddRow("user123", "hashedpassword", "employee", true, nil))
 
-	id, err := repo.FindUserByRole("employee")
+	user, err := repo.FindUserByID("user123")
 	assert.NoError(t, err)
-	assert.Equal(t, expectedID, id)
+	assert.Equal(t, "hashedpassword", user.PasswordHash)
+	assert.Nil(t, user.LockedUntil)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestAuthRepository_FindUserByRole_NotFound(t *testing.T) {
-	db, mock, err := sqlmock.New()
+func TestAuthRepository_UpdatePassword(t *testing.T) {
+	mock, err := pgxmock.NewPool()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
-	defer db.Close()
+	defer mock.Close()
 
-	repo := NewAuthRepository(db)
+	repo := NewAuthRepository(mock)
 
-	mock.ExpectQuery("SELECT id FROM users WHERE role =").
-		WithArgs("moderator").
-		WillReturnError(sql.ErrNoRows)
+	changedAt := time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET (.+) password_changed_at = \\$3").
+		WithArgs("user123", "newhash", changedAt).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("DELETE FROM password_reset_tokens WHERE user_id =").
+		WithArgs("user123").
+		WillReturnResult(pgxmock.NewResult("DELETE", 2))
+	mock.ExpectCommit()
 
-	_, err = repo.FindUserByRole("moderator")
-	assert.Error(t, err)
-	assert.True(t, errors.Is(err, sql.ErrNoRows))
+	assert.NoError(t, repo.UpdatePassword("user123", "newhash", changedAt.Add(900*time.Millisecond)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthRepository_UpdatePassword_UnknownUser(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewAuthRepository(mock)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET").
+		WithArgs("missing", "newhash", pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectRollback()
+
+	assert.ErrorIs(t, repo.UpdatePassword("missing", "newhash", time.Now()), sql.ErrNoRows)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
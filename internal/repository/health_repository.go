@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+)
+
+type HealthRepository interface {
+	Ping(ctx context.Context) error
+	SchemaVersion(ctx context.Context) (int, error)
+}
+
+type HealthRepositoryImpl struct {
+	db DB
+}
+
+func NewHealthRepository(db DB) *HealthRepositoryImpl {
+	return &HealthRepositoryImpl{db: db}
+}
+
+func (r *HealthRepositoryImpl) Ping(ctx context.Context) error {
+	return r.db.Ping(ctx)
+}
+
+// SchemaVersion returns the latest applied migration, 0 for a database migrated before
+// migrations were recorded.
+func (r *HealthRepositoryImpl) SchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := r.db.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if isPgError(err, pgUndefinedTable) {
+		return 0, nil
+	}
+	return version, err
+}
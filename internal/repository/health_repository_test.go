@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthRepository_SchemaVersion(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewHealthRepository(mock)
+
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(version\\), 0\\) FROM schema_migrations").
+		WillReturnRows(pgxmock.NewRows([]string{"version"}).AddRow(11))
+
+	version, err := repo.SchemaVersion(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 11, version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHealthRepository_SchemaVersionWithoutMigrationsTable(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewHealthRepository(mock)
+
+	mock.ExpectQuery("FROM schema_migrations").
+		WillReturnError(&pgconn.PgError{Code: "42P01", Message: `relation "schema_migrations" does not exist`})
+
+	version, err := repo.SchemaVersion(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
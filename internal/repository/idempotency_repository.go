@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"pvzService/internal/models"
+)
+
+type IdempotencyRepository interface {
+	ReserveKey(key, userID, requestHash string, lease time.Duration, expiresAt time.Time) (time.Time, bool, error)
+	GetKey(key, userID string) (models.IdempotencyKey, error)
+	SaveResponse(key, userID string, lockedUntil time.Time, statusCode int, contentType string, body []byte) error
+	DeleteKey(key, userID string, lockedUntil time.Time) error
+	DeleteExpired() (int64, error)
+}
+
+type IdempotencyRepositoryImpl struct {
+	db DB
+}
+
+func NewIdempotencyRepository(db DB) *IdempotencyRepositoryImpl {
+	return &IdempotencyRepositoryImpl{db: db}
+}
+
+// ReserveKey claims the key for the user for the lease and returns when the lease ends,
+// the end identifies the holder in SaveResponse and DeleteKey. An expired record with
+// the same key is replaced, and so is an unfinished one whose lease has ended. A live
+// record is left untouched and false is returned.
+func (r *IdempotencyRepositoryImpl) ReserveKey(key, userID, requestHash string, lease time.Duration, expiresAt time.Time) (time.Time, bool, error) {
+	var lockedUntil time.Time
+	err := r.db.QueryRow(context.Background(), `
+        INSERT INTO idempotency_keys (key, user_id, request_hash, locked_until, expires_at)
+        VALUES ($1, $2, $3, NOW() + make_interval(secs => $4), $5)
+        ON CONFLICT (key, user_id) DO UPDATE SET
+            request_hash = EXCLUDED.request_hash,
+            status_code = NULL,
+            content_type = NULL,
+            response_body = NULL,
+            locked_until = EXCLUDED.locked_until,
+            created_at = NOW(),
+            expires_at = EXCLUDED.expires_at
+        WHERE idempotency_keys.expires_at <= NOW()
+            OR (idempotency_keys.status_code IS NULL AND idempotency_keys.locked_until <= NOW())
+        RETURNING locked_until`,
+		key, userID, requestHash, lease.Seconds(), expiresAt).Scan(&lockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return lockedUntil, true, nil
+}
+
+func (r *IdempotencyRepositoryImpl) GetKey(key, userID string) (models.IdempotencyKey, error) {
+	var (
+		record      models.IdempotencyKey
+		statusCode  *int
+		contentType *string
+		lockedUntil *time.Time
+	)
+
+	err := r.db.QueryRow(context.Background(), `
+        SELECT key, user_id, request_hash, status_code, content_type, response_body, locked_until, created_at, expires_at
+        FROM idempotency_keys
+        WHERE key = $1 AND user_id = $2 AND expires_at > NOW()`,
+		key, userID).
+		Scan(&record.Key, &record.UserID, &record.RequestHash, &statusCode, &contentType,
+			&record.ResponseBody, &lockedUntil, &record.CreatedAt, &record.ExpiresAt)
+	if err != nil {
+		return models.IdempotencyKey{}, err
+	}
+
+	if statusCode != nil {
+		record.StatusCode = *statusCode
+	}
+	if contentType != nil {
+		record.ContentType = *contentType
+	}
+	if lockedUntil != nil {
+		record.LockedUntil = *lockedUntil
+	}
+	return record, nil
+}
+
+// SaveResponse stores the response of the request holding the lease that ends at
+// lockedUntil, nothing is stored once a retry has taken the key over.
+func (r *IdempotencyRepositoryImpl) SaveResponse(key, userID string, lockedUntil time.Time, statusCode int, contentType string, body []byte) error {
+	_, err := r.db.Exec(context.Background(), `UPDATE idempotency_keys SET status_code = $1, content_type = $2, response_body = $3, locked_until = NULL
+		 WHERE key = $4 AND user_id = $5 AND locked_until = $6`,
+		statusCode, contentType, body, key, userID, lockedUntil)
+	return err
+}
+
+// DeleteKey releases the key held with the lease that ends at lockedUntil.
+func (r *IdempotencyRepositoryImpl) DeleteKey(key, userID string, lockedUntil time.Time) error {
+	_, err := r.db.Exec(context.Background(), "DELETE FROM idempotency_keys WHERE key = $1 AND user_id = $2 AND locked_until = $3", key, userID, lockedUntil)
+	return err
+}
+
+// DeleteExpired removes expired keys with their stored responses and returns how many
+// were removed.
+func (r *IdempotencyRepositoryImpl) DeleteExpired() (int64, error) {
+	res, err := r.db.Exec(context.Background(), "DELETE FROM idempotency_keys WHERE expires_at <= NOW()")
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected(), nil
+}
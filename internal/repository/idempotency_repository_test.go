@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyRepository_ReserveKey(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewIdempotencyRepository(mock)
+	expiresAt := time.Now().Add(time.Hour)
+	lockedUntil := time.Now().Add(time.Minute)
+
+	t.Run("new key", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO idempotency_keys (.+) OR \\(idempotency_keys.status_code IS NULL AND idempotency_keys.locked_until <= NOW\\(\\)\\)").
+			WithArgs("key-1", "user-1", "hash", 60.0, expiresAt).
+			WillReturnRows(pgxmock.NewRows([]string{"locked_until"}).AddRow(lockedUntil))
+
+		lease, reserved, err := repo.ReserveKey("key-1", "user-1", "hash", time.Minute, expiresAt)
+
+		assert.NoError(t, err)
+		assert.True(t, reserved)
+		assert.Equal(t, lockedUntil, lease)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("live key already exists", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO idempotency_keys").
+			WithArgs("key-1", "user-1", "hash", 60.0, expiresAt).
+			WillReturnRows(pgxmock.NewRows([]string{"locked_until"}))
+
+		_, reserved, err := repo.ReserveKey("key-1", "user-1", "hash", time.Minute, expiresAt)
+
+		assert.NoError(t, err)
+		assert.False(t, reserved)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO idempotency_keys").
+			WithArgs("key-1", "user-1", "hash", 60.0, expiresAt).
+			WillReturnError(sql.ErrConnDone)
+
+		_, _, err := repo.ReserveKey("key-1", "user-1", "hash", time.Minute, expiresAt)
+
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestIdempotencyRepository_GetKey(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewIdempotencyRepository(mock)
+	now := time.Now()
+	columns := []string{"key", "user_id", "request_hash", "status_code", "content_type", "response_body", "locked_until", "created_at", "expires_at"}
+
+	t.Run("completed request", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM idempotency_keys").
+			WithArgs("key-1", "user-1").
+			WillReturnRows(pgxmock.NewRows(columns).
+				AddRow("key-1", "user-1", "hash", ptr(201), ptr("application/json"), []byte(`{"id":"1"}`), nil, now, now.Add(time.Hour)))
+
+		record, err := repo.GetKey("key-1", "user-1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "hash", record.RequestHash)
+		assert.Equal(t, 201, record.StatusCode)
+		assert.Equal(t, "application/json", record.ContentType)
+		assert.Equal(t, []byte(`{"id":"1"}`), record.ResponseBody)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("request in progress", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM idempotency_keys").
+			WithArgs("key-1", "user-1").
+			WillReturnRows(pgxmock.NewRows(columns).
+				AddRow("key-1", "user-1", "hash", nil, nil, nil, ptr(now.Add(time.Minute)), now, now.Add(time.Hour)))
+
+		record, err := repo.GetKey("key-1", "user-1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, record.StatusCode)
+		assert.Equal(t, now.Add(time.Minute), record.LockedUntil)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM idempotency_keys").
+			WithArgs("key-1", "user-1").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetKey("key-1", "user-1")
+
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestIdempotencyRepository_SaveResponse(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewIdempotencyRepository(mock)
+
+	lockedUntil := time.Now()
+	mock.ExpectExec("UPDATE idempotency_keys SET (.+) AND locked_until = \\$6").
+		WithArgs(201, "application/json", []byte(`{}`), "key-1", "user-1", lockedUntil).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	err = repo.SaveResponse("key-1", "user-1", lockedUntil, 201, "application/json", []byte(`{}`))
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotencyRepository_DeleteKey(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewIdempotencyRepository(mock)
+
+	lockedUntil := time.Now()
+	mock.ExpectExec("DELETE FROM idempotency_keys").
+		WithArgs("key-1", "user-1", lockedUntil).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	err = repo.DeleteKey("key-1", "user-1", lockedUntil)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotencyRepository_DeleteExpired(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewIdempotencyRepository(mock)
+
+	mock.ExpectExec("DELETE FROM idempotency_keys WHERE expires_at <= NOW\\(\\)").
+		WillReturnResult(pgxmock.NewResult("DELETE", 3))
+
+	deleted, err := repo.DeleteExpired()
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
+)
+
+// IdentityRepository links users to accounts of external identity providers, an
+// identity is the pair of the provider issuer and the subject it assigned.
+type IdentityRepository interface {
+	FindUserByIdentity(issuer, subject string) (models.UserCredentials, error)
+	LinkIdentity(userID, issuer, subject string) error
+	CreateUserWithIdentity(email, hashedPassword, role, issuer, subject string) (string, error)
+}
+
+type IdentityRepositoryImpl struct {
+	db DB
+}
+
+func NewIdentityRepository(db DB) IdentityRepository {
+	return &IdentityRepositoryImpl{db: db}
+}
+
+func (r *IdentityRepositoryImpl) FindUserByIdentity(issuer, subject string) (models.UserCredentials, error) {
+	return scanCredentials(r.db.QueryRow(context.Background(), "SELECT "+credentialColumns+" FROM users JOIN user_identities ON user_identities.user_id = users.id "+
+		"WHERE user_identities.issuer = $1 AND user_identities.subject = $2",
+		issuer, subject))
+}
+
+func (r *IdentityRepositoryImpl) LinkIdentity(userID, issuer, subject string) error {
+	_, err := r.db.Exec(context.Background(), "INSERT INTO user_identities (issuer, subject, user_id) VALUES ($1, $2, $3) ON CONFLICT (issuer, subject) DO NOTHING",
+		issuer, subject, userID)
+	return err
+}
+
+// CreateUserWithIdentity provisions a user on the first login through a provider.
+func (r *IdentityRepositoryImpl) CreateUserWithIdentity(email, hashedPassword, role, issuer, subject string) (string, error) {
+	ctx := context.Background()
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	userID := uuid.New().String()
+	_, err = tx.Exec(ctx, "INSERT INTO users (id, email, password, role) VALUES ($1, $2, $3, $4)",
+		userID, email, hashedPassword, role,
+	)
+	if err != nil {
+		if isPgError(err, pgUniqueViolation) {
+			return "", apperrors.ErrEmailAlreadyExists
+		}
+		return "", err
+	}
+
+	if _, err := tx.Exec(ctx, "INSERT INTO user_identities (issuer, subject, user_id) VALUES ($1, $2, $3)",
+		issuer, subject, userID); err != nil {
+		return "", err
+	}
+	return userID, tx.Commit(ctx)
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+
+	"pvzService/internal/apperrors"
+)
+
+func TestIdentityRepository_FindUserByIdentity(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewIdentityRepository(mock)
+
+	mock.ExpectQuery("SELECT id, password, role, is_active, locked_until FROM users JOIN user_identities").
+		WithArgs("https://idp", "sub1").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "password", "role", "is_active", "locked_until"}).
+			AddRow("user1", "!", "employee", true, nil))
+	mock.ExpectQuery("SELECT id, password, role, is_active, locked_until FROM users JOIN user_identities").
+		WithArgs("https://idp", "unknown").
+		WillReturnError(sql.ErrNoRows)
+
+	user, err := repo.FindUserByIdentity("https://idp", "sub1")
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", user.ID)
+	assert.Equal(t, "employee", user.Role)
+	assert.True(t, user.Active)
+
+	_, err = repo.FindUserByIdentity("https://idp", "unknown")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdentityRepository_LinkIdentity(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewIdentityRepository(mock)
+
+	mock.ExpectExec("INSERT INTO user_identities").
+		WithArgs("https://idp", "sub1", "user1").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	assert.NoError(t, repo.LinkIdentity("user1", "https://idp", "sub1"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdentityRepository_CreateUserWithIdentity(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewIdentityRepository(mock)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO users").
+		WithArgs(pgxmock.AnyArg(), "alice@example.com", "!", "moderator").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO user_identities").
+		WithArgs("https://idp", "sub1", pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	userID, err := repo.CreateUserWithIdentity("alice@example.com", "!", "moderator", "https://idp", "sub1")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, userID)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO users").
+		WithArgs(pgxmock.AnyArg(), "alice@example.com", "!", "moderator").
+		WillReturnError(&pgconn.PgError{Code: "23505"})
+	mock.ExpectRollback()
+
+	_, err = repo.CreateUserWithIdentity("alice@example.com", "!", "moderator", "https://idp", "sub2")
+	assert.ErrorIs(t, err, apperrors.ErrEmailAlreadyExists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// LoginAttemptRepository tracks failed logins per client IP, independently of the
+// account the attempts were made against.
+type LoginAttemptRepository interface {
+	IsIPLocked(ip string) (bool, error)
+	RegisterIPFailure(ip string, maxAttempts int, window, lockout time.Duration) (bool, error)
+}
+
+type LoginAttemptRepositoryImpl struct {
+	db DB
+}
+
+func NewLoginAttemptRepository(db DB) LoginAttemptRepository {
+	return &LoginAttemptRepositoryImpl{db: db}
+}
+
+func (r *LoginAttemptRepositoryImpl) IsIPLocked(ip string) (bool, error) {
+	var locked bool
+	err := r.db.QueryRow(context.Background(), "SELECT EXISTS (SELECT 1 FROM login_ip_failures WHERE ip = $1 AND locked_until > NOW())",
+		ip).Scan(&locked)
+	return locked, err
+}
+
+// RegisterIPFailure counts a failed attempt from the IP within the window and locks
+// the IP once maxAttempts is reached. It reports whether the IP got locked.
+func (r *LoginAttemptRepositoryImpl) RegisterIPFailure(ip string, maxAttempts int, window, lockout time.Duration) (bool, error) {
+	attempts := attemptsInWindow("f.failed_attempts", "f.last_failed_at")
+
+	var locked bool
+	err := r.db.QueryRow(context.Background(), `
+        INSERT INTO login_ip_failures AS f (ip, failed_attempts, last_failed_at, locked_until)
+        VALUES ($1, 1, NOW(), CASE WHEN $2 <= 1 THEN NOW() + make_interval(secs => $4) END)
+        ON CONFLICT (ip) DO UPDATE SET
+            failed_attempts = CASE WHEN `+attempts+` >= $2 THEN 0 ELSE `+attempts+` END,
+            locked_until = CASE WHEN `+attempts+` >= $2 THEN NOW() + make_interval(secs => $4) ELSE f.locked_until END,
+            last_failed_at = NOW()
+        RETURNING COALESCE(f.locked_until > NOW(), FALSE)`,
+		ip, maxAttempts, window.Seconds(), lockout.Seconds()).Scan(&locked)
+	return locked, err
+}
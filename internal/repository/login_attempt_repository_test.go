@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginAttemptRepository_IsIPLocked(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewLoginAttemptRepository(mock)
+
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs("10.0.0.1").
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+
+	locked, err := repo.IsIPLocked("10.0.0.1")
+	assert.NoError(t, err)
+	assert.True(t, locked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoginAttemptRepository_RegisterIPFailure(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewLoginAttemptRepository(mock)
+
+	mock.ExpectQuery("INSERT INTO login_ip_failures").
+		WithArgs("10.0.0.1", 20, float64(900), float64(900)).
+		WillReturnRows(pgxmock.NewRows([]string{"locked"}).AddRow(false))
+
+	locked, err := repo.RegisterIPFailure("10.0.0.1", 20, 15*time.Minute, 15*time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, locked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// PasswordResetRepository stores password reset tokens by their hash only.
+type PasswordResetRepository interface {
+	CreateResetToken(userID, tokenHash string, expiresAt time.Time) error
+	ConsumeResetToken(tokenHash string) (string, error)
+}
+
+type PasswordResetRepositoryImpl struct {
+	db DB
+}
+
+func NewPasswordResetRepository(db DB) PasswordResetRepository {
+	return &PasswordResetRepositoryImpl{db: db}
+}
+
+func (r *PasswordResetRepositoryImpl) CreateResetToken(userID, tokenHash string, expiresAt time.Time) error {
+	_, err := r.db.Exec(context.Background(), "INSERT INTO password_reset_tokens (token_hash, user_id, expires_at) VALUES ($1, $2, $3)",
+		tokenHash, userID, expiresAt)
+	return err
+}
+
+// ConsumeResetToken marks an unused, unexpired token as used and returns its user.
+// sql.ErrNoRows is returned for unknown, used and expired tokens.
+func (r *PasswordResetRepositoryImpl) ConsumeResetToken(tokenHash string) (string, error) {
+	var userID string
+	err := r.db.QueryRow(context.Background(), `
+        UPDATE password_reset_tokens SET used_at = NOW()
+        WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+        RETURNING user_id`,
+		tokenHash).Scan(&userID)
+	return userID, err
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordResetRepository_CreateResetToken(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewPasswordResetRepository(mock)
+	expiresAt := time.Now().Add(time.Hour)
+
+	mock.ExpectExec("INSERT INTO password_reset_tokens").
+		WithArgs("hash", "user1", expiresAt).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	assert.NoError(t, repo.CreateResetToken("user1", "hash", expiresAt))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPasswordResetRepository_ConsumeResetToken(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewPasswordResetRepository(mock)
+
+	mock.ExpectQuery("UPDATE password_reset_tokens SET used_at = NOW\\(\\)").
+		WithArgs("hash").
+		WillReturnRows(pgxmock.NewRows([]string{"user_id"}).AddRow("user1"))
+	mock.ExpectQuery("UPDATE password_reset_tokens SET used_at = NOW\\(\\)").
+		WithArgs("hash").
+		WillReturnError(sql.ErrNoRows)
+
+	userID, err := repo.ConsumeResetToken("hash")
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", userID)
+
+	_, err = repo.ConsumeResetToken("hash")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+)
+
+type PermissionRepository interface {
+	ListRolePermissions() (map[string][]string, error)
+}
+
+type PermissionRepositoryImpl struct {
+	db DB
+}
+
+func NewPermissionRepository(db DB) *PermissionRepositoryImpl {
+	return &PermissionRepositoryImpl{db: db}
+}
+
+// ListRolePermissions returns the permissions of every role from the role_permissions table.
+func (r *PermissionRepositoryImpl) ListRolePermissions() (map[string][]string, error) {
+	rows, err := r.db.Query(context.Background(), "SELECT role, permission FROM role_permissions ORDER BY role, permission")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := map[string][]string{}
+	for rows.Next() {
+		var role, permission string
+		if err := rows.Scan(&role, &permission); err != nil {
+			return nil, err
+		}
+		permissions[role] = append(permissions[role], permission)
+	}
+	return permissions, rows.Err()
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissionRepository_ListRolePermissions(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewPermissionRepository(mock)
+
+	mock.ExpectQuery("SELECT role, permission FROM role_permissions").
+		WillReturnRows(pgxmock.NewRows([]string{"role", "permission"}).
+			AddRow("auditor", "pvz:read").
+			AddRow("auditor", "user:read").
+			AddRow("moderator", "pvz:create"))
+
+	permissions, err := repo.ListRolePermissions()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"auditor":   {"pvz:read", "user:read"},
+		"moderator": {"pvz:create"},
+	}, permissions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
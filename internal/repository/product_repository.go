@@ -1,38 +1,66 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"time"
+
 	"github.com/google/uuid"
 
+	"pvzService/internal/apperrors"
 	"pvzService/internal/models"
 )
 
 type ProductRepository struct {
-	db *sql.DB
+	db DB
 }
 
-func NewProductRepository(db *sql.DB) *ProductRepository {
+func NewProductRepository(db DB) *ProductRepository {
 	return &ProductRepository{db: db}
 }
 
-func (r *ProductRepository) AddProduct(receptionID, productType string, idGenerator func() uuid.UUID) (string, error) {
+// reserveStorage takes room for a product in the PVZ of the reception $1 and reports
+// whether the PVZ is over its capacity with it. The PVZ row stays locked until the
+// product is inserted, so concurrent additions can't both take the last room.
+const reserveStorage = `UPDATE pvz p SET stored_items = p.stored_items + 1
+	FROM receptions r WHERE r.id = $1 AND p.id = r.pvz_id
+	RETURNING p.capacity IS NOT NULL AND p.stored_items > p.capacity`
+
+// AddProduct stores a product in the PVZ of the reception. A product that doesn't fit
+// into the capacity of the PVZ gives apperrors.ErrPVZCapacityExceeded, or is added
+// with overflow set when allowOverflow is set.
+func (r *ProductRepository) AddProduct(receptionID, productType string, createdAt time.Time, idGenerator func() uuid.UUID, allowOverflow bool) (string, bool, error) {
+	ctx := context.Background()
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var overflow bool
+	if err := tx.QueryRow(ctx, reserveStorage, receptionID).Scan(&overflow); err != nil {
+		return "", false, err
+	}
+	if overflow && !allowOverflow {
+		return "", false, apperrors.ErrPVZCapacityExceeded
+	}
+
 	productID := idGenerator().String()
-	_, err := r.db.Exec(
-		"INSERT INTO products (id, reception_id, type) VALUES ($1, $2, $3)",
-		productID, receptionID, productType,
+	_, err = tx.Exec(ctx, "INSERT INTO products (id, reception_id, type, created_at) VALUES ($1, $2, $3, $4)",
+		productID, receptionID, productType, createdAt,
 	)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
-	return productID, nil
+	return productID, overflow, tx.Commit(ctx)
 }
 
 func (r *ProductRepository) GetProductByID(id string) (models.Product, error) {
 	var product models.Product
-	err := r.db.QueryRow(
-		"SELECT id, created_at, type, reception_id FROM products WHERE id = $1",
+	err := r.db.QueryRow(context.Background(), "SELECT id, created_at, type, reception_id, issued_at FROM products WHERE id = $1",
 		id,
-	).Scan(&product.ID, &product.DateTime, &product.Type, &product.ReceptionId)
+	).Scan(&product.ID, &product.DateTime, &product.Type, &product.ReceptionId, &product.IssuedAt)
 
 	if err != nil {
 		return models.Product{}, err
@@ -42,12 +70,11 @@ func (r *ProductRepository) GetProductByID(id string) (models.Product, error) {
 
 func (r *ProductRepository) GetLastProduct(receptionID string) (models.Product, error) {
 	var product models.Product
-	err := r.db.QueryRow(
-		`SELECT id, created_at, type, reception_id 
+	err := r.db.QueryRow(context.Background(), `SELECT id, created_at, type, reception_id, issued_at
 		 FROM products WHERE reception_id = $1 
-		 ORDER BY created_at DESC LIMIT 1`,
+		 ORDER BY seq DESC LIMIT 1`,
 		receptionID,
-	).Scan(&product.ID, &product.DateTime, &product.Type, &product.ReceptionId)
+	).Scan(&product.ID, &product.DateTime, &product.Type, &product.ReceptionId, &product.IssuedAt)
 
 	if err != nil {
 		return models.Product{}, err
@@ -55,7 +82,52 @@ func (r *ProductRepository) GetLastProduct(receptionID string) (models.Product,
 	return product, nil
 }
 
+// DeleteProduct deletes a product and frees its room in the PVZ, an issued product
+// has already freed it.
 func (r *ProductRepository) DeleteProduct(id string) error {
-	_, err := r.db.Exec("DELETE FROM products WHERE id = $1", id)
+	_, err := r.db.Exec(context.Background(), `WITH deleted AS (DELETE FROM products WHERE id = $1 RETURNING reception_id, issued_at)
+		UPDATE pvz p SET stored_items = p.stored_items - 1
+		FROM deleted d JOIN receptions r ON r.id = d.reception_id
+		WHERE p.id = r.pvz_id AND d.issued_at IS NULL`, id)
 	return err
 }
+
+// issueProduct marks the product $1 of the PVZ $2 issued at $3 and frees its room in
+// the PVZ in the same statement. Only a stored product of a closed reception is issued,
+// a concurrent issue of the same product rechecks issued_at and changes nothing.
+const issueProduct = `WITH issued AS (
+		UPDATE products pr SET issued_at = $3
+		FROM receptions r
+		WHERE pr.id = $1 AND r.id = pr.reception_id AND r.pvz_id = $2 AND r.status = 'close' AND pr.issued_at IS NULL
+		RETURNING pr.id, pr.created_at, pr.type, pr.reception_id, pr.issued_at, r.pvz_id
+	), freed AS (
+		UPDATE pvz p SET stored_items = p.stored_items - 1 FROM issued i WHERE p.id = i.pvz_id
+	)
+	SELECT id, created_at, type, reception_id, issued_at FROM issued`
+
+// IssueProduct hands a product of the PVZ over to the recipient. A product that is not
+// in the PVZ gives sql.ErrNoRows, an issued one apperrors.ErrProductAlreadyIssued and
+// one of an open reception apperrors.ErrReceptionNotClosed.
+func (r *ProductRepository) IssueProduct(pvzID, productID string, issuedAt time.Time) (models.Product, error) {
+	ctx := context.Background()
+	var product models.Product
+	err := r.db.QueryRow(ctx, issueProduct, productID, pvzID, issuedAt).
+		Scan(&product.ID, &product.DateTime, &product.Type, &product.ReceptionId, &product.IssuedAt)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return product, err
+	}
+
+	var issued bool
+	var status string
+	err = r.db.QueryRow(ctx, `SELECT pr.issued_at IS NOT NULL, r.status
+		FROM products pr JOIN receptions r ON r.id = pr.reception_id
+		WHERE pr.id = $1 AND r.pvz_id = $2`, productID, pvzID).Scan(&issued, &status)
+	switch {
+	case err != nil:
+		return models.Product{}, err
+	case issued:
+		return models.Product{}, apperrors.ErrProductAlreadyIssued
+	default:
+		return models.Product{}, apperrors.ErrReceptionNotClosed
+	}
+}
@@ -1,44 +1,73 @@
 package repository
 
 import (
+	"database/sql"
 	"testing"
+	"time"
 
-	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v4"
 	"github.com/stretchr/testify/assert"
 
+	"pvzService/internal/apperrors"
 	"pvzService/internal/models"
 )
 
 func TestProductRepository_AddProduct(t *testing.T) {
-	db, mock, err := sqlmock.New()
+	mock, err := pgxmock.NewPool()
 	assert.NoError(t, err)
-	defer db.Close()
+	defer mock.Close()
 
-	repo := NewProductRepository(db)
+	repo := NewProductRepository(mock)
 
 	receptionID := uuid.NewString()
 	productID := uuid.NewString()
+	createdAt := time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC)
 
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE pvz p SET stored_items").
+		WithArgs(receptionID).
+		WillReturnRows(pgxmock.NewRows([]string{"overflow"}).AddRow(false))
 	mock.ExpectExec("INSERT INTO products").
-		WithArgs(productID, receptionID, "электроника").
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		WithArgs(productID, receptionID, "электроника", createdAt).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
 
-	id, err := repo.AddProduct(receptionID, "электроника", func() uuid.UUID {
+	id, overflow, err := repo.AddProduct(receptionID, "электроника", createdAt, func() uuid.UUID {
 		return uuid.MustParse(productID)
-	})
+	}, false)
 
 	assert.NoError(t, err)
 	assert.Equal(t, productID, id)
+	assert.False(t, overflow)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProductRepository_AddProduct_CapacityExceeded(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewProductRepository(mock)
+	receptionID := uuid.NewString()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE pvz p SET stored_items").
+		WithArgs(receptionID).
+		WillReturnRows(pgxmock.NewRows([]string{"overflow"}).AddRow(true))
+	mock.ExpectRollback()
+
+	_, _, err = repo.AddProduct(receptionID, "обувь", time.Now(), uuid.New, false)
+	assert.ErrorIs(t, err, apperrors.ErrPVZCapacityExceeded)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestProductRepository_GetProductByID(t *testing.T) {
-	db, mock, err := sqlmock.New()
+	mock, err := pgxmock.NewPool()
 	assert.NoError(t, err)
-	defer db.Close()
+	defer mock.Close()
 
-	repo := NewProductRepository(db)
+	repo := NewProductRepository(mock)
 
 	productID := uuid.NewString()
 	expected := models.Product{
@@ -46,10 +75,10 @@ func TestProductRepository_GetProductByID(t *testing.T) {
 		Type: "электроника",
 	}
 
-	mock.ExpectQuery("SELECT id, created_at, type, reception_id FROM products").
+	mock.ExpectQuery("SELECT id, created_at, type, reception_id, issued_at FROM products").
 		WithArgs(productID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "type", "reception_id"}).
-			AddRow(expected.ID, expected.DateTime, expected.Type, expected.ReceptionId))
+		WillReturnRows(pgxmock.NewRows([]string{"id", "created_at", "type", "reception_id", "issued_at"}).
+			AddRow(expected.ID, expected.DateTime, expected.Type, expected.ReceptionId, nil))
 
 	product, err := repo.GetProductByID(productID)
 	assert.NoError(t, err)
@@ -58,19 +87,91 @@ func TestProductRepository_GetProductByID(t *testing.T) {
 }
 
 func TestProductRepository_DeleteProduct(t *testing.T) {
-	db, mock, err := sqlmock.New()
+	mock, err := pgxmock.NewPool()
 	assert.NoError(t, err)
-	defer db.Close()
+	defer mock.Close()
 
-	repo := NewProductRepository(db)
+	repo := NewProductRepository(mock)
 
 	productID := uuid.NewString()
 
-	mock.ExpectExec("DELETE FROM products").
+	mock.ExpectExec("DELETE FROM products .* UPDATE pvz p SET stored_items = p.stored_items - 1 .* d.issued_at IS NULL").
 		WithArgs(productID).
-		WillReturnResult(sqlmock.NewResult(0, 1))
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
 
 	err = repo.DeleteProduct(productID)
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestProductRepository_IssueProduct(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewProductRepository(mock)
+
+	pvzID := uuid.NewString()
+	productID := uuid.NewString()
+	receptionID := uuid.NewString()
+	createdAt := time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC)
+	issuedAt := time.Date(2025, 4, 3, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("UPDATE products pr SET issued_at = \\$3 .* pr.issued_at IS NULL .* UPDATE pvz p SET stored_items = p.stored_items - 1").
+		WithArgs(productID, pvzID, issuedAt).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "created_at", "type", "reception_id", "issued_at"}).
+			AddRow(productID, createdAt, "обувь", receptionID, &issuedAt))
+
+	product, err := repo.IssueProduct(pvzID, productID, issuedAt)
+	assert.NoError(t, err)
+	assert.Equal(t, models.Product{ID: productID, DateTime: createdAt, Type: "обувь", ReceptionId: receptionID, IssuedAt: &issuedAt}, product)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProductRepository_IssueProduct_NotIssued(t *testing.T) {
+	tests := []struct {
+		name     string
+		rows     *pgxmock.Rows
+		expected error
+	}{
+		{
+			name:     "not in the PVZ",
+			rows:     pgxmock.NewRows([]string{"issued", "status"}),
+			expected: sql.ErrNoRows,
+		},
+		{
+			name:     "already issued",
+			rows:     pgxmock.NewRows([]string{"issued", "status"}).AddRow(true, "close"),
+			expected: apperrors.ErrProductAlreadyIssued,
+		},
+		{
+			name:     "reception is open",
+			rows:     pgxmock.NewRows([]string{"issued", "status"}).AddRow(false, "in_progress"),
+			expected: apperrors.ErrReceptionNotClosed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, err := pgxmock.NewPool()
+			assert.NoError(t, err)
+			defer mock.Close()
+
+			repo := NewProductRepository(mock)
+			pvzID := uuid.NewString()
+			productID := uuid.NewString()
+			issuedAt := time.Now()
+
+			mock.ExpectQuery("UPDATE products pr SET issued_at").
+				WithArgs(productID, pvzID, issuedAt).
+				WillReturnRows(pgxmock.NewRows([]string{"id", "created_at", "type", "reception_id", "issued_at"}))
+			mock.ExpectQuery("SELECT pr.issued_at IS NOT NULL, r.status").
+				WithArgs(productID, pvzID).
+				WillReturnRows(tt.rows)
+
+			_, err = repo.IssueProduct(pvzID, productID, issuedAt)
+			assert.ErrorIs(t, err, tt.expected)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
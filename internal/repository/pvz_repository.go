@@ -1,177 +1,474 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"pvzService/internal/apperrors"
 	"pvzService/internal/models"
-	"pvzService/internal/utils"
 )
 
 type PVZRepository interface {
-	CreatePVZ(city string, idGenerator func() uuid.UUID) (models.PVZ, error)
-	GetPVZByID(id string) (models.PVZ, error)
-	ListPVZsWithRelations(startDate, endDate time.Time, limit, offset int) ([]PVZResponse, error)
+	CreatePVZ(city string, registeredAt time.Time, idGenerator func() uuid.UUID) (models.PVZ, error)
+	GetPVZByID(id, city string) (models.PVZ, error)
+	ListPVZsWithRelations(startDate, endDate time.Time, city string, includeArchived bool, limit, offset int) ([]PVZResponse, error)
+	ArchivePVZ(id, city, archivedBy string, archivedAt time.Time) error
+	RestorePVZ(id, city string) error
+	UpdatePVZ(id, city string, update models.UpdatePVZRequest) error
+	ListNearbyPVZs(lat, lon, radiusKm float64, openAt time.Time, city string, limit int) ([]models.NearbyPVZ, error)
+	ListPVZUtilization(minUtilization float64, city string, limit, offset int) ([]models.PVZUtilization, error)
+	ListAllPVZUtilization() ([]models.PVZUtilization, error)
+	ListActivePVZs() ([]models.PVZ, error)
 }
 
 type PVZRepositoryImpl struct {
-	db *sql.DB
+	db    DB
+	reads *ReadRouter
 }
 
-func NewPVZRepository(db *sql.DB) *PVZRepositoryImpl {
-	return &PVZRepositoryImpl{db: db}
+// NewPVZRepository writes to db, listing goes through reads and may use a replica.
+func NewPVZRepository(db DB, reads *ReadRouter) *PVZRepositoryImpl {
+	return &PVZRepositoryImpl{db: db, reads: reads}
 }
 
-func (r *PVZRepositoryImpl) CreatePVZ(city string, idGenerator func() uuid.UUID) (models.PVZ, error) {
+// pvzColumns are the columns of models.PVZ, the time zone comes from the city.
+const pvzColumns = "p.id, p.registration_date, p.city, c.timezone, " +
+	"p.name, p.address, p.latitude, p.longitude, p.working_hours, p.capacity, p.stored_items, " +
+	"p.archived, p.archived_at, p.archived_by " +
+	"FROM pvz p JOIN cities c ON c.name = p.city"
+
+// pvzFields are the scan destinations of pvzColumns, id may differ from &pvz.ID to
+// scan the id as uuid.UUID.
+func pvzFields(id interface{}, pvz *models.PVZ) []interface{} {
+	return []interface{}{id, &pvz.RegistrationDate, &pvz.City, &pvz.Timezone,
+		&pvz.Name, &pvz.Address, &pvz.Latitude, &pvz.Longitude, &jsonColumn{&pvz.WorkingHours}, &pvz.Capacity, &pvz.StoredItems,
+		&pvz.Archived, &pvz.ArchivedAt, &pvz.ArchivedBy}
+}
+
+// jsonColumn scans a JSON column into dest, NULL leaves dest as it is.
+type jsonColumn struct {
+	dest interface{}
+}
+
+func (c jsonColumn) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, c.dest)
+	case string:
+		return json.Unmarshal([]byte(v), c.dest)
+	}
+	return fmt.Errorf("unsupported JSON column type %T", src)
+}
+
+func (r *PVZRepositoryImpl) CreatePVZ(city string, registeredAt time.Time, idGenerator func() uuid.UUID) (models.PVZ, error) {
+	ctx := context.Background()
 	pvzID := idGenerator().String()
-	_, err := r.db.Exec("INSERT INTO pvz (id, city) VALUES ($1, $2)", pvzID, city)
+	_, err := r.db.Exec(ctx, "INSERT INTO pvz (id, city, registration_date) VALUES ($1, $2, $3)", pvzID, city, registeredAt)
 	if err != nil {
 		return models.PVZ{}, err
 	}
 
 	var pvz models.PVZ
-	err = r.db.QueryRow("SELECT id, registration_date, city FROM pvz WHERE id = $1", pvzID).
-		Scan(&pvz.ID, &pvz.RegistrationDate, &pvz.City)
+	err = r.db.QueryRow(ctx, "SELECT "+pvzColumns+" WHERE p.id = $1", pvzID).
+		Scan(pvzFields(&pvz.ID, &pvz)...)
 	return pvz, err
 }
 
-func (r *PVZRepositoryImpl) GetPVZByID(id string) (models.PVZ, error) {
+// GetPVZByID returns archived PVZ too. It returns sql.ErrNoRows for an unknown id and,
+// when city is not empty, for a PVZ in another city.
+func (r *PVZRepositoryImpl) GetPVZByID(id, city string) (models.PVZ, error) {
+	query := "SELECT " + pvzColumns + " WHERE p.id = $1"
+	args := []interface{}{id}
+	if city != "" {
+		query += " AND p.city = $2"
+		args = append(args, city)
+	}
+
 	var pvz models.PVZ
-	err := r.db.QueryRow("SELECT id, registration_date, city FROM pvz WHERE id = $1", id).
-		Scan(&pvz.ID, &pvz.RegistrationDate, &pvz.City)
+	err := r.db.QueryRow(context.Background(), query, args...).Scan(pvzFields(&pvz.ID, &pvz)...)
 	return pvz, err
 }
 
-type PVZResponse struct {
-	PVZ        models.PVZ          `json:"pvz"`
-	Receptions []ReceptionResponse `json:"receptions"`
+// ArchivePVZ archives a PVZ without an open reception and returns
+// apperrors.ErrPVZHasOpenReception otherwise. The PVZ row stays locked until the PVZ
+// is archived, so no reception can be opened after the check. Archiving an archived
+// PVZ keeps the time and the user of the first archiving. Unknown PVZ and, when city
+// is not empty, PVZ of other cities give sql.ErrNoRows.
+func (r *PVZRepositoryImpl) ArchivePVZ(id, city, archivedBy string, archivedAt time.Time) error {
+	ctx := context.Background()
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := "SELECT archived FROM pvz WHERE id = $1"
+	args := []interface{}{id}
+	if city != "" {
+		query += " AND city = $2"
+		args = append(args, city)
+	}
+	var archived bool
+	if err := tx.QueryRow(ctx, query+" FOR UPDATE", args...).Scan(&archived); err != nil {
+		return err
+	}
+	if archived {
+		return nil
+	}
+
+	var hasOpen bool
+	err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM receptions WHERE pvz_id = $1 AND status = 'in_progress')", id).
+		Scan(&hasOpen)
+	if err != nil {
+		return err
+	}
+	if hasOpen {
+		return apperrors.ErrPVZHasOpenReception
+	}
+
+	var by interface{}
+	if archivedBy != "" {
+		by = archivedBy
+	}
+	if _, err := tx.Exec(ctx, "UPDATE pvz SET archived = TRUE, archived_at = $2, archived_by = $3 WHERE id = $1",
+		id, archivedAt, by); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
-type ReceptionResponse struct {
-	Reception models.Reception `json:"reception"`
-	Products  []models.Product `json:"products"`
+// RestorePVZ takes a PVZ out of the archive, restoring a PVZ that isn't archived
+// changes nothing. Unknown PVZ and, when city is not empty, PVZ of other cities give
+// sql.ErrNoRows.
+func (r *PVZRepositoryImpl) RestorePVZ(id, city string) error {
+	query := "UPDATE pvz SET archived = FALSE, archived_at = NULL, archived_by = NULL WHERE id = $1"
+	args := []interface{}{id}
+	if city != "" {
+		query += " AND city = $2"
+		args = append(args, city)
+	}
+
+	res, err := r.db.Exec(context.Background(), query, args...)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
 
-func (r *PVZRepositoryImpl) ListPVZsWithRelations(startDate, endDate time.Time, limit, offset int) ([]PVZResponse, error) {
-	var rows *sql.Rows
-	var err error
+// UpdatePVZ changes the profile fields that are set in update, working hours replace
+// the stored ones and capacity 0 removes the limit. Unknown PVZ and, when city is not empty, PVZ of other cities give
+// sql.ErrNoRows.
+func (r *PVZRepositoryImpl) UpdatePVZ(id, city string, update models.UpdatePVZRequest) error {
+	var workingHours interface{}
+	if update.WorkingHours != nil {
+		hours := *update.WorkingHours
+		if hours == nil {
+			hours = []models.WorkingHours{}
+		}
+		encoded, err := json.Marshal(hours)
+		if err != nil {
+			return err
+		}
+		workingHours = string(encoded)
+	}
 
-	query := `
-        SELECT 
-            p.id, p.registration_date, p.city,
-            r.id, r.created_at, r.pvz_id, r.status, r.closed_at,
-            pr.id, pr.created_at, pr.type, pr.reception_id
-        FROM pvz p
-        LEFT JOIN receptions r ON p.id = r.pvz_id
-        LEFT JOIN products pr ON r.id = pr.reception_id
-    `
+	query := `UPDATE pvz SET
+		name = COALESCE($2, name),
+		address = COALESCE($3, address),
+		latitude = COALESCE($4, latitude),
+		longitude = COALESCE($5, longitude),
+		working_hours = COALESCE($6::jsonb, working_hours),
+		capacity = CASE WHEN $7::integer IS NULL THEN capacity ELSE NULLIF($7::integer, 0) END
+		WHERE id = $1`
+	args := []interface{}{id, update.Name, update.Address, update.Latitude, update.Longitude, workingHours, update.Capacity}
+	if city != "" {
+		query += " AND city = $8"
+		args = append(args, city)
+	}
 
-	if !startDate.IsZero() && !endDate.IsZero() {
-		query += " WHERE p.registration_date >= $1 AND p.registration_date <= $2"
-		query += " ORDER BY p.registration_date ASC"
-		query += " LIMIT $3 OFFSET $4"
-		rows, err = r.db.Query(query, startDate, endDate, limit, offset)
-	} else {
-		query += " ORDER BY p.registration_date ASC"
-		query += " LIMIT $1 OFFSET $2"
-		rows, err = r.db.Query(query, limit, offset)
+	res, err := r.db.Exec(context.Background(), query, args...)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// earthRadiusKm is the mean radius of the Earth, a degree of latitude is about 111.2 km.
+const earthRadiusKm = 6371.0
+
+// pvzDistance is the haversine distance in kilometers from the point ($1, $2) to a PVZ
+// on a sphere of earthRadiusKm.
+const pvzDistance = "2 * 6371.0 * asin(least(1, sqrt(" +
+	"power(sin(radians(p.latitude - $1) / 2), 2) + " +
+	"cos(radians($1)) * cos(radians(p.latitude)) * power(sin(radians(p.longitude - $2) / 2), 2))))"
+
+// pvzOpenAt reports whether the working hours of a PVZ include the time $n, compared
+// in the local time of the PVZ.
+const pvzOpenAt = `EXISTS (SELECT 1 FROM jsonb_array_elements(p.working_hours) h
+	WHERE h->>'weekday' = to_char($%[1]d::timestamptz AT TIME ZONE c.timezone, 'FMday')
+	AND h->>'opens' <= to_char($%[1]d::timestamptz AT TIME ZONE c.timezone, 'HH24:MI')
+	AND to_char($%[1]d::timestamptz AT TIME ZONE c.timezone, 'HH24:MI') < h->>'closes')`
+
+// ListNearbyPVZs returns up to limit PVZ in operation within radiusKm of the point,
+// nearest first. PVZ without coordinates are never found. With a non-zero openAt only
+// PVZ open at that time are returned, a non-empty city hides the PVZ of other cities.
+// It reads from the replica when one is usable.
+func (r *PVZRepositoryImpl) ListNearbyPVZs(lat, lon, radiusKm float64, openAt time.Time, city string, limit int) ([]models.NearbyPVZ, error) {
+	// The latitude band can be checked with an index, the exact distance can't
+	band := radiusKm * 180 / (math.Pi * earthRadiusKm)
+	args := []interface{}{lat, lon, radiusKm, lat - band, lat + band, limit}
+	conditions := []string{
+		"NOT p.archived",
+		"p.latitude BETWEEN $4 AND $5",
+		pvzDistance + " <= $3",
+	}
+	if city != "" {
+		args = append(args, city)
+		conditions = append(conditions, fmt.Sprintf("p.city = $%d", len(args)))
 	}
+	if !openAt.IsZero() {
+		args = append(args, openAt)
+		conditions = append(conditions, fmt.Sprintf(pvzOpenAt, len(args)))
+	}
+	query := "SELECT " + pvzDistance + " AS distance_km, " + pvzColumns +
+		" WHERE " + strings.Join(conditions, " AND ") +
+		" ORDER BY distance_km, p.id LIMIT $6"
 
+	rows, err := r.reads.ReadDB().Query(context.Background(), query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	pvzMap := make(map[string]*PVZResponse)
-	receptionMap := make(map[string]*struct {
-		reception models.Reception
-		products  []models.Product
-	})
+	result := []models.NearbyPVZ{}
+	for rows.Next() {
+		var nearby models.NearbyPVZ
+		if err := rows.Scan(append([]interface{}{&nearby.DistanceKm}, pvzFields(&nearby.ID, &nearby.PVZ)...)...); err != nil {
+			return nil, err
+		}
+		result = append(result, nearby)
+	}
+	return result, rows.Err()
+}
+
+// utilization is the share of the capacity of a PVZ taken by its stored products.
+const utilization = "p.stored_items::double precision / p.capacity"
+
+// ListPVZUtilization lists PVZ in operation with a capacity whose utilization is at
+// least minUtilization, the fullest first. A non-empty city hides the PVZ of other
+// cities. It reads from the replica when one is usable.
+func (r *PVZRepositoryImpl) ListPVZUtilization(minUtilization float64, city string, limit, offset int) ([]models.PVZUtilization, error) {
+	args := []interface{}{minUtilization, limit, offset}
+	query := "SELECT " + utilization + " AS utilization, " + pvzColumns +
+		" WHERE NOT p.archived AND p.capacity IS NOT NULL AND " + utilization + " >= $1"
+	if city != "" {
+		args = append(args, city)
+		query += " AND p.city = $4"
+	}
+	query += " ORDER BY utilization DESC, p.id LIMIT $2 OFFSET $3"
+	return r.listPVZUtilization(query, args...)
+}
+
+// ListAllPVZUtilization lists every PVZ in operation with a capacity, whatever its
+// utilization, the fullest first. It reads from the replica when one is usable.
+func (r *PVZRepositoryImpl) ListAllPVZUtilization() ([]models.PVZUtilization, error) {
+	return r.listPVZUtilization("SELECT " + utilization + " AS utilization, " + pvzColumns +
+		" WHERE NOT p.archived AND p.capacity IS NOT NULL ORDER BY utilization DESC, p.id")
+}
+
+func (r *PVZRepositoryImpl) listPVZUtilization(query string, args ...interface{}) ([]models.PVZUtilization, error) {
+	rows, err := r.reads.ReadDB().Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
+	result := []models.PVZUtilization{}
 	for rows.Next() {
-		var (
-			pvzID, receptionID, productID sql.NullString
-			pvzRegDate                    sql.NullTime
-			pvzCity                       sql.NullString
-			receptionCreatedAt            sql.NullTime
-			receptionPvzID                sql.NullString
-			receptionStatus               sql.NullString
-			receptionClosedAt             sql.NullTime
-			productCreatedAt              sql.NullTime
-			productType                   sql.NullString
-			productReceptionID            sql.NullString
-		)
-
-		if err := rows.Scan(
-			&pvzID, &pvzRegDate, &pvzCity,
-			&receptionID, &receptionCreatedAt, &receptionPvzID, &receptionStatus, &receptionClosedAt,
-			&productID, &productCreatedAt, &productType, &productReceptionID,
-		); err != nil {
+		var pvz models.PVZUtilization
+		if err := rows.Scan(append([]interface{}{&pvz.Utilization}, pvzFields(&pvz.ID, &pvz.PVZ)...)...); err != nil {
 			return nil, err
 		}
+		result = append(result, pvz)
+	}
+	return result, rows.Err()
+}
+
+// ListActivePVZs lists every PVZ in operation in the order of registration. It reads
+// from the replica when one is usable.
+func (r *PVZRepositoryImpl) ListActivePVZs() ([]models.PVZ, error) {
+	rows, err := r.reads.ReadDB().Query(context.Background(), "SELECT "+pvzColumns+" WHERE NOT p.archived ORDER BY p.registration_date, p.id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-		if pvzID.Valid {
-			if _, exists := pvzMap[pvzID.String]; !exists {
-				pvzMap[pvzID.String] = &PVZResponse{
-					PVZ: models.PVZ{
-						ID:               pvzID.String,
-						RegistrationDate: pvzRegDate.Time,
-						City:             pvzCity.String,
-					},
-					Receptions: []ReceptionResponse{},
-				}
-			}
+	result := []models.PVZ{}
+	for rows.Next() {
+		var pvz models.PVZ
+		if err := rows.Scan(pvzFields(&pvz.ID, &pvz)...); err != nil {
+			return nil, err
 		}
+		result = append(result, pvz)
+	}
+	return result, rows.Err()
+}
+
+type PVZResponse struct {
+	PVZ        models.PVZ          `json:"pvz"`
+	Receptions []ReceptionResponse `json:"receptions"`
+}
+
+type ReceptionResponse struct {
+	Reception models.Reception `json:"reception"`
+	Products  []models.Product `json:"products"`
+}
+
+// ListPVZsWithRelations lists PVZ with their receptions and products, only the PVZ of
+// the city when it is not empty. Archived PVZ are left out unless includeArchived is
+// set. It reads from the replica when one is usable.
+//
+// The page of PVZ is selected first, then receptions and products of that page are
+// loaded with one query each, so a PVZ with many products doesn't repeat its columns
+// in every row and limit counts PVZ rather than joined rows.
+func (r *PVZRepositoryImpl) ListPVZsWithRelations(startDate, endDate time.Time, city string, includeArchived bool, limit, offset int) ([]PVZResponse, error) {
+	db := r.reads.ReadDB()
+
+	result, pvzIDs, err := listPVZPage(db, startDate, endDate, city, includeArchived, limit, offset)
+	if err != nil || len(result) == 0 {
+		return result, err
+	}
+
+	receptions, receptionIDs, err := listReceptions(db, pvzIDs)
+	if err != nil {
+		return nil, err
+	}
+	products, err := listProducts(db, receptionIDs)
+	if err != nil {
+		return nil, err
+	}
 
-		if receptionID.Valid && pvzID.Valid {
-			receptionKey := receptionID.String
-			if _, exists := receptionMap[receptionKey]; !exists {
-				receptionMap[receptionKey] = &struct {
-					reception models.Reception
-					products  []models.Product
-				}{
-					reception: models.Reception{
-						ID:       receptionID.String,
-						DateTime: receptionCreatedAt.Time,
-						PvzId:    receptionPvzID.String,
-						Status:   receptionStatus.String,
-						ClosedAt: utils.NullableTime(receptionClosedAt),
-					},
-					products: []models.Product{},
-				}
-			}
+	byPVZ := make(map[string][]ReceptionResponse, len(result))
+	for _, reception := range receptions {
+		byPVZ[reception.PvzId] = append(byPVZ[reception.PvzId], ReceptionResponse{
+			Reception: reception,
+			Products:  append([]models.Product{}, products[reception.ID]...),
+		})
+	}
+	for i := range result {
+		if receptions, ok := byPVZ[result[i].PVZ.ID]; ok {
+			result[i].Receptions = receptions
 		}
+	}
+	return result, nil
+}
+
+// listPVZPage selects a page of PVZ in the order of registration.
+func listPVZPage(db DB, startDate, endDate time.Time, city string, includeArchived bool, limit, offset int) ([]PVZResponse, []uuid.UUID, error) {
+	query := "SELECT " + pvzColumns
+
+	args := []interface{}{limit, offset}
+	var conditions []string
+	if !startDate.IsZero() && !endDate.IsZero() {
+		conditions = append(conditions, "p.registration_date >= $3 AND p.registration_date <= $4")
+		args = append(args, startDate, endDate)
+	}
+	if city != "" {
+		args = append(args, city)
+		conditions = append(conditions, fmt.Sprintf("p.city = $%d", len(args)))
+	}
+	if !includeArchived {
+		conditions = append(conditions, "NOT p.archived")
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY p.registration_date ASC, p.id"
+	query += " LIMIT $1 OFFSET $2"
+
+	rows, err := db.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
 
-		if productID.Valid && productReceptionID.Valid {
-			if reception, exists := receptionMap[productReceptionID.String]; exists {
-				reception.products = append(reception.products, models.Product{
-					ID:          productID.String,
-					DateTime:    productCreatedAt.Time,
-					Type:        productType.String,
-					ReceptionId: productReceptionID.String,
-				})
-			}
+	// An empty page is listed as [], not null
+	result := []PVZResponse{}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		var pvz models.PVZ
+		if err := rows.Scan(pvzFields(&id, &pvz)...); err != nil {
+			return nil, nil, err
 		}
+		pvz.ID = id.String()
+		result = append(result, PVZResponse{PVZ: pvz, Receptions: []ReceptionResponse{}})
+		ids = append(ids, id)
 	}
+	return result, ids, rows.Err()
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
+// listReceptions loads the receptions of the PVZ in the order they were opened.
+func listReceptions(db DB, pvzIDs []uuid.UUID) ([]models.Reception, []uuid.UUID, error) {
+	rows, err := db.Query(context.Background(), "SELECT id, created_at, pvz_id, status, closed_at FROM receptions WHERE pvz_id = ANY($1) ORDER BY created_at, id",
+		pvzIDs)
+	if err != nil {
+		return nil, nil, err
 	}
+	defer rows.Close()
 
-	for _, reception := range receptionMap {
-		if pvz, exists := pvzMap[reception.reception.PvzId]; exists {
-			pvz.Receptions = append(pvz.Receptions, ReceptionResponse{
-				Reception: reception.reception,
-				Products:  reception.products,
-			})
+	var (
+		receptions []models.Reception
+		ids        []uuid.UUID
+	)
+	for rows.Next() {
+		var id uuid.UUID
+		var reception models.Reception
+		if err := rows.Scan(&id, &reception.DateTime, &reception.PvzId, &reception.Status, &reception.ClosedAt); err != nil {
+			return nil, nil, err
 		}
+		reception.ID = id.String()
+		receptions = append(receptions, reception)
+		ids = append(ids, id)
 	}
+	return receptions, ids, rows.Err()
+}
 
-	result := make([]PVZResponse, 0, len(pvzMap))
-	for _, pvz := range pvzMap {
-		result = append(result, *pvz)
+// listProducts loads the products of the receptions by reception in the order they
+// were added.
+func listProducts(db DB, receptionIDs []uuid.UUID) (map[string][]models.Product, error) {
+	products := make(map[string][]models.Product, len(receptionIDs))
+	if len(receptionIDs) == 0 {
+		return products, nil
 	}
 
-	return result, nil
+	rows, err := db.Query(context.Background(), "SELECT id, created_at, type, reception_id, issued_at FROM products WHERE reception_id = ANY($1) ORDER BY seq",
+		receptionIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product models.Product
+		if err := rows.Scan(&product.ID, &product.DateTime, &product.Type, &product.ReceptionId, &product.IssuedAt); err != nil {
+			return nil, err
+		}
+		products[product.ReceptionId] = append(products[product.ReceptionId], product)
+	}
+	return products, rows.Err()
 }
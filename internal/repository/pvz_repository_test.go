@@ -2,49 +2,56 @@ package repository
 
 import (
 	"database/sql"
+	"math"
+	"regexp"
 	"testing"
 	"time"
 
-	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v4"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
 )
 
 func TestPVZRepository_CreatePVZ(t *testing.T) {
-	db, mock, err := sqlmock.New()
+	mock, err := pgxmock.NewPool()
 	assert.NoError(t, err)
-	defer db.Close()
+	defer mock.Close()
 
-	repo := NewPVZRepository(db)
+	repo := NewPVZRepository(mock, NewReadRouter(mock, nil, 0))
 	pvzID := uuid.NewString()
 	now := time.Now()
 
 	t.Run("success", func(t *testing.T) {
 		mock.ExpectExec("INSERT INTO pvz").
-			WithArgs(pvzID, "Москва").
-			WillReturnResult(sqlmock.NewResult(1, 1))
+			WithArgs(pvzID, "Москва", now).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
-		mock.ExpectQuery("SELECT id, registration_date, city FROM pvz WHERE id =").
+		mock.ExpectQuery("SELECT p.id, p.registration_date, p.city, c.timezone, p.name, p.address, p.latitude, p.longitude, p.working_hours, p.capacity, p.stored_items, p.archived, p.archived_at, p.archived_by FROM pvz p JOIN cities c ON c.name = p.city WHERE p.id =").
 			WithArgs(pvzID).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "registration_date", "city"}).
-				AddRow(pvzID, now, "Москва"))
+			WillReturnRows(pgxmock.NewRows(pvzTestColumns).
+				AddRow(pvzID, now, "Москва", "Europe/Moscow", "", "", nil, nil, nil, nil, 0, false, nil, nil))
 
-		pvz, err := repo.CreatePVZ("Москва", func() uuid.UUID {
+		pvz, err := repo.CreatePVZ("Москва", now, func() uuid.UUID {
 			return uuid.MustParse(pvzID)
 		})
 
 		assert.NoError(t, err)
 		assert.Equal(t, pvzID, pvz.ID)
 		assert.Equal(t, "Москва", pvz.City)
+		assert.Equal(t, "Europe/Moscow", pvz.Timezone)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("insert error", func(t *testing.T) {
 		mock.ExpectExec("INSERT INTO pvz").
-			WithArgs(pvzID, "Москва").
+			WithArgs(pvzID, "Москва", now).
 			WillReturnError(sql.ErrConnDone)
 
-		_, err := repo.CreatePVZ("Москва", func() uuid.UUID {
+		_, err := repo.CreatePVZ("Москва", now, func() uuid.UUID {
 			return uuid.MustParse(pvzID)
 		})
 
@@ -54,100 +61,510 @@ func TestPVZRepository_CreatePVZ(t *testing.T) {
 }
 
 func TestPVZRepository_GetPVZByID(t *testing.T) {
-	db, mock, err := sqlmock.New()
+	mock, err := pgxmock.NewPool()
 	assert.NoError(t, err)
-	defer db.Close()
+	defer mock.Close()
 
-	repo := NewPVZRepository(db)
+	repo := NewPVZRepository(mock, NewReadRouter(mock, nil, 0))
 	pvzID := uuid.NewString()
 	now := time.Now()
 
 	t.Run("success", func(t *testing.T) {
-		mock.ExpectQuery("SELECT id, registration_date, city FROM pvz WHERE id =").
+		mock.ExpectQuery("SELECT p.id, p.registration_date, p.city, c.timezone, p.name, p.address, p.latitude, p.longitude, p.working_hours, p.capacity, p.stored_items, p.archived, p.archived_at, p.archived_by FROM pvz p JOIN cities c ON c.name = p.city WHERE p.id =").
 			WithArgs(pvzID).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "registration_date", "city"}).
-				AddRow(pvzID, now, "Москва"))
+			WillReturnRows(pgxmock.NewRows(pvzTestColumns).
+				AddRow(pvzID, now, "Москва", "Europe/Moscow", "", "", nil, nil, nil, nil, 0, false, nil, nil))
 
-		pvz, err := repo.GetPVZByID(pvzID)
+		pvz, err := repo.GetPVZByID(pvzID, "")
 
 		assert.NoError(t, err)
 		assert.Equal(t, pvzID, pvz.ID)
 		assert.Equal(t, "Москва", pvz.City)
+		assert.Equal(t, "Europe/Moscow", pvz.Timezone)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("not found", func(t *testing.T) {
-		mock.ExpectQuery("SELECT id, registration_date, city FROM pvz WHERE id =").
+		mock.ExpectQuery("SELECT p.id, p.registration_date, p.city, c.timezone, p.name, p.address, p.latitude, p.longitude, p.working_hours, p.capacity, p.stored_items, p.archived, p.archived_at, p.archived_by FROM pvz p JOIN cities c ON c.name = p.city WHERE p.id =").
 			WithArgs(pvzID).
 			WillReturnError(sql.ErrNoRows)
 
-		_, err := repo.GetPVZByID(pvzID)
+		_, err := repo.GetPVZByID(pvzID, "")
 
 		assert.Error(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
+
+	t.Run("profile", func(t *testing.T) {
+		mock.ExpectQuery("FROM pvz p JOIN cities c ON c.name = p.city WHERE p.id =").
+			WithArgs(pvzID).
+			WillReturnRows(pgxmock.NewRows(pvzTestColumns).
+				AddRow(pvzID, now, "Москва", "Europe/Moscow", "ПВЗ на Тверской", "Тверская ул., 1", ptr(55.76), ptr(37.61),
+					[]byte(`[{"weekday":"monday","opens":"09:00","closes":"21:00"}]`), ptr(100), 42, false, nil, nil))
+
+		pvz, err := repo.GetPVZByID(pvzID, "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "ПВЗ на Тверской", pvz.Name)
+		assert.Equal(t, "Тверская ул., 1", pvz.Address)
+		assert.Equal(t, 55.76, *pvz.Latitude)
+		assert.Equal(t, 37.61, *pvz.Longitude)
+		assert.Equal(t, []models.WorkingHours{{Weekday: "monday", Opens: "09:00", Closes: "21:00"}}, pvz.WorkingHours)
+		assert.Equal(t, 100, *pvz.Capacity)
+		assert.Equal(t, 42, pvz.StoredItems)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("scoped to a city", func(t *testing.T) {
+		mock.ExpectQuery("FROM pvz p JOIN cities c ON c.name = p.city WHERE p.id = \\$1 AND p.city = \\$2").
+			WithArgs(pvzID, "Казань").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetPVZByID(pvzID, "Казань")
+
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPVZRepository_ArchivePVZ(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewPVZRepository(mock, NewReadRouter(mock, nil, 0))
+	pvzID := uuid.NewString()
+	userID := uuid.NewString()
+	now := time.Now()
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT archived FROM pvz WHERE id = $1 FOR UPDATE")).
+			WithArgs(pvzID).
+			WillReturnRows(pgxmock.NewRows([]string{"archived"}).AddRow(false))
+		mock.ExpectQuery("SELECT EXISTS").
+			WithArgs(pvzID).
+			WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE pvz SET archived = TRUE, archived_at = $2, archived_by = $3 WHERE id = $1")).
+			WithArgs(pvzID, now, userID).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.ArchivePVZ(pvzID, "", userID, now))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("archived with an API key", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT archived FROM pvz").
+			WithArgs(pvzID).
+			WillReturnRows(pgxmock.NewRows([]string{"archived"}).AddRow(false))
+		mock.ExpectQuery("SELECT EXISTS").
+			WithArgs(pvzID).
+			WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec("UPDATE pvz SET archived = TRUE").
+			WithArgs(pvzID, now, nil).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.ArchivePVZ(pvzID, "", "", now))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("open reception", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT archived FROM pvz").
+			WithArgs(pvzID).
+			WillReturnRows(pgxmock.NewRows([]string{"archived"}).AddRow(false))
+		mock.ExpectQuery("SELECT EXISTS").
+			WithArgs(pvzID).
+			WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, repo.ArchivePVZ(pvzID, "", userID, now), apperrors.ErrPVZHasOpenReception)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("already archived", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT archived FROM pvz").
+			WithArgs(pvzID).
+			WillReturnRows(pgxmock.NewRows([]string{"archived"}).AddRow(true))
+		mock.ExpectRollback()
+
+		assert.NoError(t, repo.ArchivePVZ(pvzID, "", userID, now))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("PVZ of another city", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT archived FROM pvz WHERE id = $1 AND city = $2 FOR UPDATE")).
+			WithArgs(pvzID, "Казань").
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, repo.ArchivePVZ(pvzID, "Казань", userID, now), sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPVZRepository_RestorePVZ(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewPVZRepository(mock, NewReadRouter(mock, nil, 0))
+	pvzID := uuid.NewString()
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE pvz SET archived = FALSE, archived_at = NULL, archived_by = NULL WHERE id = $1")).
+			WithArgs(pvzID).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+		assert.NoError(t, repo.RestorePVZ(pvzID, ""))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("WHERE id = $1 AND city = $2")).
+			WithArgs(pvzID, "Казань").
+			WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+		assert.ErrorIs(t, repo.RestorePVZ(pvzID, "Казань"), sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPVZRepository_UpdatePVZ(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewPVZRepository(mock, NewReadRouter(mock, nil, 0))
+	pvzID := uuid.NewString()
+	name := "ПВЗ на Тверской"
+
+	t.Run("only set fields", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("capacity = CASE WHEN $7::integer IS NULL THEN capacity ELSE NULLIF($7::integer, 0) END\n\t\tWHERE id = $1")).
+			WithArgs(pvzID, &name, (*string)(nil), (*float64)(nil), (*float64)(nil), nil, (*int)(nil)).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+		assert.NoError(t, repo.UpdatePVZ(pvzID, "", models.UpdatePVZRequest{Name: &name}))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("working hours as JSON", func(t *testing.T) {
+		hours := []models.WorkingHours{{Weekday: "monday", Opens: "09:00", Closes: "21:00"}}
+		mock.ExpectExec("UPDATE pvz SET").
+			WithArgs(pvzID, (*string)(nil), (*string)(nil), (*float64)(nil), (*float64)(nil), `[{"weekday":"monday","opens":"09:00","closes":"21:00"}]`, (*int)(nil)).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+		assert.NoError(t, repo.UpdatePVZ(pvzID, "", models.UpdatePVZRequest{WorkingHours: &hours}))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("cleared working hours", func(t *testing.T) {
+		var hours []models.WorkingHours
+		mock.ExpectExec("UPDATE pvz SET").
+			WithArgs(pvzID, (*string)(nil), (*string)(nil), (*float64)(nil), (*float64)(nil), "[]", (*int)(nil)).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+		assert.NoError(t, repo.UpdatePVZ(pvzID, "", models.UpdatePVZRequest{WorkingHours: &hours}))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("capacity", func(t *testing.T) {
+		capacity := 0
+		mock.ExpectExec("UPDATE pvz SET").
+			WithArgs(pvzID, (*string)(nil), (*string)(nil), (*float64)(nil), (*float64)(nil), nil, &capacity).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+		assert.NoError(t, repo.UpdatePVZ(pvzID, "", models.UpdatePVZRequest{Capacity: &capacity}))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("PVZ of another city", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("WHERE id = $1 AND city = $8")).
+			WithArgs(pvzID, &name, (*string)(nil), (*float64)(nil), (*float64)(nil), nil, (*int)(nil), "Казань").
+			WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+		err := repo.UpdatePVZ(pvzID, "Казань", models.UpdatePVZRequest{Name: &name})
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 }
 
+func TestPVZRepository_ListNearbyPVZs(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewPVZRepository(mock, NewReadRouter(mock, nil, 0))
+	now := time.Now()
+	nearbyColumns := append([]string{"distance_km"}, pvzTestColumns...)
+
+	t.Run("nearest first", func(t *testing.T) {
+		pvzID := uuid.NewString()
+		mock.ExpectQuery(regexp.QuoteMeta("AS distance_km, p.id")+".*"+
+			regexp.QuoteMeta("WHERE NOT p.archived AND p.latitude BETWEEN $4 AND $5 AND")+".*"+
+			regexp.QuoteMeta("<= $3 ORDER BY distance_km, p.id LIMIT $6")).
+			WithArgs(55.75, 37.62, 11.1195, pgxmock.AnyArg(), pgxmock.AnyArg(), 10).
+			WillReturnRows(pgxmock.NewRows(nearbyColumns).
+				AddRow(1.5, pvzID, now, "Москва", "Europe/Moscow", "ПВЗ на Тверской", "", ptr(55.76), ptr(37.61), []byte("[]"), nil, 0, false, nil, nil))
+
+		result, err := repo.ListNearbyPVZs(55.75, 37.62, 11.1195, time.Time{}, "", 10)
+
+		assert.NoError(t, err)
+		require.Len(t, result, 1)
+		assert.Equal(t, pvzID, result[0].ID)
+		assert.Equal(t, "ПВЗ на Тверской", result[0].Name)
+		assert.Equal(t, 1.5, result[0].DistanceKm)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("latitude band", func(t *testing.T) {
+		mock.ExpectQuery("FROM pvz p").
+			WithArgs(55.75, 37.62, 11.1195, bandArg(55.65), bandArg(55.85), 10).
+			WillReturnRows(pgxmock.NewRows(nearbyColumns))
+
+		result, err := repo.ListNearbyPVZs(55.75, 37.62, 11.1195, time.Time{}, "", 10)
+
+		assert.NoError(t, err)
+		assert.Empty(t, result)
+		assert.NotNil(t, result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("open in a city", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta("AND p.city = $7 AND EXISTS (SELECT 1 FROM jsonb_array_elements(p.working_hours) h")+".*"+
+			regexp.QuoteMeta("to_char($8::timestamptz AT TIME ZONE c.timezone, 'FMday')")).
+			WithArgs(55.75, 37.62, 5.0, pgxmock.AnyArg(), pgxmock.AnyArg(), 10, "Москва", now).
+			WillReturnRows(pgxmock.NewRows(nearbyColumns))
+
+		_, err := repo.ListNearbyPVZs(55.75, 37.62, 5, now, "Москва", 10)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPVZRepository_ListPVZUtilization(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewPVZRepository(mock, NewReadRouter(mock, nil, 0))
+	utilizationColumns := append([]string{"utilization"}, pvzTestColumns...)
+
+	t.Run("fullest first", func(t *testing.T) {
+		pvzID := uuid.NewString()
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT p.stored_items::double precision / p.capacity AS utilization, p.id")+".*"+
+			regexp.QuoteMeta("WHERE NOT p.archived AND p.capacity IS NOT NULL AND p.stored_items::double precision / p.capacity >= $1 AND p.city = $4 ORDER BY utilization DESC, p.id LIMIT $2 OFFSET $3")).
+			WithArgs(0.9, 10, 20, "Казань").
+			WillReturnRows(pgxmock.NewRows(utilizationColumns).
+				AddRow(1.1, pvzID, time.Now(), "Казань", "Europe/Moscow", "", "", nil, nil, nil, ptr(100), 110, false, nil, nil))
+
+		result, err := repo.ListPVZUtilization(0.9, "Казань", 10, 20)
+
+		assert.NoError(t, err)
+		require.Len(t, result, 1)
+		assert.Equal(t, pvzID, result[0].ID)
+		assert.Equal(t, 1.1, result[0].Utilization)
+		assert.Equal(t, 110, result[0].StoredItems)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("all PVZ with a capacity", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta("WHERE NOT p.archived AND p.capacity IS NOT NULL ORDER BY utilization DESC, p.id")).
+			WillReturnRows(pgxmock.NewRows(utilizationColumns))
+
+		result, err := repo.ListAllPVZUtilization()
+
+		assert.NoError(t, err)
+		assert.NotNil(t, result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPVZRepository_ListActivePVZs(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	repo := NewPVZRepository(mock, NewReadRouter(mock, nil, 0))
+	pvzID := uuid.NewString()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM pvz p JOIN cities c ON c.name = p.city WHERE NOT p.archived ORDER BY p.registration_date, p.id")).
+		WillReturnRows(pgxmock.NewRows(pvzTestColumns).
+			AddRow(pvzID, time.Now(), "Казань", "Europe/Moscow", "ПВЗ на Баумана", "", nil, nil, `[{"weekday":"monday","opens":"09:00","closes":"21:00"}]`, nil, 0, false, nil, nil))
+
+	result, err := repo.ListActivePVZs()
+
+	assert.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, pvzID, result[0].ID)
+	assert.Equal(t, "ПВЗ на Баумана", result[0].Name)
+	assert.Len(t, result[0].WorkingHours, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// bandArg matches a latitude bound to a thousandth of a degree.
+type bandArg float64
+
+func (a bandArg) Match(v interface{}) bool {
+	f, ok := v.(float64)
+	return ok && math.Abs(f-float64(a)) < 1e-3
+}
+
+var (
+	pvzTestColumns       = []string{"id", "registration_date", "city", "timezone", "name", "address", "latitude", "longitude", "working_hours", "capacity", "stored_items", "archived", "archived_at", "archived_by"}
+	receptionTestColumns = []string{"id", "created_at", "pvz_id", "status", "closed_at"}
+	productTestColumns   = []string{"id", "created_at", "type", "reception_id", "issued_at"}
+)
+
 func TestPVZRepository_ListPVZsWithRelations(t *testing.T) {
-	db, mock, err := sqlmock.New()
+	mock, err := pgxmock.NewPool()
 	assert.NoError(t, err)
-	defer db.Close()
+	defer mock.Close()
 
-	repo := NewPVZRepository(db)
+	repo := NewPVZRepository(mock, NewReadRouter(mock, nil, 0))
 	now := time.Now()
 
 	t.Run("success without date filter", func(t *testing.T) {
-		rows := sqlmock.NewRows([]string{
-			"id", "registration_date", "city",
-			"r.id", "r.created_at", "r.pvz_id", "r.status", "r.closed_at",
-			"pr.id", "pr.created_at", "pr.type", "pr.reception_id",
-		}).
-			AddRow(
-				"pvz1", now, "Москва",
-				"rec1", now, "pvz1", "in_progress", nil,
-				"prod1", now, "электроника", "rec1",
-			).
-			AddRow(
-				"pvz1", now, "Москва",
-				"rec1", now, "pvz1", "in_progress", nil,
-				"prod2", now, "одежда", "rec1",
-			).
-			AddRow(
-				"pvz2", now, "Санкт-Петербург",
-				"rec2", now, "pvz2", "closed", now,
-				nil, nil, nil, nil,
-			)
-
-		mock.ExpectQuery(`SELECT .* FROM pvz p`).
-			WillReturnRows(rows)
-
-		result, err := repo.ListPVZsWithRelations(time.Time{}, time.Time{}, 10, 0)
+		pvz1, pvz2, pvz3 := uuid.New(), uuid.New(), uuid.New()
+		rec1, rec2 := uuid.New(), uuid.New()
+
+		mock.ExpectQuery(`SELECT p.id, .* FROM pvz p JOIN cities c ON c.name = p.city WHERE NOT p.archived ORDER BY p.registration_date ASC, p.id LIMIT \$1 OFFSET \$2`).
+			WithArgs(10, 0).
+			WillReturnRows(pgxmock.NewRows(pvzTestColumns).
+				AddRow(pvz1.String(), now, "Москва", "Europe/Moscow", "", "", nil, nil, nil, nil, 0, false, nil, nil).
+				AddRow(pvz2.String(), now, "Санкт-Петербург", "Europe/Moscow", "", "", nil, nil, nil, nil, 0, false, nil, nil).
+				AddRow(pvz3.String(), now, "Казань", "Europe/Moscow", "", "", nil, nil, nil, nil, 0, false, nil, nil))
+		mock.ExpectQuery(`FROM receptions WHERE pvz_id = ANY\(\$1\)`).
+			WithArgs([]uuid.UUID{pvz1, pvz2, pvz3}).
+			WillReturnRows(pgxmock.NewRows(receptionTestColumns).
+				AddRow(rec1.String(), now, pvz1.String(), "in_progress", nil).
+				AddRow(rec2.String(), now, pvz2.String(), "close", &now))
+		mock.ExpectQuery(`FROM products WHERE reception_id = ANY\(\$1\) ORDER BY seq`).
+			WithArgs([]uuid.UUID{rec1, rec2}).
+			WillReturnRows(pgxmock.NewRows(productTestColumns).
+				AddRow("prod1", now, "электроника", rec1.String(), nil).
+				AddRow("prod2", now, "одежда", rec1.String(), nil).
+				AddRow("prod3", now, "обувь", rec2.String(), &now))
+
+		result, err := repo.ListPVZsWithRelations(time.Time{}, time.Time{}, "", false, 10, 0)
+
+		assert.NoError(t, err)
+		assert.Len(t, result, 3)
+
+		assert.Equal(t, pvz1.String(), result[0].PVZ.ID)
+		assert.Equal(t, "Europe/Moscow", result[0].PVZ.Timezone)
+		assert.Len(t, result[0].Receptions, 1)
+		assert.Equal(t, rec1.String(), result[0].Receptions[0].Reception.ID)
+		assert.Equal(t, "in_progress", result[0].Receptions[0].Reception.Status)
+		assert.Nil(t, result[0].Receptions[0].Reception.ClosedAt)
+		assert.Equal(t, "prod1", result[0].Receptions[0].Products[0].ID)
+		assert.Equal(t, "prod2", result[0].Receptions[0].Products[1].ID)
+
+		assert.Len(t, result[1].Receptions, 1)
+		assert.Equal(t, "close", result[1].Receptions[0].Reception.Status)
+		assert.NotNil(t, result[1].Receptions[0].Reception.ClosedAt)
+		assert.Nil(t, result[0].Receptions[0].Products[0].IssuedAt)
+		assert.Len(t, result[1].Receptions[0].Products, 1)
+		assert.NotNil(t, result[1].Receptions[0].Products[0].IssuedAt)
+
+		assert.Equal(t, []ReceptionResponse{}, result[2].Receptions)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("PVZ without receptions skip loading products", func(t *testing.T) {
+		pvz1 := uuid.New()
+		mock.ExpectQuery(`FROM pvz p`).
+			WithArgs(10, 0).
+			WillReturnRows(pgxmock.NewRows(pvzTestColumns).AddRow(pvz1.String(), now, "Москва", "Europe/Moscow", "", "", nil, nil, nil, nil, 0, false, nil, nil))
+		mock.ExpectQuery(`FROM receptions`).
+			WithArgs([]uuid.UUID{pvz1}).
+			WillReturnRows(pgxmock.NewRows(receptionTestColumns))
+
+		result, err := repo.ListPVZsWithRelations(time.Time{}, time.Time{}, "", false, 10, 0)
+
+		assert.NoError(t, err)
+		assert.Len(t, result, 1)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("scoped to a city with date filter", func(t *testing.T) {
+		start, end := now.Add(-time.Hour), now
+		mock.ExpectQuery(`FROM pvz p .* WHERE p.registration_date >= \$3 AND p.registration_date <= \$4 AND p.city = \$5 AND NOT p.archived ORDER BY`).
+			WithArgs(10, 0, start, end, "Казань").
+			WillReturnRows(pgxmock.NewRows(pvzTestColumns))
+
+		result, err := repo.ListPVZsWithRelations(start, end, "Казань", false, 10, 0)
 
 		assert.NoError(t, err)
-		assert.Len(t, result, 2)
-
-		foundPVZ1 := false
-		foundPVZ2 := false
-		for _, pvz := range result {
-			if pvz.PVZ.City == "Москва" {
-				foundPVZ1 = true
-				assert.Len(t, pvz.Receptions, 1)
-				assert.Len(t, pvz.Receptions[0].Products, 2)
-				assert.Equal(t, "rec1", pvz.Receptions[0].Reception.ID)
-				assert.Equal(t, "in_progress", pvz.Receptions[0].Reception.Status)
-			}
-			if pvz.PVZ.City == "Санкт-Петербург" {
-				foundPVZ2 = true
-				assert.Len(t, pvz.Receptions, 1)
-				assert.Len(t, pvz.Receptions[0].Products, 0)
-				assert.Equal(t, "rec2", pvz.Receptions[0].Reception.ID)
-				assert.Equal(t, "closed", pvz.Receptions[0].Reception.Status)
-			}
-		}
-
-		assert.True(t, foundPVZ1)
-		assert.True(t, foundPVZ2)
+		assert.Equal(t, []PVZResponse{}, result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("archived PVZ are included on request", func(t *testing.T) {
+		pvz1 := uuid.New()
+		archivedBy := uuid.NewString()
+		mock.ExpectQuery(`FROM pvz p JOIN cities c ON c.name = p.city ORDER BY`).
+			WithArgs(10, 0).
+			WillReturnRows(pgxmock.NewRows(pvzTestColumns).AddRow(pvz1.String(), now, "Москва", "Europe/Moscow", "", "", nil, nil, nil, nil, 0, true, &now, &archivedBy))
+		mock.ExpectQuery(`FROM receptions`).
+			WithArgs([]uuid.UUID{pvz1}).
+			WillReturnRows(pgxmock.NewRows(receptionTestColumns))
+
+		result, err := repo.ListPVZsWithRelations(time.Time{}, time.Time{}, "", true, 10, 0)
+
+		assert.NoError(t, err)
+		assert.Len(t, result, 1)
+		assert.True(t, result[0].PVZ.Archived)
+		assert.Equal(t, &archivedBy, result[0].PVZ.ArchivedBy)
+		assert.NotNil(t, result[0].PVZ.ArchivedAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 
+	t.Run("receptions error", func(t *testing.T) {
+		mock.ExpectQuery(`FROM pvz p`).
+			WithArgs(10, 0).
+			WillReturnRows(pgxmock.NewRows(pvzTestColumns).AddRow(uuid.NewString(), now, "Москва", "Europe/Moscow", "", "", nil, nil, nil, nil, 0, false, nil, nil))
+		mock.ExpectQuery(`FROM receptions`).
+			WithArgs(pgxmock.AnyArg()).
+			WillReturnError(sql.ErrConnDone)
+
+		_, err := repo.ListPVZsWithRelations(time.Time{}, time.Time{}, "", false, 10, 0)
+
+		assert.ErrorIs(t, err, sql.ErrConnDone)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 }
+
+func TestPVZRepository_ListPVZsWithRelationsReadsFromReplica(t *testing.T) {
+	primaryMock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer primaryMock.Close()
+	replicaMock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer replicaMock.Close()
+
+	reads := NewReadRouter(primaryMock, replicaMock, 5*time.Second)
+	repo := NewPVZRepository(primaryMock, reads)
+
+	replicaMock.ExpectQuery("pg_last_xact_replay_timestamp").
+		WillReturnRows(pgxmock.NewRows(lagColumns).AddRow(0.5, true))
+	reads.CheckReplica()
+	replicaMock.ExpectQuery(`FROM pvz p`).
+		WithArgs(10, 0).
+		WillReturnRows(pgxmock.NewRows(pvzTestColumns).AddRow(uuid.NewString(), time.Now(), "Казань", "Europe/Moscow", "", "", nil, nil, nil, nil, 0, false, nil, nil))
+	replicaMock.ExpectQuery(`FROM receptions`).
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows(receptionTestColumns))
+
+	result, err := repo.ListPVZsWithRelations(time.Time{}, time.Time{}, "", false, 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"pvzService/internal/ratelimit"
+)
+
+// RateLimitRepositoryImpl is a ratelimit.Store that keeps the counters in Postgres, so
+// all instances of the service share one budget.
+type RateLimitRepositoryImpl struct {
+	db DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+var _ ratelimit.Store = (*RateLimitRepositoryImpl)(nil)
+
+func NewRateLimitRepository(db DB) *RateLimitRepositoryImpl {
+	return &RateLimitRepositoryImpl{db: db, lastSweep: time.Now()}
+}
+
+// Hit counts a request in the current window of the key and starts a new window when
+// the previous one has ended. The end of the window is computed from the remaining
+// seconds, so the result doesn't depend on the time zone of the database.
+func (r *RateLimitRepositoryImpl) Hit(key string, window time.Duration) (int, time.Time, error) {
+	r.sweep(window)
+
+	var hits int
+	var remaining float64
+	err := r.db.QueryRow(context.Background(), `
+        INSERT INTO rate_limits AS l (key, hits, reset_at)
+        VALUES ($1, 1, NOW() + make_interval(secs => $2))
+        ON CONFLICT (key) DO UPDATE SET
+            hits = CASE WHEN l.reset_at <= NOW() THEN 1 ELSE l.hits + 1 END,
+            reset_at = CASE WHEN l.reset_at <= NOW() THEN NOW() + make_interval(secs => $2) ELSE l.reset_at END
+        RETURNING l.hits, EXTRACT(EPOCH FROM l.reset_at - NOW())`,
+		key, window.Seconds()).Scan(&hits, &remaining)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return hits, time.Now().Add(time.Duration(remaining * float64(time.Second))), nil
+}
+
+// sweep removes counters of finished windows at most once per window, so clients that
+// stopped sending requests don't stay in the table. A failed sweep is retried later.
+func (r *RateLimitRepositoryImpl) sweep(window time.Duration) {
+	r.mu.Lock()
+	if time.Since(r.lastSweep) < window {
+		r.mu.Unlock()
+		return
+	}
+	r.lastSweep = time.Now()
+	r.mu.Unlock()
+
+	if _, err := r.db.Exec(context.Background(), "DELETE FROM rate_limits WHERE reset_at <= NOW()"); err != nil {
+		log.Printf("Failed to delete expired rate limit counters: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitRepository_Hit(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewRateLimitRepository(mock)
+
+	mock.ExpectQuery("INSERT INTO rate_limits").
+		WithArgs("write:user:1", float64(60)).
+		WillReturnRows(pgxmock.NewRows([]string{"hits", "remaining"}).AddRow(3, 42.5))
+
+	hits, resetAt, err := repo.Hit("write:user:1", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, hits)
+	assert.WithinDuration(t, time.Now().Add(42500*time.Millisecond), resetAt, time.Second)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRateLimitRepository_HitSweepsExpiredCounters(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewRateLimitRepository(mock)
+	repo.lastSweep = time.Now().Add(-time.Hour)
+
+	mock.ExpectExec("DELETE FROM rate_limits").WillReturnResult(pgxmock.NewResult("DELETE", 5))
+	mock.ExpectQuery("INSERT INTO rate_limits").
+		WithArgs("auth:ip:10.0.0.1", float64(60)).
+		WillReturnRows(pgxmock.NewRows([]string{"hits", "remaining"}).AddRow(1, 60.0))
+
+	hits, _, err := repo.Hit("auth:ip:10.0.0.1", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, hits)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
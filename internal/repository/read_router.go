@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"pvzService/internal/prometheus"
+)
+
+// replicaLagCheckTimeout bounds a single lag measurement, so that an unreachable replica
+// does not hold up the next check.
+const replicaLagCheckTimeout = time.Second
+
+// ReadRouter sends read-only queries that tolerate slightly stale data to the read
+// replica and everything else to the primary. While the replica lags behind by more
+// than the threshold or can't be reached, reads go to the primary as well.
+type ReadRouter struct {
+	primary DB
+	replica DB
+	maxLag  time.Duration
+
+	useReplica atomic.Bool
+}
+
+// NewReadRouter routes every query to the primary when replica is nil.
+func NewReadRouter(primary, replica DB, maxLag time.Duration) *ReadRouter {
+	return &ReadRouter{primary: primary, replica: replica, maxLag: maxLag}
+}
+
+// HasReplica reports whether a replica is configured and CheckReplica has to be run.
+func (r *ReadRouter) HasReplica() bool {
+	return r.replica != nil
+}
+
+// ReadDB returns the database for a read-only query. It only reads the result of the
+// last CheckReplica, until the first check passes reads stay on the primary.
+func (r *ReadRouter) ReadDB() DB {
+	if r.replica == nil {
+		return r.primary
+	}
+	if r.useReplica.Load() {
+		prometheus.ReadQueries.WithLabelValues("replica").Inc()
+		return r.replica
+	}
+	prometheus.ReadQueries.WithLabelValues("primary").Inc()
+	return r.primary
+}
+
+// CheckReplica measures the replication lag and decides whether reads may use the
+// replica. It is run periodically by a background worker, not on the request path.
+func (r *ReadRouter) CheckReplica() {
+	if r.replica == nil {
+		return
+	}
+
+	usable := r.useReplica.Load()
+	lag, err := r.replicaLag()
+	switch {
+	case err != nil:
+		if usable {
+			log.Printf("Read replica is unavailable, reading from the primary: %v", err)
+		}
+		usable = false
+	case lag > r.maxLag:
+		if usable {
+			log.Printf("Read replica lags behind by %s, reading from the primary", lag)
+		}
+		usable = false
+	default:
+		if !usable {
+			log.Printf("Read replica lags behind by %s, reading from the replica", lag)
+		}
+		usable = true
+	}
+	r.useReplica.Store(usable)
+}
+
+// errReplicaNotStreaming is reported while the replica has no WAL receiver streaming
+// from the primary, its lag can't be measured then.
+var errReplicaNotStreaming = errors.New("WAL receiver is not streaming from the primary")
+
+// replicaLag is the age of the last replayed transaction. A replica that has replayed
+// everything it received is not lagging even if the primary had no writes for a while,
+// as long as its WAL receiver is streaming: a replica cut off from the primary has
+// replayed everything it received too. The receiver status is only visible to roles
+// with pg_read_all_stats, for other roles a running receiver is enough.
+func (r *ReadRouter) replicaLag() (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaLagCheckTimeout)
+	defer cancel()
+
+	var seconds float64
+	var streaming bool
+	err := r.replica.QueryRow(ctx, `
+        SELECT COALESCE(CASE
+            WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+            ELSE EXTRACT(EPOCH FROM NOW() - pg_last_xact_replay_timestamp())
+        END, 0),
+        EXISTS (SELECT 1 FROM pg_stat_wal_receiver WHERE status IS NULL OR status = 'streaming')`).
+		Scan(&seconds, &streaming)
+	if err != nil {
+		return 0, err
+	}
+	if !streaming {
+		return 0, errReplicaNotStreaming
+	}
+
+	lag := time.Duration(seconds * float64(time.Second))
+	prometheus.ReplicaLag.Set(lag.Seconds())
+	return lag, nil
+}
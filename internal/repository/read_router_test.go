@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var lagColumns = []string{"lag", "streaming"}
+
+func TestReadRouter_WithoutReplica(t *testing.T) {
+	primaryMock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer primaryMock.Close()
+
+	router := NewReadRouter(primaryMock, nil, time.Second)
+	assert.False(t, router.HasReplica())
+	router.CheckReplica()
+	assert.Same(t, primaryMock, router.ReadDB())
+}
+
+func TestReadRouter_ReadDB(t *testing.T) {
+	tests := []struct {
+		name         string
+		lag          float64
+		notStreaming bool
+		lagErr       error
+		wantReplica  bool
+	}{
+		{name: "replica in sync", lag: 0, wantReplica: true},
+		{name: "lag below threshold", lag: 4.5, wantReplica: true},
+		{name: "lag above threshold", lag: 30, wantReplica: false},
+		{name: "replica unavailable", lagErr: errors.New("connection refused"), wantReplica: false},
+		// Without a WAL receiver the replica has replayed everything it got, but is stale
+		{name: "WAL receiver down", lag: 0, notStreaming: true, wantReplica: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primaryMock, err := pgxmock.NewPool()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer primaryMock.Close()
+			replicaMock, err := pgxmock.NewPool()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer replicaMock.Close()
+
+			lagQuery := replicaMock.ExpectQuery("pg_last_xact_replay_timestamp")
+			if tt.lagErr != nil {
+				lagQuery.WillReturnError(tt.lagErr)
+			} else {
+				lagQuery.WillReturnRows(pgxmock.NewRows(lagColumns).AddRow(tt.lag, !tt.notStreaming))
+			}
+
+			router := NewReadRouter(primaryMock, replicaMock, 5*time.Second)
+			// Reads stay on the primary until the first check
+			assert.Same(t, primaryMock, router.ReadDB())
+
+			router.CheckReplica()
+			expected := primaryMock
+			if tt.wantReplica {
+				expected = replicaMock
+			}
+			// ReadDB does not measure the lag itself
+			assert.Same(t, expected, router.ReadDB())
+			assert.Same(t, expected, router.ReadDB())
+			assert.NoError(t, replicaMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReadRouter_FallsBackWhenReplicaStartsLagging(t *testing.T) {
+	primaryMock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer primaryMock.Close()
+	replicaMock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer replicaMock.Close()
+
+	router := NewReadRouter(primaryMock, replicaMock, 5*time.Second)
+	replicaMock.ExpectQuery("pg_last_xact_replay_timestamp").WillReturnRows(pgxmock.NewRows(lagColumns).AddRow(1.0, true))
+	router.CheckReplica()
+	assert.Same(t, replicaMock, router.ReadDB())
+
+	replicaMock.ExpectQuery("pg_last_xact_replay_timestamp").WillReturnRows(pgxmock.NewRows(lagColumns).AddRow(60.0, true))
+	router.CheckReplica()
+	assert.Same(t, primaryMock, router.ReadDB())
+
+	replicaMock.ExpectQuery("pg_last_xact_replay_timestamp").WillReturnRows(pgxmock.NewRows(lagColumns).AddRow(0.0, true))
+	router.CheckReplica()
+	assert.Same(t, replicaMock, router.ReadDB())
+
+	// The replica stops receiving WAL and keeps reporting no lag
+	replicaMock.ExpectQuery("pg_stat_wal_receiver").WillReturnRows(pgxmock.NewRows(lagColumns).AddRow(0.0, false))
+	router.CheckReplica()
+	assert.Same(t, primaryMock, router.ReadDB())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
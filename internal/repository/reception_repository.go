@@ -1,15 +1,18 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"github.com/google/uuid"
 	"time"
 
+	"pvzService/internal/apperrors"
 	"pvzService/internal/models"
 )
 
 type ReceptionRepository interface {
-	CreateReception(pvzID string, idGenerator func() uuid.UUID) (string, error)
+	CreateReception(pvzID string, createdAt time.Time, idGenerator func() uuid.UUID) (string, error)
 	GetReceptionByID(id string) (models.Reception, error)
 	GetOpenReception(pvzID string) (models.Reception, error)
 	CloseReception(id string, closeTime time.Time) error
@@ -17,46 +20,67 @@ type ReceptionRepository interface {
 }
 
 type ReceptionRepositoryImpl struct {
-	db *sql.DB
+	db DB
 }
 
-func NewReceptionRepository(db *sql.DB) *ReceptionRepositoryImpl {
+func NewReceptionRepository(db DB) *ReceptionRepositoryImpl {
 	return &ReceptionRepositoryImpl{db: db}
 }
 
-func (r *ReceptionRepositoryImpl) CreateReception(pvzID string, idGenerator func() uuid.UUID) (string, error) {
+// CreateReception opens a reception at a PVZ that is not archived. The PVZ row is
+// share-locked while the reception is inserted, so the PVZ can't be archived at the
+// same time.
+func (r *ReceptionRepositoryImpl) CreateReception(pvzID string, createdAt time.Time, idGenerator func() uuid.UUID) (string, error) {
+	ctx := context.Background()
 	receptionID := idGenerator().String()
-	_, err := r.db.Exec("INSERT INTO receptions (id, pvz_id, status, created_at) VALUES ($1, $2, $3, $4)",
-		receptionID, pvzID, "in_progress", time.Now())
-	return receptionID, err
+	res, err := r.db.Exec(ctx, "INSERT INTO receptions (id, pvz_id, status, created_at) "+
+		"SELECT $1, id, 'in_progress', $3 FROM pvz WHERE id = $2 AND NOT archived FOR SHARE",
+		receptionID, pvzID, createdAt)
+	if err != nil {
+		if isPgError(err, pgForeignKeyViolation) {
+			return "", apperrors.ErrPVZNotFound
+		}
+		return receptionID, err
+	}
+
+	if res.RowsAffected() == 0 {
+		// The PVZ is unknown or was archived when the reception was inserted
+		err := r.db.QueryRow(ctx, "SELECT 1 FROM pvz WHERE id = $1", pvzID).Scan(new(int))
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", apperrors.ErrPVZNotFound
+		}
+		if err != nil {
+			return "", err
+		}
+		return "", apperrors.ErrPVZArchived
+	}
+	return receptionID, nil
 }
 
 func (r *ReceptionRepositoryImpl) GetReceptionByID(id string) (models.Reception, error) {
 	var reception models.Reception
-	err := r.db.QueryRow("SELECT id, created_at, pvz_id, status, closed_at FROM receptions WHERE id = $1", id).
+	err := r.db.QueryRow(context.Background(), "SELECT id, created_at, pvz_id, status, closed_at FROM receptions WHERE id = $1", id).
 		Scan(&reception.ID, &reception.DateTime, &reception.PvzId, &reception.Status, &reception.ClosedAt)
 	return reception, err
 }
 
 func (r *ReceptionRepositoryImpl) GetOpenReception(pvzID string) (models.Reception, error) {
 	var reception models.Reception
-	err := r.db.QueryRow(
-		"SELECT id, created_at, pvz_id, status, closed_at FROM receptions WHERE pvz_id = $1 AND status = 'in_progress'",
+	err := r.db.QueryRow(context.Background(), "SELECT id, created_at, pvz_id, status, closed_at FROM receptions WHERE pvz_id = $1 AND status = 'in_progress'",
 		pvzID).
 		Scan(&reception.ID, &reception.DateTime, &reception.PvzId, &reception.Status, &reception.ClosedAt)
 	return reception, err
 }
 
 func (r *ReceptionRepositoryImpl) CloseReception(id string, closeTime time.Time) error {
-	_, err := r.db.Exec("UPDATE receptions SET status = 'close', closed_at = $1 WHERE id = $2",
+	_, err := r.db.Exec(context.Background(), "UPDATE receptions SET status = 'close', closed_at = $1 WHERE id = $2",
 		closeTime, id)
 	return err
 }
 
 func (r *ReceptionRepositoryImpl) HasOpenReception(pvzID string) (bool, error) {
 	var exists bool
-	err := r.db.QueryRow(
-		"SELECT EXISTS (SELECT 1 FROM receptions WHERE pvz_id = $1 AND status = 'in_progress')",
+	err := r.db.QueryRow(context.Background(), "SELECT EXISTS (SELECT 1 FROM receptions WHERE pvz_id = $1 AND status = 'in_progress')",
 		pvzID).
 		Scan(&exists)
 	return exists, err
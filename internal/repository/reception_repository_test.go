@@ -3,33 +3,36 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"regexp"
 	"testing"
 	"time"
 
-	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v4"
 	"github.com/stretchr/testify/assert"
+	"pvzService/internal/apperrors"
 	"pvzService/internal/models"
 )
 
 func TestCreateReception(t *testing.T) {
-	db, mock, err := sqlmock.New()
+	mock, err := pgxmock.NewPool()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
-	defer db.Close()
+	defer mock.Close()
 
-	repo := NewReceptionRepository(db)
+	repo := NewReceptionRepository(mock)
 
 	t.Run("successful creation", func(t *testing.T) {
 		pvzID := uuid.New().String()
 		expectedID := uuid.New()
+		createdAt := time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC)
 
-		mock.ExpectExec("INSERT INTO receptions").
-			WithArgs(expectedID.String(), pvzID, "in_progress", sqlmock.AnyArg()).
-			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO receptions (id, pvz_id, status, created_at) SELECT $1, id, 'in_progress', $3 FROM pvz WHERE id = $2 AND NOT archived FOR SHARE")).
+			WithArgs(expectedID.String(), pvzID, createdAt).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
 
-		id, err := repo.CreateReception(pvzID, func() uuid.UUID { return expectedID })
+		id, err := repo.CreateReception(pvzID, createdAt, func() uuid.UUID { return expectedID })
 
 		assert.NoError(t, err)
 		assert.Equal(t, expectedID.String(), id)
@@ -41,25 +44,57 @@ func TestCreateReception(t *testing.T) {
 		expectedError := errors.New("database error")
 
 		mock.ExpectExec("INSERT INTO receptions").
-			WithArgs(sqlmock.AnyArg(), pvzID, "in_progress", sqlmock.AnyArg()).
+			WithArgs(pgxmock.AnyArg(), pvzID, pgxmock.AnyArg()).
 			WillReturnError(expectedError)
 
-		_, err := repo.CreateReception(pvzID, uuid.New)
+		_, err := repo.CreateReception(pvzID, time.Now(), uuid.New)
 
 		assert.Error(t, err)
 		assert.Equal(t, expectedError, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
+
+	t.Run("archived PVZ", func(t *testing.T) {
+		pvzID := uuid.New().String()
+
+		mock.ExpectExec("INSERT INTO receptions").
+			WithArgs(pgxmock.AnyArg(), pvzID, pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("INSERT", 0))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT 1 FROM pvz WHERE id = $1")).
+			WithArgs(pvzID).
+			WillReturnRows(pgxmock.NewRows([]string{"?column?"}).AddRow(1))
+
+		_, err := repo.CreateReception(pvzID, time.Now(), uuid.New)
+
+		assert.ErrorIs(t, err, apperrors.ErrPVZArchived)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown PVZ", func(t *testing.T) {
+		pvzID := uuid.New().String()
+
+		mock.ExpectExec("INSERT INTO receptions").
+			WithArgs(pgxmock.AnyArg(), pvzID, pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("INSERT", 0))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT 1 FROM pvz WHERE id = $1")).
+			WithArgs(pvzID).
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.CreateReception(pvzID, time.Now(), uuid.New)
+
+		assert.ErrorIs(t, err, apperrors.ErrPVZNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 }
 
 func TestGetReceptionByID(t *testing.T) {
-	db, mock, err := sqlmock.New()
+	mock, err := pgxmock.NewPool()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
-	defer db.Close()
+	defer mock.Close()
 
-	repo := NewReceptionRepository(db)
+	repo := NewReceptionRepository(mock)
 
 	t.Run("successful retrieval", func(t *testing.T) {
 		receptionID := uuid.New().String()
@@ -75,7 +110,7 @@ func TestGetReceptionByID(t *testing.T) {
 			ClosedAt: &closedAt,
 		}
 
-		rows := sqlmock.NewRows([]string{"id", "created_at", "pvz_id", "status", "closed_at"}).
+		rows := pgxmock.NewRows([]string{"id", "created_at", "pvz_id", "status", "closed_at"}).
 			AddRow(expectedReception.ID, expectedReception.DateTime, expectedReception.PvzId, expectedReception.Status, expectedReception.ClosedAt)
 
 		mock.ExpectQuery("SELECT id, created_at, pvz_id, status, closed_at FROM receptions WHERE id = \\$1").
@@ -120,13 +155,13 @@ func TestGetReceptionByID(t *testing.T) {
 }
 
 func TestGetOpenReception(t *testing.T) {
-	db, mock, err := sqlmock.New()
+	mock, err := pgxmock.NewPool()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
-	defer db.Close()
+	defer mock.Close()
 
-	repo := NewReceptionRepository(db)
+	repo := NewReceptionRepository(mock)
 
 	t.Run("successful retrieval", func(t *testing.T) {
 		pvzID := uuid.New().String()
@@ -141,7 +176,7 @@ func TestGetOpenReception(t *testing.T) {
 			ClosedAt: nil,
 		}
 
-		rows := sqlmock.NewRows([]string{"id", "created_at", "pvz_id", "status", "closed_at"}).
+		rows := pgxmock.NewRows([]string{"id", "created_at", "pvz_id", "status", "closed_at"}).
 			AddRow(expectedReception.ID, expectedReception.DateTime, expectedReception.PvzId, expectedReception.Status, nil)
 
 		mock.ExpectQuery("SELECT id, created_at, pvz_id, status, closed_at FROM receptions WHERE pvz_id = \\$1 AND status = 'in_progress'").
@@ -171,13 +206,13 @@ func TestGetOpenReception(t *testing.T) {
 }
 
 func TestCloseReception(t *testing.T) {
-	db, mock, err := sqlmock.New()
+	mock, err := pgxmock.NewPool()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
-	defer db.Close()
+	defer mock.Close()
 
-	repo := NewReceptionRepository(db)
+	repo := NewReceptionRepository(mock)
 
 	t.Run("successful close", func(t *testing.T) {
 		receptionID := uuid.New().String()
@@ -185,7 +220,7 @@ func TestCloseReception(t *testing.T) {
 
 		mock.ExpectExec("UPDATE receptions SET status = 'close', closed_at = \\$1 WHERE id = \\$2").
 			WithArgs(closeTime, receptionID).
-			WillReturnResult(sqlmock.NewResult(0, 1))
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
 
 		err := repo.CloseReception(receptionID, closeTime)
 
@@ -199,7 +234,7 @@ func TestCloseReception(t *testing.T) {
 
 		mock.ExpectExec("UPDATE receptions SET status = 'close', closed_at = \\$1 WHERE id = \\$2").
 			WithArgs(closeTime, receptionID).
-			WillReturnResult(sqlmock.NewResult(0, 0))
+			WillReturnResult(pgxmock.NewResult("UPDATE", 0))
 
 		err := repo.CloseReception(receptionID, closeTime)
 
@@ -225,18 +260,18 @@ func TestCloseReception(t *testing.T) {
 }
 
 func TestHasOpenReception(t *testing.T) {
-	db, mock, err := sqlmock.New()
+	mock, err := pgxmock.NewPool()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
-	defer db.Close()
+	defer mock.Close()
 
-	repo := NewReceptionRepository(db)
+	repo := NewReceptionRepository(mock)
 
 	t.Run("has open reception", func(t *testing.T) {
 		pvzID := uuid.New().String()
 
-		rows := sqlmock.NewRows([]string{"exists"}).AddRow(true)
+		rows := pgxmock.NewRows([]string{"exists"}).AddRow(true)
 
 		mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM receptions WHERE pvz_id = \\$1 AND status = 'in_progress'\\)").
 			WithArgs(pvzID).
@@ -252,7 +287,7 @@ func TestHasOpenReception(t *testing.T) {
 	t.Run("no open reception", func(t *testing.T) {
 		pvzID := uuid.New().String()
 
-		rows := sqlmock.NewRows([]string{"exists"}).AddRow(false)
+		rows := pgxmock.NewRows([]string{"exists"}).AddRow(false)
 
 		mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM receptions WHERE pvz_id = \\$1 AND status = 'in_progress'\\)").
 			WithArgs(pvzID).
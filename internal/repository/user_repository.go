@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jackc/pgx/v5"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
+)
+
+type UserRepository interface {
+	ListUsers(role string, page, limit int) ([]models.User, error)
+	UpdateUser(id string, role, city *string, active *bool) (models.User, error)
+	DeleteUser(id string) error
+	GetUserStatus(id string) (models.UserStatus, error)
+}
+
+type UserRepositoryImpl struct {
+	db DB
+}
+
+func NewUserRepository(db DB) UserRepository {
+	return &UserRepositoryImpl{db: db}
+}
+
+const userColumns = "id, email, role, city, is_active, created_at"
+
+func scanUser(row pgx.Row) (models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.Email, &user.Role, &user.City, &user.Active, &user.CreatedAt)
+	return user, err
+}
+
+func (r *UserRepositoryImpl) ListUsers(role string, page, limit int) ([]models.User, error) {
+	query := "SELECT " + userColumns + " FROM users"
+	args := []interface{}{limit, (page - 1) * limit}
+	if role != "" {
+		query += " WHERE role = $3"
+		args = append(args, role)
+	}
+	query += " ORDER BY created_at, id LIMIT $1 OFFSET $2"
+
+	rows, err := r.db.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// UpdateUser changes the role, city and active flag when they are not nil and returns
+// the updated user, sql.ErrNoRows is returned for an unknown id. A city manager keeps
+// their city unless a new one is given, any other role loses it. ErrCityAssignment is
+// returned when a city manager ends up without a city or another role with one.
+func (r *UserRepositoryImpl) UpdateUser(id string, role, city *string, active *bool) (models.User, error) {
+	user, err := scanUser(r.db.QueryRow(context.Background(), `
+        UPDATE users SET
+            role = COALESCE($2, role),
+            city = CASE WHEN COALESCE($2, role) = 'city_manager' THEN COALESCE($3, city) ELSE $3 END,
+            is_active = COALESCE($4, is_active)
+        WHERE id = $1 RETURNING `+userColumns,
+		id, role, city, active))
+	if pgErr := pgError(err); pgErr != nil && pgErr.Code == pgCheckViolation && pgErr.ConstraintName == "users_city_role_check" {
+		return models.User{}, apperrors.ErrCityAssignment
+	}
+	return user, err
+}
+
+func (r *UserRepositoryImpl) DeleteUser(id string) error {
+	res, err := r.db.Exec(context.Background(), "DELETE FROM users WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetUserStatus returns the current role, city and state of a user, sql.ErrNoRows for
+// deleted users.
+func (r *UserRepositoryImpl) GetUserStatus(id string) (models.UserStatus, error) {
+	var status models.UserStatus
+	err := r.db.QueryRow(context.Background(), "SELECT role, is_active, password_changed_at, city FROM users WHERE id = $1", id).
+		Scan(&status.Role, &status.Active, &status.PasswordChangedAt, &status.City)
+	if err != nil {
+		return models.UserStatus{}, err
+	}
+	return status, nil
+}
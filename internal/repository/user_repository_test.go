@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+
+	"pvzService/internal/apperrors"
+)
+
+func TestUserRepository_ListUsers(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewUserRepository(mock)
+	createdAt := time.Now()
+
+	mock.ExpectQuery("SELECT id, email, role, city, is_active, created_at FROM users WHERE role = \\$3 ORDER BY created_at, id LIMIT \\$1 OFFSET \\$2").
+		WithArgs(10, 10, "employee").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "email", "role", "city", "is_active", "created_at"}).
+			AddRow("user1", "a@example.com", "employee", nil, true, createdAt).
+			AddRow("user2", "b@example.com", "employee", nil, false, createdAt))
+
+	users, err := repo.ListUsers("employee", 2, 10)
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "user1", users[0].ID)
+	assert.False(t, users[1].Active)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_ListUsers_Empty(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewUserRepository(mock)
+
+	mock.ExpectQuery("SELECT id, email, role, city, is_active, created_at FROM users ORDER BY").
+		WithArgs(30, 0).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "email", "role", "city", "is_active", "created_at"}))
+
+	users, err := repo.ListUsers("", 1, 30)
+	assert.NoError(t, err)
+	assert.NotNil(t, users)
+	assert.Empty(t, users)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_UpdateUser(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewUserRepository(mock)
+	active := false
+
+	mock.ExpectQuery("UPDATE users SET").
+		WithArgs("user1", (*string)(nil), (*string)(nil), &active).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "email", "role", "city", "is_active", "created_at"}).
+			AddRow("user1", "a@example.com", "employee", nil, false, time.Now()))
+
+	user, err := repo.UpdateUser("user1", nil, nil, &active)
+	assert.NoError(t, err)
+	assert.False(t, user.Active)
+	assert.Nil(t, user.City)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_UpdateUser_CityManager(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewUserRepository(mock)
+	role, city := "city_manager", "Казань"
+
+	mock.ExpectQuery("UPDATE users SET").
+		WithArgs("user1", &role, &city, (*bool)(nil)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "email", "role", "city", "is_active", "created_at"}).
+			AddRow("user1", "a@example.com", "city_manager", ptr("Казань"), true, time.Now()))
+	mock.ExpectQuery("UPDATE users SET").
+		WithArgs("user2", &role, (*string)(nil), (*bool)(nil)).
+		WillReturnError(&pgconn.PgError{Code: "23514", ConstraintName: "users_city_role_check"})
+
+	user, err := repo.UpdateUser("user1", &role, &city, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Казань", *user.City)
+
+	_, err = repo.UpdateUser("user2", &role, nil, nil)
+	assert.ErrorIs(t, err, apperrors.ErrCityAssignment)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_DeleteUser(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewUserRepository(mock)
+
+	mock.ExpectExec("DELETE FROM users WHERE id =").
+		WithArgs("user1").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec("DELETE FROM users WHERE id =").
+		WithArgs("missing").
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+
+	assert.NoError(t, repo.DeleteUser("user1"))
+	assert.ErrorIs(t, repo.DeleteUser("missing"), sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_GetUserStatus(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	repo := NewUserRepository(mock)
+	changedAt := time.Now()
+
+	mock.ExpectQuery("SELECT role, is_active, password_changed_at, city FROM users WHERE id =").
+		WithArgs("user1").
+		WillReturnRows(pgxmock.NewRows([]string{"role", "is_active", "password_changed_at", "city"}).AddRow("city_manager", true, &changedAt, ptr("Москва")))
+	mock.ExpectQuery("SELECT role, is_active, password_changed_at, city FROM users WHERE id =").
+		WithArgs("deleted").
+		WillReturnError(sql.ErrNoRows)
+
+	status, err := repo.GetUserStatus("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, "city_manager", status.Role)
+	assert.True(t, status.Active)
+	assert.Equal(t, changedAt, *status.PasswordChangedAt)
+	assert.Equal(t, "Москва", *status.City)
+
+	_, err = repo.GetUserStatus("deleted")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,18 +3,21 @@ package tests
 import (
 	"bytes"
 	"context"
-	"database/sql"
 	"encoding/json"
 	"fmt"
 	"github.com/testcontainers/testcontainers-go"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/assert"
 	"github.com/testcontainers/testcontainers-go/wait"
 
@@ -25,6 +28,7 @@ import (
 )
 
 func TestFullPVZWorkflowWithRoles(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
 	t.Log("=== Начало комплексного теста рабочего процесса ПВЗ с проверкой ролей ===")
 
 	ctx := context.Background()
@@ -43,10 +47,11 @@ func TestFullPVZWorkflowWithRoles(t *testing.T) {
 	applyMigrations(t, testDB)
 
 	testCfg := config.Config{
-		JWTSecret: "test-secret",
+		Auth: config.AuthConfig{JWTSecret: "test-secret"},
+		HTTP: config.HTTPConfig{IdempotencyTTL: time.Hour},
 	}
 
-	testApp := app.MakeApp(testDB, testCfg)
+	testApp := app.MakeApp(testDB, nil, nil, testCfg)
 
 	// 1. Создание нового ПВЗ (требуется роль moderator)
 	pvzID := createPVZAsModerator(t, testApp, testCfg)
@@ -60,6 +65,10 @@ func TestFullPVZWorkflowWithRoles(t *testing.T) {
 	productIDs := addProductsAsEmployee(t, testApp, testCfg, pvzID, 50)
 	assert.Len(t, productIDs, 50)
 
+	// 3.1. Повтор запроса с тем же Idempotency-Key не создаёт дубликат товара
+	firstID, retryID := addProductTwiceWithIdempotencyKey(t, testApp, testCfg, pvzID)
+	assert.Equal(t, firstID, retryID)
+
 	// 4. Закрытие приёмки (требуется роль employee)
 	closedReception := closeReceptionAsEmployee(t, testApp, testCfg, pvzID)
 	assert.Equal(t, "close", closedReception.Status)
@@ -69,9 +78,18 @@ func TestFullPVZWorkflowWithRoles(t *testing.T) {
 	tryCreatePVZAsEmployee(t, testApp, testCfg)
 }
 
+// Test users are seeded by applyMigrations, one per role: tokens of unknown users are
+// rejected and permissions are checked against the role stored in the database
+const (
+	testUserID      = "00000000-0000-0000-0000-000000000001"
+	testModeratorID = "00000000-0000-0000-0000-000000000002"
+)
+
+var testUserIDs = map[string]string{"employee": testUserID, "moderator": testModeratorID}
+
 func generateTokenWithRole(role string, secret string) (string, error) {
 	claims := jwt.MapClaims{
-		"userId": "test-user",
+		"userId": testUserIDs[role],
 		"role":   role,
 		"exp":    time.Now().Add(time.Hour * 1).Unix(),
 	}
@@ -80,7 +98,7 @@ func generateTokenWithRole(role string, secret string) (string, error) {
 	return token.SignedString([]byte(secret))
 }
 
-func setupTestDB(ctx context.Context, t *testing.T) (testcontainers.Container, string) {
+func setupTestDB(ctx context.Context, t testing.TB) (testcontainers.Container, string) {
 	t.Log("Настройка контейнера PostgreSQL...")
 	req := testcontainers.ContainerRequest{
 		Image:        "postgres:13-alpine",
@@ -124,15 +142,15 @@ func setupTestDB(ctx context.Context, t *testing.T) (testcontainers.Container, s
 	return postgresContainer, dsn
 }
 
-func connectToTestDB(t *testing.T, dsn string) *sql.DB {
+func connectToTestDB(t testing.TB, dsn string) *pgxpool.Pool {
 	t.Logf("Попытка подключения к БД (до 5 попыток)...")
-	var testDB *sql.DB
+	var testDB *pgxpool.Pool
 	var err error
 
 	for i := 0; i < 5; i++ {
 		testDB, err = db.InitializeTestDB(dsn)
 		if err == nil {
-			_, err = testDB.Exec(`CREATE EXTENSION IF NOT EXISTS "pgcrypto"`)
+			_, err = testDB.Exec(context.Background(), `CREATE EXTENSION IF NOT EXISTS "pgcrypto"`)
 			if err != nil {
 				t.Logf("Ошибка при проверке расширений: %v", err)
 				continue
@@ -147,43 +165,27 @@ func connectToTestDB(t *testing.T, dsn string) *sql.DB {
 	return nil
 }
 
-func applyMigrations(t *testing.T, db *sql.DB) {
+func applyMigrations(t testing.TB, db *pgxpool.Pool) {
 	t.Log("Применение SQL миграций...")
 
-	_, err := db.Exec(`CREATE EXTENSION IF NOT EXISTS "pgcrypto"`)
+	_, err := db.Exec(context.Background(), `CREATE EXTENSION IF NOT EXISTS "pgcrypto"`)
 	assert.NoError(t, err, "Не удалось подключить расширение pgcrypto")
 
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-			email TEXT UNIQUE NOT NULL,
-			password TEXT NOT NULL,
-			role TEXT NOT NULL CHECK (role IN ('employee', 'moderator')),
-			created_at TIMESTAMP DEFAULT NOW()
-		);
-
-		CREATE TABLE IF NOT EXISTS pvz (
-			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-			city TEXT NOT NULL CHECK (city IN ('Москва', 'Санкт-Петербург', 'Казань')),
-			registration_date TIMESTAMP DEFAULT NOW()
-		);
-
-		CREATE TABLE IF NOT EXISTS receptions (
-			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-			pvz_id UUID REFERENCES pvz(id),
-			status TEXT NOT NULL CHECK (status IN ('in_progress', 'close')),
-			created_at TIMESTAMP DEFAULT NOW(),
-			closed_at TIMESTAMP
-		);
-
-		CREATE TABLE IF NOT EXISTS products (
-			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-			reception_id UUID REFERENCES receptions(id),
-			type TEXT NOT NULL CHECK (type IN ('электроника', 'одежда', 'обувь')),
-			created_at TIMESTAMP DEFAULT NOW()
-		);
+	files, err := filepath.Glob(filepath.Join("..", "..", "migrations", "*.sql"))
+	assert.NoError(t, err, "Не удалось найти файлы миграций")
+	sort.Strings(files)
 
-		INSERT INTO users (email, password, role) VALUES (
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		assert.NoError(t, err, "Не удалось прочитать миграцию %s", file)
+
+		_, err = db.Exec(context.Background(), string(migration))
+		assert.NoError(t, err, "Не удалось применить миграцию %s", file)
+	}
+
+	_, err = db.Exec(context.Background(), `
+		INSERT INTO users (id, email, password, role) VALUES (
+			'`+testModeratorID+`',
 			'moderator@test.com',
 			crypt('moderator123', gen_salt('bf')),
 			'moderator'
@@ -194,13 +196,20 @@ func applyMigrations(t *testing.T, db *sql.DB) {
 			crypt('employee123', gen_salt('bf')),
 			'employee'
 		) ON CONFLICT DO NOTHING;
+
+		INSERT INTO users (id, email, password, role) VALUES (
+			'`+testUserID+`',
+			'test-user@test.com',
+			crypt('testuser123', gen_salt('bf')),
+			'employee'
+		) ON CONFLICT DO NOTHING;
 	`)
 	assert.NoError(t, err, "Не удалось применить миграции")
 	t.Log("Миграции успешно применены")
 }
 
 func createPVZAsModerator(t *testing.T, app *fiber.App, cfg config.Config) string {
-	token, err := generateTokenWithRole("moderator", cfg.JWTSecret)
+	token, err := generateTokenWithRole("moderator", cfg.Auth.JWTSecret)
 	assert.NoError(t, err)
 
 	t.Log("Создание тестового запроса для ПВЗ...")
@@ -226,7 +235,7 @@ func createPVZAsModerator(t *testing.T, app *fiber.App, cfg config.Config) strin
 }
 
 func createReceptionAsEmployee(t *testing.T, app *fiber.App, cfg config.Config, pvzID string) string {
-	token, err := generateTokenWithRole("employee", cfg.JWTSecret)
+	token, err := generateTokenWithRole("employee", cfg.Auth.JWTSecret)
 	assert.NoError(t, err)
 
 	t.Log("Формирование запроса на создание приёмки...")
@@ -248,7 +257,7 @@ func createReceptionAsEmployee(t *testing.T, app *fiber.App, cfg config.Config,
 }
 
 func addProductsAsEmployee(t *testing.T, app *fiber.App, cfg config.Config, pvzID string, count int) []string {
-	token, err := generateTokenWithRole("employee", cfg.JWTSecret)
+	token, err := generateTokenWithRole("employee", cfg.Auth.JWTSecret)
 	assert.NoError(t, err)
 
 	t.Logf("Начало добавления %d товаров...", count)
@@ -278,8 +287,36 @@ func addProductsAsEmployee(t *testing.T, app *fiber.App, cfg config.Config, pvzI
 	return productIDs
 }
 
+func addProductTwiceWithIdempotencyKey(t *testing.T, app *fiber.App, cfg config.Config, pvzID string) (string, string) {
+	token, err := generateTokenWithRole("employee", cfg.Auth.JWTSecret)
+	assert.NoError(t, err)
+
+	t.Log("Добавление товара с Idempotency-Key и повтор запроса...")
+	reqBody := fmt.Sprintf(`{"type": "обувь", "pvzId": "%s"}`, pvzID)
+	productIDs := make([]string, 0, 2)
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/products", strings.NewReader(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		req.Header.Set("Idempotency-Key", "scanner-retry-1")
+
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		var product models.Product
+		err = json.NewDecoder(resp.Body).Decode(&product)
+		assert.NoError(t, err)
+
+		productIDs = append(productIDs, product.ID)
+	}
+
+	return productIDs[0], productIDs[1]
+}
+
 func closeReceptionAsEmployee(t *testing.T, app *fiber.App, cfg config.Config, pvzID string) models.Reception {
-	token, err := generateTokenWithRole("employee", cfg.JWTSecret)
+	token, err := generateTokenWithRole("employee", cfg.Auth.JWTSecret)
 	assert.NoError(t, err)
 
 	t.Log("Формирование запроса на закрытие приёмки...")
@@ -299,7 +336,7 @@ func closeReceptionAsEmployee(t *testing.T, app *fiber.App, cfg config.Config, p
 }
 
 func tryCreatePVZAsEmployee(t *testing.T, app *fiber.App, cfg config.Config) {
-	token, err := generateTokenWithRole("employee", cfg.JWTSecret)
+	token, err := generateTokenWithRole("employee", cfg.Auth.JWTSecret)
 	assert.NoError(t, err)
 
 	t.Log("Попытка создания ПВЗ с ролью employee (должна завершиться ошибкой)...")
@@ -0,0 +1,833 @@
+package tests
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+
+	"pvzService/cmd/app"
+	"pvzService/internal/config"
+	"pvzService/internal/models"
+	"pvzService/internal/oidc/mockidp"
+	"pvzService/internal/openapi"
+	"pvzService/internal/repository"
+)
+
+// Routes that serve the documentation itself and are not part of the API contract
+var undocumentedRoutes = map[string]bool{
+	"GET /openapi.json": true,
+	"GET /docs":         true,
+}
+
+var fiberParamPattern = regexp.MustCompile(`:(\w+)`)
+
+func loadSpec(t *testing.T) map[string]interface{} {
+	var spec map[string]interface{}
+	require.NoError(t, json.Unmarshal(openapi.Spec, &spec), "openapi.json должен быть корректным JSON")
+	return spec
+}
+
+func documentedOperations(spec map[string]interface{}) map[string]map[string]interface{} {
+	operations := make(map[string]map[string]interface{})
+	for path, item := range spec["paths"].(map[string]interface{}) {
+		for method, operation := range item.(map[string]interface{}) {
+			operations[strings.ToUpper(method)+" "+path] = operation.(map[string]interface{})
+		}
+	}
+	return operations
+}
+
+func TestOpenAPIDocumentsAllRoutes(t *testing.T) {
+	spec := loadSpec(t)
+	testApp := app.MakeApp(nil, nil, nil, config.Config{
+		Auth: config.AuthConfig{JWTSecret: "test-secret", OIDC: config.OIDCConfig{IssuerURL: "http://idp.invalid"}},
+		HTTP: config.HTTPConfig{IdempotencyTTL: time.Hour},
+	})
+
+	registered := make(map[string]bool)
+	for _, route := range testApp.GetRoutes(true) {
+		if route.Method == fiber.MethodHead {
+			continue
+		}
+		key := route.Method + " " + fiberParamPattern.ReplaceAllString(route.Path, "{$1}")
+		if !undocumentedRoutes[key] {
+			registered[key] = true
+		}
+	}
+
+	documented := documentedOperations(spec)
+	for key := range registered {
+		assert.Contains(t, documented, key, "маршрут не описан в openapi.json")
+	}
+	for key := range documented {
+		assert.Contains(t, registered, key, "в openapi.json описан несуществующий маршрут")
+	}
+}
+
+func TestDummyLoginIsNotServedInProd(t *testing.T) {
+	testApp := app.MakeApp(nil, nil, nil, config.Config{
+		Env:  config.EnvProd,
+		Auth: config.AuthConfig{JWTSecret: "test-secret"},
+		HTTP: config.HTTPConfig{IdempotencyTTL: time.Hour},
+	})
+
+	for _, route := range testApp.GetRoutes(true) {
+		assert.NotEqual(t, "/dummyLogin", route.Path, "в prod не должен регистрироваться /dummyLogin")
+	}
+
+	req := httptest.NewRequest("POST", "/dummyLogin", strings.NewReader(`{"role":"moderator"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := testApp.Test(req)
+	require.NoError(t, err)
+	// Unknown paths fall through to the protected group and are rejected there
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestOpenAPISpecIsServed(t *testing.T) {
+	testApp := app.MakeApp(nil, nil, nil, config.Config{
+		Auth: config.AuthConfig{JWTSecret: "test-secret"},
+		HTTP: config.HTTPConfig{IdempotencyTTL: time.Hour},
+	})
+
+	resp, err := testApp.Test(httptest.NewRequest("GET", "/openapi.json", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "application/json")
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(openapi.Spec), string(body))
+
+	resp, err = testApp.Test(httptest.NewRequest("GET", "/docs", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+}
+
+// contractChecker sends requests to the app, validates every response against the
+// documented status codes and schemas, and records which responses were exercised.
+type contractChecker struct {
+	t       *testing.T
+	app     *fiber.App
+	spec    map[string]interface{}
+	covered map[string]bool
+	// header of the last response, for redirects and cookies
+	header http.Header
+}
+
+type contractRequest struct {
+	method  string
+	route   string
+	path    string
+	token   string
+	headers map[string]string
+	body    string
+}
+
+func (c *contractChecker) expect(req contractRequest, expectedStatus int) []byte {
+	t := c.t
+	t.Helper()
+
+	var bodyReader io.Reader
+	if req.body != "" {
+		bodyReader = strings.NewReader(req.body)
+	}
+	httpReq := httptest.NewRequest(req.method, req.path, bodyReader)
+	if req.body != "" {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if req.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+req.token)
+	}
+	for key, value := range req.headers {
+		httpReq.Header.Set(key, value)
+	}
+
+	resp, err := c.app.Test(httpReq, -1)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	c.header = resp.Header
+
+	operationKey := req.method + " " + req.route
+	require.Equalf(t, expectedStatus, resp.StatusCode, "%s %s: неожиданный статус, тело ответа: %s", req.method, req.path, body)
+
+	operation, ok := documentedOperations(c.spec)[operationKey]
+	require.Truef(t, ok, "операция %s не описана", operationKey)
+
+	response, ok := operation["responses"].(map[string]interface{})[strconv.Itoa(resp.StatusCode)]
+	require.Truef(t, ok, "%s: статус %d не описан", operationKey, resp.StatusCode)
+	c.covered[operationKey+" "+strconv.Itoa(resp.StatusCode)] = true
+
+	content, _ := resolve(c.spec, response)["content"].(map[string]interface{})
+	if media, ok := content["application/json"].(map[string]interface{}); ok {
+		var value interface{}
+		require.NoErrorf(t, json.Unmarshal(body, &value), "%s: ответ не является JSON: %s", operationKey, body)
+		problems := validateAgainstSchema(c.spec, media["schema"].(map[string]interface{}), value, "$")
+		assert.Emptyf(t, problems, "%s %d: ответ не соответствует схеме: %s", operationKey, resp.StatusCode, body)
+	}
+
+	return body
+}
+
+// assertAllResponsesCovered fails for documented client-visible responses that no
+// scenario exercised; 5xx responses cannot be triggered on purpose and are skipped.
+func (c *contractChecker) assertAllResponsesCovered() {
+	var missing []string
+	for key, operation := range documentedOperations(c.spec) {
+		for status := range operation["responses"].(map[string]interface{}) {
+			if strings.HasPrefix(status, "5") {
+				continue
+			}
+			if !c.covered[key+" "+status] {
+				missing = append(missing, key+" "+status)
+			}
+		}
+	}
+	sort.Strings(missing)
+	assert.Empty(c.t, missing, "описанные ответы не проверены контрактными тестами")
+}
+
+func resolve(spec map[string]interface{}, node interface{}) map[string]interface{} {
+	object := node.(map[string]interface{})
+	ref, ok := object["$ref"].(string)
+	if !ok {
+		return object
+	}
+
+	var current interface{} = spec
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		current = current.(map[string]interface{})[part]
+	}
+	return resolve(spec, current)
+}
+
+// validateAgainstSchema supports the subset of JSON Schema used in openapi.json.
+// Properties that are not documented are reported as well to catch drift.
+func validateAgainstSchema(spec map[string]interface{}, schema map[string]interface{}, value interface{}, at string) []string {
+	schema = resolve(spec, schema)
+
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable {
+			return nil
+		}
+		return []string{at + ": значение null не допускается"}
+	}
+
+	var problems []string
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{at + ": ожидался объект"}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: отсутствует обязательное поле %s", at, name))
+			}
+		}
+		for name, fieldValue := range object {
+			fieldSchema, ok := properties[name]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: поле %s не описано в схеме", at, name))
+				continue
+			}
+			problems = append(problems, validateAgainstSchema(spec, fieldSchema.(map[string]interface{}), fieldValue, at+"."+name)...)
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return []string{at + ": ожидался массив"}
+		}
+		for i, item := range items {
+			problems = append(problems, validateAgainstSchema(spec, schema["items"].(map[string]interface{}), item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return []string{at + ": ожидалась строка"}
+		}
+		if enum, ok := schema["enum"].([]interface{}); ok && !containsValue(enum, str) {
+			problems = append(problems, fmt.Sprintf("%s: значение %q не входит в enum", at, str))
+		}
+		switch schema["format"] {
+		case "uuid":
+			if _, err := uuid.Parse(str); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q не является UUID", at, str))
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q не является date-time", at, str))
+			}
+		}
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != float64(int64(number)) {
+			return []string{at + ": ожидалось целое число"}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{at + ": ожидалось число"}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{at + ": ожидалось логическое значение"}
+		}
+	}
+	return problems
+}
+
+func containsValue(values []interface{}, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// idempotencyHash mirrors the request hash computed by middleware.Idempotency.
+func idempotencyHash(method, path, userID, body string) string {
+	sum := sha256.Sum256([]byte(method + " " + path + "\n" + userID + "\n" + body))
+	return hex.EncodeToString(sum[:])
+}
+
+// followIdPRedirect opens the IdP authorization URL like a browser and returns the
+// callback URL it redirects to.
+func followIdPRedirect(t *testing.T, authURL string) *url.URL {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return location
+}
+
+// lastResetToken reads the last password reset token sent to email by the file notifier.
+func lastResetToken(t *testing.T, path, email string) string {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var token string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var message struct {
+			Type  string `json:"type"`
+			Email string `json:"email"`
+			Token string `json:"token"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(line), &message))
+		if message.Type == "password_reset" && message.Email == email {
+			token = message.Token
+		}
+	}
+	require.NotEmpty(t, token, "токен сброса пароля не отправлен")
+	return token
+}
+
+func TestOpenAPIContract(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	ctx := context.Background()
+	postgresContainer, dsn := setupTestDB(ctx, t)
+	defer postgresContainer.Terminate(ctx)
+
+	testDB := connectToTestDB(t, dsn)
+	defer testDB.Close()
+	applyMigrations(t, testDB)
+
+	idp, idpServer, err := mockidp.NewTestServer("pvz-service", "pvz-secret")
+	require.NoError(t, err)
+	defer idpServer.Close()
+	idp.AddUser("sso-moderator", mockidp.User{Subject: "sso-1", Email: "sso@contract.com", EmailVerified: true, Groups: []string{"pvz-moderators"}})
+	idp.AddUser("outsider", mockidp.User{Subject: "sso-2", Email: "outsider@contract.com", EmailVerified: true, Groups: []string{"finance"}})
+
+	cfg := config.Config{
+		HTTP:  config.HTTPConfig{IdempotencyTTL: time.Hour},
+		Cache: config.CacheConfig{Enabled: true, Backend: "memory", TTL: time.Minute, MaxEntries: 100},
+		Auth: config.AuthConfig{
+			JWTSecret: "test-secret",
+			Login: config.LoginProtectionConfig{
+				IPMaxAttempts:   5,
+				AttemptWindow:   time.Hour,
+				LockoutDuration: time.Hour,
+			},
+			PasswordReset: config.PasswordResetConfig{TTL: time.Hour},
+			OIDC: config.OIDCConfig{
+				IssuerURL:       idp.Issuer,
+				ClientID:        idp.ClientID,
+				ClientSecret:    idp.ClientSecret,
+				RedirectURL:     "http://localhost:8080/auth/oidc/callback",
+				EmployeeGroups:  []string{"pvz-staff"},
+				ModeratorGroups: []string{"pvz-moderators"},
+			},
+		},
+		Notifier: config.NotifierConfig{
+			Type: "file",
+			File: filepath.Join(t.TempDir(), "notifications.jsonl"),
+		},
+	}
+	c := &contractChecker{
+		t:       t,
+		app:     app.MakeApp(testDB, nil, nil, cfg),
+		spec:    loadSpec(t),
+		covered: make(map[string]bool),
+	}
+
+	employeeToken, err := generateTokenWithRole("employee", cfg.Auth.JWTSecret)
+	require.NoError(t, err)
+
+	// expectIdempotencyConflicts checks a reused key with another body (422) and a key
+	// whose first request is still being processed (409)
+	expectIdempotencyConflicts := func(route, path, token, userID, body, otherBody string, created int) {
+		reused := map[string]string{"Idempotency-Key": "contract-" + uuid.NewString()}
+		c.expect(contractRequest{method: "POST", route: route, path: path, token: token, headers: reused, body: body}, created)
+		c.expect(contractRequest{method: "POST", route: route, path: path, token: token, headers: reused, body: otherBody}, http.StatusUnprocessableEntity)
+
+		inProgressKey := "contract-" + uuid.NewString()
+		_, err := testDB.Exec(context.Background(),
+			"INSERT INTO idempotency_keys (key, user_id, request_hash, locked_until, expires_at) VALUES ($1, $2, $3, NOW() + INTERVAL '1 minute', NOW() + INTERVAL '1 hour')",
+			inProgressKey, userID, idempotencyHash("POST", path, userID, body))
+		require.NoError(t, err)
+		c.expect(contractRequest{method: "POST", route: route, path: path, token: token,
+			headers: map[string]string{"Idempotency-Key": inProgressKey}, body: body}, http.StatusConflict)
+	}
+
+	// Health checks, the database is migrated to the version the service requires
+	c.expect(contractRequest{method: "GET", route: "/health", path: "/health"}, http.StatusOK)
+	c.expect(contractRequest{method: "GET", route: "/livez", path: "/livez"}, http.StatusOK)
+	c.expect(contractRequest{method: "GET", route: "/readyz", path: "/readyz"}, http.StatusOK)
+
+	// Auth
+	var token models.Token
+	body := c.expect(contractRequest{method: "POST", route: "/dummyLogin", path: "/dummyLogin", body: `{"role":"moderator"}`}, http.StatusOK)
+	require.NoError(t, json.Unmarshal(body, &token))
+	moderatorToken := token.Token
+	c.expect(contractRequest{method: "POST", route: "/dummyLogin", path: "/dummyLogin", body: `{"role":"admin"}`}, http.StatusBadRequest)
+
+	// Every role has pvz:read, so a caller without it is an API key granted other permissions
+	var guestKey models.CreatedAPIKey
+	body = c.expect(contractRequest{method: "POST", route: "/api-keys", path: "/api-keys", token: moderatorToken,
+		body: `{"name":"guest","permissions":["user:read"]}`}, http.StatusCreated)
+	require.NoError(t, json.Unmarshal(body, &guestKey))
+	guestHeaders := map[string]string{"X-API-Key": guestKey.Key}
+
+	c.expect(contractRequest{method: "POST", route: "/register", path: "/register",
+		body: `{"email":"contract@test.com","password":"contract123","role":"employee"}`}, http.StatusCreated)
+	c.expect(contractRequest{method: "POST", route: "/register", path: "/register",
+		body: `{"email":"not-an-email","password":"contract123","role":"employee"}`}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "POST", route: "/register", path: "/register",
+		body: `{"email":"moderator@contract.com","password":"contract123","role":"moderator"}`}, http.StatusForbidden)
+	c.expect(contractRequest{method: "POST", route: "/register", path: "/register", token: "invalid",
+		body: `{"email":"employee@contract.com","password":"contract123","role":"employee"}`}, http.StatusUnauthorized)
+
+	c.expect(contractRequest{method: "POST", route: "/login", path: "/login",
+		body: `{"email":"contract@test.com","password":"contract123"}`}, http.StatusOK)
+	c.expect(contractRequest{method: "POST", route: "/login", path: "/login",
+		body: `{"email":"contract@test.com","password":"wrong-password1"}`}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "POST", route: "/login", path: "/login", body: `{"email":""}`}, http.StatusBadRequest)
+
+	// PVZ
+	var pvz models.PVZ
+	body = c.expect(contractRequest{method: "POST", route: "/pvz", path: "/pvz", token: moderatorToken, body: `{"city":"Казань"}`}, http.StatusCreated)
+	require.NoError(t, json.Unmarshal(body, &pvz))
+	c.expect(contractRequest{method: "POST", route: "/pvz", path: "/pvz", token: moderatorToken, body: `{"city":"Тверь"}`}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "POST", route: "/pvz", path: "/pvz", body: `{"city":"Казань"}`}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "POST", route: "/pvz", path: "/pvz", token: employeeToken, body: `{"city":"Казань"}`}, http.StatusForbidden)
+	moderatorJWT, err := generateTokenWithRole("moderator", cfg.Auth.JWTSecret)
+	require.NoError(t, err)
+	expectIdempotencyConflicts("/pvz", "/pvz", moderatorJWT, testModeratorID, `{"city":"Москва"}`, `{"city":"Казань"}`, http.StatusCreated)
+
+	listPath := "/pvz?page=1&limit=10"
+	c.expect(contractRequest{method: "GET", route: "/pvz", path: listPath, token: employeeToken}, http.StatusOK)
+	listETag := c.header.Get("ETag")
+	c.expect(contractRequest{method: "GET", route: "/pvz", path: listPath, token: employeeToken, headers: map[string]string{"If-None-Match": listETag}}, http.StatusNotModified)
+	// A page past the end is an empty array, not null
+	body = c.expect(contractRequest{method: "GET", route: "/pvz", path: "/pvz?page=1000&limit=30", token: employeeToken}, http.StatusOK)
+	assert.JSONEq(t, `[]`, string(body))
+	c.expect(contractRequest{method: "GET", route: "/pvz", path: "/pvz?page=0&limit=10", token: employeeToken}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "GET", route: "/pvz", path: listPath}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "GET", route: "/pvz", path: listPath, headers: guestHeaders}, http.StatusForbidden)
+
+	pvzPath := "/pvz/" + pvz.ID
+	c.expect(contractRequest{method: "GET", route: "/pvz/{pvzId}", path: pvzPath, token: employeeToken}, http.StatusOK)
+	c.expect(contractRequest{method: "GET", route: "/pvz/{pvzId}", path: pvzPath, token: employeeToken, headers: map[string]string{"If-None-Match": c.header.Get("ETag")}}, http.StatusNotModified)
+	c.expect(contractRequest{method: "GET", route: "/pvz/{pvzId}", path: "/pvz/" + uuid.NewString(), token: employeeToken}, http.StatusNotFound)
+	c.expect(contractRequest{method: "GET", route: "/pvz/{pvzId}", path: "/pvz/not-a-uuid", token: employeeToken}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "GET", route: "/pvz/{pvzId}", path: pvzPath}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "GET", route: "/pvz/{pvzId}", path: pvzPath, headers: guestHeaders}, http.StatusForbidden)
+
+	// Receptions
+	receptionBody := fmt.Sprintf(`{"pvzId":"%s"}`, pvz.ID)
+	c.expect(contractRequest{method: "POST", route: "/receptions", path: "/receptions", token: employeeToken, body: receptionBody}, http.StatusCreated)
+	c.expect(contractRequest{method: "POST", route: "/receptions", path: "/receptions", token: employeeToken, body: receptionBody}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "POST", route: "/receptions", path: "/receptions", token: employeeToken,
+		body: fmt.Sprintf(`{"pvzId":"%s"}`, uuid.NewString())}, http.StatusNotFound)
+	c.expect(contractRequest{method: "POST", route: "/receptions", path: "/receptions", body: receptionBody}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "POST", route: "/receptions", path: "/receptions", token: moderatorToken, body: receptionBody}, http.StatusForbidden)
+
+	var secondPVZ models.PVZ
+	body = c.expect(contractRequest{method: "POST", route: "/pvz", path: "/pvz", token: moderatorToken, body: `{"city":"Москва"}`}, http.StatusCreated)
+	require.NoError(t, json.Unmarshal(body, &secondPVZ))
+	var thirdPVZ models.PVZ
+	body = c.expect(contractRequest{method: "POST", route: "/pvz", path: "/pvz", token: moderatorToken, body: `{"city":"Москва"}`}, http.StatusCreated)
+	require.NoError(t, json.Unmarshal(body, &thirdPVZ))
+	expectIdempotencyConflicts("/receptions", "/receptions", employeeToken, testUserID,
+		fmt.Sprintf(`{"pvzId":"%s"}`, secondPVZ.ID), fmt.Sprintf(`{"pvzId":"%s"}`, thirdPVZ.ID), http.StatusCreated)
+
+	// Products
+	productBody := fmt.Sprintf(`{"type":"обувь","pvzId":"%s"}`, pvz.ID)
+	var product models.Product
+	body = c.expect(contractRequest{method: "POST", route: "/products", path: "/products", token: employeeToken, body: productBody}, http.StatusCreated)
+	require.NoError(t, json.Unmarshal(body, &product))
+	assert.Nil(t, product.IssuedAt)
+	c.expect(contractRequest{method: "POST", route: "/products", path: "/products", token: employeeToken,
+		body: fmt.Sprintf(`{"type":"мебель","pvzId":"%s"}`, pvz.ID)}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "POST", route: "/products", path: "/products", body: productBody}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "POST", route: "/products", path: "/products", token: moderatorToken, body: productBody}, http.StatusForbidden)
+	expectIdempotencyConflicts("/products", "/products", employeeToken, testUserID,
+		productBody, fmt.Sprintf(`{"type":"одежда","pvzId":"%s"}`, pvz.ID), http.StatusCreated)
+
+	c.expect(contractRequest{method: "GET", route: "/pvz", path: listPath, token: moderatorToken}, http.StatusOK)
+
+	// Capacity: a full PVZ takes no more products and is listed by its utilization
+	var stored models.PVZ
+	body = c.expect(contractRequest{method: "GET", route: "/pvz/{pvzId}", path: pvzPath, token: moderatorToken}, http.StatusOK)
+	require.NoError(t, json.Unmarshal(body, &stored))
+	require.NotZero(t, stored.StoredItems)
+	c.expect(contractRequest{method: "PATCH", route: "/pvz/{pvzId}", path: pvzPath, token: moderatorToken,
+		body: fmt.Sprintf(`{"capacity":%d}`, stored.StoredItems)}, http.StatusOK)
+	body = c.expect(contractRequest{method: "POST", route: "/products", path: "/products", token: employeeToken, body: productBody}, http.StatusBadRequest)
+	assert.Contains(t, string(body), "PVZ_CAPACITY_EXCEEDED")
+	utilizationPath := "/pvz/utilization?page=1&limit=100&minUtilization=1"
+	body = c.expect(contractRequest{method: "GET", route: "/pvz/utilization", path: utilizationPath, token: employeeToken}, http.StatusOK)
+	var utilization []models.PVZUtilization
+	require.NoError(t, json.Unmarshal(body, &utilization))
+	utilized := make(map[string]float64)
+	for _, item := range utilization {
+		utilized[item.ID] = item.Utilization
+	}
+	assert.Equal(t, 1.0, utilized[pvz.ID])
+	c.expect(contractRequest{method: "GET", route: "/pvz/utilization", path: "/pvz/utilization?page=1&limit=101", token: employeeToken}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "GET", route: "/pvz/utilization", path: utilizationPath}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "GET", route: "/pvz/utilization", path: utilizationPath, headers: guestHeaders}, http.StatusForbidden)
+	c.expect(contractRequest{method: "PATCH", route: "/pvz/{pvzId}", path: pvzPath, token: moderatorToken, body: `{"capacity":0}`}, http.StatusOK)
+
+	// Delete last product and close reception
+	deletePath := "/pvz/" + pvz.ID + "/delete_last_product"
+	c.expect(contractRequest{method: "POST", route: "/pvz/{pvzId}/delete_last_product", path: deletePath, token: employeeToken}, http.StatusOK)
+	c.expect(contractRequest{method: "POST", route: "/pvz/{pvzId}/delete_last_product", path: "/pvz/not-a-uuid/delete_last_product", token: employeeToken}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "POST", route: "/pvz/{pvzId}/delete_last_product", path: deletePath}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "POST", route: "/pvz/{pvzId}/delete_last_product", path: deletePath, token: moderatorToken}, http.StatusForbidden)
+
+	// A product is issued only after its reception is closed
+	issueRoute := "/pvz/{pvzId}/products/{productId}/issue"
+	issuePath := "/pvz/" + pvz.ID + "/products/" + product.ID + "/issue"
+	body = c.expect(contractRequest{method: "POST", route: issueRoute, path: issuePath, token: employeeToken}, http.StatusBadRequest)
+	assert.Contains(t, string(body), "RECEPTION_NOT_CLOSED")
+
+	closePath := "/pvz/" + pvz.ID + "/close_last_reception"
+	c.expect(contractRequest{method: "POST", route: "/pvz/{pvzId}/close_last_reception", path: closePath, token: employeeToken}, http.StatusOK)
+	c.expect(contractRequest{method: "POST", route: "/pvz/{pvzId}/close_last_reception", path: closePath, token: employeeToken}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "POST", route: "/pvz/{pvzId}/close_last_reception", path: closePath}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "POST", route: "/pvz/{pvzId}/close_last_reception", path: closePath, token: moderatorToken}, http.StatusForbidden)
+
+	// Issuing frees the room of the product in the PVZ
+	body = c.expect(contractRequest{method: "GET", route: "/pvz/{pvzId}", path: pvzPath, token: moderatorToken}, http.StatusOK)
+	require.NoError(t, json.Unmarshal(body, &stored))
+	var issued models.Product
+	body = c.expect(contractRequest{method: "POST", route: issueRoute, path: issuePath, token: employeeToken}, http.StatusOK)
+	require.NoError(t, json.Unmarshal(body, &issued))
+	assert.Equal(t, product.ID, issued.ID)
+	assert.NotNil(t, issued.IssuedAt)
+	var afterIssue models.PVZ
+	body = c.expect(contractRequest{method: "GET", route: "/pvz/{pvzId}", path: pvzPath, token: moderatorToken}, http.StatusOK)
+	require.NoError(t, json.Unmarshal(body, &afterIssue))
+	assert.Equal(t, stored.StoredItems-1, afterIssue.StoredItems)
+	body = c.expect(contractRequest{method: "POST", route: issueRoute, path: issuePath, token: employeeToken}, http.StatusBadRequest)
+	assert.Contains(t, string(body), "PRODUCT_ALREADY_ISSUED")
+	c.expect(contractRequest{method: "POST", route: issueRoute, path: "/pvz/" + secondPVZ.ID + "/products/" + product.ID + "/issue", token: employeeToken}, http.StatusNotFound)
+	c.expect(contractRequest{method: "POST", route: issueRoute, path: "/pvz/" + pvz.ID + "/products/not-a-uuid/issue", token: employeeToken}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "POST", route: issueRoute, path: issuePath}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "POST", route: issueRoute, path: issuePath, token: moderatorToken}, http.StatusForbidden)
+
+	// Profile: partial updates keep the fields that are not sent
+	profileBody := `{"name":"ПВЗ на Тверской","address":"Москва, Тверская ул., 1","latitude":55.7601,"longitude":37.6186,` +
+		`"workingHours":[{"weekday":"sunday","opens":"10:00","closes":"18:00"},{"weekday":"monday","opens":"09:00","closes":"24:00"}]}`
+	c.expect(contractRequest{method: "PATCH", route: "/pvz/{pvzId}", path: pvzPath, token: moderatorToken, body: profileBody}, http.StatusOK)
+	body = c.expect(contractRequest{method: "PATCH", route: "/pvz/{pvzId}", path: pvzPath, token: moderatorToken, body: `{"name":"ПВЗ у метро"}`}, http.StatusOK)
+	var profile models.PVZ
+	require.NoError(t, json.Unmarshal(body, &profile))
+	assert.Equal(t, "ПВЗ у метро", profile.Name)
+	assert.Equal(t, "Москва, Тверская ул., 1", profile.Address)
+	require.NotNil(t, profile.Latitude)
+	assert.Equal(t, 55.7601, *profile.Latitude)
+	assert.Equal(t, []models.WorkingHours{{Weekday: "monday", Opens: "09:00", Closes: "24:00"}, {Weekday: "sunday", Opens: "10:00", Closes: "18:00"}},
+		profile.WorkingHours)
+	c.expect(contractRequest{method: "PATCH", route: "/pvz/{pvzId}", path: pvzPath, token: moderatorToken, body: `{"latitude":55.7}`}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "PATCH", route: "/pvz/{pvzId}", path: pvzPath, token: moderatorToken,
+		body: `{"workingHours":[{"weekday":"monday","opens":"21:00","closes":"09:00"}]}`}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "PATCH", route: "/pvz/{pvzId}", path: "/pvz/" + uuid.NewString(), token: moderatorToken, body: `{"name":"ПВЗ"}`}, http.StatusNotFound)
+	c.expect(contractRequest{method: "PATCH", route: "/pvz/{pvzId}", path: pvzPath, body: `{"name":"ПВЗ"}`}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "PATCH", route: "/pvz/{pvzId}", path: pvzPath, token: employeeToken, body: `{"name":"ПВЗ"}`}, http.StatusForbidden)
+
+	// Nearby search finds the PVZ by its coordinates and working hours in local time
+	nearbyIDs := func(path string) map[string]float64 {
+		body := c.expect(contractRequest{method: "GET", route: "/pvz/nearby", path: path, token: employeeToken}, http.StatusOK)
+		var found []models.NearbyPVZ
+		require.NoError(t, json.Unmarshal(body, &found))
+		distances := make(map[string]float64)
+		for _, nearby := range found {
+			distances[nearby.ID] = nearby.DistanceKm
+		}
+		return distances
+	}
+	nearbyPath := "/pvz/nearby?lat=55.7558&lon=37.6173&radiusKm=2&limit=50"
+	distance, ok := nearbyIDs(nearbyPath)[pvz.ID]
+	require.True(t, ok)
+	assert.InDelta(t, 0.5, distance, 0.1)
+	assert.NotContains(t, nearbyIDs("/pvz/nearby?lat=59.9386&lon=30.3141&radiusKm=50&limit=50"), pvz.ID)
+	assert.Contains(t, nearbyIDs(nearbyPath+"&openAt=2025-03-31T07:00:00Z"), pvz.ID)
+	assert.NotContains(t, nearbyIDs(nearbyPath+"&openAt=2025-03-30T17:00:00Z"), pvz.ID)
+	c.expect(contractRequest{method: "GET", route: "/pvz/nearby", path: "/pvz/nearby?lat=91&lon=37.6&radiusKm=2&limit=10", token: employeeToken}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "GET", route: "/pvz/nearby", path: nearbyPath}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "GET", route: "/pvz/nearby", path: nearbyPath, headers: guestHeaders}, http.StatusForbidden)
+
+	// Archiving: an archived PVZ leaves the listing unless asked for and accepts no
+	// receptions until it is restored
+	c.expect(contractRequest{method: "DELETE", route: "/pvz/{pvzId}", path: "/pvz/" + secondPVZ.ID, token: moderatorToken}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "DELETE", route: "/pvz/{pvzId}", path: pvzPath, token: moderatorToken}, http.StatusNoContent)
+	c.expect(contractRequest{method: "DELETE", route: "/pvz/{pvzId}", path: pvzPath, token: moderatorToken}, http.StatusNoContent)
+	c.expect(contractRequest{method: "DELETE", route: "/pvz/{pvzId}", path: "/pvz/not-a-uuid", token: moderatorToken}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "DELETE", route: "/pvz/{pvzId}", path: "/pvz/" + uuid.NewString(), token: moderatorToken}, http.StatusNotFound)
+	c.expect(contractRequest{method: "DELETE", route: "/pvz/{pvzId}", path: pvzPath}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "DELETE", route: "/pvz/{pvzId}", path: pvzPath, token: employeeToken}, http.StatusForbidden)
+
+	listedIDs := func(path string) map[string]models.PVZ {
+		body := c.expect(contractRequest{method: "GET", route: "/pvz", path: path, token: moderatorToken}, http.StatusOK)
+		var list []struct {
+			PVZ models.PVZ `json:"pvz"`
+		}
+		require.NoError(t, json.Unmarshal(body, &list))
+		listed := make(map[string]models.PVZ)
+		for _, item := range list {
+			listed[item.PVZ.ID] = item.PVZ
+		}
+		return listed
+	}
+	assert.NotContains(t, listedIDs("/pvz?page=1&limit=30"), pvz.ID)
+	archived, ok := listedIDs("/pvz?page=1&limit=30&includeArchived=true")[pvz.ID]
+	require.True(t, ok)
+	assert.True(t, archived.Archived)
+	assert.NotNil(t, archived.ArchivedAt)
+	assert.NotContains(t, nearbyIDs(nearbyPath), pvz.ID)
+	body = c.expect(contractRequest{method: "POST", route: "/receptions", path: "/receptions", token: employeeToken, body: receptionBody}, http.StatusBadRequest)
+	assert.Contains(t, string(body), "PVZ_ARCHIVED")
+
+	restorePath := "/pvz/" + pvz.ID + "/restore"
+	body = c.expect(contractRequest{method: "POST", route: "/pvz/{pvzId}/restore", path: restorePath, token: moderatorToken}, http.StatusOK)
+	var restored models.PVZ
+	require.NoError(t, json.Unmarshal(body, &restored))
+	assert.False(t, restored.Archived)
+	assert.Nil(t, restored.ArchivedAt)
+	c.expect(contractRequest{method: "POST", route: "/pvz/{pvzId}/restore", path: "/pvz/not-a-uuid/restore", token: moderatorToken}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "POST", route: "/pvz/{pvzId}/restore", path: "/pvz/" + uuid.NewString() + "/restore", token: moderatorToken}, http.StatusNotFound)
+	c.expect(contractRequest{method: "POST", route: "/pvz/{pvzId}/restore", path: restorePath}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "POST", route: "/pvz/{pvzId}/restore", path: restorePath, token: employeeToken}, http.StatusForbidden)
+	assert.Contains(t, listedIDs("/pvz?page=1&limit=30"), pvz.ID)
+
+	// User administration
+	c.expect(contractRequest{method: "POST", route: "/register", path: "/register", token: moderatorToken,
+		body: `{"email":"managed@contract.com","password":"contract123","role":"moderator"}`}, http.StatusCreated)
+	var managedUserID string
+	require.NoError(t, testDB.QueryRow(context.Background(), "SELECT id FROM users WHERE email = 'managed@contract.com'").Scan(&managedUserID))
+	managedPath := "/users/" + managedUserID
+
+	c.expect(contractRequest{method: "GET", route: "/users", path: "/users?page=1&limit=100", token: moderatorToken}, http.StatusOK)
+	c.expect(contractRequest{method: "GET", route: "/users", path: "/users?page=1&limit=10&role=superuser", token: moderatorToken}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "GET", route: "/users", path: "/users?page=1&limit=10"}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "GET", route: "/users", path: "/users?page=1&limit=10", token: employeeToken}, http.StatusForbidden)
+
+	c.expect(contractRequest{method: "PATCH", route: "/users/{userId}", path: managedPath, token: moderatorToken, body: `{"active":false}`}, http.StatusOK)
+	c.expect(contractRequest{method: "POST", route: "/login", path: "/login",
+		body: `{"email":"managed@contract.com","password":"contract123"}`}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "PATCH", route: "/users/{userId}", path: managedPath, token: moderatorToken, body: `{"role":"superuser"}`}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "PATCH", route: "/users/{userId}", path: managedPath, body: `{"active":true}`}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "PATCH", route: "/users/{userId}", path: managedPath, token: employeeToken, body: `{"active":true}`}, http.StatusForbidden)
+	c.expect(contractRequest{method: "PATCH", route: "/users/{userId}", path: "/users/" + uuid.NewString(), token: moderatorToken, body: `{"active":true}`}, http.StatusNotFound)
+
+	// user:manage doesn't let a moderator create admins or promote anyone to admin
+	c.expect(contractRequest{method: "POST", route: "/register", path: "/register", token: moderatorToken,
+		body: `{"email":"admin2@contract.com","password":"contract123","role":"admin"}`}, http.StatusForbidden)
+	c.expect(contractRequest{method: "PATCH", route: "/users/{userId}", path: managedPath, token: moderatorToken, body: `{"role":"admin"}`}, http.StatusForbidden)
+
+	// A city manager only sees the PVZ of their city and can't change anything
+	c.expect(contractRequest{method: "PATCH", route: "/users/{userId}", path: managedPath, token: moderatorToken, body: `{"role":"city_manager"}`}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "PATCH", route: "/users/{userId}", path: managedPath, token: moderatorToken,
+		body: `{"role":"city_manager","city":"Казань","active":true}`}, http.StatusOK)
+	body = c.expect(contractRequest{method: "POST", route: "/login", path: "/login",
+		body: `{"email":"managed@contract.com","password":"contract123"}`}, http.StatusOK)
+	require.NoError(t, json.Unmarshal(body, &token))
+	cityManagerToken := token.Token
+
+	var scoped []repository.PVZResponse
+	body = c.expect(contractRequest{method: "GET", route: "/pvz", path: "/pvz?page=1&limit=30", token: cityManagerToken}, http.StatusOK)
+	require.NoError(t, json.Unmarshal(body, &scoped))
+	require.NotEmpty(t, scoped)
+	for _, item := range scoped {
+		assert.Equal(t, "Казань", item.PVZ.City)
+	}
+	c.expect(contractRequest{method: "GET", route: "/pvz/{pvzId}", path: pvzPath, token: cityManagerToken}, http.StatusOK)
+	c.expect(contractRequest{method: "GET", route: "/pvz/{pvzId}", path: "/pvz/" + secondPVZ.ID, token: cityManagerToken}, http.StatusNotFound)
+	c.expect(contractRequest{method: "POST", route: "/pvz", path: "/pvz", token: cityManagerToken, body: `{"city":"Казань"}`}, http.StatusForbidden)
+	c.expect(contractRequest{method: "GET", route: "/users", path: "/users?page=1&limit=10", token: cityManagerToken}, http.StatusForbidden)
+
+	c.expect(contractRequest{method: "DELETE", route: "/users/{userId}", path: "/users/not-a-uuid", token: moderatorToken}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "DELETE", route: "/users/{userId}", path: managedPath}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "DELETE", route: "/users/{userId}", path: managedPath, token: employeeToken}, http.StatusForbidden)
+	c.expect(contractRequest{method: "DELETE", route: "/users/{userId}", path: managedPath, token: moderatorToken}, http.StatusNoContent)
+	c.expect(contractRequest{method: "DELETE", route: "/users/{userId}", path: managedPath, token: moderatorToken}, http.StatusNotFound)
+
+	// API keys
+	var apiKey models.CreatedAPIKey
+	body = c.expect(contractRequest{method: "POST", route: "/api-keys", path: "/api-keys", token: moderatorToken,
+		body: `{"name":"reports","permissions":["pvz:read"]}`}, http.StatusCreated)
+	require.NoError(t, json.Unmarshal(body, &apiKey))
+	keyHeaders := map[string]string{"X-API-Key": apiKey.Key}
+	c.expect(contractRequest{method: "POST", route: "/api-keys", path: "/api-keys", token: moderatorToken,
+		body: `{"name":"reports","permissions":["apikey:manage"]}`}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "POST", route: "/api-keys", path: "/api-keys", token: moderatorToken,
+		body: `{"name":"writer","permissions":["product:create"]}`}, http.StatusForbidden)
+	c.expect(contractRequest{method: "POST", route: "/api-keys", path: "/api-keys",
+		body: `{"name":"reports","permissions":["pvz:read"]}`}, http.StatusUnauthorized)
+	expectIdempotencyConflicts("/api-keys", "/api-keys", moderatorJWT, testModeratorID,
+		`{"name":"retried","permissions":["pvz:read"]}`, `{"name":"retried","permissions":["user:read"]}`, http.StatusCreated)
+
+	c.expect(contractRequest{method: "GET", route: "/pvz", path: listPath, headers: keyHeaders}, http.StatusOK)
+	c.expect(contractRequest{method: "POST", route: "/pvz", path: "/pvz", headers: keyHeaders, body: `{"city":"Казань"}`}, http.StatusForbidden)
+	c.expect(contractRequest{method: "GET", route: "/api-keys", path: "/api-keys?page=1&limit=10", headers: keyHeaders}, http.StatusForbidden)
+	c.expect(contractRequest{method: "POST", route: "/me/password", path: "/me/password", headers: keyHeaders,
+		body: `{"currentPassword":"contract123","newPassword":"contract456"}`}, http.StatusForbidden)
+
+	var apiKeys []models.APIKey
+	body = c.expect(contractRequest{method: "GET", route: "/api-keys", path: "/api-keys?page=1&limit=10", token: moderatorToken}, http.StatusOK)
+	require.NoError(t, json.Unmarshal(body, &apiKeys))
+	require.NotEmpty(t, apiKeys)
+	assert.NotNil(t, apiKeys[0].LastUsedAt)
+	c.expect(contractRequest{method: "GET", route: "/api-keys", path: "/api-keys?page=0&limit=10", token: moderatorToken}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "GET", route: "/api-keys", path: "/api-keys?page=1&limit=10"}, http.StatusUnauthorized)
+
+	apiKeyPath := "/api-keys/" + apiKey.ID
+	c.expect(contractRequest{method: "DELETE", route: "/api-keys/{keyId}", path: apiKeyPath, token: employeeToken}, http.StatusForbidden)
+	c.expect(contractRequest{method: "DELETE", route: "/api-keys/{keyId}", path: apiKeyPath}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "DELETE", route: "/api-keys/{keyId}", path: "/api-keys/not-a-uuid", token: moderatorToken}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "DELETE", route: "/api-keys/{keyId}", path: apiKeyPath, token: moderatorToken}, http.StatusNoContent)
+	c.expect(contractRequest{method: "DELETE", route: "/api-keys/{keyId}", path: apiKeyPath, token: moderatorToken}, http.StatusNotFound)
+	c.expect(contractRequest{method: "GET", route: "/pvz", path: listPath, headers: keyHeaders}, http.StatusUnauthorized)
+
+	// Login through the identity provider
+	oidcCallback := func(loginHint string) (string, string) {
+		c.expect(contractRequest{method: "GET", route: "/auth/oidc/login", path: "/auth/oidc/login"}, http.StatusFound)
+		cookie := strings.Split(c.header.Get("Set-Cookie"), ";")[0]
+		callback := followIdPRedirect(t, c.header.Get("Location")+"&login_hint="+loginHint)
+		return "/auth/oidc/callback?" + callback.RawQuery, cookie
+	}
+
+	callbackPath, cookie := oidcCallback("sso-moderator")
+	body = c.expect(contractRequest{method: "GET", route: "/auth/oidc/callback", path: callbackPath, headers: map[string]string{"Cookie": cookie}}, http.StatusOK)
+	require.NoError(t, json.Unmarshal(body, &token))
+	c.expect(contractRequest{method: "GET", route: "/users", path: "/users?page=1&limit=10", token: token.Token}, http.StatusOK)
+	c.expect(contractRequest{method: "GET", route: "/auth/oidc/callback", path: callbackPath, headers: map[string]string{"Cookie": cookie}}, http.StatusUnauthorized)
+
+	callbackPath, cookie = oidcCallback("outsider")
+	c.expect(contractRequest{method: "GET", route: "/auth/oidc/callback", path: callbackPath, headers: map[string]string{"Cookie": cookie}}, http.StatusForbidden)
+
+	// Password change and reset
+	body = c.expect(contractRequest{method: "POST", route: "/login", path: "/login",
+		body: `{"email":"contract@test.com","password":"contract123"}`}, http.StatusOK)
+	require.NoError(t, json.Unmarshal(body, &token))
+	userToken := token.Token
+
+	c.expect(contractRequest{method: "POST", route: "/me/password", path: "/me/password", token: userToken,
+		body: `{"currentPassword":"wrong-password1","newPassword":"contract456"}`}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "POST", route: "/me/password", path: "/me/password",
+		body: `{"currentPassword":"contract123","newPassword":"contract456"}`}, http.StatusUnauthorized)
+	c.expect(contractRequest{method: "POST", route: "/me/password", path: "/me/password", token: userToken,
+		body: `{"currentPassword":"contract123","newPassword":"contract456"}`}, http.StatusOK)
+
+	c.expect(contractRequest{method: "POST", route: "/password/reset-request", path: "/password/reset-request",
+		body: `{"email":"contract@test.com"}`}, http.StatusAccepted)
+	c.expect(contractRequest{method: "POST", route: "/password/reset-request", path: "/password/reset-request",
+		body: `{"email":"unknown@contract.com"}`}, http.StatusAccepted)
+	c.expect(contractRequest{method: "POST", route: "/password/reset-request", path: "/password/reset-request",
+		body: `{"email":"not-an-email"}`}, http.StatusBadRequest)
+
+	resetToken := lastResetToken(t, cfg.Notifier.File, "contract@test.com")
+	resetBody := fmt.Sprintf(`{"token":"%s","newPassword":"contract789"}`, resetToken)
+	c.expect(contractRequest{method: "POST", route: "/password/reset", path: "/password/reset", body: resetBody}, http.StatusNoContent)
+	c.expect(contractRequest{method: "POST", route: "/password/reset", path: "/password/reset", body: resetBody}, http.StatusBadRequest)
+	c.expect(contractRequest{method: "POST", route: "/login", path: "/login",
+		body: `{"email":"contract@test.com","password":"contract789"}`}, http.StatusOK)
+
+	// Brute-force protection: failed logins from one IP end up locked out
+	for attempt := 0; attempt < cfg.Auth.Login.IPMaxAttempts; attempt++ {
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(`{"email":"contract@test.com","password":"wrong-password1"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := c.app.Test(req, -1)
+		require.NoError(t, err)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			break
+		}
+	}
+	c.expect(contractRequest{method: "POST", route: "/login", path: "/login",
+		body: `{"email":"contract@test.com","password":"contract123"}`}, http.StatusTooManyRequests)
+
+	// Rate limits: with a budget of one request the second request to every limited
+	// endpoint is rejected before validation, so any path and body will do
+	limitedCfg := cfg
+	limitedCfg.HTTP.RateLimit.AuthRequests, limitedCfg.HTTP.RateLimit.AuthWindow = 1, time.Hour
+	limitedCfg.HTTP.RateLimit.WriteRequests, limitedCfg.HTTP.RateLimit.WriteWindow = 1, time.Hour
+	limited := &contractChecker{t: t, app: app.MakeApp(testDB, nil, nil, limitedCfg), spec: c.spec, covered: c.covered}
+	for key, operation := range documentedOperations(c.spec) {
+		response, ok := operation["responses"].(map[string]interface{})["429"].(map[string]interface{})
+		if !ok || response["$ref"] != "#/components/responses/RateLimited" {
+			continue
+		}
+		method, route, _ := strings.Cut(key, " ")
+		path := strings.NewReplacer("{pvzId}", uuid.NewString(), "{userId}", uuid.NewString(), "{keyId}", uuid.NewString()).Replace(route)
+		req := contractRequest{method: method, route: route, path: path, token: moderatorJWT, body: `{}`}
+
+		first := httptest.NewRequest(method, path, strings.NewReader(req.body))
+		first.Header.Set("Authorization", "Bearer "+moderatorJWT)
+		_, err := limited.app.Test(first, -1)
+		require.NoError(t, err)
+		limited.expect(req, http.StatusTooManyRequests)
+		assert.NotEmptyf(t, limited.header.Get("Retry-After"), "%s: нет заголовка Retry-After", key)
+	}
+
+	c.assertAllResponsesCovered()
+}
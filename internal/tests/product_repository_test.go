@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/repository"
+)
+
+// Issuing frees the room of the product in the same statement, so it is checked
+// against PostgreSQL as well
+func TestProductRepository_IssueProduct(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	ctx := context.Background()
+	postgresContainer, dsn := setupTestDB(ctx, t)
+	defer postgresContainer.Terminate(ctx)
+	testDB := connectToTestDB(t, dsn)
+	defer testDB.Close()
+	applyMigrations(t, testDB)
+
+	var pvzID, receptionID string
+	require.NoError(t, testDB.QueryRow(context.Background(), "INSERT INTO pvz (city) VALUES ('Казань') RETURNING id").Scan(&pvzID))
+	require.NoError(t, testDB.QueryRow(context.Background(),
+		"INSERT INTO receptions (pvz_id, status) VALUES ($1, 'in_progress') RETURNING id", pvzID).Scan(&receptionID))
+
+	repo := repository.NewProductRepository(testDB)
+	productID, _, err := repo.AddProduct(receptionID, "обувь", time.Now(), uuid.New, false)
+	require.NoError(t, err)
+
+	issuedAt := time.Now().Truncate(time.Second)
+	_, err = repo.IssueProduct(pvzID, productID, issuedAt)
+	assert.ErrorIs(t, err, apperrors.ErrReceptionNotClosed)
+
+	_, err = testDB.Exec(context.Background(), "UPDATE receptions SET status = 'close', closed_at = NOW() WHERE id = $1", receptionID)
+	require.NoError(t, err)
+	product, err := repo.IssueProduct(pvzID, productID, issuedAt)
+	require.NoError(t, err)
+	require.NotNil(t, product.IssuedAt)
+	assert.True(t, issuedAt.Equal(*product.IssuedAt))
+
+	var storedItems int
+	require.NoError(t, testDB.QueryRow(context.Background(), "SELECT stored_items FROM pvz WHERE id = $1", pvzID).Scan(&storedItems))
+	assert.Equal(t, 0, storedItems)
+
+	_, err = repo.IssueProduct(pvzID, productID, issuedAt)
+	assert.ErrorIs(t, err, apperrors.ErrProductAlreadyIssued)
+	_, err = repo.IssueProduct(pvzID, uuid.NewString(), issuedAt)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
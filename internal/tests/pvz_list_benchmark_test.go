@@ -0,0 +1,193 @@
+//go:build integration
+
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	"pvzService/internal/models"
+	"pvzService/internal/repository"
+	"pvzService/internal/utils"
+)
+
+// Seeded volume: 300 PVZ with 5 closed receptions of 200 products each, 300 000
+// products in total
+const (
+	benchmarkPVZs                 = 300
+	benchmarkReceptionsPerPVZ     = 5
+	benchmarkProductsPerReception = 200
+	benchmarkPageSize             = 30
+)
+
+// joinedListQuery is the single pvz × receptions × products join the listing used
+// before it was split into phases; LIMIT counts joined rows, not PVZ.
+const joinedListQuery = `
+        SELECT
+            p.id, p.registration_date, p.city, c.timezone,
+            r.id, r.created_at, r.pvz_id, r.status, r.closed_at,
+            pr.id, pr.created_at, pr.type, pr.reception_id
+        FROM pvz p
+        JOIN cities c ON c.name = p.city
+        LEFT JOIN receptions r ON p.id = r.pvz_id
+        LEFT JOIN products pr ON r.id = pr.reception_id
+        ORDER BY p.registration_date ASC LIMIT $1 OFFSET $2`
+
+// joinedPageQuery is the same join restricted to one page of PVZ, so that it returns
+// the same tree as the two-phase listing.
+const joinedPageQuery = `
+        SELECT
+            p.id, p.registration_date, p.city, c.timezone,
+            r.id, r.created_at, r.pvz_id, r.status, r.closed_at,
+            pr.id, pr.created_at, pr.type, pr.reception_id
+        FROM (SELECT * FROM pvz ORDER BY registration_date ASC, id LIMIT $1 OFFSET $2) p
+        JOIN cities c ON c.name = p.city
+        LEFT JOIN receptions r ON p.id = r.pvz_id
+        LEFT JOIN products pr ON r.id = pr.reception_id
+        ORDER BY p.registration_date ASC, p.id`
+
+// BenchmarkListPVZsWithRelations compares the two-phase listing with the cartesian
+// join it replaced on a seeded PostgreSQL:
+//
+//	go test -tags integration ./internal/tests -run '^$' -bench ListPVZs
+func BenchmarkListPVZsWithRelations(b *testing.B) {
+	ctx := context.Background()
+	postgresContainer, dsn := setupTestDB(ctx, b)
+	defer postgresContainer.Terminate(ctx)
+	testDB := connectToTestDB(b, dsn)
+	defer testDB.Close()
+	applyMigrations(b, testDB)
+	seedPVZTree(b, testDB)
+
+	repo := repository.NewPVZRepository(testDB, repository.NewReadRouter(testDB, nil, 0))
+	offset := benchmarkPVZs / 2
+
+	b.Run("two-phase", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			result, err := repo.ListPVZsWithRelations(time.Time{}, time.Time{}, "", false, benchmarkPageSize, offset)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(result) != benchmarkPageSize {
+				b.Fatalf("got %d PVZ, want %d", len(result), benchmarkPageSize)
+			}
+		}
+	})
+	b.Run("joined-as-before", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := listPVZsJoined(testDB, joinedListQuery, benchmarkPageSize, offset); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("joined-same-page", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			result, err := listPVZsJoined(testDB, joinedPageQuery, benchmarkPageSize, offset)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(result) != benchmarkPageSize {
+				b.Fatalf("got %d PVZ, want %d", len(result), benchmarkPageSize)
+			}
+		}
+	})
+}
+
+func seedPVZTree(b *testing.B, db *pgxpool.Pool) {
+	_, err := db.Exec(context.Background(), `
+        INSERT INTO pvz (city, registration_date)
+        SELECT (ARRAY['Москва', 'Санкт-Петербург', 'Казань'])[1 + i % 3], NOW() - i * INTERVAL '1 hour'
+        FROM generate_series(1, $1) i`, benchmarkPVZs)
+	require.NoError(b, err)
+	_, err = db.Exec(context.Background(), `
+        INSERT INTO receptions (pvz_id, status, created_at, closed_at)
+        SELECT p.id, 'close', p.registration_date + j * INTERVAL '1 day', p.registration_date + j * INTERVAL '1 day' + INTERVAL '1 hour'
+        FROM pvz p, generate_series(1, $1) j`, benchmarkReceptionsPerPVZ)
+	require.NoError(b, err)
+	_, err = db.Exec(context.Background(), `
+        INSERT INTO products (reception_id, type, created_at)
+        SELECT r.id, (ARRAY['электроника', 'одежда', 'обувь'])[1 + k % 3], r.created_at + k * INTERVAL '1 second'
+        FROM receptions r, generate_series(1, $1) k`, benchmarkProductsPerReception)
+	require.NoError(b, err)
+	_, err = db.Exec(context.Background(), "ANALYZE pvz, receptions, products")
+	require.NoError(b, err)
+}
+
+// listPVZsJoined scans a joined listing query into the PVZ tree the way the listing
+// did before the split.
+func listPVZsJoined(db *pgxpool.Pool, query string, limit, offset int) ([]repository.PVZResponse, error) {
+	rows, err := db.Query(context.Background(), query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		order      []string
+		pvzs       = make(map[string]*repository.PVZResponse)
+		receptions = make(map[string]*repository.ReceptionResponse)
+	)
+	for rows.Next() {
+		var (
+			pvz                     models.PVZ
+			receptionID, productID  sql.NullString
+			receptionCreatedAt      sql.NullTime
+			receptionPVZID          sql.NullString
+			receptionStatus         sql.NullString
+			receptionClosedAt       sql.NullTime
+			productCreatedAt        sql.NullTime
+			productType, productRID sql.NullString
+		)
+		if err := rows.Scan(&pvz.ID, &pvz.RegistrationDate, &pvz.City, &pvz.Timezone,
+			&receptionID, &receptionCreatedAt, &receptionPVZID, &receptionStatus, &receptionClosedAt,
+			&productID, &productCreatedAt, &productType, &productRID); err != nil {
+			return nil, err
+		}
+
+		if _, ok := pvzs[pvz.ID]; !ok {
+			pvzs[pvz.ID] = &repository.PVZResponse{PVZ: pvz, Receptions: []repository.ReceptionResponse{}}
+			order = append(order, pvz.ID)
+		}
+		if receptionID.Valid {
+			if _, ok := receptions[receptionID.String]; !ok {
+				receptions[receptionID.String] = &repository.ReceptionResponse{Reception: models.Reception{
+					ID:       receptionID.String,
+					DateTime: receptionCreatedAt.Time,
+					PvzId:    receptionPVZID.String,
+					Status:   receptionStatus.String,
+					ClosedAt: utils.NullableTime(receptionClosedAt),
+				}}
+			}
+		}
+		if productID.Valid {
+			reception := receptions[productRID.String]
+			reception.Products = append(reception.Products, models.Product{
+				ID:          productID.String,
+				DateTime:    productCreatedAt.Time,
+				Type:        productType.String,
+				ReceptionId: productRID.String,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, reception := range receptions {
+		pvz := pvzs[reception.Reception.PvzId]
+		pvz.Receptions = append(pvz.Receptions, *reception)
+	}
+	result := make([]repository.PVZResponse, 0, len(order))
+	for _, id := range order {
+		result = append(result, *pvzs[id])
+	}
+	return result, nil
+}
@@ -0,0 +1,89 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// PasswordPolicy describes the requirements checked by the `password` rule.
+type PasswordPolicy struct {
+	MinLength     int
+	RequireLetter bool
+	RequireDigit  bool
+	RequireUpper  bool
+	RequireSymbol bool
+}
+
+// DefaultPasswordPolicy is used until SetPasswordPolicy is called.
+var DefaultPasswordPolicy = PasswordPolicy{MinLength: 8, RequireLetter: true, RequireDigit: true}
+
+var passwordPolicy atomic.Pointer[PasswordPolicy]
+
+func init() {
+	SetPasswordPolicy(DefaultPasswordPolicy)
+}
+
+// SetPasswordPolicy replaces the policy checked by the `password` rule. It is called
+// once on startup with the configured policy.
+func SetPasswordPolicy(policy PasswordPolicy) {
+	passwordPolicy.Store(&policy)
+}
+
+// CurrentPasswordPolicy returns the policy checked by the `password` rule.
+func CurrentPasswordPolicy() PasswordPolicy {
+	return *passwordPolicy.Load()
+}
+
+// Check reports whether the password satisfies the policy.
+func (p PasswordPolicy) Check(password string) bool {
+	if len([]rune(password)) < p.MinLength {
+		return false
+	}
+
+	var hasLetter, hasDigit, hasUpper, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+			hasUpper = hasUpper || unicode.IsUpper(r)
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+	return (hasLetter || !p.RequireLetter) &&
+		(hasDigit || !p.RequireDigit) &&
+		(hasUpper || !p.RequireUpper) &&
+		(hasSymbol || !p.RequireSymbol)
+}
+
+// Description explains the policy, e.g. "must be at least 8 characters long and contain letters and digits".
+func (p PasswordPolicy) Description() string {
+	description := fmt.Sprintf("must be at least %d characters long", p.MinLength)
+
+	var parts []string
+	if p.RequireLetter {
+		parts = append(parts, "letters")
+	}
+	if p.RequireUpper {
+		parts = append(parts, "uppercase letters")
+	}
+	if p.RequireDigit {
+		parts = append(parts, "digits")
+	}
+	if p.RequireSymbol {
+		parts = append(parts, "symbols")
+	}
+
+	switch len(parts) {
+	case 0:
+		return description
+	case 1:
+		return description + " and contain " + parts[0]
+	default:
+		return description + " and contain " + strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+	}
+}
@@ -0,0 +1,187 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
+)
+
+// Struct validates exported fields of a request DTO according to their `validate` tags.
+// Field names in errors are taken from the `json` or `query` tag. Supported rules:
+//
+//	required      value is not empty, slices have at least one item
+//	uuid          string is a UUID
+//	email         string is a plain email address
+//	password      satisfies the configured PasswordPolicy
+//	rfc3339       string is an RFC3339 timestamp
+//	timeofday     string is a time of day as HH:MM, 24:00 included
+//	oneof=a b c   value is one of the space separated options
+//	min=N, max=N  numeric bounds for numbers, length bounds for strings and slices
+//
+// Rules other than required are skipped for empty values and nil pointers. Items of
+// slices of structs are validated too and named like workingHours[0].opens.
+func Struct(v interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return apperrors.Internal("validation of non-struct value", fmt.Errorf("unexpected kind %s", value.Kind()))
+	}
+
+	if details := validateStruct("", value); len(details) > 0 {
+		return apperrors.Validation(details)
+	}
+	return nil
+}
+
+func validateStruct(prefix string, value reflect.Value) []models.FieldError {
+	var details []models.FieldError
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := prefix + fieldName(field)
+		if tag := field.Tag.Get("validate"); tag != "" {
+			if fieldErr, ok := validateField(name, value.Field(i), tag); !ok {
+				details = append(details, fieldErr)
+				continue
+			}
+		}
+
+		items := reflect.Indirect(value.Field(i))
+		if items.Kind() == reflect.Slice && items.Type().Elem().Kind() == reflect.Struct {
+			for j := 0; j < items.Len(); j++ {
+				details = append(details, validateStruct(fmt.Sprintf("%s[%d].", name, j), items.Index(j))...)
+			}
+		}
+	}
+	return details
+}
+
+// Var validates a single named value such as a path parameter.
+func Var(name string, value interface{}, tag string) error {
+	if fieldErr, ok := validateField(name, reflect.ValueOf(value), tag); !ok {
+		return apperrors.Validation([]models.FieldError{fieldErr})
+	}
+	return nil
+}
+
+func validateField(name string, value reflect.Value, tag string) (models.FieldError, bool) {
+	// Optional fields are pointers, a nil pointer is treated as an empty value. A set
+	// pointer to a number is present even when the number is zero
+	set := false
+	if value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+		set = value.Kind() != reflect.String && value.Kind() != reflect.Slice
+	}
+
+	empty := value.IsZero()
+	for _, rule := range strings.Split(tag, ",") {
+		ruleName, param, _ := strings.Cut(rule, "=")
+		if (ruleName != "required" && empty) || (ruleName == "required" && set) {
+			continue
+		}
+
+		if message, ok := checkRule(ruleName, param, value); !ok {
+			return models.FieldError{Field: name, Rule: ruleName, Message: name + " " + message}, false
+		}
+	}
+	return models.FieldError{}, true
+}
+
+func checkRule(rule, param string, value reflect.Value) (string, bool) {
+	switch rule {
+	case "required":
+		switch value.Kind() {
+		case reflect.String:
+			return "is required", strings.TrimSpace(value.String()) != ""
+		case reflect.Slice:
+			return "is required", value.Len() > 0
+		}
+		return "is required", !value.IsZero()
+	case "uuid":
+		_, err := uuid.Parse(value.String())
+		return "must be a valid UUID", err == nil
+	case "email":
+		address, err := mail.ParseAddress(value.String())
+		return "must be a valid email address", err == nil && address.Address == value.String()
+	case "password":
+		policy := CurrentPasswordPolicy()
+		return policy.Description(), policy.Check(value.String())
+	case "rfc3339":
+		_, err := time.Parse(time.RFC3339, value.String())
+		return "must be an RFC3339 timestamp", err == nil
+	case "timeofday":
+		return "must be a time of day as HH:MM", isTimeOfDay(value.String())
+	case "oneof":
+		options := strings.Fields(param)
+		actual := fmt.Sprint(value.Interface())
+		for _, option := range options {
+			if actual == option {
+				return "", true
+			}
+		}
+		return "must be one of: " + strings.Join(options, ", "), false
+	case "min", "max":
+		return checkBound(rule, param, value)
+	default:
+		panic("validation: unknown rule " + rule)
+	}
+}
+
+func checkBound(rule, param string, value reflect.Value) (string, bool) {
+	bound, err := strconv.Atoi(param)
+	if err != nil {
+		panic("validation: invalid bound " + param)
+	}
+
+	var actual float64
+	unit := ""
+	switch value.Kind() {
+	case reflect.String:
+		actual = float64(len([]rune(value.String())))
+		unit = " characters"
+	case reflect.Slice:
+		actual = float64(value.Len())
+		unit = " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(value.Int())
+	case reflect.Float32, reflect.Float64:
+		actual = value.Float()
+	default:
+		panic("validation: bounds are not supported for " + value.Kind().String())
+	}
+
+	if rule == "min" {
+		return fmt.Sprintf("must be at least %d%s", bound, unit), actual >= float64(bound)
+	}
+	return fmt.Sprintf("must be at most %d%s", bound, unit), actual <= float64(bound)
+}
+
+// isTimeOfDay reports whether s is a time of day as HH:MM, 24:00 is the end of a day.
+// Times of day in this form compare as strings.
+func isTimeOfDay(s string) bool {
+	if s == "24:00" {
+		return true
+	}
+	_, err := time.Parse("15:04", s)
+	return err == nil && len(s) == len("15:04")
+}
+
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "query"} {
+		if name, _, _ := strings.Cut(field.Tag.Get(key), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
@@ -0,0 +1,194 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pvzService/internal/apperrors"
+	"pvzService/internal/models"
+)
+
+type testRequest struct {
+	ID       string   `json:"id" validate:"required,uuid"`
+	Email    string   `json:"email" validate:"email"`
+	Password string   `json:"password" validate:"password"`
+	Kind     string   `json:"kind" validate:"oneof=a b"`
+	Date     string   `query:"date" validate:"rfc3339"`
+	Limit    int      `query:"limit" validate:"min=1,max=30"`
+	Name     string   `json:"name" validate:"max=5"`
+	Tags     []string `json:"tags" validate:"max=2"`
+	Items    []string `json:"items" validate:"required"`
+	Ignored  string   `json:"ignored"`
+	Lat      *float64 `json:"lat" validate:"min=-90,max=90"`
+	Opens    string   `json:"opens" validate:"timeofday"`
+}
+
+func validRequest() testRequest {
+	return testRequest{
+		ID:       "8a6e0804-2bd0-4672-b79d-d97027f9071a",
+		Email:    "user@example.com",
+		Password: "secret123",
+		Kind:     "a",
+		Date:     "2025-04-01T10:00:00Z",
+		Limit:    10,
+		Name:     "Пункт",
+		Items:    []string{"a"},
+	}
+}
+
+func fieldErrors(t *testing.T, err error) []models.FieldError {
+	var appErr *apperrors.Error
+	if !assert.ErrorAs(t, err, &appErr) {
+		return nil
+	}
+	assert.Equal(t, apperrors.CodeValidationFailed, appErr.Code)
+	return appErr.Details
+}
+
+func TestStruct_Valid(t *testing.T) {
+	req := validRequest()
+	assert.NoError(t, Struct(&req))
+}
+
+func TestStruct_OptionalFieldsMayBeEmpty(t *testing.T) {
+	req := testRequest{ID: "8a6e0804-2bd0-4672-b79d-d97027f9071a", Items: []string{"a"}}
+	assert.NoError(t, Struct(req))
+}
+
+func TestStruct_Rules(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(r *testRequest)
+		field   string
+		rule    string
+		message string
+	}{
+		{"required", func(r *testRequest) { r.ID = "  " }, "id", "required", "id is required"},
+		{"uuid", func(r *testRequest) { r.ID = "not-a-uuid" }, "id", "uuid", "id must be a valid UUID"},
+		{"email", func(r *testRequest) { r.Email = "User <user@example.com>" }, "email", "email", "email must be a valid email address"},
+		{"password too short", func(r *testRequest) { r.Password = "abc123" }, "password", "password", "password must be at least 8 characters long and contain letters and digits"},
+		{"password without digits", func(r *testRequest) { r.Password = "abcdefgh" }, "password", "password", "password must be at least 8 characters long and contain letters and digits"},
+		{"oneof", func(r *testRequest) { r.Kind = "c" }, "kind", "oneof", "kind must be one of: a, b"},
+		{"rfc3339", func(r *testRequest) { r.Date = "2025-04-01" }, "date", "rfc3339", "date must be an RFC3339 timestamp"},
+		{"min", func(r *testRequest) { r.Limit = -1 }, "limit", "min", "limit must be at least 1"},
+		{"max", func(r *testRequest) { r.Limit = 31 }, "limit", "max", "limit must be at most 30"},
+		{"string max counts runes", func(r *testRequest) { r.Name = "Пункты" }, "name", "max", "name must be at most 5 characters"},
+		{"required slice is not empty", func(r *testRequest) { r.Items = []string{} }, "items", "required", "items is required"},
+		{"slice max counts items", func(r *testRequest) { r.Tags = []string{"a", "b", "c"} }, "tags", "max", "tags must be at most 2 items"},
+		{"float min", func(r *testRequest) { lat := -90.5; r.Lat = &lat }, "lat", "min", "lat must be at least -90"},
+		{"float max", func(r *testRequest) { lat := 90.01; r.Lat = &lat }, "lat", "max", "lat must be at most 90"},
+		{"timeofday", func(r *testRequest) { r.Opens = "9:00" }, "opens", "timeofday", "opens must be a time of day as HH:MM"},
+		{"timeofday after midnight", func(r *testRequest) { r.Opens = "24:01" }, "opens", "timeofday", "opens must be a time of day as HH:MM"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := validRequest()
+			tt.modify(&req)
+
+			details := fieldErrors(t, Struct(req))
+			assert.Equal(t, []models.FieldError{{Field: tt.field, Rule: tt.rule, Message: tt.message}}, details)
+		})
+	}
+}
+
+func TestStruct_ValidBoundsAndTimes(t *testing.T) {
+	for _, opens := range []string{"00:00", "09:30", "23:59", "24:00"} {
+		lat := -90.0
+		req := validRequest()
+		req.Opens, req.Lat = opens, &lat
+		assert.NoError(t, Struct(req), opens)
+	}
+}
+
+func TestStruct_RequiredPointers(t *testing.T) {
+	type pointRequest struct {
+		Lat  *float64 `query:"lat" validate:"required,min=-90,max=90"`
+		Name *string  `query:"name" validate:"required"`
+	}
+	zero, empty, name := 0.0, " ", "ПВЗ"
+
+	assert.NoError(t, Struct(pointRequest{Lat: &zero, Name: &name}))
+
+	details := fieldErrors(t, Struct(pointRequest{Name: &empty}))
+	assert.Equal(t, []models.FieldError{
+		{Field: "lat", Rule: "required", Message: "lat is required"},
+		{Field: "name", Rule: "required", Message: "name is required"},
+	}, details)
+}
+
+func TestStruct_SliceItems(t *testing.T) {
+	type item struct {
+		Day string `json:"day" validate:"required,oneof=monday tuesday"`
+	}
+	type listRequest struct {
+		Items *[]item `json:"items" validate:"max=2"`
+	}
+
+	assert.NoError(t, Struct(listRequest{}))
+	assert.NoError(t, Struct(listRequest{Items: &[]item{{Day: "monday"}}}))
+
+	details := fieldErrors(t, Struct(listRequest{Items: &[]item{{Day: "monday"}, {Day: "friday"}}}))
+	assert.Equal(t, []models.FieldError{{Field: "items[1].day", Rule: "oneof", Message: "items[1].day must be one of: monday, tuesday"}}, details)
+
+	details = fieldErrors(t, Struct(listRequest{Items: &[]item{{}, {}, {}}}))
+	assert.Equal(t, []models.FieldError{{Field: "items", Rule: "max", Message: "items must be at most 2 items"}}, details)
+}
+
+func TestStruct_ReportsAllFields(t *testing.T) {
+	req := validRequest()
+	req.ID = ""
+	req.Kind = "c"
+
+	details := fieldErrors(t, Struct(req))
+	assert.Len(t, details, 2)
+	assert.Equal(t, "id", details[0].Field)
+	assert.Equal(t, "kind", details[1].Field)
+}
+
+func TestVar(t *testing.T) {
+	assert.NoError(t, Var("pvzId", "8a6e0804-2bd0-4672-b79d-d97027f9071a", "required,uuid"))
+
+	details := fieldErrors(t, Var("pvzId", "invalid", "required,uuid"))
+	assert.Equal(t, []models.FieldError{{Field: "pvzId", Rule: "uuid", Message: "pvzId must be a valid UUID"}}, details)
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 10, RequireLetter: true, RequireUpper: true, RequireDigit: true, RequireSymbol: true}
+
+	assert.True(t, policy.Check("Secret-1234"))
+	assert.False(t, policy.Check("Sec-1"))
+	assert.False(t, policy.Check("secret-1234"))
+	assert.False(t, policy.Check("Secret12345"))
+	assert.False(t, policy.Check("Secret-abcd"))
+	assert.Equal(t, "must be at least 10 characters long and contain letters, uppercase letters, digits and symbols", policy.Description())
+	assert.Equal(t, "must be at least 4 characters long", PasswordPolicy{MinLength: 4}.Description())
+}
+
+func TestSetPasswordPolicy(t *testing.T) {
+	defer SetPasswordPolicy(DefaultPasswordPolicy)
+	SetPasswordPolicy(PasswordPolicy{MinLength: 12, RequireDigit: true})
+
+	req := validRequest()
+	details := fieldErrors(t, Struct(req))
+	assert.Equal(t, []models.FieldError{{Field: "password", Rule: "password", Message: "password must be at least 12 characters long and contain digits"}}, details)
+
+	req.Password = "123456789012"
+	assert.NoError(t, Struct(req))
+}
+
+func TestStruct_PointerFields(t *testing.T) {
+	type patchRequest struct {
+		Role *string `json:"role" validate:"oneof=employee moderator"`
+	}
+
+	assert.NoError(t, Struct(patchRequest{}))
+
+	role := "moderator"
+	assert.NoError(t, Struct(patchRequest{Role: &role}))
+
+	role = "admin"
+	details := fieldErrors(t, Struct(patchRequest{Role: &role}))
+	assert.Equal(t, []models.FieldError{{Field: "role", Rule: "oneof", Message: "role must be one of: employee, moderator"}}, details)
+}
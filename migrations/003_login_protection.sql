@@ -0,0 +1,11 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS failed_login_attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_failed_login_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS login_ip_failures (
+    ip TEXT PRIMARY KEY,
+    failed_attempts INT NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMP
+    );
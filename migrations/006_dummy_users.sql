@@ -0,0 +1,3 @@
+-- Dummy users are created per role without a usable password. The shared dummy user
+-- created earlier had the password "password" and could be used on /login.
+UPDATE users SET password = '!' WHERE email = 'dummy@example.com';
@@ -0,0 +1,39 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users
+    ADD CONSTRAINT users_role_check CHECK (role IN ('employee', 'moderator', 'city_manager', 'auditor', 'admin'));
+
+-- City managers work with the PVZ of a single city, other roles are not bound to a city
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS city TEXT CHECK (city IN ('Москва', 'Санкт-Петербург', 'Казань'));
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_city_role_check;
+ALTER TABLE users
+    ADD CONSTRAINT users_city_role_check CHECK ((role = 'city_manager') = (city IS NOT NULL));
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role TEXT NOT NULL,
+    permission TEXT NOT NULL,
+    PRIMARY KEY (role, permission)
+    );
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('employee', 'pvz:read'),
+    ('employee', 'reception:create'),
+    ('employee', 'reception:close'),
+    ('employee', 'product:create'),
+    ('employee', 'product:delete'),
+    ('moderator', 'pvz:create'),
+    ('moderator', 'pvz:read'),
+    ('moderator', 'user:read'),
+    ('moderator', 'user:manage'),
+    ('city_manager', 'pvz:read'),
+    ('auditor', 'pvz:read'),
+    ('auditor', 'user:read'),
+    ('admin', 'pvz:create'),
+    ('admin', 'pvz:read'),
+    ('admin', 'reception:create'),
+    ('admin', 'reception:close'),
+    ('admin', 'product:create'),
+    ('admin', 'product:delete'),
+    ('admin', 'user:read'),
+    ('admin', 'user:manage')
+ON CONFLICT DO NOTHING;
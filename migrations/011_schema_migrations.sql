@@ -0,0 +1,10 @@
+-- Applied migrations, /readyz compares the latest version with the one the service
+-- requires. Every following migration records its own version.
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT PRIMARY KEY,
+    applied_at TIMESTAMP DEFAULT NOW()
+    );
+
+INSERT INTO schema_migrations (version)
+SELECT generate_series(1, 11)
+ON CONFLICT DO NOTHING;
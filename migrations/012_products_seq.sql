@@ -0,0 +1,7 @@
+-- Insertion order of products. Products added with one COPY share created_at, so the
+-- last product of a reception is found by seq.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS seq BIGSERIAL;
+
+CREATE INDEX IF NOT EXISTS idx_products_reception_seq ON products (reception_id, seq);
+
+INSERT INTO schema_migrations (version) VALUES (12) ON CONFLICT DO NOTHING;
@@ -0,0 +1,63 @@
+-- Timestamps are stored as points in time. Existing values were written in UTC, the
+-- time zone of the database server.
+ALTER TABLE users
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN last_failed_login_at TYPE TIMESTAMPTZ USING last_failed_login_at AT TIME ZONE 'UTC',
+    ALTER COLUMN locked_until TYPE TIMESTAMPTZ USING locked_until AT TIME ZONE 'UTC',
+    ALTER COLUMN password_changed_at TYPE TIMESTAMPTZ USING password_changed_at AT TIME ZONE 'UTC';
+
+ALTER TABLE pvz
+    ALTER COLUMN registration_date TYPE TIMESTAMPTZ USING registration_date AT TIME ZONE 'UTC';
+
+ALTER TABLE receptions
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN closed_at TYPE TIMESTAMPTZ USING closed_at AT TIME ZONE 'UTC';
+
+ALTER TABLE products
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
+
+ALTER TABLE idempotency_keys
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ USING expires_at AT TIME ZONE 'UTC';
+
+ALTER TABLE login_ip_failures
+    ALTER COLUMN last_failed_at TYPE TIMESTAMPTZ USING last_failed_at AT TIME ZONE 'UTC',
+    ALTER COLUMN locked_until TYPE TIMESTAMPTZ USING locked_until AT TIME ZONE 'UTC';
+
+ALTER TABLE password_reset_tokens
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ USING expires_at AT TIME ZONE 'UTC',
+    ALTER COLUMN used_at TYPE TIMESTAMPTZ USING used_at AT TIME ZONE 'UTC';
+
+ALTER TABLE user_identities
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
+
+ALTER TABLE api_keys
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ USING expires_at AT TIME ZONE 'UTC',
+    ALTER COLUMN last_used_at TYPE TIMESTAMPTZ USING last_used_at AT TIME ZONE 'UTC',
+    ALTER COLUMN revoked_at TYPE TIMESTAMPTZ USING revoked_at AT TIME ZONE 'UTC';
+
+ALTER TABLE rate_limits
+    ALTER COLUMN reset_at TYPE TIMESTAMPTZ USING reset_at AT TIME ZONE 'UTC';
+
+ALTER TABLE schema_migrations
+    ALTER COLUMN applied_at TYPE TIMESTAMPTZ USING applied_at AT TIME ZONE 'UTC';
+
+-- Local time zone of the PVZ of each city, days of a PVZ are counted in it
+CREATE TABLE IF NOT EXISTS cities (
+    name TEXT PRIMARY KEY,
+    timezone TEXT NOT NULL
+    );
+
+INSERT INTO cities (name, timezone) VALUES
+    ('Москва', 'Europe/Moscow'),
+    ('Санкт-Петербург', 'Europe/Moscow'),
+    ('Казань', 'Europe/Moscow')
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE pvz DROP CONSTRAINT IF EXISTS pvz_city_fkey;
+ALTER TABLE pvz
+    ADD CONSTRAINT pvz_city_fkey FOREIGN KEY (city) REFERENCES cities (name);
+
+INSERT INTO schema_migrations (version) VALUES (13) ON CONFLICT DO NOTHING;
@@ -0,0 +1,21 @@
+-- Decommissioned PVZ are archived instead of deleted, their receptions and products
+-- are kept
+ALTER TABLE pvz
+    ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS archived_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+-- A PVZ with receptions and a reception with products can't be deleted
+ALTER TABLE receptions DROP CONSTRAINT IF EXISTS receptions_pvz_id_fkey;
+ALTER TABLE receptions
+    ADD CONSTRAINT receptions_pvz_id_fkey FOREIGN KEY (pvz_id) REFERENCES pvz (id) ON DELETE RESTRICT;
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_reception_id_fkey;
+ALTER TABLE products
+    ADD CONSTRAINT products_reception_id_fkey FOREIGN KEY (reception_id) REFERENCES receptions (id) ON DELETE RESTRICT;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('moderator', 'pvz:archive'),
+    ('admin', 'pvz:archive')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO schema_migrations (version) VALUES (14) ON CONFLICT DO NOTHING;
@@ -0,0 +1,20 @@
+-- Profile of a PVZ for couriers and customers. Working hours are a JSON array of
+-- {"weekday", "opens", "closes"} in the local time of the PVZ, a PVZ is closed on
+-- weekdays that are not listed
+ALTER TABLE pvz
+    ADD COLUMN IF NOT EXISTS name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS address TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION CHECK (latitude BETWEEN -90 AND 90),
+    ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION CHECK (longitude BETWEEN -180 AND 180),
+    ADD COLUMN IF NOT EXISTS working_hours JSONB NOT NULL DEFAULT '[]';
+
+ALTER TABLE pvz DROP CONSTRAINT IF EXISTS pvz_coordinates_check;
+ALTER TABLE pvz
+    ADD CONSTRAINT pvz_coordinates_check CHECK ((latitude IS NULL) = (longitude IS NULL));
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('moderator', 'pvz:update'),
+    ('admin', 'pvz:update')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO schema_migrations (version) VALUES (15) ON CONFLICT DO NOTHING;
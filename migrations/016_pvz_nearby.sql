@@ -0,0 +1,5 @@
+-- Nearby search only looks at PVZ in operation with coordinates, the latitude band
+-- around the point is narrowed with this index before distances are computed
+CREATE INDEX IF NOT EXISTS pvz_latitude_idx ON pvz (latitude) WHERE latitude IS NOT NULL AND NOT archived;
+
+INSERT INTO schema_migrations (version) VALUES (16) ON CONFLICT DO NOTHING;
@@ -0,0 +1,13 @@
+-- Shelf capacity of a PVZ in products, NULL for a PVZ without a limit. stored_items
+-- counts the products stored in the PVZ, that is not issued yet, and changes in the
+-- same transaction as the products are added, deleted or issued. No product can be
+-- issued before 019, so every product of the PVZ is counted here
+ALTER TABLE pvz
+    ADD COLUMN IF NOT EXISTS capacity INTEGER CHECK (capacity > 0),
+    ADD COLUMN IF NOT EXISTS stored_items INTEGER NOT NULL DEFAULT 0 CHECK (stored_items >= 0);
+
+UPDATE pvz p SET stored_items = (
+    SELECT count(*) FROM products pr JOIN receptions r ON r.id = pr.reception_id WHERE r.pvz_id = p.id
+);
+
+INSERT INTO schema_migrations (version) VALUES (17) ON CONFLICT DO NOTHING;
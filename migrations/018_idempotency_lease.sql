@@ -0,0 +1,6 @@
+-- A request holds its idempotency key until locked_until, a retry takes over the key
+-- of a request that didn't finish by then, e.g. when the instance crashed
+ALTER TABLE idempotency_keys
+    ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+
+INSERT INTO schema_migrations (version) VALUES (18) ON CONFLICT DO NOTHING;
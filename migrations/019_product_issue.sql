@@ -0,0 +1,16 @@
+-- A product leaves the PVZ when it is issued to the recipient, the row is kept as
+-- history. stored_items counts the products that are not issued yet
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS issued_at TIMESTAMPTZ;
+
+UPDATE pvz p SET stored_items = (
+    SELECT count(*) FROM products pr JOIN receptions r ON r.id = pr.reception_id
+    WHERE r.pvz_id = p.id AND pr.issued_at IS NULL
+);
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('employee', 'product:issue'),
+    ('admin', 'product:issue')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO schema_migrations (version) VALUES (19) ON CONFLICT DO NOTHING;